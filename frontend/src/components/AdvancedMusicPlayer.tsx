@@ -241,11 +241,15 @@ export function AdvancedMusicPlayer({
   const audioRef = useRef<HTMLAudioElement>(null);
   const progressRef = useRef<HTMLDivElement>(null);
 
-  const audioUrl = songApi.getStreamUrl(song.id);
+  const [audioUrl, setAudioUrl] = useState<string>();
+
+  useEffect(() => {
+    songApi.getStreamUrl(song.id).then(setAudioUrl).catch(console.error);
+  }, [song.id]);
 
   useEffect(() => {
     const setupAudioStream = async () => {
-      if (audioRef.current) {
+      if (audioRef.current && audioUrl) {
         audioRef.current.src = audioUrl;
         if (audioRef.current.paused) {
           audioRef.current.play().catch(console.error);
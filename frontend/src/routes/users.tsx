@@ -335,10 +335,10 @@ function UsersPage() {
                                 </span>
                               </div>
                               <button
-                                onClick={() => {
+                                onClick={async () => {
                                   if (songApi.isValidSong(song)) {
                                     const audio = new Audio(
-                                      songApi.getStreamUrl(song.id),
+                                      await songApi.getStreamUrl(song.id),
                                     );
                                     audio.play().catch(console.error);
                                   } else {
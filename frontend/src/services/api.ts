@@ -148,10 +148,12 @@ export const songApi = {
   getSongUrl: (filePath: string): string => {
     return `${API_BASE_URL}/songs/stream/${encodeURIComponent(filePath)}`;
   },
-  getStreamUrl: (songId: string | number): string => {
-    const token = localStorage.getItem("token");
-    const baseUrl = `${API_BASE_URL}/songs/${songId}/stream`;
-    return token ? `${baseUrl}?token=${encodeURIComponent(token)}` : baseUrl;
+  getStreamUrl: async (songId: string | number): Promise<string> => {
+    // Audio elements can't send headers, so they stream with a short-lived signed link
+    const response = await api.post<{ url: string }>(
+      `/songs/${songId}/stream-token`,
+    );
+    return response.data.url;
   },
   getArtistName: (song: Song): string => {
     return song.artist?.full_name || "Unknown Artist";
@@ -1,11 +1,36 @@
 package api
 
 import (
+	"net/http"
+	"time"
+
 	"github.com/gin-gonic/gin"
 	"github.com/yosp313/gotify/src/internal/config"
+	"github.com/yosp313/gotify/src/internal/features/analytics"
+	"github.com/yosp313/gotify/src/internal/features/audiobook"
+	"github.com/yosp313/gotify/src/internal/features/comment"
+	"github.com/yosp313/gotify/src/internal/features/embed"
+	"github.com/yosp313/gotify/src/internal/features/follow"
+	"github.com/yosp313/gotify/src/internal/features/genre"
+	"github.com/yosp313/gotify/src/internal/features/moderation"
+	"github.com/yosp313/gotify/src/internal/features/party"
+	"github.com/yosp313/gotify/src/internal/features/play"
+	"github.com/yosp313/gotify/src/internal/features/playlist"
+	"github.com/yosp313/gotify/src/internal/features/podcast"
+	"github.com/yosp313/gotify/src/internal/features/queue"
+	"github.com/yosp313/gotify/src/internal/features/radio"
+	"github.com/yosp313/gotify/src/internal/features/recommend"
+	"github.com/yosp313/gotify/src/internal/features/share"
+	"github.com/yosp313/gotify/src/internal/features/show"
 	"github.com/yosp313/gotify/src/internal/features/song"
+	"github.com/yosp313/gotify/src/internal/features/station"
+	"github.com/yosp313/gotify/src/internal/features/stats"
+	"github.com/yosp313/gotify/src/internal/features/subsonic"
 	"github.com/yosp313/gotify/src/internal/features/user"
 	"github.com/yosp313/gotify/src/internal/pkg/auth"
+	filestorage "github.com/yosp313/gotify/src/internal/pkg/file_storage"
+	"github.com/yosp313/gotify/src/internal/pkg/hub"
+	"github.com/yosp313/gotify/src/internal/pkg/scheduler"
 	"github.com/yosp313/gotify/src/internal/utils"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
@@ -19,7 +44,7 @@ func Run() {
 	db, err := gorm.Open(sqlite.Open(cfg.DatabaseURL), &gorm.Config{})
 	utils.HandleError(err, "Failed to connect to the database")
 
-	err = db.AutoMigrate(&user.User{}, &song.Song{})
+	err = db.AutoMigrate(&user.User{}, &song.Song{}, &song.Lyrics{}, &song.Like{}, &song.Position{}, &genre.Genre{}, &genre.Tag{}, &play.Play{}, &analytics.SongDailyStat{}, &analytics.RollupState{}, &stats.Snapshot{}, &recommend.SongSimilarity{}, &recommend.Mix{}, &recommend.MixSong{}, &playlist.Playlist{}, &playlist.PlaylistEntry{}, &playlist.PlaylistCollaborator{}, &playlist.PlaylistChange{}, &radio.Session{}, &radio.Track{}, &radio.Dislike{}, &queue.Queue{}, &queue.QueueItem{}, &party.Party{}, &party.PartyQueueItem{}, &party.PartyMessage{}, &station.Station{}, &subsonic.AppPassword{}, &podcast.Podcast{}, &podcast.Subscription{}, &podcast.Episode{}, &podcast.Chapter{}, &podcast.Progress{}, &show.Show{}, &show.Episode{}, &audiobook.Book{}, &audiobook.File{}, &audiobook.Chapter{}, &audiobook.Progress{}, &follow.Follow{}, &comment.Comment{}, &moderation.Report{}, &moderation.Ban{}, &moderation.Action{}, &share.Share{}, &share.SharePlay{})
 	utils.HandleError(err, "Failed to migrate database schema")
 
 	c := gin.Default()
@@ -31,6 +56,17 @@ func Run() {
 	api := c.Group("/api/v1")
 
 	authService := auth.NewJwtAuthService(cfg.JWTSecret)
+	jobs := scheduler.NewScheduler()
+	// WebSocket connections, grouped by user or room
+	realtime := hub.NewHub()
+
+	playService := play.NewPlayService(play.NewSqlPlayRepository(db))
+	playlistService := playlist.NewPlaylistService(playlist.NewSqlPlaylistRepository(db))
+	songService := song.NewSongService(song.NewSqlSongRepository(db))
+	genreService := genre.NewGenreService(genre.NewSqlGenreRepository(db))
+	moderationService := moderation.NewModerationService(moderation.NewSqlModerationRepository(db), cfg.Moderators)
+	linkSigner := auth.NewLinkSigner(cfg.ShareSecret)
+	shareService := share.NewShareService(share.NewSqlShareRepository(db), linkSigner, playlistService)
 
 	// Users features
 	{
@@ -46,12 +82,197 @@ func Run() {
 	// Song Features
 	{
 		songRouter := api.Group("/songs")
-		songRepo := song.NewSqlSongRepository(db)
-		songService := song.NewSongService(songRepo)
-		songHandler := song.NewSongHandler(songService, authService)
+		songHandler := song.NewSongHandler(songService, authService, playService, genreService, moderationService)
 
 		song.SetupRoutes(songRouter, songHandler, AuthMiddleware(authService))
+		song.SetupLibraryRoutes(api.Group("/me/likes"), songHandler, AuthMiddleware(authService))
+		song.SetupPositionRoutes(api.Group("/me/positions"), songHandler, AuthMiddleware(authService))
+		song.SetupSharedRoutes(c.Group("/shared"), songHandler, share.NewShareHandler(shareService).RequireLink)
+	}
+
+	// Genre and tag features
+	{
+		genreHandler := genre.NewGenreHandler(genreService)
+
+		genre.SetupRoutes(api.Group("/genres"), genreHandler, AuthMiddleware(authService))
+		genre.SetupTagRoutes(api.Group("/tags"), genreHandler, AuthMiddleware(authService))
+		genre.SetupSongRoutes(api.Group("/songs"), genreHandler, AuthMiddleware(authService))
+
+		jobs.Every(24*time.Hour, "seed genre taxonomy", genreService.Seed)
+	}
+
+	// Play history features
+	{
+		playHandler := play.NewPlayHandler(playService)
+
+		play.SetupRoutes(api.Group("/me/plays"), playHandler, AuthMiddleware(authService))
+		play.SetupSongRoutes(api.Group("/songs"), playHandler, AuthMiddleware(authService))
+
+		jobs.Every(time.Minute, "finish idle stream sessions", playService.SweepSessions)
+	}
+
+	// Artist analytics features
+	{
+		analyticsService := analytics.NewAnalyticsService(analytics.NewSqlAnalyticsRepository(db))
+		analyticsHandler := analytics.NewAnalyticsHandler(analyticsService)
+
+		analytics.SetupRoutes(api.Group("/artists"), analyticsHandler, AuthMiddleware(authService))
+
+		jobs.Every(15*time.Minute, "refresh song daily rollups", analyticsService.RefreshRollups)
+	}
+
+	// Listener stats features
+	{
+		statsService := stats.NewStatsService(stats.NewSqlStatsRepository(db))
+		statsHandler := stats.NewStatsHandler(statsService)
+
+		stats.SetupRoutes(api.Group("/me/stats"), statsHandler, AuthMiddleware(authService))
+		stats.SetupSharedRoutes(api.Group("/stats/snapshots"), statsHandler)
+	}
+
+	// Recommendation features
+	{
+		recommendService := recommend.NewRecommendService(recommend.NewSqlRecommendRepository(db))
+		recommendHandler := recommend.NewRecommendHandler(recommendService)
+
+		recommend.SetupRoutes(api.Group("/me"), recommendHandler, AuthMiddleware(authService))
+		recommend.SetupSongRoutes(api.Group("/songs"), recommendHandler, AuthMiddleware(authService))
+
+		jobs.Every(6*time.Hour, "refresh recommendations", recommendService.RefreshRecommendations)
+	}
+
+	// Playlist features
+	{
+		playlistHandler := playlist.NewPlaylistHandler(playlistService)
+
+		playlist.SetupRoutes(api.Group("/playlists"), playlistHandler, AuthMiddleware(authService))
+	}
+
+	// Radio features
+	{
+		radioService := radio.NewRadioService(radio.NewSqlRadioRepository(db), playlistService)
+		radioHandler := radio.NewRadioHandler(radioService)
+
+		radio.SetupRoutes(api.Group("/radio"), radioHandler, AuthMiddleware(authService))
+		radio.SetupDislikeRoutes(api.Group("/me/dislikes"), radioHandler, AuthMiddleware(authService))
+	}
+
+	// Play queue features
+	{
+		queueService := queue.NewQueueService(queue.NewSqlQueueRepository(db), realtime)
+		queueHandler := queue.NewQueueHandler(queueService)
+
+		queue.SetupRoutes(api.Group("/me/queue"), queueHandler, AuthMiddleware(authService))
 	}
 
+	// Listening party features
+	{
+		partyService := party.NewPartyService(party.NewSqlPartyRepository(db), realtime)
+		partyHandler := party.NewPartyHandler(partyService)
+
+		party.SetupRoutes(api.Group("/parties"), partyHandler, AuthMiddleware(authService))
+
+		jobs.Every(30*time.Minute, "end idle listening parties", partyService.EndIdleParties)
+	}
+
+	// Radio station features
+	{
+		stationService := station.NewStationService(station.NewSqlStationRepository(db), playlistService, linkSigner)
+		stationHandler := station.NewStationHandler(stationService)
+
+		station.SetupRoutes(api.Group("/stations"), stationHandler, AuthMiddleware(authService))
+		station.SetupMountRoutes(c.Group("/radio"), stationHandler, AuthMiddleware(authService))
+	}
+
+	// Subsonic features
+	{
+		subsonicService := subsonic.NewSubsonicService(subsonic.NewSqlSubsonicRepository(db), playlistService, songService, playService)
+		subsonicHandler := subsonic.NewSubsonicHandler(subsonicService)
+
+		subsonic.SetupRoutes(c.Group("/rest"), subsonicHandler)
+		subsonic.SetupAccountRoutes(api.Group("/me/subsonic"), subsonicHandler, AuthMiddleware(authService))
+	}
+
+	// Podcast features
+	{
+		podcastService := podcast.NewPodcastService(podcast.NewSqlPodcastRepository(db), filestorage.NewLocalFileStorageService("podcasts"), &http.Client{}, linkSigner)
+		podcastHandler := podcast.NewPodcastHandler(podcastService)
+
+		podcast.SetupRoutes(api.Group("/podcasts"), podcastHandler, AuthMiddleware(authService))
+		podcast.SetupStreamRoutes(api.Group("/podcasts"), podcastHandler, AuthMiddleware(authService))
+
+		jobs.Every(15*time.Minute, "refresh podcast feeds", podcastService.RefreshFeeds)
+	}
+
+	// Show features
+	{
+		showService := show.NewShowService(show.NewSqlShowRepository(db), moderationService)
+		showHandler := show.NewShowHandler(showService, cfg.BaseUrl)
+
+		show.SetupRoutes(api.Group("/shows"), showHandler, AuthMiddleware(authService))
+		show.SetupFeedRoutes(c.Group("/shows"), showHandler)
+	}
+
+	// Audiobook features
+	{
+		bookService := audiobook.NewBookService(audiobook.NewSqlBookRepository(db), filestorage.NewLocalFileStorageService("audiobooks"), moderationService, linkSigner)
+		bookHandler := audiobook.NewBookHandler(bookService)
+
+		audiobook.SetupRoutes(api.Group("/audiobooks"), bookHandler, AuthMiddleware(authService))
+		audiobook.SetupStreamRoutes(api.Group("/audiobooks"), bookHandler, AuthMiddleware(authService))
+	}
+
+	// Follow features
+	{
+		followService := follow.NewFollowService(follow.NewSqlFollowRepository(db))
+		followHandler := follow.NewFollowHandler(followService)
+
+		follow.SetupRoutes(api.Group("/users"), followHandler, AuthMiddleware(authService))
+		follow.SetupFeedRoutes(api.Group("/me"), followHandler, AuthMiddleware(authService))
+
+		jobs.Every(5*time.Minute, "drop stale activity feeds", followService.SweepFeeds)
+	}
+
+	// Comment features
+	{
+		commentService := comment.NewCommentService(comment.NewSqlCommentRepository(db), moderationService)
+		commentHandler := comment.NewCommentHandler(commentService)
+
+		comment.SetupRoutes(api.Group("/comments"), commentHandler, AuthMiddleware(authService))
+		comment.SetupSongRoutes(api.Group("/songs"), commentHandler, AuthMiddleware(authService))
+	}
+
+	// Moderation features
+	{
+		moderationHandler := moderation.NewModerationHandler(moderationService)
+
+		moderation.SetupRoutes(api.Group("/moderation"), moderationHandler, AuthMiddleware(authService))
+		moderation.SetupSongRoutes(api.Group("/songs"), moderationHandler, AuthMiddleware(authService))
+		moderation.SetupCommentRoutes(api.Group("/comments"), moderationHandler, AuthMiddleware(authService))
+	}
+
+	// Share link features
+	{
+		shareHandler := share.NewShareHandler(shareService)
+
+		share.SetupRoutes(api.Group("/me/shares"), shareHandler, AuthMiddleware(authService))
+		share.SetupSongRoutes(api.Group("/songs"), shareHandler, AuthMiddleware(authService))
+		share.SetupPlaylistRoutes(api.Group("/playlists"), shareHandler, AuthMiddleware(authService))
+		share.SetupLinkRoutes(c.Group("/shared"), shareHandler)
+
+		jobs.Every(24*time.Hour, "drop expired share links", shareService.SweepShares)
+	}
+
+	// Embed features
+	{
+		embedService := embed.NewEmbedService(embed.NewSqlEmbedRepository(db), playlistService, shareService)
+		embedHandler := embed.NewEmbedHandler(embedService, cfg.BaseUrl)
+
+		embed.SetupRoutes(c.Group("/embed"), embedHandler)
+		embed.SetupOEmbedRoutes(c.Group("/oembed"), embedHandler)
+	}
+
+	jobs.Start()
+
 	c.Run(cfg.Port)
 }
@@ -9,7 +9,7 @@ func CORSMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Client-Name")
 		c.Header("Access-Control-Allow-Credentials", "true")
 
 		if c.Request.Method == "OPTIONS" {
@@ -28,8 +28,9 @@ func AuthMiddleware(authService *auth.JwtAuthService) gin.HandlerFunc {
 
 		if header != "" && len(header) > 7 {
 			token = header[7:] // Remove "Bearer " prefix
-		} else {
-			// For streaming endpoints, also check query parameter
+		} else if c.IsWebsocket() {
+			// Browsers can't set headers on WebSockets. Anything else that can't, like an
+			// audio element, streams with a signed link instead of the session token
 			token = c.Query("token")
 		}
 
@@ -0,0 +1,52 @@
+package analytics
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yosp313/gotify/src/internal/utils"
+)
+
+type AnalyticsHandler struct {
+	service *AnalyticsService
+}
+
+type StatsQuery struct {
+	From string `form:"from" binding:"omitempty,datetime=2006-01-02"`
+	To   string `form:"to" binding:"omitempty,datetime=2006-01-02"`
+}
+
+func NewAnalyticsHandler(service *AnalyticsService) *AnalyticsHandler {
+	return &AnalyticsHandler{service: service}
+}
+
+func (h *AnalyticsHandler) GetMyStats(c *gin.Context) {
+	var query StatsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		utils.HandleErrorWithMessage(c, err, "Invalid date range, use YYYY-MM-DD", 400)
+		return
+	}
+
+	// Defaults to the last 30 days
+	to := time.Now().UTC()
+	if query.To != "" {
+		to, _ = time.Parse(DayFormat, query.To)
+	}
+	from := to.AddDate(0, 0, -29)
+	if query.From != "" {
+		from, _ = time.Parse(DayFormat, query.From)
+	}
+
+	stats, err := h.service.GetArtistStats(c.GetString("user_id"), from, to)
+	if errors.Is(err, ErrInvalidRange) {
+		utils.HandleErrorWithMessage(c, err, "Invalid date range, at most a year can be requested", 400)
+		return
+	}
+	if err != nil {
+		utils.HandleErrorWithMessage(c, err, "Failed to retrieve artist stats", 500)
+		return
+	}
+
+	c.JSON(200, stats)
+}
@@ -0,0 +1,15 @@
+package analytics
+
+import "time"
+
+type AnalyticsRepository interface {
+	GetRollupState(name string) (RollupState, error)
+	SaveRollupState(state *RollupState) error
+	EarliestChangeSince(since time.Time) (time.Time, bool, error)
+	GetPlayEvents(since time.Time) ([]PlayEvent, error)
+	GetLikeEvents(since time.Time) ([]LikeEvent, error)
+	ReplaceDailyStats(fromDay string, stats []SongDailyStat) error
+	GetArtistSongs(artistId string) ([]Song, error)
+	GetDailyStats(artistId, fromDay, toDay string) ([]SongDailyStat, error)
+	GetUniqueListeners(artistId string, from, to time.Time) (map[string]int64, int64, error)
+}
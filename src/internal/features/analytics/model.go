@@ -0,0 +1,135 @@
+package analytics
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const DayFormat = "2006-01-02"
+
+// SongDailyStat is the rollup of one song's activity on one UTC day
+type SongDailyStat struct {
+	SongId          uuid.UUID `json:"song_id" db:"song_id" gorm:"primaryKey"`
+	Day             string    `json:"day" db:"day" gorm:"primaryKey;size:10;index"`
+	ArtistId        uuid.UUID `json:"-" db:"artist_id" gorm:"not null;index"`
+	Plays           int64     `json:"plays" db:"plays"`
+	UniqueListeners int64     `json:"unique_listeners" db:"unique_listeners"`
+	Likes           int64     `json:"likes" db:"likes"`
+	Completions     int64     `json:"completions" db:"completions"`
+	UpdatedAt       time.Time `json:"-" db:"updated_at"`
+}
+
+// RollupState remembers when a rollup last ran so the next run only redoes the days that changed
+type RollupState struct {
+	Name      string    `db:"name" gorm:"primaryKey"`
+	LastRunAt time.Time `db:"last_run_at"`
+}
+
+type Song struct {
+	Id       uuid.UUID
+	Title    string
+	ArtistId uuid.UUID
+}
+
+// PlayEvent and LikeEvent are the raw rows the rollup is built from
+type PlayEvent struct {
+	SongId    uuid.UUID
+	ArtistId  uuid.UUID
+	UserId    uuid.UUID
+	StartedAt time.Time
+	Completed bool
+}
+
+type LikeEvent struct {
+	SongId    uuid.UUID
+	ArtistId  uuid.UUID
+	CreatedAt time.Time
+}
+
+type Counts struct {
+	Plays           int64   `json:"plays"`
+	UniqueListeners int64   `json:"unique_listeners"`
+	Likes           int64   `json:"likes"`
+	Completions     int64   `json:"completions"`
+	CompletionRate  float64 `json:"completion_rate"`
+}
+
+func (c *Counts) add(stat SongDailyStat) {
+	c.Plays += stat.Plays
+	c.Likes += stat.Likes
+	c.Completions += stat.Completions
+}
+
+func (c *Counts) finish() {
+	if c.Plays > 0 {
+		c.CompletionRate = float64(c.Completions) / float64(c.Plays)
+	}
+}
+
+type DayCounts struct {
+	Day string `json:"day"`
+	Counts
+}
+
+type SongStats struct {
+	SongId string      `json:"song_id"`
+	Title  string      `json:"title"`
+	Totals Counts      `json:"totals"`
+	Days   []DayCounts `json:"days"`
+}
+
+type ArtistStats struct {
+	From   string      `json:"from"`
+	To     string      `json:"to"`
+	Totals Counts      `json:"totals"`
+	Songs  []SongStats `json:"songs"`
+}
+
+// buildDailyStats buckets raw events into per-song, per-day rollups starting at fromDay
+func buildDailyStats(plays []PlayEvent, likes []LikeEvent, fromDay string) []SongDailyStat {
+	type key struct {
+		songId uuid.UUID
+		day    string
+	}
+
+	stats := map[key]*SongDailyStat{}
+	listeners := map[key]map[uuid.UUID]bool{}
+	get := func(songId, artistId uuid.UUID, day string) *SongDailyStat {
+		k := key{songId, day}
+		if stats[k] == nil {
+			stats[k] = &SongDailyStat{SongId: songId, Day: day, ArtistId: artistId}
+			listeners[k] = map[uuid.UUID]bool{}
+		}
+		return stats[k]
+	}
+
+	for _, play := range plays {
+		day := play.StartedAt.UTC().Format(DayFormat)
+		if day < fromDay {
+			continue
+		}
+
+		stat := get(play.SongId, play.ArtistId, day)
+		stat.Plays++
+		if play.Completed {
+			stat.Completions++
+		}
+		listeners[key{play.SongId, day}][play.UserId] = true
+	}
+
+	for _, like := range likes {
+		day := like.CreatedAt.UTC().Format(DayFormat)
+		if day < fromDay {
+			continue
+		}
+		get(like.SongId, like.ArtistId, day).Likes++
+	}
+
+	result := make([]SongDailyStat, 0, len(stats))
+	for k, stat := range stats {
+		stat.UniqueListeners = int64(len(listeners[k]))
+		result = append(result, *stat)
+	}
+	return result
+}
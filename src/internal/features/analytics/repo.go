@@ -0,0 +1,160 @@
+package analytics
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type SqlAnalyticsRepository struct {
+	db *gorm.DB
+}
+
+func NewSqlAnalyticsRepository(db *gorm.DB) *SqlAnalyticsRepository {
+	return &SqlAnalyticsRepository{db: db}
+}
+
+func (r *SqlAnalyticsRepository) GetRollupState(name string) (RollupState, error) {
+	var state RollupState
+	if err := r.db.Where("name = ?", name).Limit(1).Find(&state).Error; err != nil {
+		return RollupState{}, err
+	}
+	state.Name = name
+	return state, nil
+}
+
+func (r *SqlAnalyticsRepository) SaveRollupState(state *RollupState) error {
+	return r.db.Save(state).Error
+}
+
+// EarliestChangeSince finds the earliest activity time among plays and likes recorded after since
+func (r *SqlAnalyticsRepository) EarliestChangeSince(since time.Time) (time.Time, bool, error) {
+	var earliest []time.Time
+
+	var playTimes []time.Time
+	err := r.db.Table("plays").
+		Where("created_at > ? AND counted = ?", since, true).
+		Order("started_at").
+		Limit(1).
+		Pluck("started_at", &playTimes).Error
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	earliest = append(earliest, playTimes...)
+
+	var likeTimes []time.Time
+	err = r.db.Table("likes").
+		Where("created_at > ?", since).
+		Order("created_at").
+		Limit(1).
+		Pluck("created_at", &likeTimes).Error
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	earliest = append(earliest, likeTimes...)
+
+	if len(earliest) == 0 {
+		return time.Time{}, false, nil
+	}
+
+	first := earliest[0]
+	for _, t := range earliest[1:] {
+		if t.Before(first) {
+			first = t
+		}
+	}
+	return first, true, nil
+}
+
+func (r *SqlAnalyticsRepository) GetPlayEvents(since time.Time) ([]PlayEvent, error) {
+	var events []PlayEvent
+	err := r.db.Table("plays").
+		Select("plays.song_id, songs.artist_id, plays.user_id, plays.started_at, plays.completed").
+		Joins("JOIN songs ON songs.id = plays.song_id").
+		Where("plays.counted = ? AND plays.started_at >= ?", true, since).
+		Scan(&events).Error
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func (r *SqlAnalyticsRepository) GetLikeEvents(since time.Time) ([]LikeEvent, error) {
+	var events []LikeEvent
+	err := r.db.Table("likes").
+		Select("likes.song_id, songs.artist_id, likes.created_at").
+		Joins("JOIN songs ON songs.id = likes.song_id").
+		Where("likes.created_at >= ?", since).
+		Scan(&events).Error
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// ReplaceDailyStats swaps every rollup row from fromDay onwards for the freshly built ones
+func (r *SqlAnalyticsRepository) ReplaceDailyStats(fromDay string, stats []SongDailyStat) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("day >= ?", fromDay).Delete(&SongDailyStat{}).Error; err != nil {
+			return err
+		}
+		if len(stats) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(stats, 200).Error
+	})
+}
+
+func (r *SqlAnalyticsRepository) GetArtistSongs(artistId string) ([]Song, error) {
+	var songs []Song
+	if err := r.db.Where("artist_id = ?", artistId).Order("title").Find(&songs).Error; err != nil {
+		return nil, err
+	}
+	return songs, nil
+}
+
+func (r *SqlAnalyticsRepository) GetDailyStats(artistId, fromDay, toDay string) ([]SongDailyStat, error) {
+	var stats []SongDailyStat
+	err := r.db.Where("artist_id = ? AND day >= ? AND day <= ?", artistId, fromDay, toDay).
+		Order("day").
+		Find(&stats).Error
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// GetUniqueListeners counts distinct listeners per song and across all of the artist's songs.
+// Daily unique counts can't be summed, so this reads the raw plays
+func (r *SqlAnalyticsRepository) GetUniqueListeners(artistId string, from, to time.Time) (map[string]int64, int64, error) {
+	query := r.db.Table("plays").
+		Joins("JOIN songs ON songs.id = plays.song_id").
+		Where("songs.artist_id = ? AND plays.counted = ? AND plays.started_at >= ? AND plays.started_at < ?", artistId, true, from, to).
+		Session(&gorm.Session{})
+
+	var rows []struct {
+		SongId    string
+		Listeners int64
+	}
+	err := query.
+		Select("plays.song_id AS song_id, COUNT(DISTINCT plays.user_id) AS listeners").
+		Group("plays.song_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var total int64
+	err = query.
+		Select("COUNT(DISTINCT plays.user_id)").
+		Scan(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	perSong := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		perSong[row.SongId] = row.Listeners
+	}
+	return perSong, total, nil
+}
@@ -0,0 +1,9 @@
+package analytics
+
+import "github.com/gin-gonic/gin"
+
+func SetupRoutes(c *gin.RouterGroup, h *AnalyticsHandler, authMiddleware gin.HandlerFunc) {
+	c.Use(authMiddleware)
+
+	c.GET("/me/stats", h.GetMyStats)
+}
@@ -0,0 +1,128 @@
+package analytics
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	songDailyRollup = "song_daily_stats"
+	MaxStatsDays    = 366
+)
+
+var ErrInvalidRange = errors.New("invalid date range")
+
+type AnalyticsService struct {
+	repo AnalyticsRepository
+}
+
+func NewAnalyticsService(repo AnalyticsRepository) *AnalyticsService {
+	return &AnalyticsService{repo: repo}
+}
+
+// RefreshRollups rebuilds the daily rollups for every day that gained plays or likes
+// since the last run, plus today. Unlikes don't leave a trace, so a removed like only
+// disappears from the rollup once its day is rebuilt for another reason
+func (s *AnalyticsService) RefreshRollups() error {
+	state, err := s.repo.GetRollupState(songDailyRollup)
+	if err != nil {
+		return err
+	}
+
+	runAt := time.Now().UTC()
+	from := truncateDay(runAt)
+
+	earliest, changed, err := s.repo.EarliestChangeSince(state.LastRunAt)
+	if err != nil {
+		return err
+	}
+	if changed && earliest.Before(from) {
+		from = truncateDay(earliest.UTC())
+	}
+
+	// Events are read a day early to absorb timezone differences in stored timestamps
+	plays, err := s.repo.GetPlayEvents(from.AddDate(0, 0, -1))
+	if err != nil {
+		return err
+	}
+	likes, err := s.repo.GetLikeEvents(from.AddDate(0, 0, -1))
+	if err != nil {
+		return err
+	}
+
+	stats := buildDailyStats(plays, likes, from.Format(DayFormat))
+	if err := s.repo.ReplaceDailyStats(from.Format(DayFormat), stats); err != nil {
+		return err
+	}
+
+	state.LastRunAt = runAt
+	return s.repo.SaveRollupState(&state)
+}
+
+// GetArtistStats reports on the artist's songs between from and to, both inclusive UTC days
+func (s *AnalyticsService) GetArtistStats(artistId string, from, to time.Time) (ArtistStats, error) {
+	from, to = truncateDay(from.UTC()), truncateDay(to.UTC())
+	if to.Before(from) || to.Sub(from) > MaxStatsDays*24*time.Hour {
+		return ArtistStats{}, ErrInvalidRange
+	}
+	fromDay, toDay := from.Format(DayFormat), to.Format(DayFormat)
+
+	songs, err := s.repo.GetArtistSongs(artistId)
+	if err != nil {
+		return ArtistStats{}, err
+	}
+
+	daily, err := s.repo.GetDailyStats(artistId, fromDay, toDay)
+	if err != nil {
+		return ArtistStats{}, err
+	}
+
+	listeners, totalListeners, err := s.repo.GetUniqueListeners(artistId, from, to.AddDate(0, 0, 1))
+	if err != nil {
+		return ArtistStats{}, err
+	}
+
+	bySong := map[string][]SongDailyStat{}
+	for _, stat := range daily {
+		bySong[stat.SongId.String()] = append(bySong[stat.SongId.String()], stat)
+	}
+
+	result := ArtistStats{From: fromDay, To: toDay, Songs: make([]SongStats, 0, len(songs))}
+	for _, song := range songs {
+		songStats := SongStats{
+			SongId: song.Id.String(),
+			Title:  song.Title,
+			Days:   make([]DayCounts, 0, len(bySong[song.Id.String()])),
+		}
+
+		for _, stat := range bySong[song.Id.String()] {
+			day := DayCounts{
+				Day: stat.Day,
+				Counts: Counts{
+					Plays:           stat.Plays,
+					UniqueListeners: stat.UniqueListeners,
+					Likes:           stat.Likes,
+					Completions:     stat.Completions,
+				},
+			}
+			day.finish()
+			songStats.Days = append(songStats.Days, day)
+
+			songStats.Totals.add(stat)
+			result.Totals.add(stat)
+		}
+
+		songStats.Totals.UniqueListeners = listeners[song.Id.String()]
+		songStats.Totals.finish()
+		result.Songs = append(result.Songs, songStats)
+	}
+
+	result.Totals.UniqueListeners = totalListeners
+	result.Totals.finish()
+
+	return result, nil
+}
+
+func truncateDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
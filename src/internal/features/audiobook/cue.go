@@ -0,0 +1,37 @@
+package audiobook
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/yosp313/gotify/src/internal/pkg/cue"
+)
+
+// CueMarkers places the tracks of a cue sheet in the book's files. Sheets name files as the
+// ripper saw them, so names are matched without their folder, case or extension. A sheet for
+// a single file fits a single-file book whatever it calls the file
+func CueMarkers(sheet *cue.Sheet, files []File) ([]Marker, error) {
+	byName := make(map[string]int, len(files))
+	for i, file := range files {
+		byName[cueName(file.Name)] = i
+	}
+
+	var markers []Marker
+	for _, track := range sheet.Tracks() {
+		index, ok := byName[cueName(track.File)]
+		if !ok && len(sheet.Files) == 1 && len(files) == 1 {
+			index, ok = 0, true
+		}
+		if !ok {
+			return nil, ErrCueMismatch
+		}
+
+		markers = append(markers, Marker{File: index, Title: track.Title, StartMs: track.Start.Milliseconds()})
+	}
+	return markers, nil
+}
+
+func cueName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, `\`, "/"))
+	return strings.ToLower(strings.TrimSuffix(name, filepath.Ext(name)))
+}
@@ -0,0 +1,295 @@
+package audiobook
+
+import (
+	"errors"
+	"io"
+	"mime/multipart"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yosp313/gotify/src/internal/pkg/auth"
+	"github.com/yosp313/gotify/src/internal/pkg/cue"
+	"github.com/yosp313/gotify/src/internal/utils"
+	"gorm.io/gorm"
+)
+
+type BookHandler struct {
+	service *BookService
+}
+
+type BookCreateRequest struct {
+	Settings
+	Files []*multipart.FileHeader `form:"files" binding:"required"`
+	Cue   *multipart.FileHeader   `form:"cue"`
+}
+
+type CueRequest struct {
+	File *multipart.FileHeader `form:"file" binding:"required"`
+}
+
+type ProgressRequest struct {
+	PositionMs *int64 `json:"position_ms" binding:"required,min=0"`
+}
+
+func NewBookHandler(service *BookService) *BookHandler {
+	return &BookHandler{service: service}
+}
+
+func (h *BookHandler) Create(c *gin.Context) {
+	var req BookCreateRequest
+	if err := c.ShouldBind(&req); err != nil {
+		utils.HandleErrorWithMessage(c, err, "Invalid form data", 400)
+		return
+	}
+
+	var cueSheet []byte
+	if req.Cue != nil {
+		data, err := readCue(req.Cue)
+		if err != nil {
+			utils.HandleErrorWithMessage(c, err, "Invalid cue sheet", 400)
+			return
+		}
+		cueSheet = data
+	}
+
+	book, err := h.service.Create(c.GetString("user_id"), req.Settings, req.Files, cueSheet)
+	if err != nil {
+		handleBookError(c, err, "Failed to create audiobook")
+		return
+	}
+
+	c.JSON(201, book)
+}
+
+func (h *BookHandler) GetAll(c *gin.Context) {
+	var filter Filter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		utils.HandleErrorWithMessage(c, err, "Invalid query parameters", 400)
+		return
+	}
+	page, limit := utils.GetPagination(c)
+
+	books, total, err := h.service.GetAll(filter, page, limit)
+	if err != nil {
+		utils.HandleErrorWithMessage(c, err, "Failed to retrieve audiobooks", 500)
+		return
+	}
+
+	c.JSON(200, gin.H{"audiobooks": books, "total": total, "page": page, "limit": limit})
+}
+
+func (h *BookHandler) GetById(c *gin.Context) {
+	book, err := h.service.Get(c.GetString("user_id"), c.Param("id"))
+	if err != nil {
+		handleBookError(c, err, "Failed to retrieve audiobook")
+		return
+	}
+
+	c.JSON(200, book)
+}
+
+func (h *BookHandler) Update(c *gin.Context) {
+	var settings Settings
+	if err := c.ShouldBindJSON(&settings); err != nil {
+		utils.HandleErrorWithMessage(c, err, "Invalid request body", 400)
+		return
+	}
+
+	book, err := h.service.Update(c.GetString("user_id"), c.Param("id"), settings)
+	if err != nil {
+		handleBookError(c, err, "Failed to update audiobook")
+		return
+	}
+
+	c.JSON(200, book)
+}
+
+func (h *BookHandler) Delete(c *gin.Context) {
+	if err := h.service.Delete(c.GetString("user_id"), c.Param("id")); err != nil {
+		handleBookError(c, err, "Failed to delete audiobook")
+		return
+	}
+
+	c.Status(204)
+}
+
+// SetCue takes the book's chapters from an uploaded cue sheet
+func (h *BookHandler) SetCue(c *gin.Context) {
+	var req CueRequest
+	if err := c.ShouldBind(&req); err != nil {
+		utils.HandleErrorWithMessage(c, err, "Invalid form data", 400)
+		return
+	}
+
+	data, err := readCue(req.File)
+	if err != nil {
+		utils.HandleErrorWithMessage(c, err, "Invalid cue sheet", 400)
+		return
+	}
+
+	book, err := h.service.SetCue(c.GetString("user_id"), c.Param("id"), data)
+	if err != nil {
+		handleBookError(c, err, "Failed to set chapters")
+		return
+	}
+
+	c.JSON(200, book)
+}
+
+func (h *BookHandler) ClearCue(c *gin.Context) {
+	book, err := h.service.ClearCue(c.GetString("user_id"), c.Param("id"))
+	if err != nil {
+		handleBookError(c, err, "Failed to reset chapters")
+		return
+	}
+
+	c.JSON(200, book)
+}
+
+func (h *BookHandler) GetChapters(c *gin.Context) {
+	chapters, err := h.service.GetChapters(c.Param("id"))
+	if err != nil {
+		handleBookError(c, err, "Failed to retrieve chapters")
+		return
+	}
+
+	c.JSON(200, gin.H{"chapters": chapters})
+}
+
+func (h *BookHandler) GetChapter(c *gin.Context) {
+	number, err := strconv.Atoi(c.Param("number"))
+	if err != nil {
+		c.JSON(404, gin.H{"error": "Chapter not found"})
+		return
+	}
+
+	navigation, err := h.service.GetChapter(c.Param("id"), number)
+	if err != nil {
+		handleBookError(c, err, "Failed to retrieve chapter")
+		return
+	}
+
+	c.JSON(200, navigation)
+}
+
+// Locate tells players which file to play, and where in it, for a position in the book
+func (h *BookHandler) Locate(c *gin.Context) {
+	positionMs, err := strconv.ParseInt(c.Query("position_ms"), 10, 64)
+	if err != nil {
+		utils.HandleErrorWithMessage(c, ErrInvalidPosition, "Invalid position_ms", 400)
+		return
+	}
+
+	location, err := h.service.Locate(c.Param("id"), positionMs)
+	if err != nil {
+		handleBookError(c, err, "Failed to locate position")
+		return
+	}
+
+	c.JSON(200, location)
+}
+
+func (h *BookHandler) SetProgress(c *gin.Context) {
+	var req ProgressRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.HandleErrorWithMessage(c, err, "Invalid request body", 400)
+		return
+	}
+
+	location, err := h.service.SetProgress(c.GetString("user_id"), c.Param("id"), *req.PositionMs)
+	if err != nil {
+		handleBookError(c, err, "Failed to save progress")
+		return
+	}
+
+	c.JSON(200, gin.H{"audiobook_id": c.Param("id"), "progress": location})
+}
+
+func (h *BookHandler) StreamFile(c *gin.Context) {
+	file, path, err := h.service.FilePath(c.Param("id"), c.Param("fileId"))
+	if err != nil {
+		handleBookError(c, err, "Failed to retrieve file")
+		return
+	}
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		c.JSON(404, gin.H{"error": "Audio file not found on disk"})
+		return
+	}
+
+	c.Header("Content-Type", file.ContentType())
+	c.Header("Accept-Ranges", "bytes")
+	c.Header("Cache-Control", "public, max-age=3600")
+	c.File(path)
+}
+
+func (h *BookHandler) CreateStreamToken(c *gin.Context) {
+	token, expiresAt, err := h.service.StreamToken(c.GetString("user_id"), c.Param("id"), c.Param("fileId"))
+	if err != nil {
+		handleBookError(c, err, "Failed to create stream token")
+		return
+	}
+
+	url := utils.BaseUrl(c) + strings.TrimSuffix(c.Request.URL.Path, "-token") + "?stream_token=" + token
+	c.JSON(200, StreamToken{Token: token, Url: url, ExpiresAt: expiresAt})
+}
+
+// RequireStream lets players that can't send headers stream a file with a stream token in
+// ?stream_token=, other requests log in as usual
+func (h *BookHandler) RequireStream(authMiddleware gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.Query("stream_token")
+		if token == "" {
+			authMiddleware(c)
+			return
+		}
+
+		userId, err := h.service.AuthorizeStream(token, c.Param("fileId"))
+		if err != nil {
+			handleBookError(c, err, "Failed to open stream")
+			c.Abort()
+			return
+		}
+		c.Set("user_id", userId)
+		c.Next()
+	}
+}
+
+func readCue(header *multipart.FileHeader) ([]byte, error) {
+	if header.Size > MaxCueSize {
+		return nil, cue.ErrMalformed
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return io.ReadAll(io.LimitReader(file, MaxCueSize))
+}
+
+func handleBookError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(404, gin.H{"error": "Audiobook not found"})
+	case errors.Is(err, ErrChapterNotFound):
+		c.JSON(404, gin.H{"error": "Chapter not found"})
+	case errors.Is(err, ErrNotBookOwner), errors.Is(err, ErrBanned):
+		utils.HandleErrorWithMessage(c, err, "Forbidden", 403)
+	case errors.Is(err, ErrFileTooLarge):
+		utils.HandleErrorWithMessage(c, err, message, 413)
+	case errors.Is(err, auth.ErrInvalidLink):
+		utils.HandleErrorWithMessage(c, err, message, 401)
+	case errors.Is(err, auth.ErrLinkExpired):
+		utils.HandleErrorWithMessage(c, err, message, 410)
+	case errors.Is(err, ErrNoFiles), errors.Is(err, ErrTooManyFiles), errors.Is(err, ErrInvalidAudioFile),
+		errors.Is(err, ErrNoTitle), errors.Is(err, ErrCueMismatch), errors.Is(err, ErrInvalidPosition),
+		errors.Is(err, cue.ErrMalformed), errors.Is(err, cue.ErrNoTracks):
+		utils.HandleErrorWithMessage(c, err, message, 400)
+	default:
+		utils.HandleErrorWithMessage(c, err, message, 500)
+	}
+}
@@ -0,0 +1,19 @@
+package audiobook
+
+type BookRepository interface {
+	Create(book *Book) error
+	Update(book *Book) error
+	ReplaceChapters(book *Book) error
+	Delete(id string) error
+	GetById(id string) (Book, error)
+	GetAll(filter Filter, page, limit int) ([]Book, int64, error)
+
+	GetProgress(userId, bookId string) (Progress, error)
+	SaveProgress(progress *Progress) error
+	DeleteProgress(userId, bookId string) error
+}
+
+// BanList tells whether moderation banned a user from uploading
+type BanList interface {
+	IsBanned(userId string) (bool, error)
+}
@@ -0,0 +1,372 @@
+package audiobook
+
+import (
+	"errors"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// Largest single file and most files accepted for a book
+	MaxFileSize = 2 << 30
+	MaxFiles    = 200
+	// Largest cue sheet accepted
+	MaxCueSize = 1 << 20
+	// A book counts as finished this close to its end, and its progress is cleared
+	FinishedMargin = 30 * 1000 // milliseconds
+)
+
+// Where a book's chapters came from
+const (
+	ChaptersFromCue   = "cue"
+	ChaptersEmbedded  = "embedded"
+	ChaptersFromFiles = "files"
+)
+
+// What stream tokens stream
+const TypeBookFile = "audiobook_file"
+
+var (
+	ErrNotBookOwner     = errors.New("only the uploader can change this book")
+	ErrNoFiles          = errors.New("a book needs at least one audio file")
+	ErrTooManyFiles     = errors.New("a book can have at most 200 files")
+	ErrInvalidAudioFile = errors.New("only MP3, M4A, M4B, AAC, OGG, OPUS, FLAC and WAV files are allowed")
+	ErrFileTooLarge     = errors.New("file is larger than 2 GB")
+	ErrCueMismatch      = errors.New("cue sheet names a file that isn't part of the book")
+	ErrInvalidPosition  = errors.New("position is outside the book")
+	ErrNoTitle          = errors.New("a book needs a title")
+	ErrChapterNotFound  = errors.New("chapter not found")
+	ErrBanned           = errors.New("you were banned from uploading")
+)
+
+// Book is an audiobook, one or more files played in order with chapters across them
+type Book struct {
+	Id          uuid.UUID `json:"id" db:"id" gorm:"primaryKey"`
+	OwnerId     uuid.UUID `json:"owner_id" db:"owner_id" gorm:"not null;index"`
+	Title       string    `json:"title" db:"title" gorm:"not null"`
+	Author      string    `json:"author" db:"author" gorm:"index"`
+	Narrator    string    `json:"narrator" db:"narrator"`
+	Series      string    `json:"series,omitempty" db:"series" gorm:"index"`
+	SeriesIndex float64   `json:"series_index,omitempty" db:"series_index"`
+	Description string    `json:"description" db:"description"`
+	Language    string    `json:"language,omitempty" db:"language"`
+	Year        int       `json:"year,omitempty" db:"year"`
+	DurationMs  int64     `json:"duration_ms" db:"duration_ms"`
+	// Where the chapters came from: a cue sheet, the files' chapter markers or one per file
+	ChapterSource string    `json:"chapter_source" db:"chapter_source" gorm:"not null"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at" db:"updated_at"`
+
+	// Relationships
+	Owner    User      `json:"owner" gorm:"foreignKey:OwnerId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Files    []File    `json:"files,omitempty" gorm:"foreignKey:BookId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Chapters []Chapter `json:"chapters,omitempty" gorm:"foreignKey:BookId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+
+	// The user's progress, filled in on read
+	Progress *Location `json:"progress,omitempty" gorm:"-"`
+}
+
+// File is one part of a book. Positions in the book run through the files in order
+type File struct {
+	Id         uuid.UUID `json:"id" db:"id" gorm:"primaryKey"`
+	BookId     uuid.UUID `json:"-" db:"book_id" gorm:"not null;index"`
+	Position   int       `json:"position" db:"position" gorm:"not null"`
+	Name       string    `json:"name" db:"name"` // as uploaded, for matching cue sheets
+	Filename   string    `json:"-" db:"filename" gorm:"not null"`
+	Size       int64     `json:"size" db:"size"`
+	DurationMs int64     `json:"duration_ms" db:"duration_ms"`
+	// Where the file starts in the book
+	OffsetMs int64 `json:"offset_ms" db:"offset_ms"`
+}
+
+// Chapter marks where a part of the book starts, both in the book and in its file
+type Chapter struct {
+	Id          uuid.UUID `json:"-" db:"id" gorm:"primaryKey"`
+	BookId      uuid.UUID `json:"-" db:"book_id" gorm:"not null;index"`
+	Number      int       `json:"number" db:"number" gorm:"not null"`
+	Title       string    `json:"title" db:"title"`
+	FileId      uuid.UUID `json:"file_id" db:"file_id" gorm:"not null"`
+	FileStartMs int64     `json:"file_start_ms" db:"file_start_ms"`
+	StartMs     int64     `json:"start_ms" db:"start_ms"`
+	DurationMs  int64     `json:"duration_ms" db:"duration_ms"`
+}
+
+// TableName keeps book chapters apart from podcast chapters
+func (Chapter) TableName() string {
+	return "audiobook_chapters"
+}
+
+// Progress is where a user stopped in a book
+type Progress struct {
+	UserId     uuid.UUID `json:"-" db:"user_id" gorm:"primaryKey"`
+	BookId     uuid.UUID `json:"-" db:"book_id" gorm:"primaryKey;index"`
+	PositionMs int64     `json:"position_ms" db:"position_ms" gorm:"not null"`
+	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
+
+	// Relationships
+	User User `json:"-" gorm:"foreignKey:UserId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Book Book `json:"-" gorm:"foreignKey:BookId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+func (Progress) TableName() string {
+	return "audiobook_progress"
+}
+
+// Location resolves a position in the book to the file to play and where in it
+type Location struct {
+	PositionMs     int64     `json:"position_ms"`
+	FileId         uuid.UUID `json:"file_id"`
+	FilePositionMs int64     `json:"file_position_ms"`
+	Chapter        *Chapter  `json:"chapter"`
+}
+
+// Navigation is a chapter with its neighbours, for skipping back and forth
+type Navigation struct {
+	Chapter  Chapter  `json:"chapter"`
+	Previous *Chapter `json:"previous"`
+	Next     *Chapter `json:"next"`
+}
+
+// Settings are the book's details. On upload, blank ones are taken from the files' tags
+type Settings struct {
+	Title       string  `form:"title" json:"title" binding:"max=300"`
+	Author      string  `form:"author" json:"author" binding:"max=200"`
+	Narrator    string  `form:"narrator" json:"narrator" binding:"max=200"`
+	Series      string  `form:"series" json:"series" binding:"max=200"`
+	SeriesIndex float64 `form:"series_index" json:"series_index" binding:"min=0"`
+	Description string  `form:"description" json:"description" binding:"max=10000"`
+	Language    string  `form:"language" json:"language" binding:"max=20"`
+	Year        int     `form:"year" json:"year" binding:"omitempty,min=1,max=9999"`
+}
+
+// Filter narrows the list of books, blank fields match everything
+type Filter struct {
+	Author   string `form:"author"`
+	Narrator string `form:"narrator"`
+	Series   string `form:"series"`
+}
+
+// Marker is a chapter start found in a file, before it's placed in the book
+type Marker struct {
+	File    int // index into the book's files
+	Title   string
+	StartMs int64
+}
+
+// StreamToken lets the user's own player stream a book's file without the session in its URL
+type StreamToken struct {
+	Token     string    `json:"token"`
+	Url       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type User struct {
+	Id       uuid.UUID `json:"id"`
+	FullName string    `json:"full_name"`
+}
+
+func NewBook(ownerId string, settings Settings) (*Book, error) {
+	ownerUUID, err := uuid.Parse(ownerId)
+	if err != nil {
+		return nil, err
+	}
+
+	book := &Book{Id: uuid.New(), OwnerId: ownerUUID}
+	book.Apply(settings)
+	return book, nil
+}
+
+func NewProgress(userId, bookId uuid.UUID, positionMs int64) *Progress {
+	return &Progress{UserId: userId, BookId: bookId, PositionMs: positionMs}
+}
+
+func (b *Book) Apply(settings Settings) {
+	b.Title = strings.TrimSpace(settings.Title)
+	b.Author = strings.TrimSpace(settings.Author)
+	b.Narrator = strings.TrimSpace(settings.Narrator)
+	b.Series = strings.TrimSpace(settings.Series)
+	b.SeriesIndex = settings.SeriesIndex
+	b.Description = settings.Description
+	b.Language = strings.TrimSpace(settings.Language)
+	b.Year = settings.Year
+}
+
+func (b *Book) IsOwner(userId string) bool {
+	return b.OwnerId.String() == userId
+}
+
+// SetFiles lays the files out one after another, working out where each starts in the book
+func (b *Book) SetFiles(files []File) {
+	var offset int64
+	for i := range files {
+		files[i].BookId = b.Id
+		files[i].Position = i + 1
+		files[i].OffsetMs = offset
+		offset += files[i].DurationMs
+	}
+	b.Files = files
+	b.DurationMs = offset
+}
+
+// SetChapters places markers in the book. Markers past the end of their file are dropped,
+// and a chapter lasts until the next one starts
+func (b *Book) SetChapters(markers []Marker, source string) {
+	valid := make([]Marker, 0, len(markers))
+	for _, marker := range markers {
+		if marker.File < 0 || marker.File >= len(b.Files) || marker.StartMs < 0 {
+			continue
+		}
+		if file := b.Files[marker.File]; file.DurationMs > 0 && marker.StartMs >= file.DurationMs {
+			continue
+		}
+		valid = append(valid, marker)
+	}
+	sort.SliceStable(valid, func(i, j int) bool {
+		if valid[i].File != valid[j].File {
+			return valid[i].File < valid[j].File
+		}
+		return valid[i].StartMs < valid[j].StartMs
+	})
+
+	chapters := make([]Chapter, 0, len(valid))
+	for i, marker := range valid {
+		file := b.Files[marker.File]
+		chapter := Chapter{
+			Id:          uuid.New(),
+			BookId:      b.Id,
+			Number:      i + 1,
+			Title:       strings.TrimSpace(marker.Title),
+			FileId:      file.Id,
+			FileStartMs: marker.StartMs,
+			StartMs:     file.OffsetMs + marker.StartMs,
+		}
+		if chapter.Title == "" {
+			chapter.Title = "Chapter " + strconv.Itoa(i+1)
+		}
+		chapters = append(chapters, chapter)
+	}
+	for i := range chapters {
+		end := b.DurationMs
+		if i+1 < len(chapters) {
+			end = chapters[i+1].StartMs
+		}
+		chapters[i].DurationMs = max(end-chapters[i].StartMs, 0)
+	}
+
+	b.Chapters = chapters
+	b.ChapterSource = source
+}
+
+// FileMarkers starts a chapter at the top of every file, titled by the file's name
+func FileMarkers(files []File, titles []string) []Marker {
+	markers := make([]Marker, len(files))
+	for i, file := range files {
+		title := ""
+		if i < len(titles) {
+			title = titles[i]
+		}
+		if title == "" {
+			title = strings.TrimSuffix(file.Name, filepath.Ext(file.Name))
+		}
+		markers[i] = Marker{File: i, Title: title}
+	}
+	return markers
+}
+
+// Locate resolves a position in the book to its file and chapter
+func (b *Book) Locate(positionMs int64) (Location, error) {
+	if positionMs < 0 || len(b.Files) == 0 || (b.DurationMs > 0 && positionMs > b.DurationMs) {
+		return Location{}, ErrInvalidPosition
+	}
+
+	file := b.Files[0]
+	for _, f := range b.Files {
+		if f.OffsetMs <= positionMs {
+			file = f
+		}
+	}
+
+	location := Location{PositionMs: positionMs, FileId: file.Id, FilePositionMs: positionMs - file.OffsetMs}
+	if chapter, ok := b.ChapterAt(positionMs); ok {
+		location.Chapter = &chapter
+	}
+	return location, nil
+}
+
+// ChapterAt is the chapter playing at a position in the book
+func (b *Book) ChapterAt(positionMs int64) (Chapter, bool) {
+	var found *Chapter
+	for i := range b.Chapters {
+		if b.Chapters[i].StartMs <= positionMs {
+			found = &b.Chapters[i]
+		}
+	}
+	if found == nil {
+		return Chapter{}, false
+	}
+	return *found, true
+}
+
+// Navigate returns the chapter with the given number and its neighbours
+func (b *Book) Navigate(number int) (Navigation, bool) {
+	if number < 1 || number > len(b.Chapters) {
+		return Navigation{}, false
+	}
+
+	navigation := Navigation{Chapter: b.Chapters[number-1]}
+	if number > 1 {
+		navigation.Previous = &b.Chapters[number-2]
+	}
+	if number < len(b.Chapters) {
+		navigation.Next = &b.Chapters[number]
+	}
+	return navigation, true
+}
+
+// IsFinished tells whether a position is at the end of the book
+func (b *Book) IsFinished(positionMs int64) bool {
+	return b.DurationMs > 0 && positionMs >= b.DurationMs-FinishedMargin
+}
+
+func (b *Book) File(id string) (File, bool) {
+	for _, file := range b.Files {
+		if file.Id.String() == id {
+			return file, true
+		}
+	}
+	return File{}, false
+}
+
+func (f *File) ContentType() string {
+	switch strings.ToLower(filepath.Ext(f.Filename)) {
+	case ".m4a", ".m4b":
+		return "audio/mp4"
+	case ".aac":
+		return "audio/aac"
+	case ".ogg":
+		return "audio/ogg"
+	case ".opus":
+		return "audio/opus"
+	case ".flac":
+		return "audio/flac"
+	case ".wav":
+		return "audio/wav"
+	default:
+		return "audio/mpeg"
+	}
+}
+
+// IsAudioFile tells whether a file name has an extension books can be made of
+func IsAudioFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".mp3", ".m4a", ".m4b", ".aac", ".ogg", ".opus", ".flac", ".wav":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,104 @@
+package audiobook
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type SqlBookRepository struct {
+	db *gorm.DB
+}
+
+func NewSqlBookRepository(db *gorm.DB) *SqlBookRepository {
+	return &SqlBookRepository{db: db}
+}
+
+// Create stores a book along with its files and chapters
+func (r *SqlBookRepository) Create(book *Book) error {
+	return r.db.Omit("Owner").Create(book).Error
+}
+
+func (r *SqlBookRepository) Update(book *Book) error {
+	return r.db.Model(book).
+		Select("title", "author", "narrator", "series", "series_index", "description", "language", "year", "updated_at").
+		Updates(book).Error
+}
+
+func (r *SqlBookRepository) ReplaceChapters(book *Book) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&Chapter{}, "book_id = ?", book.Id).Error; err != nil {
+			return err
+		}
+		if len(book.Chapters) > 0 {
+			if err := tx.Create(&book.Chapters).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(book).Select("chapter_source", "updated_at").Updates(book).Error
+	})
+}
+
+func (r *SqlBookRepository) Delete(id string) error {
+	return r.db.Delete(&Book{}, "id = ?", id).Error
+}
+
+func (r *SqlBookRepository) GetById(id string) (Book, error) {
+	var book Book
+	err := r.db.Preload("Owner").
+		Preload("Files", func(db *gorm.DB) *gorm.DB { return db.Order("position") }).
+		Preload("Chapters", func(db *gorm.DB) *gorm.DB { return db.Order("number") }).
+		First(&book, "id = ?", id).Error
+	if err != nil {
+		return Book{}, err
+	}
+	return book, nil
+}
+
+// GetAll pages through books, a series in reading order and everything else by title
+func (r *SqlBookRepository) GetAll(filter Filter, page, limit int) ([]Book, int64, error) {
+	query := r.db.Model(&Book{})
+	if filter.Author != "" {
+		query = query.Where("LOWER(author) = LOWER(?)", filter.Author)
+	}
+	if filter.Narrator != "" {
+		query = query.Where("LOWER(narrator) = LOWER(?)", filter.Narrator)
+	}
+	if filter.Series != "" {
+		query = query.Where("LOWER(series) = LOWER(?)", filter.Series)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var books []Book
+	err := query.Preload("Owner").
+		Order("series, series_index, title").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&books).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return books, total, nil
+}
+
+func (r *SqlBookRepository) GetProgress(userId, bookId string) (Progress, error) {
+	var progress Progress
+	if err := r.db.First(&progress, "user_id = ? AND book_id = ?", userId, bookId).Error; err != nil {
+		return Progress{}, err
+	}
+	return progress, nil
+}
+
+func (r *SqlBookRepository) SaveProgress(progress *Progress) error {
+	return r.db.Omit("User", "Book").Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "book_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"position_ms", "updated_at"}),
+	}).Create(progress).Error
+}
+
+func (r *SqlBookRepository) DeleteProgress(userId, bookId string) error {
+	return r.db.Where("user_id = ? AND book_id = ?", userId, bookId).Delete(&Progress{}).Error
+}
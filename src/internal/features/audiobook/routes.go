@@ -0,0 +1,25 @@
+package audiobook
+
+import "github.com/gin-gonic/gin"
+
+func SetupRoutes(c *gin.RouterGroup, h *BookHandler, authMiddleware gin.HandlerFunc) {
+	c.Use(authMiddleware)
+
+	c.POST("", h.Create)
+	c.GET("", h.GetAll)
+	c.GET("/:id", h.GetById)
+	c.PUT("/:id", h.Update)
+	c.DELETE("/:id", h.Delete)
+	c.PUT("/:id/cue", h.SetCue)
+	c.DELETE("/:id/cue", h.ClearCue)
+	c.GET("/:id/chapters", h.GetChapters)
+	c.GET("/:id/chapters/:number", h.GetChapter)
+	c.GET("/:id/locate", h.Locate)
+	c.PUT("/:id/progress", h.SetProgress)
+	c.POST("/:id/files/:fileId/stream-token", h.CreateStreamToken)
+}
+
+// SetupStreamRoutes serves book files to players with a login or a stream token
+func SetupStreamRoutes(c *gin.RouterGroup, h *BookHandler, authMiddleware gin.HandlerFunc) {
+	c.GET("/:id/files/:fileId/stream", h.RequireStream(authMiddleware), h.StreamFile)
+}
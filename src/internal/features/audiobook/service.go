@@ -0,0 +1,396 @@
+package audiobook
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"mime/multipart"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	audiotags "github.com/yosp313/gotify/src/internal/pkg/audio_tags"
+	"github.com/yosp313/gotify/src/internal/pkg/auth"
+	"github.com/yosp313/gotify/src/internal/pkg/cue"
+	filestorage "github.com/yosp313/gotify/src/internal/pkg/file_storage"
+	"gorm.io/gorm"
+)
+
+type BookService struct {
+	repo    BookRepository
+	storage filestorage.FileStorageService
+	bans    BanList
+	links   *auth.LinkSigner
+}
+
+func NewBookService(repo BookRepository, storage filestorage.FileStorageService, bans BanList, links *auth.LinkSigner) *BookService {
+	return &BookService{repo: repo, storage: storage, bans: bans, links: links}
+}
+
+// Create stores the uploaded files as a book in the order given. Chapters come from the cue
+// sheet when there is one, else from the chapter markers in the files, else one per file.
+// Details left blank are taken from the first file's tags
+func (s *BookService) Create(userId string, settings Settings, uploads []*multipart.FileHeader, cueSheet []byte) (Book, error) {
+	if len(uploads) == 0 {
+		return Book{}, ErrNoFiles
+	}
+	if len(uploads) > MaxFiles {
+		return Book{}, ErrTooManyFiles
+	}
+	for _, upload := range uploads {
+		if !IsAudioFile(upload.Filename) {
+			return Book{}, ErrInvalidAudioFile
+		}
+		if upload.Size > MaxFileSize {
+			return Book{}, ErrFileTooLarge
+		}
+	}
+	if err := s.refuseBanned(userId); err != nil {
+		return Book{}, err
+	}
+
+	var sheet *cue.Sheet
+	if len(cueSheet) > 0 {
+		parsed, err := cue.Parse(bytes.NewReader(cueSheet))
+		if err != nil {
+			return Book{}, err
+		}
+		sheet = parsed
+	}
+
+	book, err := NewBook(userId, settings)
+	if err != nil {
+		return Book{}, err
+	}
+
+	files, err := s.store(uploads)
+	if err != nil {
+		return Book{}, err
+	}
+	book.SetFiles(files)
+
+	s.fillFromTags(book)
+	if err := s.chapter(book, sheet); err != nil {
+		s.deleteFiles(book.Files)
+		return Book{}, err
+	}
+
+	if err := s.repo.Create(book); err != nil {
+		s.deleteFiles(book.Files)
+		return Book{}, err
+	}
+	return s.Get(userId, book.Id.String())
+}
+
+func (s *BookService) GetAll(filter Filter, page, limit int) ([]Book, int64, error) {
+	return s.repo.GetAll(filter, page, limit)
+}
+
+// Get returns a book with its files, chapters and where the user is in it
+func (s *BookService) Get(userId, id string) (Book, error) {
+	book, err := s.repo.GetById(id)
+	if err != nil {
+		return Book{}, err
+	}
+
+	progress, err := s.repo.GetProgress(userId, id)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return Book{}, err
+	}
+	if err == nil {
+		if location, err := book.Locate(progress.PositionMs); err == nil {
+			book.Progress = &location
+		}
+	}
+	return book, nil
+}
+
+func (s *BookService) Update(userId, id string, settings Settings) (Book, error) {
+	book, err := s.owned(userId, id)
+	if err != nil {
+		return Book{}, err
+	}
+
+	book.Apply(settings)
+	if book.Title == "" {
+		return Book{}, ErrNoTitle
+	}
+	if err := s.repo.Update(&book); err != nil {
+		return Book{}, err
+	}
+	return s.Get(userId, id)
+}
+
+func (s *BookService) Delete(userId, id string) error {
+	book, err := s.owned(userId, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.Delete(id); err != nil {
+		return err
+	}
+	s.deleteFiles(book.Files)
+	return nil
+}
+
+// SetCue replaces the book's chapters with the tracks of a cue sheet
+func (s *BookService) SetCue(userId, id string, cueSheet []byte) (Book, error) {
+	book, err := s.owned(userId, id)
+	if err != nil {
+		return Book{}, err
+	}
+
+	sheet, err := cue.Parse(bytes.NewReader(cueSheet))
+	if err != nil {
+		return Book{}, err
+	}
+	if err := s.chapter(&book, sheet); err != nil {
+		return Book{}, err
+	}
+
+	if err := s.repo.ReplaceChapters(&book); err != nil {
+		return Book{}, err
+	}
+	return s.Get(userId, id)
+}
+
+// ClearCue goes back to the chapters the files themselves have
+func (s *BookService) ClearCue(userId, id string) (Book, error) {
+	book, err := s.owned(userId, id)
+	if err != nil {
+		return Book{}, err
+	}
+
+	if err := s.chapter(&book, nil); err != nil {
+		return Book{}, err
+	}
+	if err := s.repo.ReplaceChapters(&book); err != nil {
+		return Book{}, err
+	}
+	return s.Get(userId, id)
+}
+
+func (s *BookService) GetChapters(id string) ([]Chapter, error) {
+	book, err := s.repo.GetById(id)
+	if err != nil {
+		return nil, err
+	}
+	return book.Chapters, nil
+}
+
+// GetChapter returns a chapter with the ones before and after it
+func (s *BookService) GetChapter(id string, number int) (Navigation, error) {
+	book, err := s.repo.GetById(id)
+	if err != nil {
+		return Navigation{}, err
+	}
+
+	navigation, ok := book.Navigate(number)
+	if !ok {
+		return Navigation{}, ErrChapterNotFound
+	}
+	return navigation, nil
+}
+
+// Locate resolves a position in the book to the file and chapter playing there
+func (s *BookService) Locate(id string, positionMs int64) (Location, error) {
+	book, err := s.repo.GetById(id)
+	if err != nil {
+		return Location{}, err
+	}
+	return book.Locate(positionMs)
+}
+
+// SetProgress remembers where the user is in the book. Reaching the end clears it, so the
+// book starts over next time. It returns where the book resumes, nil for the start
+func (s *BookService) SetProgress(userId, id string, positionMs int64) (*Location, error) {
+	userUUID, err := uuid.Parse(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	book, err := s.repo.GetById(id)
+	if err != nil {
+		return nil, err
+	}
+
+	location, err := book.Locate(positionMs)
+	if err != nil {
+		return nil, err
+	}
+	if positionMs == 0 || book.IsFinished(positionMs) {
+		return nil, s.repo.DeleteProgress(userId, id)
+	}
+
+	if err := s.repo.SaveProgress(NewProgress(userUUID, book.Id, positionMs)); err != nil {
+		return nil, err
+	}
+	return &location, nil
+}
+
+// FilePath is where one of the book's files lives on disk
+// StreamToken signs a short-lived link for the user's own player, so it can stream one of a
+// book's files without the user's session in its URL
+func (s *BookService) StreamToken(userId, id, fileId string) (string, time.Time, error) {
+	file, _, err := s.FilePath(id, fileId)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	token, expiresAt := s.links.SignStream(TypeBookFile, file.Id.String(), userId)
+	return token, expiresAt, nil
+}
+
+// AuthorizeStream checks a stream token for the file and returns the user it streams as
+func (s *BookService) AuthorizeStream(token, fileId string) (string, error) {
+	return s.links.ParseStream(token, TypeBookFile, fileId)
+}
+
+func (s *BookService) FilePath(id, fileId string) (File, string, error) {
+	book, err := s.repo.GetById(id)
+	if err != nil {
+		return File{}, "", err
+	}
+
+	file, ok := book.File(fileId)
+	if !ok {
+		return File{}, "", gorm.ErrRecordNotFound
+	}
+	return file, s.storage.FilePath(file.Filename), nil
+}
+
+func (s *BookService) owned(userId, id string) (Book, error) {
+	book, err := s.repo.GetById(id)
+	if err != nil {
+		return Book{}, err
+	}
+	if !book.IsOwner(userId) {
+		return Book{}, ErrNotBookOwner
+	}
+	return book, nil
+}
+
+// store saves the uploads, measuring each one. Nothing is kept when one fails
+func (s *BookService) store(uploads []*multipart.FileHeader) ([]File, error) {
+	files := make([]File, 0, len(uploads))
+	for _, upload := range uploads {
+		file := File{Id: uuid.New(), Name: filepath.Base(upload.Filename), Size: upload.Size}
+
+		src, err := upload.Open()
+		if err != nil {
+			s.deleteFiles(files)
+			return nil, err
+		}
+		file.Filename, err = s.storage.SaveFile(src, file.Id.String()+strings.ToLower(filepath.Ext(upload.Filename)))
+		src.Close()
+		if err != nil {
+			s.deleteFiles(files)
+			return nil, err
+		}
+
+		if duration, err := audiotags.Duration(s.storage.FilePath(file.Filename)); err == nil {
+			file.DurationMs = duration.Milliseconds()
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// fillFromTags takes the details the uploader left blank from the first file's tags. The
+// album is the book's title, the artist its author and the composer its narrator, the way
+// audiobook rippers tag them
+func (s *BookService) fillFromTags(book *Book) {
+	tags, err := audiotags.ReadFile(s.storage.FilePath(book.Files[0].Filename))
+	if err != nil {
+		tags = &audiotags.Tags{Frames: map[string]string{}}
+	}
+
+	if book.Title == "" {
+		book.Title = tags.Album()
+	}
+	if book.Title == "" {
+		name := book.Files[0].Name
+		book.Title = strings.TrimSuffix(name, filepath.Ext(name))
+	}
+	if book.Author == "" {
+		book.Author = tags.Artist()
+	}
+	if book.Narrator == "" {
+		book.Narrator = tags.Text("TCOM")
+	}
+}
+
+// chapter works out the book's chapters from the cue sheet, or else from the files
+func (s *BookService) chapter(book *Book, sheet *cue.Sheet) error {
+	if sheet != nil {
+		markers, err := CueMarkers(sheet, book.Files)
+		if err != nil {
+			return err
+		}
+		book.SetChapters(markers, ChaptersFromCue)
+		return nil
+	}
+
+	var markers []Marker
+	titles := make([]string, len(book.Files))
+	for i, file := range book.Files {
+		path := s.storage.FilePath(file.Filename)
+		if chapters, err := audiotags.ReadChapters(path); err == nil {
+			for _, chapter := range chapters {
+				markers = append(markers, Marker{File: i, Title: chapter.Title, StartMs: chapter.StartMs})
+			}
+		}
+		if tags, err := audiotags.ReadFile(path); err == nil {
+			titles[i] = tags.Title()
+		}
+	}
+
+	if len(markers) > 0 {
+		// Files without markers of their own still start a chapter
+		for i, marker := range FileMarkers(book.Files, titles) {
+			if !hasMarker(markers, i) {
+				markers = append(markers, marker)
+			}
+		}
+		book.SetChapters(markers, ChaptersEmbedded)
+		return nil
+	}
+
+	book.SetChapters(FileMarkers(book.Files, titles), ChaptersFromFiles)
+	return nil
+}
+
+func (s *BookService) deleteFiles(files []File) {
+	for _, file := range files {
+		if err := s.storage.DeleteFile(file.Filename); err != nil {
+			log.Printf("audiobook: deleting %s failed: %v", file.Filename, err)
+		}
+	}
+}
+
+func hasMarker(markers []Marker, file int) bool {
+	for _, marker := range markers {
+		if marker.File == file {
+			return true
+		}
+	}
+	return false
+}
+
+// refuseBanned fails with ErrBanned when moderation banned the user from uploading
+func (s *BookService) refuseBanned(userId string) error {
+	if s.bans == nil {
+		return nil
+	}
+
+	banned, err := s.bans.IsBanned(userId)
+	if err != nil {
+		return err
+	}
+	if banned {
+		return ErrBanned
+	}
+	return nil
+}
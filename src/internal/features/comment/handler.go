@@ -0,0 +1,149 @@
+package comment
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yosp313/gotify/src/internal/utils"
+)
+
+var ErrInvalidQuery = errors.New("from_ms and to_ms must be whole milliseconds")
+
+type CommentHandler struct {
+	service *CommentService
+}
+
+type CommentRequest struct {
+	Body     string  `json:"body" binding:"required"`
+	AtMs     *int64  `json:"at_ms" binding:"omitempty,min=0"`
+	ParentId *string `json:"parent_id" binding:"omitempty,uuid"`
+}
+
+type CommentUpdateRequest struct {
+	Body string `json:"body" binding:"required"`
+}
+
+func NewCommentHandler(service *CommentService) *CommentHandler {
+	return &CommentHandler{service: service}
+}
+
+func (h *CommentHandler) Create(c *gin.Context) {
+	var req CommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.HandleErrorWithMessage(c, err, "Invalid request body", 400)
+		return
+	}
+
+	comment, err := h.service.Create(c.GetString("user_id"), c.Param("id"), req.ParentId, req.AtMs, req.Body)
+	if err != nil {
+		handleCommentError(c, err, "Failed to post comment")
+		return
+	}
+
+	c.JSON(201, comment)
+}
+
+func (h *CommentHandler) GetSongComments(c *gin.Context) {
+	page, limit := utils.GetPagination(c)
+
+	query := Query{Sort: c.DefaultQuery("sort", SortNewest), Timed: c.Query("timed") == "true"}
+	if query.Sort != SortNewest && query.Sort != SortOldest && query.Sort != SortTimestamp {
+		query.Sort = SortNewest
+	}
+	var err error
+	if query.FromMs, err = msQuery(c, "from_ms"); err != nil {
+		utils.HandleErrorWithMessage(c, err, "Invalid query", 400)
+		return
+	}
+	if query.ToMs, err = msQuery(c, "to_ms"); err != nil {
+		utils.HandleErrorWithMessage(c, err, "Invalid query", 400)
+		return
+	}
+	query.Timed = query.Timed || query.FromMs != nil || query.ToMs != nil
+
+	comments, total, err := h.service.GetComments(c.GetString("user_id"), c.Param("id"), query, page, limit)
+	if err != nil {
+		handleCommentError(c, err, "Failed to retrieve comments")
+		return
+	}
+
+	c.JSON(200, gin.H{"comments": comments, "total": total, "page": page, "limit": limit})
+}
+
+func (h *CommentHandler) GetById(c *gin.Context) {
+	comment, err := h.service.Get(c.GetString("user_id"), c.Param("id"))
+	if err != nil {
+		handleCommentError(c, err, "Failed to retrieve comment")
+		return
+	}
+
+	c.JSON(200, comment)
+}
+
+func (h *CommentHandler) GetReplies(c *gin.Context) {
+	page, limit := utils.GetPagination(c)
+
+	replies, total, err := h.service.GetReplies(c.GetString("user_id"), c.Param("id"), page, limit)
+	if err != nil {
+		handleCommentError(c, err, "Failed to retrieve replies")
+		return
+	}
+
+	c.JSON(200, gin.H{"comments": replies, "total": total, "page": page, "limit": limit})
+}
+
+func (h *CommentHandler) Update(c *gin.Context) {
+	var req CommentUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.HandleErrorWithMessage(c, err, "Invalid request body", 400)
+		return
+	}
+
+	comment, err := h.service.Update(c.GetString("user_id"), c.Param("id"), req.Body)
+	if err != nil {
+		handleCommentError(c, err, "Failed to update comment")
+		return
+	}
+
+	c.JSON(200, comment)
+}
+
+func (h *CommentHandler) Delete(c *gin.Context) {
+	if err := h.service.Delete(c.GetString("user_id"), c.Param("id")); err != nil {
+		handleCommentError(c, err, "Failed to delete comment")
+		return
+	}
+
+	c.Status(204)
+}
+
+// msQuery reads an optional millisecond query parameter
+func msQuery(c *gin.Context, name string) (*int64, error) {
+	if c.Query(name) == "" {
+		return nil, nil
+	}
+	ms, err := strconv.ParseInt(c.Query(name), 10, 64)
+	if err != nil {
+		return nil, ErrInvalidQuery
+	}
+	return &ms, nil
+}
+
+func handleCommentError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, ErrCommentNotFound):
+		c.JSON(404, gin.H{"error": "Comment not found"})
+	case errors.Is(err, ErrSongNotFound):
+		c.JSON(404, gin.H{"error": "Song not found"})
+	case errors.Is(err, ErrNotAuthor), errors.Is(err, ErrNotAllowed), errors.Is(err, ErrCommentRejected):
+		utils.HandleErrorWithMessage(c, err, "Forbidden", 403)
+	case errors.Is(err, ErrCommentDeleted):
+		utils.HandleErrorWithMessage(c, err, message, 409)
+	case errors.Is(err, ErrEmptyComment), errors.Is(err, ErrCommentTooLong),
+		errors.Is(err, ErrInvalidTimestamp), errors.Is(err, ErrReplyTimestamp), errors.Is(err, ErrWrongSong):
+		utils.HandleErrorWithMessage(c, err, message, 400)
+	default:
+		utils.HandleErrorWithMessage(c, err, message, 500)
+	}
+}
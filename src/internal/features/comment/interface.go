@@ -0,0 +1,21 @@
+package comment
+
+import "github.com/google/uuid"
+
+type CommentRepository interface {
+	Create(comment *Comment) error
+	Get(id string) (Comment, error)
+	Update(comment *Comment) error
+	Delete(id string) error
+	GetSong(id string) (Song, error)
+	GetComments(songId, viewerId string, query Query, page, limit int) ([]Comment, int64, error)
+	GetReplies(parentId, viewerId string, page, limit int) ([]Comment, int64, error)
+	HasReplies(id string) (bool, error)
+	CountReplies(parentIds []uuid.UUID, viewerId string) (map[uuid.UUID]int64, error)
+}
+
+// Moderator screens comments before they're posted or edited, a comment it doesn't allow is
+// rejected with ErrCommentRejected
+type Moderator interface {
+	Screen(userId string, songId uuid.UUID, body string) (bool, error)
+}
@@ -0,0 +1,108 @@
+package comment
+
+import (
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// MaxCommentLength is the longest comment in characters
+const MaxCommentLength = 2000
+
+// Orders for listing a song's comments
+const (
+	SortNewest    = "newest"
+	SortOldest    = "oldest"
+	SortTimestamp = "timestamp"
+)
+
+var (
+	ErrCommentNotFound  = errors.New("comment not found")
+	ErrSongNotFound     = errors.New("song not found")
+	ErrEmptyComment     = errors.New("comment can't be empty")
+	ErrCommentTooLong   = errors.New("comment is too long")
+	ErrInvalidTimestamp = errors.New("timestamp must be within the song")
+	ErrReplyTimestamp   = errors.New("replies can't have a timestamp, they belong to their comment's")
+	ErrWrongSong        = errors.New("the comment replied to is on another song")
+	ErrNotAuthor        = errors.New("only the author can change this comment")
+	ErrNotAllowed       = errors.New("only the author or the song's artist can delete this comment")
+	ErrCommentDeleted   = errors.New("comment was deleted")
+	ErrCommentRejected  = errors.New("you can't comment right now")
+)
+
+// Comment is a comment on a song, or a reply to one. Threads are one level deep: replying to
+// a reply adds to the thread of the comment it's under. Top-level comments can be anchored to
+// a moment of the song with AtMs
+type Comment struct {
+	Id       uuid.UUID  `json:"id" db:"id" gorm:"primaryKey"`
+	SongId   uuid.UUID  `json:"song_id" db:"song_id" gorm:"not null;index"`
+	UserId   uuid.UUID  `json:"user_id" db:"user_id" gorm:"not null;index"`
+	ParentId *uuid.UUID `json:"parent_id" db:"parent_id" gorm:"index"`
+	AtMs     *int64     `json:"at_ms" db:"at_ms"`
+	Body     string     `json:"body" db:"body" gorm:"not null"`
+	// Deleted comments with replies stay, without their body, to keep the thread together
+	Deleted bool `json:"deleted" db:"deleted" gorm:"not null;default:false"`
+	// Hidden comments are only shown to their author
+	Hidden    bool       `json:"hidden,omitempty" db:"hidden" gorm:"not null;default:false"`
+	EditedAt  *time.Time `json:"edited_at" db:"edited_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+
+	ReplyCount int64 `json:"reply_count" gorm:"-"`
+
+	// Relationships
+	User   *User    `json:"user,omitempty" gorm:"foreignKey:UserId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Song   *Song    `json:"-" gorm:"foreignKey:SongId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Parent *Comment `json:"-" gorm:"foreignKey:ParentId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+// Query narrows down and orders the top-level comments of a song
+type Query struct {
+	Sort string
+	// Only comments anchored to a moment of the song, between FromMs and ToMs when set
+	Timed  bool
+	FromMs *int64
+	ToMs   *int64
+}
+
+type User struct {
+	Id       uuid.UUID `json:"id"`
+	FullName string    `json:"full_name"`
+}
+
+type Song struct {
+	Id       uuid.UUID
+	ArtistId uuid.UUID
+	Duration int // seconds, 0 when unknown
+	Status   string
+}
+
+func NewComment(songId, userId uuid.UUID, parentId *uuid.UUID, atMs *int64, body string) (*Comment, error) {
+	body, err := NormalizeBody(body)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Comment{
+		Id:       uuid.New(),
+		SongId:   songId,
+		UserId:   userId,
+		ParentId: parentId,
+		AtMs:     atMs,
+		Body:     body,
+	}, nil
+}
+
+// NormalizeBody trims a comment and checks it has something to say and isn't too long
+func NormalizeBody(body string) (string, error) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return "", ErrEmptyComment
+	}
+	if utf8.RuneCountInString(body) > MaxCommentLength {
+		return "", ErrCommentTooLong
+	}
+	return body, nil
+}
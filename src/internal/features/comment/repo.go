@@ -0,0 +1,119 @@
+package comment
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type SqlCommentRepository struct {
+	db *gorm.DB
+}
+
+func NewSqlCommentRepository(db *gorm.DB) *SqlCommentRepository {
+	return &SqlCommentRepository{db: db}
+}
+
+func (r *SqlCommentRepository) Create(comment *Comment) error {
+	return r.db.Omit("User", "Song", "Parent").Create(comment).Error
+}
+
+func (r *SqlCommentRepository) Get(id string) (Comment, error) {
+	var comment Comment
+	if err := r.db.Preload("User").First(&comment, "id = ?", id).Error; err != nil {
+		return Comment{}, err
+	}
+	return comment, nil
+}
+
+func (r *SqlCommentRepository) Update(comment *Comment) error {
+	return r.db.Model(comment).Select("Body", "Deleted", "Hidden", "EditedAt").Updates(comment).Error
+}
+
+func (r *SqlCommentRepository) Delete(id string) error {
+	return r.db.Where("id = ?", id).Delete(&Comment{}).Error
+}
+
+func (r *SqlCommentRepository) GetSong(id string) (Song, error) {
+	var song Song
+	if err := r.db.First(&song, "id = ?", id).Error; err != nil {
+		return Song{}, err
+	}
+	return song, nil
+}
+
+func (r *SqlCommentRepository) GetComments(songId, viewerId string, query Query, page, limit int) ([]Comment, int64, error) {
+	db := r.visible(viewerId).Where("song_id = ? AND parent_id IS NULL", songId)
+	if query.Timed {
+		db = db.Where("at_ms IS NOT NULL")
+	}
+	if query.FromMs != nil {
+		db = db.Where("at_ms >= ?", *query.FromMs)
+	}
+	if query.ToMs != nil {
+		db = db.Where("at_ms <= ?", *query.ToMs)
+	}
+
+	switch query.Sort {
+	case SortOldest:
+		db = db.Order("created_at ASC")
+	case SortTimestamp:
+		db = db.Order("at_ms IS NULL, at_ms ASC, created_at ASC")
+	default:
+		db = db.Order("created_at DESC")
+	}
+	return r.page(db, page, limit)
+}
+
+func (r *SqlCommentRepository) GetReplies(parentId, viewerId string, page, limit int) ([]Comment, int64, error) {
+	db := r.visible(viewerId).Where("parent_id = ?", parentId).Order("created_at ASC")
+	return r.page(db, page, limit)
+}
+
+func (r *SqlCommentRepository) HasReplies(id string) (bool, error) {
+	var count int64
+	err := r.db.Model(&Comment{}).Where("parent_id = ?", id).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *SqlCommentRepository) CountReplies(parentIds []uuid.UUID, viewerId string) (map[uuid.UUID]int64, error) {
+	var rows []struct {
+		ParentId uuid.UUID
+		Count    int64
+	}
+	err := r.visible(viewerId).
+		Select("parent_id, COUNT(*) AS count").
+		Where("parent_id IN ?", parentIds).
+		Group("parent_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[uuid.UUID]int64, len(rows))
+	for _, row := range rows {
+		counts[row.ParentId] = row.Count
+	}
+	return counts, nil
+}
+
+// visible scopes comments to the ones the viewer can see: everything not hidden, and their own
+func (r *SqlCommentRepository) visible(viewerId string) *gorm.DB {
+	return r.db.Model(&Comment{}).Where("hidden = ? OR user_id = ?", false, viewerId)
+}
+
+func (r *SqlCommentRepository) page(db *gorm.DB, page, limit int) ([]Comment, int64, error) {
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var comments []Comment
+	err := db.Preload("User").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&comments).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return comments, total, nil
+}
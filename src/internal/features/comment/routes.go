@@ -0,0 +1,19 @@
+package comment
+
+import "github.com/gin-gonic/gin"
+
+func SetupRoutes(c *gin.RouterGroup, h *CommentHandler, authMiddleware gin.HandlerFunc) {
+	c.Use(authMiddleware)
+
+	c.GET("/:id", h.GetById)
+	c.GET("/:id/replies", h.GetReplies)
+	c.PUT("/:id", h.Update)
+	c.DELETE("/:id", h.Delete)
+}
+
+func SetupSongRoutes(c *gin.RouterGroup, h *CommentHandler, authMiddleware gin.HandlerFunc) {
+	c.Use(authMiddleware)
+
+	c.GET("/:id/comments", h.GetSongComments)
+	c.POST("/:id/comments", h.Create)
+}
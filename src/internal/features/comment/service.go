@@ -0,0 +1,252 @@
+package comment
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type CommentService struct {
+	repo      CommentRepository
+	moderator Moderator
+}
+
+// NewCommentService creates the service, moderator may be nil to post comments unscreened
+func NewCommentService(repo CommentRepository, moderator Moderator) *CommentService {
+	return &CommentService{repo: repo, moderator: moderator}
+}
+
+// Create posts a comment on a song. Replies go under parentId, or under its parent when
+// parentId is itself a reply; only top-level comments take a timestamp
+func (s *CommentService) Create(userId, songId string, parentId *string, atMs *int64, body string) (Comment, error) {
+	userUUID, err := uuid.Parse(userId)
+	if err != nil {
+		return Comment{}, err
+	}
+
+	song, err := s.getSong(userId, songId)
+	if err != nil {
+		return Comment{}, err
+	}
+
+	var parentUUID *uuid.UUID
+	if parentId != nil {
+		if atMs != nil {
+			return Comment{}, ErrReplyTimestamp
+		}
+		parent, err := s.Get(userId, *parentId)
+		if err != nil {
+			return Comment{}, err
+		}
+		if parent.SongId != song.Id {
+			return Comment{}, ErrWrongSong
+		}
+		if parent.ParentId != nil {
+			parentUUID = parent.ParentId
+		} else {
+			parentUUID = &parent.Id
+		}
+	}
+
+	if atMs != nil && (*atMs < 0 || song.Duration > 0 && *atMs > int64(song.Duration)*1000) {
+		return Comment{}, ErrInvalidTimestamp
+	}
+
+	comment, err := NewComment(song.Id, userUUID, parentUUID, atMs, body)
+	if err != nil {
+		return Comment{}, err
+	}
+	if err := s.screen(userId, song.Id, comment.Body); err != nil {
+		return Comment{}, err
+	}
+
+	if err := s.repo.Create(comment); err != nil {
+		return Comment{}, err
+	}
+	return s.Get(userId, comment.Id.String())
+}
+
+// Get returns a comment with its reply count. Hidden comments are only found by their author
+func (s *CommentService) Get(userId, id string) (Comment, error) {
+	comment, err := s.getComment(id)
+	if err != nil {
+		return Comment{}, err
+	}
+	if comment.Hidden && comment.UserId.String() != userId {
+		return Comment{}, ErrCommentNotFound
+	}
+
+	comments := []Comment{comment}
+	if err := s.countReplies(userId, comments); err != nil {
+		return Comment{}, err
+	}
+	return comments[0], nil
+}
+
+// GetComments lists the top-level comments of a song, each with its reply count
+func (s *CommentService) GetComments(userId, songId string, query Query, page, limit int) ([]Comment, int64, error) {
+	if _, err := s.getSong(userId, songId); err != nil {
+		return nil, 0, err
+	}
+
+	comments, total, err := s.repo.GetComments(songId, userId, query, page, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := s.countReplies(userId, comments); err != nil {
+		return nil, 0, err
+	}
+	return comments, total, nil
+}
+
+// GetReplies lists the replies to a comment, oldest first
+func (s *CommentService) GetReplies(userId, id string, page, limit int) ([]Comment, int64, error) {
+	if _, err := s.Get(userId, id); err != nil {
+		return nil, 0, err
+	}
+	return s.repo.GetReplies(id, userId, page, limit)
+}
+
+// Update changes the text of a comment, only its author can
+func (s *CommentService) Update(userId, id, body string) (Comment, error) {
+	comment, err := s.getComment(id)
+	if err != nil {
+		return Comment{}, err
+	}
+	if comment.UserId.String() != userId {
+		return Comment{}, ErrNotAuthor
+	}
+	if comment.Deleted {
+		return Comment{}, ErrCommentDeleted
+	}
+
+	body, err = NormalizeBody(body)
+	if err != nil {
+		return Comment{}, err
+	}
+	if body == comment.Body {
+		return s.Get(userId, id)
+	}
+	if err := s.screen(userId, comment.SongId, body); err != nil {
+		return Comment{}, err
+	}
+
+	now := time.Now()
+	comment.Body = body
+	comment.EditedAt = &now
+	if err := s.repo.Update(&comment); err != nil {
+		return Comment{}, err
+	}
+	return s.Get(userId, id)
+}
+
+// Delete removes a comment. Its author can, and so can the artist of the song it's on.
+// A comment with replies keeps its place in the thread without its text
+func (s *CommentService) Delete(userId, id string) error {
+	comment, err := s.getComment(id)
+	if err != nil {
+		return err
+	}
+	if comment.UserId.String() != userId {
+		song, err := s.getSong(userId, comment.SongId.String())
+		if err != nil {
+			return err
+		}
+		if song.ArtistId.String() != userId {
+			return ErrNotAllowed
+		}
+	}
+
+	if comment.ParentId == nil {
+		replied, err := s.repo.HasReplies(id)
+		if err != nil {
+			return err
+		}
+		if replied {
+			comment.Body = ""
+			comment.Deleted = true
+			return s.repo.Update(&comment)
+		}
+		return s.repo.Delete(id)
+	}
+
+	if err := s.repo.Delete(id); err != nil {
+		return err
+	}
+	// A deleted comment only stayed for its replies, it goes with the last one
+	parent, err := s.getComment(comment.ParentId.String())
+	if err != nil || !parent.Deleted {
+		return nil
+	}
+	replied, err := s.repo.HasReplies(parent.Id.String())
+	if err != nil {
+		return err
+	}
+	if !replied {
+		return s.repo.Delete(parent.Id.String())
+	}
+	return nil
+}
+
+func (s *CommentService) screen(userId string, songId uuid.UUID, body string) error {
+	if s.moderator == nil {
+		return nil
+	}
+
+	allowed, err := s.moderator.Screen(userId, songId, body)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return ErrCommentRejected
+	}
+	return nil
+}
+
+// countReplies fills in how many replies the viewer can see under each comment
+func (s *CommentService) countReplies(userId string, comments []Comment) error {
+	var ids []uuid.UUID
+	for _, comment := range comments {
+		if comment.ParentId == nil {
+			ids = append(ids, comment.Id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	counts, err := s.repo.CountReplies(ids, userId)
+	if err != nil {
+		return err
+	}
+	for i := range comments {
+		comments[i].ReplyCount = counts[comments[i].Id]
+	}
+	return nil
+}
+
+func (s *CommentService) getComment(id string) (Comment, error) {
+	comment, err := s.repo.Get(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return Comment{}, ErrCommentNotFound
+	}
+	return comment, err
+}
+
+func (s *CommentService) getSong(userId, id string) (Song, error) {
+	song, err := s.repo.GetSong(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return Song{}, ErrSongNotFound
+	}
+	if err != nil {
+		return Song{}, err
+	}
+
+	// Songs moderation pulled are only seen by their artist
+	if song.Status != "active" && song.ArtistId.String() != userId {
+		return Song{}, ErrSongNotFound
+	}
+	return song, nil
+}
@@ -0,0 +1,88 @@
+package embed
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yosp313/gotify/src/internal/utils"
+)
+
+type EmbedHandler struct {
+	service *EmbedService
+	// Base URL of the players and streams, taken from each request when empty
+	baseUrl string
+}
+
+func NewEmbedHandler(service *EmbedService, baseUrl string) *EmbedHandler {
+	return &EmbedHandler{service: service, baseUrl: baseUrl}
+}
+
+// Song serves the embedded player of a song. Like everything embedded it needs no login
+func (h *EmbedHandler) Song(c *gin.Context) {
+	embed, err := h.service.GetSong(c.Param("id"))
+	if err != nil {
+		handleEmbedError(c, err, "Failed to embed song")
+		return
+	}
+	h.writePage(c, embed)
+}
+
+func (h *EmbedHandler) Playlist(c *gin.Context) {
+	embed, err := h.service.GetPlaylist(c.Param("id"))
+	if err != nil {
+		// Private playlists look like they don't exist
+		if errors.Is(err, ErrPrivatePlaylist) {
+			err = ErrPlaylistNotFound
+		}
+		handleEmbedError(c, err, "Failed to embed playlist")
+		return
+	}
+	h.writePage(c, embed)
+}
+
+// OEmbed tells sites and chat tools how to embed a song or playlist link
+func (h *EmbedHandler) OEmbed(c *gin.Context) {
+	if format := c.Query("format"); format != "" && format != "json" {
+		utils.HandleErrorWithMessage(c, ErrUnsupportedFormat, "Unsupported format", 501)
+		return
+	}
+
+	embed, err := h.service.Resolve(c.Query("url"))
+	if err != nil {
+		handleEmbedError(c, err, "Failed to embed link")
+		return
+	}
+
+	maxWidth, _ := strconv.Atoi(c.Query("maxwidth"))
+	maxHeight, _ := strconv.Atoi(c.Query("maxheight"))
+	baseUrl, scope := utils.PublicBaseUrl(c, h.baseUrl)
+	c.Header("Cache-Control", scope+", max-age="+strconv.Itoa(CacheAge))
+	c.JSON(200, NewOEmbed(embed, baseUrl, maxWidth, maxHeight))
+}
+
+func (h *EmbedHandler) writePage(c *gin.Context, embed Embed) {
+	baseUrl, scope := utils.PublicBaseUrl(c, h.baseUrl)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Header("Cache-Control", scope+", max-age="+strconv.Itoa(CacheAge))
+	c.Status(200)
+	if err := WritePage(c.Writer, embed, baseUrl); err != nil {
+		c.Error(err)
+	}
+}
+
+func handleEmbedError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, ErrSongNotFound):
+		c.JSON(404, gin.H{"error": "Song not found"})
+	case errors.Is(err, ErrPlaylistNotFound):
+		c.JSON(404, gin.H{"error": "Playlist not found"})
+	case errors.Is(err, ErrUnsupportedUrl):
+		utils.HandleErrorWithMessage(c, err, message, 404)
+	case errors.Is(err, ErrPrivatePlaylist):
+		// oEmbed answers 401 for links to private things
+		utils.HandleErrorWithMessage(c, err, message, 401)
+	default:
+		utils.HandleErrorWithMessage(c, err, message, 500)
+	}
+}
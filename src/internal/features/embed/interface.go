@@ -0,0 +1,24 @@
+package embed
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type EmbedRepository interface {
+	GetSong(id string) (Song, error)
+	GetSongs(ids []uuid.UUID) ([]Song, error)
+	GetPlaylist(id string) (Playlist, error)
+}
+
+// SongSource resolves the songs of a playlist as its owner sees them, the playlist feature
+// provides it
+type SongSource interface {
+	SongIds(userId, playlistId string) ([]uuid.UUID, error)
+}
+
+// StreamSigner signs links that stream a song without a login, the share feature provides it
+type StreamSigner interface {
+	PublicStreamToken(songId string) (string, time.Time, error)
+}
@@ -0,0 +1,98 @@
+package embed
+
+import (
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+// How big embedded players are, sites can ask for smaller ones
+const (
+	Width          = 480
+	SongHeight     = 152
+	PlaylistHeight = 400
+)
+
+// What can be embedded
+const (
+	TypeSong     = "song"
+	TypePlaylist = "playlist"
+)
+
+var (
+	ErrSongNotFound      = errors.New("song not found")
+	ErrPlaylistNotFound  = errors.New("playlist not found")
+	ErrPrivatePlaylist   = errors.New("only public playlists can be embedded")
+	ErrUnsupportedUrl    = errors.New("url isn't a song or playlist")
+	ErrUnsupportedFormat = errors.New("only the json format is supported")
+)
+
+// Embed is a public song or playlist as an embedded player shows it
+type Embed struct {
+	Type   string
+	Id     uuid.UUID
+	Title  string
+	Author string
+	Songs  []Song
+}
+
+// Height is how tall the player is unless a site asks for less
+func (e *Embed) Height() int {
+	if e.Type == TypePlaylist {
+		return PlaylistHeight
+	}
+	return SongHeight
+}
+
+// Path is where the embedded player lives
+func (e *Embed) Path() string {
+	return "/embed/" + e.Type + "s/" + e.Id.String()
+}
+
+// OEmbed is the oEmbed 1.0 response describing an embedded player
+type OEmbed struct {
+	Version      string `json:"version"`
+	Type         string `json:"type"`
+	ProviderName string `json:"provider_name"`
+	ProviderUrl  string `json:"provider_url"`
+	Title        string `json:"title"`
+	AuthorName   string `json:"author_name"`
+	Html         string `json:"html"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	CacheAge     int    `json:"cache_age"`
+}
+
+type User struct {
+	Id       uuid.UUID
+	FullName string
+}
+
+type Song struct {
+	Id       uuid.UUID
+	Title    string
+	ArtistId uuid.UUID
+	Duration int
+	Status   string
+	Public   bool
+	// Signed link the player streams the song with
+	StreamToken string `gorm:"-"`
+
+	// Relationships
+	Artist *User `gorm:"foreignKey:ArtistId;references:Id"`
+}
+
+// IsAvailable tells whether the artist made the song public and moderators left it up
+func (s *Song) IsAvailable() bool {
+	return s.Public && s.Status == "active"
+}
+
+type Playlist struct {
+	Id      uuid.UUID
+	Name    string
+	OwnerId uuid.UUID
+	Public  bool
+
+	// Relationships
+	Owner *User `gorm:"foreignKey:OwnerId;references:Id"`
+}
@@ -0,0 +1,117 @@
+package embed
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"net/url"
+)
+
+const providerName = "Gotify"
+
+// CacheAge is how long, in seconds, pages and oEmbed responses can be cached. Stream links
+// last hours, so a cached page still plays
+const CacheAge = 300
+
+var page = template.Must(template.New("embed").Funcs(template.FuncMap{
+	"duration": func(seconds int) string { return fmt.Sprintf("%d:%02d", seconds/60, seconds%60) },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<meta property="og:site_name" content="` + providerName + `">
+<meta property="og:title" content="{{.Title}}">
+{{with .Author}}<meta property="og:description" content="By {{.}}">{{end}}
+<link rel="alternate" type="application/json+oembed" href="{{.OEmbedUrl}}" title="{{.Title}}">
+<style>
+body { margin: 0; padding: 12px; font-family: system-ui, sans-serif; font-size: 14px; color: #111; background: #fff; }
+h1 { margin: 0; font-size: 16px; }
+p { margin: 2px 0 10px; color: #666; }
+ol { margin: 0; padding: 0; list-style: none; }
+li { padding: 6px 0; border-top: 1px solid #eee; }
+li span { color: #666; float: right; }
+audio { display: block; width: 100%; height: 32px; margin-top: 4px; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{with .Author}}<p>{{.}}</p>{{end}}
+{{if eq .Type "song"}}{{with index .Songs 0}}<audio controls preload="none" src="{{.StreamUrl}}"></audio>{{end}}
+{{else}}<ol>
+{{range .Songs}}<li>{{.Title}}{{with .Artist}} · {{.FullName}}{{end}} <span>{{duration .Duration}}</span>
+<audio controls preload="none" src="{{.StreamUrl}}"></audio></li>
+{{else}}<li>This playlist is empty</li>
+{{end}}</ol>
+{{end}}</body>
+</html>
+`))
+
+type pageData struct {
+	Type      string
+	Title     string
+	Author    string
+	OEmbedUrl string
+	Songs     []pageSong
+}
+
+type pageSong struct {
+	Title     string
+	Duration  int
+	Artist    *User
+	StreamUrl string
+}
+
+// WritePage renders the embedded player, it streams through the share links under baseUrl
+func WritePage(w io.Writer, embed Embed, baseUrl string) error {
+	data := pageData{
+		Type:      embed.Type,
+		Title:     embed.Title,
+		Author:    embed.Author,
+		OEmbedUrl: OEmbedUrl(baseUrl, embed),
+		Songs:     make([]pageSong, len(embed.Songs)),
+	}
+	for i, song := range embed.Songs {
+		data.Songs[i] = pageSong{
+			Title:     song.Title,
+			Duration:  song.Duration,
+			Artist:    song.Artist,
+			StreamUrl: baseUrl + "/shared/" + song.StreamToken + "/stream",
+		}
+	}
+	return page.Execute(w, data)
+}
+
+// NewOEmbed describes the embedded player as an iframe, no bigger than maxWidth and maxHeight
+// when they're above 0
+func NewOEmbed(embed Embed, baseUrl string, maxWidth, maxHeight int) OEmbed {
+	width, height := Width, embed.Height()
+	if maxWidth > 0 {
+		width = min(width, maxWidth)
+	}
+	if maxHeight > 0 {
+		height = min(height, maxHeight)
+	}
+
+	iframe := fmt.Sprintf(`<iframe src="%s" width="%d" height="%d" title="%s" frameborder="0" allow="autoplay"></iframe>`,
+		html.EscapeString(baseUrl+embed.Path()), width, height, html.EscapeString(embed.Title))
+	return OEmbed{
+		Version:      "1.0",
+		Type:         "rich",
+		ProviderName: providerName,
+		ProviderUrl:  baseUrl,
+		Title:        embed.Title,
+		AuthorName:   embed.Author,
+		Html:         iframe,
+		Width:        width,
+		Height:       height,
+		CacheAge:     CacheAge,
+	}
+}
+
+// OEmbedUrl is where oEmbed consumers find out how to embed the player
+func OEmbedUrl(baseUrl string, embed Embed) string {
+	return baseUrl + "/oembed?format=json&url=" + url.QueryEscape(baseUrl+embed.Path())
+}
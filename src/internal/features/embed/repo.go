@@ -0,0 +1,60 @@
+package embed
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type SqlEmbedRepository struct {
+	db *gorm.DB
+}
+
+func NewSqlEmbedRepository(db *gorm.DB) *SqlEmbedRepository {
+	return &SqlEmbedRepository{db: db}
+}
+
+func (r *SqlEmbedRepository) GetSong(id string) (Song, error) {
+	var song Song
+	if err := r.db.Preload("Artist").First(&song, "id = ?", id).Error; err != nil {
+		return Song{}, err
+	}
+	return song, nil
+}
+
+// GetSongs loads the songs that can be played in the order of ids, others are skipped
+func (r *SqlEmbedRepository) GetSongs(ids []uuid.UUID) ([]Song, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	var found []Song
+	if err := r.db.Scopes(available).Preload("Artist").Where("id IN ?", ids).Find(&found).Error; err != nil {
+		return nil, err
+	}
+
+	byId := make(map[uuid.UUID]Song, len(found))
+	for _, song := range found {
+		byId[song.Id] = song
+	}
+
+	songs := make([]Song, 0, len(ids))
+	for _, id := range ids {
+		if song, ok := byId[id]; ok {
+			songs = append(songs, song)
+		}
+	}
+	return songs, nil
+}
+
+func (r *SqlEmbedRepository) GetPlaylist(id string) (Playlist, error) {
+	var playlist Playlist
+	if err := r.db.Preload("Owner").First(&playlist, "id = ?", id).Error; err != nil {
+		return Playlist{}, err
+	}
+	return playlist, nil
+}
+
+// available leaves out songs that aren't public and those moderators quarantined or took down
+func available(db *gorm.DB) *gorm.DB {
+	return db.Where("songs.status = ? AND songs.public = ?", "active", true)
+}
@@ -0,0 +1,14 @@
+package embed
+
+import "github.com/gin-gonic/gin"
+
+// SetupRoutes serves embedded players without a login, outside the API so other sites can
+// frame them with short URLs
+func SetupRoutes(c *gin.RouterGroup, h *EmbedHandler) {
+	c.GET("/songs/:id", h.Song)
+	c.GET("/playlists/:id", h.Playlist)
+}
+
+func SetupOEmbedRoutes(c *gin.RouterGroup, h *EmbedHandler) {
+	c.GET("", h.OEmbed)
+}
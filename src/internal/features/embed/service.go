@@ -0,0 +1,106 @@
+package embed
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type EmbedService struct {
+	repo    EmbedRepository
+	songs   SongSource
+	streams StreamSigner
+}
+
+func NewEmbedService(repo EmbedRepository, songs SongSource, streams StreamSigner) *EmbedService {
+	return &EmbedService{repo: repo, songs: songs, streams: streams}
+}
+
+// GetSong embeds a song the artist made public, unless moderators pulled it
+func (s *EmbedService) GetSong(id string) (Embed, error) {
+	song, err := s.repo.GetSong(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && !song.IsAvailable()) {
+		return Embed{}, ErrSongNotFound
+	}
+	if err != nil {
+		return Embed{}, err
+	}
+
+	embed := Embed{Type: TypeSong, Id: song.Id, Title: song.Title, Author: artistName(song), Songs: []Song{song}}
+	return s.withStreams(embed)
+}
+
+// GetPlaylist embeds a public playlist with the public songs its owner sees in it
+func (s *EmbedService) GetPlaylist(id string) (Embed, error) {
+	playlist, err := s.repo.GetPlaylist(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return Embed{}, ErrPlaylistNotFound
+	}
+	if err != nil {
+		return Embed{}, err
+	}
+	if !playlist.Public {
+		return Embed{}, ErrPrivatePlaylist
+	}
+
+	ids, err := s.songs.SongIds(playlist.OwnerId.String(), playlist.Id.String())
+	if err != nil {
+		return Embed{}, err
+	}
+	songs, err := s.repo.GetSongs(ids)
+	if err != nil {
+		return Embed{}, err
+	}
+
+	embed := Embed{Type: TypePlaylist, Id: playlist.Id, Title: playlist.Name, Songs: songs}
+	if playlist.Owner != nil {
+		embed.Author = playlist.Owner.FullName
+	}
+	return s.withStreams(embed)
+}
+
+// Resolve embeds what a link points to, for oEmbed. It takes any URL with songs/:id or
+// playlists/:id in its path, like the embed pages and the web app's pages
+func (s *EmbedService) Resolve(link string) (Embed, error) {
+	parsed, err := url.Parse(link)
+	if err != nil {
+		return Embed{}, ErrUnsupportedUrl
+	}
+
+	segments := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+	for i := len(segments) - 2; i >= 0; i-- {
+		id, err := uuid.Parse(segments[i+1])
+		if err != nil {
+			continue
+		}
+		switch segments[i] {
+		case "songs":
+			return s.GetSong(id.String())
+		case "playlists":
+			return s.GetPlaylist(id.String())
+		}
+	}
+	return Embed{}, ErrUnsupportedUrl
+}
+
+// withStreams signs a stream link for each song, players can't log in
+func (s *EmbedService) withStreams(embed Embed) (Embed, error) {
+	for i := range embed.Songs {
+		token, _, err := s.streams.PublicStreamToken(embed.Songs[i].Id.String())
+		if err != nil {
+			return Embed{}, err
+		}
+		embed.Songs[i].StreamToken = token
+	}
+	return embed, nil
+}
+
+func artistName(song Song) string {
+	if song.Artist == nil {
+		return ""
+	}
+	return song.Artist.FullName
+}
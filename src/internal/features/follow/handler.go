@@ -0,0 +1,93 @@
+package follow
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yosp313/gotify/src/internal/utils"
+)
+
+type FollowHandler struct {
+	service *FollowService
+}
+
+func NewFollowHandler(service *FollowService) *FollowHandler {
+	return &FollowHandler{service: service}
+}
+
+func (h *FollowHandler) GetProfile(c *gin.Context) {
+	profile, err := h.service.GetProfile(c.GetString("user_id"), c.Param("id"))
+	if err != nil {
+		handleFollowError(c, err, "Failed to retrieve profile")
+		return
+	}
+
+	c.JSON(200, profile)
+}
+
+func (h *FollowHandler) Follow(c *gin.Context) {
+	profile, err := h.service.Follow(c.GetString("user_id"), c.Param("id"))
+	if err != nil {
+		handleFollowError(c, err, "Failed to follow user")
+		return
+	}
+
+	c.JSON(200, profile)
+}
+
+func (h *FollowHandler) Unfollow(c *gin.Context) {
+	profile, err := h.service.Unfollow(c.GetString("user_id"), c.Param("id"))
+	if err != nil {
+		handleFollowError(c, err, "Failed to unfollow user")
+		return
+	}
+
+	c.JSON(200, profile)
+}
+
+func (h *FollowHandler) GetFollowers(c *gin.Context) {
+	page, limit := utils.GetPagination(c)
+
+	users, total, err := h.service.GetFollowers(c.Param("id"), page, limit)
+	if err != nil {
+		handleFollowError(c, err, "Failed to retrieve followers")
+		return
+	}
+
+	c.JSON(200, gin.H{"users": users, "total": total, "page": page, "limit": limit})
+}
+
+func (h *FollowHandler) GetFollowing(c *gin.Context) {
+	page, limit := utils.GetPagination(c)
+
+	users, total, err := h.service.GetFollowing(c.Param("id"), page, limit)
+	if err != nil {
+		handleFollowError(c, err, "Failed to retrieve followed users")
+		return
+	}
+
+	c.JSON(200, gin.H{"users": users, "total": total, "page": page, "limit": limit})
+}
+
+func (h *FollowHandler) GetFeed(c *gin.Context) {
+	page, limit := utils.GetPagination(c)
+
+	activities, total, err := h.service.GetFeed(c.GetString("user_id"), page, limit)
+	if err != nil {
+		utils.HandleErrorWithMessage(c, err, "Failed to retrieve feed", 500)
+		return
+	}
+
+	c.JSON(200, gin.H{"activities": activities, "total": total, "page": page, "limit": limit})
+}
+
+func handleFollowError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, ErrUserNotFound):
+		c.JSON(404, gin.H{"error": "User not found"})
+	case errors.Is(err, ErrFollowSelf), errors.Is(err, ErrNotAnArtist):
+		utils.HandleErrorWithMessage(c, err, message, 400)
+	default:
+		utils.HandleErrorWithMessage(c, err, message, 500)
+	}
+}
@@ -0,0 +1,19 @@
+package follow
+
+import "github.com/google/uuid"
+
+type FollowRepository interface {
+	Follow(follow *Follow) error
+	Unfollow(followerId, followeeId string) error
+	IsFollowing(followerId, followeeId string) (bool, error)
+	GetUser(id string) (User, error)
+	HasSongs(userId string) (bool, error)
+	CountFollowers(userId string) (int64, error)
+	CountFollowing(userId string) (int64, error)
+	GetFollowers(userId string, page, limit int) ([]User, int64, error)
+	GetFollowing(userId string, page, limit int) ([]User, int64, error)
+	GetFolloweeIds(userId string) ([]uuid.UUID, error)
+	GetRecentUploads(artistIds []uuid.UUID, limit int) ([]Song, error)
+	GetRecentPlaylists(ownerIds []uuid.UUID, limit int) ([]Playlist, error)
+	GetRecentLikes(userIds []uuid.UUID, limit int) ([]Like, error)
+}
@@ -0,0 +1,119 @@
+package follow
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// FeedWindow is how many of the latest activities a feed holds, older ones drop off
+	FeedWindow = 300
+	// FeedTtl is how long a built feed is served before it's built again
+	FeedTtl = time.Minute
+)
+
+// Activity types
+const (
+	ActivityUpload   = "upload"
+	ActivityPlaylist = "playlist"
+	ActivityLike     = "like"
+)
+
+var (
+	ErrUserNotFound = errors.New("user not found")
+	ErrFollowSelf   = errors.New("you can't follow yourself")
+	ErrNotAnArtist  = errors.New("only users who upload songs can be followed")
+)
+
+// Follow means the follower sees the followee's activity in their feed
+type Follow struct {
+	FollowerId uuid.UUID `json:"follower_id" db:"follower_id" gorm:"primaryKey"`
+	FolloweeId uuid.UUID `json:"followee_id" db:"followee_id" gorm:"primaryKey;index"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+
+	// Relationships
+	Follower User `json:"-" gorm:"foreignKey:FollowerId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Followee User `json:"-" gorm:"foreignKey:FolloweeId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+// Activity is one entry of a feed: someone followed uploaded a song, published a playlist or
+// liked a song
+type Activity struct {
+	Type     string    `json:"type"`
+	At       time.Time `json:"at"`
+	Actor    User      `json:"actor"`
+	Song     *Song     `json:"song,omitempty"`
+	Playlist *Playlist `json:"playlist,omitempty"`
+}
+
+// Profile is a user with their follow counts
+type Profile struct {
+	User
+	Followers int64 `json:"followers"`
+	Following int64 `json:"following"`
+	// Whether the user asking follows them
+	Followed bool `json:"followed"`
+}
+
+type User struct {
+	Id       uuid.UUID `json:"id"`
+	FullName string    `json:"full_name"`
+}
+
+type Song struct {
+	Id        uuid.UUID `json:"id"`
+	Title     string    `json:"title"`
+	ArtistId  uuid.UUID `json:"artist_id"`
+	Duration  int       `json:"duration"`
+	Genre     string    `json:"genre"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// Relationships
+	Artist User `json:"artist" gorm:"foreignKey:ArtistId;references:Id"`
+}
+
+type Playlist struct {
+	Id          uuid.UUID `json:"id"`
+	OwnerId     uuid.UUID `json:"owner_id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Public      bool      `json:"-"`
+	CreatedAt   time.Time `json:"created_at"`
+
+	// Relationships
+	Owner User `json:"-" gorm:"foreignKey:OwnerId;references:Id"`
+}
+
+type Like struct {
+	UserId    uuid.UUID
+	SongId    uuid.UUID
+	CreatedAt time.Time
+
+	// Relationships
+	User User `gorm:"foreignKey:UserId;references:Id"`
+	Song Song `gorm:"foreignKey:SongId;references:Id"`
+}
+
+func NewFollow(followerId, followeeId uuid.UUID) *Follow {
+	return &Follow{FollowerId: followerId, FolloweeId: followeeId}
+}
+
+// Merge interleaves uploads, playlists and likes newest first, keeping at most limit
+func Merge(songs []Song, playlists []Playlist, likes []Like, limit int) []Activity {
+	activities := make([]Activity, 0, len(songs)+len(playlists)+len(likes))
+	for _, song := range songs {
+		activities = append(activities, Activity{Type: ActivityUpload, At: song.CreatedAt, Actor: song.Artist, Song: &song})
+	}
+	for _, playlist := range playlists {
+		activities = append(activities, Activity{Type: ActivityPlaylist, At: playlist.CreatedAt, Actor: playlist.Owner, Playlist: &playlist})
+	}
+	for _, like := range likes {
+		activities = append(activities, Activity{Type: ActivityLike, At: like.CreatedAt, Actor: like.User, Song: &like.Song})
+	}
+
+	sort.SliceStable(activities, func(i, j int) bool { return activities[i].At.After(activities[j].At) })
+	return activities[:min(len(activities), limit)]
+}
@@ -0,0 +1,130 @@
+package follow
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type SqlFollowRepository struct {
+	db *gorm.DB
+}
+
+func NewSqlFollowRepository(db *gorm.DB) *SqlFollowRepository {
+	return &SqlFollowRepository{db: db}
+}
+
+func (r *SqlFollowRepository) Follow(follow *Follow) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Omit("Follower", "Followee").Create(follow).Error
+}
+
+func (r *SqlFollowRepository) Unfollow(followerId, followeeId string) error {
+	return r.db.Where("follower_id = ? AND followee_id = ?", followerId, followeeId).Delete(&Follow{}).Error
+}
+
+func (r *SqlFollowRepository) IsFollowing(followerId, followeeId string) (bool, error) {
+	var count int64
+	err := r.db.Model(&Follow{}).Where("follower_id = ? AND followee_id = ?", followerId, followeeId).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *SqlFollowRepository) GetUser(id string) (User, error) {
+	var user User
+	if err := r.db.First(&user, "id = ?", id).Error; err != nil {
+		return User{}, err
+	}
+	return user, nil
+}
+
+func (r *SqlFollowRepository) HasSongs(userId string) (bool, error) {
+	var count int64
+	err := r.db.Model(&Song{}).Scopes(available).Where("artist_id = ?", userId).Limit(1).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *SqlFollowRepository) CountFollowers(userId string) (int64, error) {
+	var count int64
+	err := r.db.Model(&Follow{}).Where("followee_id = ?", userId).Count(&count).Error
+	return count, err
+}
+
+func (r *SqlFollowRepository) CountFollowing(userId string) (int64, error) {
+	var count int64
+	err := r.db.Model(&Follow{}).Where("follower_id = ?", userId).Count(&count).Error
+	return count, err
+}
+
+func (r *SqlFollowRepository) GetFollowers(userId string, page, limit int) ([]User, int64, error) {
+	return r.pageUsers("follows.follower_id", "follows.followee_id = ?", userId, page, limit)
+}
+
+func (r *SqlFollowRepository) GetFollowing(userId string, page, limit int) ([]User, int64, error) {
+	return r.pageUsers("follows.followee_id", "follows.follower_id = ?", userId, page, limit)
+}
+
+func (r *SqlFollowRepository) GetFolloweeIds(userId string) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := r.db.Model(&Follow{}).Where("follower_id = ?", userId).Pluck("followee_id", &ids).Error
+	return ids, err
+}
+
+func (r *SqlFollowRepository) GetRecentUploads(artistIds []uuid.UUID, limit int) ([]Song, error) {
+	var songs []Song
+	err := r.db.Scopes(available).
+		Preload("Artist").
+		Where("artist_id IN ?", artistIds).
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&songs).Error
+	return songs, err
+}
+
+func (r *SqlFollowRepository) GetRecentPlaylists(ownerIds []uuid.UUID, limit int) ([]Playlist, error) {
+	var playlists []Playlist
+	err := r.db.Preload("Owner").
+		Where("owner_id IN ? AND public = ?", ownerIds, true).
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&playlists).Error
+	return playlists, err
+}
+
+func (r *SqlFollowRepository) GetRecentLikes(userIds []uuid.UUID, limit int) ([]Like, error) {
+	var likes []Like
+	err := r.db.Preload("User").
+		Preload("Song.Artist").
+		Joins("JOIN songs ON songs.id = likes.song_id").
+		Scopes(available).
+		Where("likes.user_id IN ?", userIds).
+		Order("likes.created_at DESC").
+		Limit(limit).
+		Find(&likes).Error
+	return likes, err
+}
+
+// pageUsers lists the users on one side of the follows of userId, most recent follow first
+func (r *SqlFollowRepository) pageUsers(column, condition, userId string, page, limit int) ([]User, int64, error) {
+	query := r.db.Model(&User{}).
+		Joins("JOIN follows ON users.id = "+column).
+		Where(condition, userId)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var users []User
+	err := query.Order("follows.created_at DESC").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&users).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+// available leaves out songs moderators quarantined or took down
+func available(db *gorm.DB) *gorm.DB {
+	return db.Where("songs.status = ?", "active")
+}
@@ -0,0 +1,19 @@
+package follow
+
+import "github.com/gin-gonic/gin"
+
+func SetupRoutes(c *gin.RouterGroup, h *FollowHandler, authMiddleware gin.HandlerFunc) {
+	c.Use(authMiddleware)
+
+	c.GET("/:id/profile", h.GetProfile)
+	c.PUT("/:id/follow", h.Follow)
+	c.DELETE("/:id/follow", h.Unfollow)
+	c.GET("/:id/followers", h.GetFollowers)
+	c.GET("/:id/following", h.GetFollowing)
+}
+
+func SetupFeedRoutes(c *gin.RouterGroup, h *FollowHandler, authMiddleware gin.HandlerFunc) {
+	c.Use(authMiddleware)
+
+	c.GET("/feed", h.GetFeed)
+}
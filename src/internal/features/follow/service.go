@@ -0,0 +1,201 @@
+package follow
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type FollowService struct {
+	repo FollowRepository
+
+	mu    sync.Mutex
+	feeds map[string]*cachedFeed
+}
+
+// cachedFeed is a built feed kept around so paging through it doesn't build it again. One
+// without activities marks a feed dropped at builtAt, which feeds built before can't replace
+type cachedFeed struct {
+	activities []Activity
+	builtAt    time.Time
+}
+
+func NewFollowService(repo FollowRepository) *FollowService {
+	return &FollowService{repo: repo, feeds: map[string]*cachedFeed{}}
+}
+
+// Follow makes the follower see the followee's uploads, public playlists and likes in their
+// feed. Only users who have uploaded songs can be followed
+func (s *FollowService) Follow(followerId, followeeId string) (Profile, error) {
+	followerUUID, err := uuid.Parse(followerId)
+	if err != nil {
+		return Profile{}, err
+	}
+	if followerId == followeeId {
+		return Profile{}, ErrFollowSelf
+	}
+
+	followee, err := s.getUser(followeeId)
+	if err != nil {
+		return Profile{}, err
+	}
+	artist, err := s.repo.HasSongs(followeeId)
+	if err != nil {
+		return Profile{}, err
+	}
+	if !artist {
+		return Profile{}, ErrNotAnArtist
+	}
+
+	if err := s.repo.Follow(NewFollow(followerUUID, followee.Id)); err != nil {
+		return Profile{}, err
+	}
+	s.invalidate(followerId)
+	return s.GetProfile(followerId, followeeId)
+}
+
+func (s *FollowService) Unfollow(followerId, followeeId string) (Profile, error) {
+	if _, err := s.getUser(followeeId); err != nil {
+		return Profile{}, err
+	}
+
+	if err := s.repo.Unfollow(followerId, followeeId); err != nil {
+		return Profile{}, err
+	}
+	s.invalidate(followerId)
+	return s.GetProfile(followerId, followeeId)
+}
+
+// GetProfile returns a user with their follow counts, and whether the viewer follows them
+func (s *FollowService) GetProfile(viewerId, userId string) (Profile, error) {
+	user, err := s.getUser(userId)
+	if err != nil {
+		return Profile{}, err
+	}
+
+	profile := Profile{User: user}
+	if profile.Followers, err = s.repo.CountFollowers(userId); err != nil {
+		return Profile{}, err
+	}
+	if profile.Following, err = s.repo.CountFollowing(userId); err != nil {
+		return Profile{}, err
+	}
+	if profile.Followed, err = s.repo.IsFollowing(viewerId, userId); err != nil {
+		return Profile{}, err
+	}
+	return profile, nil
+}
+
+func (s *FollowService) GetFollowers(userId string, page, limit int) ([]User, int64, error) {
+	if _, err := s.getUser(userId); err != nil {
+		return nil, 0, err
+	}
+	return s.repo.GetFollowers(userId, page, limit)
+}
+
+func (s *FollowService) GetFollowing(userId string, page, limit int) ([]User, int64, error) {
+	if _, err := s.getUser(userId); err != nil {
+		return nil, 0, err
+	}
+	return s.repo.GetFollowing(userId, page, limit)
+}
+
+// GetFeed returns a page of what the people the user follows have been doing, newest first.
+// Feeds are built when read from the latest activity of everyone followed, and kept for
+// FeedTtl so paging doesn't build them again
+func (s *FollowService) GetFeed(userId string, page, limit int) ([]Activity, int64, error) {
+	activities, err := s.feed(userId)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total := int64(len(activities))
+	// Pages past the end are empty. The page is checked before multiplying so a huge one
+	// can't overflow into a negative index
+	start := len(activities)
+	if page-1 < len(activities)/limit+1 {
+		start = min((page-1)*limit, len(activities))
+	}
+	end := min(start+limit, len(activities))
+	return activities[start:end], total, nil
+}
+
+// SweepFeeds drops cached feeds that are too old to be served
+func (s *FollowService) SweepFeeds() error {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for userId, feed := range s.feeds {
+		if now.Sub(feed.builtAt) > FeedTtl {
+			delete(s.feeds, userId)
+		}
+	}
+	return nil
+}
+
+func (s *FollowService) feed(userId string) ([]Activity, error) {
+	s.mu.Lock()
+	cached := s.feeds[userId]
+	s.mu.Unlock()
+	if cached != nil && cached.activities != nil && time.Since(cached.builtAt) <= FeedTtl {
+		return cached.activities, nil
+	}
+
+	builtAt := time.Now()
+	activities, err := s.build(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	if current := s.feeds[userId]; current == nil || current.builtAt.Before(builtAt) {
+		s.feeds[userId] = &cachedFeed{activities: activities, builtAt: builtAt}
+	}
+	s.mu.Unlock()
+	return activities, nil
+}
+
+// build merges the latest uploads, public playlists and likes of everyone the user follows.
+// Each source is cut at FeedWindow, which is all the merged feed can hold anyway
+func (s *FollowService) build(userId string) ([]Activity, error) {
+	ids, err := s.repo.GetFolloweeIds(userId)
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return []Activity{}, nil
+	}
+
+	songs, err := s.repo.GetRecentUploads(ids, FeedWindow)
+	if err != nil {
+		return nil, err
+	}
+	playlists, err := s.repo.GetRecentPlaylists(ids, FeedWindow)
+	if err != nil {
+		return nil, err
+	}
+	likes, err := s.repo.GetRecentLikes(ids, FeedWindow)
+	if err != nil {
+		return nil, err
+	}
+	return Merge(songs, playlists, likes, FeedWindow), nil
+}
+
+// invalidate drops the user's cached feed after who they follow changed
+func (s *FollowService) invalidate(userId string) {
+	s.mu.Lock()
+	s.feeds[userId] = &cachedFeed{builtAt: time.Now()}
+	s.mu.Unlock()
+}
+
+func (s *FollowService) getUser(id string) (User, error) {
+	user, err := s.repo.GetUser(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return User{}, ErrUserNotFound
+	}
+	return user, err
+}
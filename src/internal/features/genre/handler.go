@@ -0,0 +1,125 @@
+package genre
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yosp313/gotify/src/internal/utils"
+)
+
+type GenreHandler struct {
+	service *GenreService
+}
+
+func NewGenreHandler(service *GenreService) *GenreHandler {
+	return &GenreHandler{service: service}
+}
+
+func (h *GenreHandler) GetTree(c *gin.Context) {
+	genres, err := h.service.GetTree()
+	if err != nil {
+		utils.HandleErrorWithMessage(c, err, "Failed to retrieve genres", 500)
+		return
+	}
+
+	c.JSON(200, gin.H{"genres": genres})
+}
+
+func (h *GenreHandler) GetBySlug(c *gin.Context) {
+	genre, err := h.service.Get(c.Param("slug"))
+	if err != nil {
+		handleGenreError(c, err, "Failed to retrieve genre")
+		return
+	}
+
+	c.JSON(200, genre)
+}
+
+func (h *GenreHandler) GetSongs(c *gin.Context) {
+	page, limit := utils.GetPagination(c)
+
+	songs, total, err := h.service.GetSongs(c.Param("slug"), page, limit)
+	if err != nil {
+		handleGenreError(c, err, "Failed to retrieve songs")
+		return
+	}
+
+	c.JSON(200, gin.H{"songs": songs, "total": total, "page": page, "limit": limit})
+}
+
+func (h *GenreHandler) GetTopTags(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 200 {
+		limit = 50
+	}
+
+	tags, err := h.service.TopTags(c.Query("mood") == "true", limit)
+	if err != nil {
+		utils.HandleErrorWithMessage(c, err, "Failed to retrieve tags", 500)
+		return
+	}
+
+	c.JSON(200, gin.H{"tags": tags})
+}
+
+func (h *GenreHandler) GetMoods(c *gin.Context) {
+	c.JSON(200, gin.H{"moods": Moods})
+}
+
+func (h *GenreHandler) GetTaggedSongs(c *gin.Context) {
+	page, limit := utils.GetPagination(c)
+
+	songs, total, err := h.service.GetSongsByTag(c.Param("tag"), page, limit)
+	if err != nil {
+		handleGenreError(c, err, "Failed to retrieve songs")
+		return
+	}
+
+	c.JSON(200, gin.H{"songs": songs, "total": total, "page": page, "limit": limit})
+}
+
+func (h *GenreHandler) GetSongTags(c *gin.Context) {
+	tags, err := h.service.GetSongTags(c.GetString("user_id"), c.Param("id"))
+	if err != nil {
+		handleGenreError(c, err, "Failed to retrieve tags")
+		return
+	}
+
+	c.JSON(200, gin.H{"song_id": c.Param("id"), "tags": tags})
+}
+
+func (h *GenreHandler) AddTag(c *gin.Context) {
+	tags, err := h.service.AddTag(c.GetString("user_id"), c.Param("id"), c.Param("tag"))
+	if err != nil {
+		handleGenreError(c, err, "Failed to tag song")
+		return
+	}
+
+	c.JSON(200, gin.H{"song_id": c.Param("id"), "tags": tags})
+}
+
+func (h *GenreHandler) RemoveTag(c *gin.Context) {
+	tags, err := h.service.RemoveTag(c.GetString("user_id"), c.Param("id"), c.Param("tag"))
+	if err != nil {
+		handleGenreError(c, err, "Failed to untag song")
+		return
+	}
+
+	c.JSON(200, gin.H{"song_id": c.Param("id"), "tags": tags})
+}
+
+func handleGenreError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, ErrGenreNotFound):
+		c.JSON(404, gin.H{"error": "Genre not found"})
+	case errors.Is(err, ErrSongNotFound):
+		c.JSON(404, gin.H{"error": "Song not found"})
+	case errors.Is(err, ErrInvalidTag):
+		utils.HandleErrorWithMessage(c, err, message, 400)
+	case errors.Is(err, ErrTooManyTags):
+		utils.HandleErrorWithMessage(c, err, message, 409)
+	default:
+		utils.HandleErrorWithMessage(c, err, message, 500)
+	}
+}
@@ -0,0 +1,17 @@
+package genre
+
+type GenreRepository interface {
+	Create(genre *Genre) error
+	GetAll() ([]Genre, error)
+	GetBySlug(slug string) (Genre, error)
+	SongGenreCounts() (map[string]int64, error)
+	RenameSongGenre(from, to string) error
+	GetSongsByGenres(names []string, page, limit int) ([]Song, int64, error)
+	GetSong(id string) (Song, error)
+	AddTag(tag *Tag) error
+	RemoveTag(userId, songId, name string) error
+	CountUserTags(userId, songId string) (int64, error)
+	GetSongTags(songId string) ([]Tag, error)
+	TopTags(names []string, limit int) ([]TagCount, error)
+	GetSongsByTag(name string, page, limit int) ([]Song, int64, error)
+}
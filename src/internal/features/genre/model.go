@@ -0,0 +1,136 @@
+package genre
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+const (
+	MaxTagLength   = 40
+	MaxTagsPerSong = 20 // per listener
+)
+
+var (
+	ErrGenreNotFound = errors.New("genre not found")
+	ErrSongNotFound  = errors.New("song not found")
+	ErrInvalidGenre  = errors.New("genre name needs a letter or digit")
+	ErrInvalidTag    = errors.New("tags are up to 40 letters, digits, spaces, dashes, ampersands or apostrophes")
+	ErrTooManyTags   = errors.New("you can put at most 20 tags on a song")
+)
+
+// Genre is a node of the genre tree. Songs belong to a genre by name, so a genre's songs are
+// those whose genre is its name or the name of one of its subgenres
+type Genre struct {
+	Id        uuid.UUID  `json:"id" db:"id" gorm:"primaryKey"`
+	Name      string     `json:"name" db:"name" gorm:"not null"`
+	Slug      string     `json:"slug" db:"slug" gorm:"uniqueIndex;not null"`
+	ParentId  *uuid.UUID `json:"parent_id" db:"parent_id" gorm:"index"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+
+	// Filled in by the service, the song count includes the subgenres' songs
+	SongCount int64    `json:"song_count" gorm:"-"`
+	Children  []*Genre `json:"children,omitempty" gorm:"-"`
+}
+
+// Tag is a free-form word a listener put on a song. Tags by the song's own artist are marked
+// as such, so clients can tell how the artist describes their music
+type Tag struct {
+	SongId    uuid.UUID `json:"song_id" db:"song_id" gorm:"primaryKey"`
+	UserId    uuid.UUID `json:"user_id" db:"user_id" gorm:"primaryKey;index"`
+	Name      string    `json:"name" db:"name" gorm:"primaryKey;index"`
+	ByArtist  bool      `json:"by_artist" db:"by_artist" gorm:"not null"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+
+	// Relationships
+	Song Song `json:"-" gorm:"foreignKey:SongId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	User User `json:"-" gorm:"foreignKey:UserId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+func (Tag) TableName() string {
+	return "song_tags"
+}
+
+// TagCount is how many listeners put a tag on a song, or on any song
+type TagCount struct {
+	Name     string `json:"name"`
+	Count    int64  `json:"count"`
+	ByArtist bool   `json:"by_artist"`
+	Mood     bool   `json:"mood"`
+	Mine     bool   `json:"mine,omitempty"`
+}
+
+type Song struct {
+	Id        uuid.UUID `json:"id"`
+	Title     string    `json:"title"`
+	ArtistId  uuid.UUID `json:"artist_id"`
+	Duration  int       `json:"duration"`
+	Genre     string    `json:"genre"`
+	Bpm       int       `json:"bpm"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// Relationships
+	Artist User `json:"artist" gorm:"foreignKey:ArtistId;references:Id"`
+}
+
+type User struct {
+	Id       uuid.UUID `json:"id"`
+	FullName string    `json:"full_name"`
+}
+
+func NewGenre(name string, parentId *uuid.UUID) (*Genre, error) {
+	name = strings.Join(strings.Fields(name), " ")
+	slug := Slug(name)
+	if slug == "" {
+		return nil, ErrInvalidGenre
+	}
+	return &Genre{Id: uuid.New(), Name: name, Slug: slug, ParentId: parentId}, nil
+}
+
+func NewTag(songId, userId uuid.UUID, name string, byArtist bool) *Tag {
+	return &Tag{SongId: songId, UserId: userId, Name: name, ByArtist: byArtist}
+}
+
+// Slug names a genre in URLs, and decides which spellings are the same genre: "Hip Hop",
+// "hip-hop" and "HIP HOP" all have the slug hip-hop
+func Slug(name string) string {
+	var slug strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && slug.Len() > 0 {
+				slug.WriteByte('-')
+			}
+			slug.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return slug.String()
+}
+
+// NormalizeTag lower-cases a tag and tidies its spacing, so listeners typing the same word
+// get the same tag
+func NormalizeTag(tag string) (string, error) {
+	tag = strings.Join(strings.Fields(strings.ToLower(tag)), " ")
+	if tag == "" || utf8.RuneCountInString(tag) > MaxTagLength {
+		return "", ErrInvalidTag
+	}
+	for _, r := range tag {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune(" -&'", r) {
+			return "", ErrInvalidTag
+		}
+	}
+	return tag, nil
+}
+
+// IsMood tells whether a tag is one of the moods clients offer as suggestions
+func IsMood(tag string) bool {
+	return slices.Contains(Moods, tag)
+}
@@ -0,0 +1,145 @@
+package genre
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type SqlGenreRepository struct {
+	db *gorm.DB
+}
+
+func NewSqlGenreRepository(db *gorm.DB) *SqlGenreRepository {
+	return &SqlGenreRepository{db: db}
+}
+
+// Create adds a genre unless one with its slug already exists
+func (r *SqlGenreRepository) Create(genre *Genre) error {
+	return r.db.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "slug"}}, DoNothing: true}).Create(genre).Error
+}
+
+func (r *SqlGenreRepository) GetAll() ([]Genre, error) {
+	var genres []Genre
+	if err := r.db.Order("name").Find(&genres).Error; err != nil {
+		return nil, err
+	}
+	return genres, nil
+}
+
+func (r *SqlGenreRepository) GetBySlug(slug string) (Genre, error) {
+	var genre Genre
+	if err := r.db.First(&genre, "slug = ?", slug).Error; err != nil {
+		return Genre{}, err
+	}
+	return genre, nil
+}
+
+// SongGenreCounts counts songs by their genre as written on the song
+func (r *SqlGenreRepository) SongGenreCounts() (map[string]int64, error) {
+	var rows []struct {
+		Genre string
+		Count int64
+	}
+	err := r.db.Model(&Song{}).
+		Scopes(available).
+		Select("genre, COUNT(*) AS count").
+		Where("genre <> ''").
+		Group("genre").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Genre] = row.Count
+	}
+	return counts, nil
+}
+
+func (r *SqlGenreRepository) RenameSongGenre(from, to string) error {
+	return r.db.Model(&Song{}).Where("genre = ?", from).Update("genre", to).Error
+}
+
+func (r *SqlGenreRepository) GetSongsByGenres(names []string, page, limit int) ([]Song, int64, error) {
+	return r.pageSongs(r.db.Model(&Song{}).Where("genre IN ?", names), page, limit)
+}
+
+func (r *SqlGenreRepository) GetSong(id string) (Song, error) {
+	var song Song
+	if err := r.db.Scopes(available).First(&song, "id = ?", id).Error; err != nil {
+		return Song{}, err
+	}
+	return song, nil
+}
+
+func (r *SqlGenreRepository) AddTag(tag *Tag) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Omit("Song", "User").Create(tag).Error
+}
+
+func (r *SqlGenreRepository) RemoveTag(userId, songId, name string) error {
+	return r.db.Where("user_id = ? AND song_id = ? AND name = ?", userId, songId, name).Delete(&Tag{}).Error
+}
+
+func (r *SqlGenreRepository) CountUserTags(userId, songId string) (int64, error) {
+	var count int64
+	err := r.db.Model(&Tag{}).Where("user_id = ? AND song_id = ?", userId, songId).Count(&count).Error
+	return count, err
+}
+
+func (r *SqlGenreRepository) GetSongTags(songId string) ([]Tag, error) {
+	var tags []Tag
+	if err := r.db.Where("song_id = ?", songId).Order("created_at").Find(&tags).Error; err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// TopTags counts each tag once per song it's on, so a crowd tagging one song doesn't make a
+// tag popular. Only the given tags are counted when names isn't empty
+func (r *SqlGenreRepository) TopTags(names []string, limit int) ([]TagCount, error) {
+	query := r.db.Model(&Tag{}).
+		Select("name, COUNT(DISTINCT song_id) AS count, MAX(by_artist) AS by_artist").
+		Group("name").
+		Order("count DESC, name").
+		Limit(limit)
+	if len(names) > 0 {
+		query = query.Where("name IN ?", names)
+	}
+
+	var counts []TagCount
+	if err := query.Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+func (r *SqlGenreRepository) GetSongsByTag(name string, page, limit int) ([]Song, int64, error) {
+	tagged := r.db.Model(&Tag{}).Select("song_id").Where("name = ?", name)
+	return r.pageSongs(r.db.Model(&Song{}).Where("id IN (?)", tagged), page, limit)
+}
+
+func (r *SqlGenreRepository) pageSongs(query *gorm.DB, page, limit int) ([]Song, int64, error) {
+	query = query.Scopes(available)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var songs []Song
+	err := query.Preload("Artist").
+		Order("title").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&songs).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return songs, total, nil
+}
+
+// available leaves out songs moderators quarantined or took down
+func available(db *gorm.DB) *gorm.DB {
+	return db.Where("songs.status = ?", "active")
+}
@@ -0,0 +1,27 @@
+package genre
+
+import "github.com/gin-gonic/gin"
+
+func SetupRoutes(c *gin.RouterGroup, h *GenreHandler, authMiddleware gin.HandlerFunc) {
+	c.Use(authMiddleware)
+
+	c.GET("", h.GetTree)
+	c.GET("/:slug", h.GetBySlug)
+	c.GET("/:slug/songs", h.GetSongs)
+}
+
+func SetupTagRoutes(c *gin.RouterGroup, h *GenreHandler, authMiddleware gin.HandlerFunc) {
+	c.Use(authMiddleware)
+
+	c.GET("", h.GetTopTags)
+	c.GET("/moods", h.GetMoods)
+	c.GET("/:tag/songs", h.GetTaggedSongs)
+}
+
+func SetupSongRoutes(c *gin.RouterGroup, h *GenreHandler, authMiddleware gin.HandlerFunc) {
+	c.Use(authMiddleware)
+
+	c.GET("/:id/tags", h.GetSongTags)
+	c.PUT("/:id/tags/:tag", h.AddTag)
+	c.DELETE("/:id/tags/:tag", h.RemoveTag)
+}
@@ -0,0 +1,291 @@
+package genre
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type GenreService struct {
+	repo GenreRepository
+}
+
+func NewGenreService(repo GenreRepository) *GenreService {
+	return &GenreService{repo: repo}
+}
+
+// Seed adds the default taxonomy and files the genres songs already have under it. Genres
+// only differing in spelling from one in the tree are renamed to match it
+func (s *GenreService) Seed() error {
+	for _, family := range Taxonomy {
+		parent, err := s.ensure(family.Name, nil)
+		if err != nil {
+			return err
+		}
+		for _, name := range family.Subgenres {
+			if _, err := s.ensure(name, &parent.Id); err != nil {
+				return err
+			}
+		}
+	}
+
+	counts, err := s.repo.SongGenreCounts()
+	if err != nil {
+		return err
+	}
+	for name := range counts {
+		canonical, err := s.Ensure(name)
+		if err != nil {
+			continue
+		}
+		if canonical != name {
+			if err := s.repo.RenameSongGenre(name, canonical); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Ensure files an uploaded song's genre under the taxonomy, adding it as a top-level genre
+// when it's new. It returns the name the taxonomy spells the genre with, which the song
+// should use, or "" for a blank genre
+func (s *GenreService) Ensure(name string) (string, error) {
+	if strings.TrimSpace(name) == "" {
+		return "", nil
+	}
+
+	genre, err := s.ensure(name, nil)
+	if err != nil {
+		return "", err
+	}
+	return genre.Name, nil
+}
+
+// GetTree returns the top-level genres with their subgenres nested inside
+func (s *GenreService) GetTree() ([]*Genre, error) {
+	genres, err := s.tree()
+	if err != nil {
+		return nil, err
+	}
+
+	var roots []*Genre
+	for _, genre := range genres {
+		if genre.ParentId == nil {
+			roots = append(roots, genre)
+		}
+	}
+	return roots, nil
+}
+
+// Get returns a genre with its subgenres
+func (s *GenreService) Get(slug string) (*Genre, error) {
+	genres, err := s.tree()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, genre := range genres {
+		if genre.Slug == slug {
+			return genre, nil
+		}
+	}
+	return nil, ErrGenreNotFound
+}
+
+// GetSongs returns the songs of a genre and all its subgenres
+func (s *GenreService) GetSongs(slug string, page, limit int) ([]Song, int64, error) {
+	genre, err := s.Get(slug)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var names []string
+	var collect func(genre *Genre)
+	collect = func(genre *Genre) {
+		names = append(names, genre.Name)
+		for _, child := range genre.Children {
+			collect(child)
+		}
+	}
+	collect(genre)
+
+	return s.repo.GetSongsByGenres(names, page, limit)
+}
+
+// GetSongTags counts the tags on a song, marking the ones the user put there
+func (s *GenreService) GetSongTags(userId, songId string) ([]TagCount, error) {
+	if _, err := s.getSong(songId); err != nil {
+		return nil, err
+	}
+
+	tags, err := s.repo.GetSongTags(songId)
+	if err != nil {
+		return nil, err
+	}
+
+	var counts []TagCount
+	index := map[string]int{}
+	for _, tag := range tags {
+		i, ok := index[tag.Name]
+		if !ok {
+			i = len(counts)
+			index[tag.Name] = i
+			counts = append(counts, TagCount{Name: tag.Name, Mood: IsMood(tag.Name)})
+		}
+		counts[i].Count++
+		counts[i].ByArtist = counts[i].ByArtist || tag.ByArtist
+		counts[i].Mine = counts[i].Mine || tag.UserId.String() == userId
+	}
+
+	sort.SliceStable(counts, func(i, j int) bool { return counts[i].Count > counts[j].Count })
+	return counts, nil
+}
+
+// AddTag puts a tag on a song for the user, tagging a song twice the same way does nothing
+func (s *GenreService) AddTag(userId, songId, name string) ([]TagCount, error) {
+	userUUID, err := uuid.Parse(userId)
+	if err != nil {
+		return nil, err
+	}
+	name, err = NormalizeTag(name)
+	if err != nil {
+		return nil, err
+	}
+
+	song, err := s.getSong(songId)
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := s.repo.CountUserTags(userId, songId)
+	if err != nil {
+		return nil, err
+	}
+	if count >= MaxTagsPerSong {
+		return nil, ErrTooManyTags
+	}
+
+	if err := s.repo.AddTag(NewTag(song.Id, userUUID, name, song.ArtistId == userUUID)); err != nil {
+		return nil, err
+	}
+	return s.GetSongTags(userId, songId)
+}
+
+func (s *GenreService) RemoveTag(userId, songId, name string) ([]TagCount, error) {
+	name, err := NormalizeTag(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.RemoveTag(userId, songId, name); err != nil {
+		return nil, err
+	}
+	return s.GetSongTags(userId, songId)
+}
+
+// TopTags returns the tags on the most songs, only moods when moods is set
+func (s *GenreService) TopTags(moods bool, limit int) ([]TagCount, error) {
+	var names []string
+	if moods {
+		names = Moods
+	}
+
+	counts, err := s.repo.TopTags(names, limit)
+	if err != nil {
+		return nil, err
+	}
+	for i := range counts {
+		counts[i].Mood = IsMood(counts[i].Name)
+	}
+	return counts, nil
+}
+
+func (s *GenreService) GetSongsByTag(name string, page, limit int) ([]Song, int64, error) {
+	name, err := NormalizeTag(name)
+	if err != nil {
+		return nil, 0, err
+	}
+	return s.repo.GetSongsByTag(name, page, limit)
+}
+
+func (s *GenreService) getSong(id string) (Song, error) {
+	song, err := s.repo.GetSong(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return Song{}, ErrSongNotFound
+	}
+	return song, err
+}
+
+// ensure finds the genre spelled like name, adding it under parentId when there's none
+func (s *GenreService) ensure(name string, parentId *uuid.UUID) (Genre, error) {
+	genre, err := NewGenre(name, parentId)
+	if err != nil {
+		return Genre{}, err
+	}
+
+	existing, err := s.repo.GetBySlug(genre.Slug)
+	if err == nil {
+		return existing, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return Genre{}, err
+	}
+
+	// Another upload may add the same genre meanwhile, Create leaves it be and the lookup
+	// finds it
+	if err := s.repo.Create(genre); err != nil {
+		return Genre{}, err
+	}
+	return s.repo.GetBySlug(genre.Slug)
+}
+
+// tree loads every genre with its song count, linked to its subgenres
+func (s *GenreService) tree() ([]*Genre, error) {
+	all, err := s.repo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	counts, err := s.repo.SongGenreCounts()
+	if err != nil {
+		return nil, err
+	}
+
+	genres := make([]*Genre, len(all))
+	byId := make(map[uuid.UUID]*Genre, len(all))
+	for i := range all {
+		genres[i] = &all[i]
+		genres[i].SongCount = counts[all[i].Name]
+		byId[all[i].Id] = genres[i]
+	}
+	for _, genre := range genres {
+		if genre.ParentId == nil {
+			continue
+		}
+		if parent, ok := byId[*genre.ParentId]; ok {
+			parent.Children = append(parent.Children, genre)
+		} else {
+			// A genre whose parent is gone is top-level again
+			genre.ParentId = nil
+		}
+	}
+
+	var total func(genre *Genre) int64
+	total = func(genre *Genre) int64 {
+		count := genre.SongCount
+		for _, child := range genre.Children {
+			count += total(child)
+		}
+		genre.SongCount = count
+		return count
+	}
+	for _, genre := range genres {
+		if genre.ParentId == nil {
+			total(genre)
+		}
+	}
+	return genres, nil
+}
@@ -0,0 +1,36 @@
+package genre
+
+// Family is a top-level genre and its subgenres
+type Family struct {
+	Name      string
+	Subgenres []string
+}
+
+// Taxonomy is the genre tree a new server starts with. It files every ID3v1 genre, which is
+// what most tagged uploads carry, under a broad family. Genres uploads bring in that aren't
+// here become top-level genres of their own
+var Taxonomy = []Family{
+	{"Rock", []string{"Classic Rock", "Hard Rock", "Alternative", "AlternRock", "Grunge", "Punk", "Acid Punk", "Southern Rock", "Psychedelic", "Rock & Roll", "Instrumental Rock", "New Wave", "Gothic", "Indie Rock"}},
+	{"Metal", []string{"Death Metal", "Heavy Metal", "Black Metal", "Thrash Metal"}},
+	{"Electronic", []string{"Techno", "Euro-Techno", "Techno-Industrial", "Industrial", "House", "Trance", "Ambient", "Dance", "Eurodance", "Jungle", "Trip-Hop", "Rave", "Darkwave", "Acid", "Space", "Dream", "Drum & Bass", "Dubstep"}},
+	{"Hip-Hop", []string{"Rap", "Gangsta", "Christian Rap"}},
+	{"Jazz", []string{"Acid Jazz", "Fusion", "Jazz+Funk", "Bebop", "Swing"}},
+	{"Pop", []string{"Instrumental Pop", "Pop-Folk", "Pop/Funk", "Top 40", "Vocal", "Eurobeat", "K-Pop"}},
+	{"R&B", []string{"Soul", "Funk", "Disco", "Gospel"}},
+	{"Blues", nil},
+	{"Country", []string{"Bluegrass"}},
+	{"Folk", nil},
+	{"Classical", []string{"Opera", "Baroque", "Chamber Music"}},
+	{"Reggae", []string{"Ska", "Dub", "Dancehall"}},
+	{"Soundtrack", []string{"Showtunes", "Musical", "Game", "Trailer", "Cabaret"}},
+	{"World", []string{"Ethnic", "Tribal", "Native American", "Polka"}},
+	{"Easy Listening", []string{"New Age", "Meditative", "Lo-Fi", "Instrumental", "Oldies", "Retro"}},
+	{"Spoken Word", []string{"Comedy", "Pranks", "Cult"}},
+	{"Other", []string{"Noise", "Sound Clip", "Bass"}},
+}
+
+// Moods are the tags clients suggest for describing how a song feels
+var Moods = []string{
+	"happy", "sad", "energetic", "calm", "chill", "dark", "romantic", "angry",
+	"uplifting", "melancholic", "dreamy", "aggressive", "relaxing", "epic", "nostalgic", "party",
+}
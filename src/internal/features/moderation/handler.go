@@ -0,0 +1,161 @@
+package moderation
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yosp313/gotify/src/internal/utils"
+)
+
+type ModerationHandler struct {
+	service *ModerationService
+}
+
+type ReportRequest struct {
+	Reason  string `json:"reason" binding:"required"`
+	Details string `json:"details"`
+}
+
+type ActionRequest struct {
+	Action string `json:"action" binding:"required"`
+	Note   string `json:"note" binding:"max=1000"`
+}
+
+func NewModerationHandler(service *ModerationService) *ModerationHandler {
+	return &ModerationHandler{service: service}
+}
+
+// RequireModerator lets only moderators through
+func (h *ModerationHandler) RequireModerator(c *gin.Context) {
+	if !h.service.IsModerator(c.GetString("user_id")) {
+		utils.HandleErrorWithMessage(c, ErrNotModerator, "Forbidden", 403)
+		c.Abort()
+		return
+	}
+	c.Next()
+}
+
+func (h *ModerationHandler) ReportSong(c *gin.Context) {
+	h.report(c, TargetSong)
+}
+
+func (h *ModerationHandler) ReportComment(c *gin.Context) {
+	h.report(c, TargetComment)
+}
+
+func (h *ModerationHandler) GetQueue(c *gin.Context) {
+	page, limit := utils.GetPagination(c)
+
+	cases, total, err := h.service.Queue(c.Query("type"), page, limit)
+	if err != nil {
+		handleModerationError(c, err, "Failed to retrieve moderation queue")
+		return
+	}
+
+	c.JSON(200, gin.H{"cases": cases, "total": total, "page": page, "limit": limit})
+}
+
+func (h *ModerationHandler) GetSongReports(c *gin.Context) {
+	h.getReports(c, TargetSong)
+}
+
+func (h *ModerationHandler) GetCommentReports(c *gin.Context) {
+	h.getReports(c, TargetComment)
+}
+
+func (h *ModerationHandler) ActOnSong(c *gin.Context) {
+	h.act(c, TargetSong)
+}
+
+func (h *ModerationHandler) ActOnComment(c *gin.Context) {
+	h.act(c, TargetComment)
+}
+
+func (h *ModerationHandler) ActOnUser(c *gin.Context) {
+	h.act(c, TargetUser)
+}
+
+func (h *ModerationHandler) GetBans(c *gin.Context) {
+	page, limit := utils.GetPagination(c)
+
+	bans, total, err := h.service.GetBans(page, limit)
+	if err != nil {
+		utils.HandleErrorWithMessage(c, err, "Failed to retrieve bans", 500)
+		return
+	}
+
+	c.JSON(200, gin.H{"bans": bans, "total": total, "page": page, "limit": limit})
+}
+
+func (h *ModerationHandler) GetAudit(c *gin.Context) {
+	page, limit := utils.GetPagination(c)
+
+	actions, total, err := h.service.GetAudit(c.Query("target_type"), c.Query("target_id"), page, limit)
+	if err != nil {
+		utils.HandleErrorWithMessage(c, err, "Failed to retrieve audit log", 500)
+		return
+	}
+
+	c.JSON(200, gin.H{"actions": actions, "total": total, "page": page, "limit": limit})
+}
+
+func (h *ModerationHandler) report(c *gin.Context, targetType string) {
+	var req ReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.HandleErrorWithMessage(c, err, "Invalid request body", 400)
+		return
+	}
+
+	report, err := h.service.Report(c.GetString("user_id"), targetType, c.Param("id"), req.Reason, req.Details)
+	if err != nil {
+		handleModerationError(c, err, "Failed to report "+targetType)
+		return
+	}
+
+	c.JSON(201, report)
+}
+
+func (h *ModerationHandler) getReports(c *gin.Context, targetType string) {
+	page, limit := utils.GetPagination(c)
+
+	reports, total, err := h.service.GetReports(targetType, c.Param("id"), page, limit)
+	if err != nil {
+		utils.HandleErrorWithMessage(c, err, "Failed to retrieve reports", 500)
+		return
+	}
+
+	c.JSON(200, gin.H{"reports": reports, "total": total, "page": page, "limit": limit})
+}
+
+func (h *ModerationHandler) act(c *gin.Context, targetType string) {
+	var req ActionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.HandleErrorWithMessage(c, err, "Invalid request body", 400)
+		return
+	}
+
+	action, err := h.service.Act(c.GetString("user_id"), targetType, c.Param("id"), req.Action, req.Note)
+	if err != nil {
+		handleModerationError(c, err, "Failed to take action")
+		return
+	}
+
+	c.JSON(200, action)
+}
+
+func handleModerationError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, ErrSongNotFound):
+		c.JSON(404, gin.H{"error": "Song not found"})
+	case errors.Is(err, ErrCommentNotFound):
+		c.JSON(404, gin.H{"error": "Comment not found"})
+	case errors.Is(err, ErrUserNotFound):
+		c.JSON(404, gin.H{"error": "User not found"})
+	case errors.Is(err, ErrInvalidReason), errors.Is(err, ErrDetailsTooLong), errors.Is(err, ErrInvalidAction):
+		utils.HandleErrorWithMessage(c, err, message, 400)
+	case errors.Is(err, ErrSongRemoved), errors.Is(err, ErrBanModerator):
+		utils.HandleErrorWithMessage(c, err, message, 409)
+	default:
+		utils.HandleErrorWithMessage(c, err, message, 500)
+	}
+}
@@ -0,0 +1,25 @@
+package moderation
+
+import "github.com/google/uuid"
+
+type ModerationRepository interface {
+	GetUser(id string) (User, error)
+	GetSong(id string) (Song, error)
+	GetComment(id string) (Comment, error)
+	SetSongStatus(id uuid.UUID, status string) error
+	SetCommentHidden(id uuid.UUID, hidden bool) error
+	RemoveUserSongs(userId uuid.UUID) ([]uuid.UUID, error)
+	HideUserComments(userId uuid.UUID) ([]uuid.UUID, error)
+	SaveReport(report *Report) error
+	CountOpenReports(targetType string, targetId uuid.UUID) (int64, error)
+	ResolveReports(targetType string, targetIds []uuid.UUID, status string) (int64, error)
+	GetReports(targetType, targetId string, page, limit int) ([]Report, int64, error)
+	GetOpenReports(targetType string, targetIds []uuid.UUID) ([]Report, error)
+	GetCases(targetType string, page, limit int) ([]Case, int64, error)
+	Ban(ban *Ban) error
+	Unban(userId uuid.UUID) error
+	IsBanned(userId string) (bool, error)
+	GetBans(page, limit int) ([]Ban, int64, error)
+	SaveAction(action *Action) error
+	GetActions(targetType, targetId string, page, limit int) ([]Action, int64, error)
+}
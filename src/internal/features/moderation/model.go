@@ -0,0 +1,203 @@
+package moderation
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AutoHideReports is how many people have to report something before it's hidden until a
+// moderator looks at it
+const AutoHideReports = 3
+
+// MaxDetailsLength is the longest explanation a report can carry, in bytes
+const MaxDetailsLength = 1000
+
+// What can be reported and acted on
+const (
+	TargetSong    = "song"
+	TargetComment = "comment"
+	TargetUser    = "user"
+)
+
+// Report reasons
+const (
+	ReasonCopyright = "copyright"
+	ReasonOffensive = "offensive"
+	ReasonMetadata  = "metadata"
+	ReasonSpam      = "spam"
+	ReasonOther     = "other"
+)
+
+var Reasons = []string{ReasonCopyright, ReasonOffensive, ReasonMetadata, ReasonSpam, ReasonOther}
+
+// Report statuses
+const (
+	ReportOpen      = "open"
+	ReportDismissed = "dismissed"
+	ReportActioned  = "actioned"
+)
+
+// Moderator actions
+const (
+	// ActionDismiss closes the reports and leaves the content be
+	ActionDismiss = "dismiss"
+	// ActionHide quarantines a song or hides a comment until it's restored
+	ActionHide = "hide"
+	// ActionRestore makes hidden content visible again
+	ActionRestore = "restore"
+	// ActionTakeDown removes a song for good, it can't be restored
+	ActionTakeDown = "take_down"
+	// ActionBan bans the uploader or author, taking down their songs and hiding their comments
+	ActionBan = "ban"
+	// ActionUnban lets a banned user upload and comment again, their content stays down
+	ActionUnban = "unban"
+)
+
+// Actions lists what moderators can do to each kind of target
+var Actions = map[string][]string{
+	TargetSong:    {ActionDismiss, ActionHide, ActionRestore, ActionTakeDown, ActionBan},
+	TargetComment: {ActionDismiss, ActionHide, ActionRestore, ActionBan},
+	TargetUser:    {ActionBan, ActionUnban},
+}
+
+// Song statuses, as the song package spells them
+const (
+	SongActive      = "active"
+	SongQuarantined = "quarantined"
+	SongRemoved     = "removed"
+)
+
+var (
+	ErrNotModerator    = errors.New("only moderators can do this")
+	ErrSongNotFound    = errors.New("song not found")
+	ErrCommentNotFound = errors.New("comment not found")
+	ErrUserNotFound    = errors.New("user not found")
+	ErrInvalidReason   = errors.New("reason must be one of copyright, offensive, metadata, spam or other")
+	ErrDetailsTooLong  = errors.New("details are too long")
+	ErrInvalidAction   = errors.New("action can't be taken on this")
+	ErrSongRemoved     = errors.New("song was taken down and can't be restored")
+	ErrBanModerator    = errors.New("moderators can't be banned")
+)
+
+// Report is someone flagging a song or comment for moderators. Each user has one report per
+// target, reporting it again updates it
+type Report struct {
+	Id         uuid.UUID  `json:"id" db:"id" gorm:"primaryKey"`
+	ReporterId uuid.UUID  `json:"reporter_id" db:"reporter_id" gorm:"not null;uniqueIndex:idx_report_target"`
+	TargetType string     `json:"target_type" db:"target_type" gorm:"not null;uniqueIndex:idx_report_target;index:idx_report_status"`
+	TargetId   uuid.UUID  `json:"target_id" db:"target_id" gorm:"not null;uniqueIndex:idx_report_target;index:idx_report_status"`
+	Reason     string     `json:"reason" db:"reason" gorm:"not null"`
+	Details    string     `json:"details" db:"details"`
+	Status     string     `json:"status" db:"status" gorm:"not null;index:idx_report_status"`
+	ResolvedAt *time.Time `json:"resolved_at" db:"resolved_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+
+	// Relationships
+	Reporter *User `json:"reporter,omitempty" gorm:"foreignKey:ReporterId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+// Ban keeps a user from uploading songs and posting comments
+type Ban struct {
+	UserId      uuid.UUID `json:"user_id" db:"user_id" gorm:"primaryKey"`
+	ModeratorId uuid.UUID `json:"moderator_id" db:"moderator_id" gorm:"not null"`
+	Note        string    `json:"note" db:"note"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+
+	// Relationships
+	User *User `json:"-" gorm:"foreignKey:UserId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+// Action is an entry of the audit log: what was done to what, by whom and why. Actions taken
+// automatically have no moderator
+type Action struct {
+	Id          uuid.UUID  `json:"id" db:"id" gorm:"primaryKey"`
+	ModeratorId *uuid.UUID `json:"moderator_id" db:"moderator_id" gorm:"index"`
+	Action      string     `json:"action" db:"action" gorm:"not null"`
+	TargetType  string     `json:"target_type" db:"target_type" gorm:"not null;index:idx_action_target"`
+	TargetId    uuid.UUID  `json:"target_id" db:"target_id" gorm:"not null;index:idx_action_target"`
+	Note        string     `json:"note" db:"note"`
+	// How many open reports the action closed
+	Reports   int64     `json:"reports" db:"reports"`
+	CreatedAt time.Time `json:"created_at" db:"created_at" gorm:"index"`
+
+	// Relationships
+	Moderator *User `json:"moderator,omitempty" gorm:"foreignKey:ModeratorId;references:Id"`
+}
+
+func (Action) TableName() string {
+	return "moderation_actions"
+}
+
+// Case is an entry of the moderation queue: a song or comment with open reports
+type Case struct {
+	TargetType      string    `json:"target_type"`
+	TargetId        uuid.UUID `json:"target_id"`
+	Reports         int64     `json:"reports"`
+	Reasons         []string  `json:"reasons" gorm:"-"`
+	FirstReportedAt time.Time `json:"first_reported_at" gorm:"-"`
+	LastReportedAt  time.Time `json:"last_reported_at" gorm:"-"`
+	Song            *Song     `json:"song,omitempty" gorm:"-"`
+	Comment         *Comment  `json:"comment,omitempty" gorm:"-"`
+}
+
+type User struct {
+	Id       uuid.UUID `json:"id"`
+	FullName string    `json:"full_name"`
+}
+
+type Song struct {
+	Id       uuid.UUID `json:"id"`
+	Title    string    `json:"title"`
+	ArtistId uuid.UUID `json:"artist_id"`
+	Status   string    `json:"status"`
+
+	// Relationships
+	Artist *User `json:"artist,omitempty" gorm:"foreignKey:ArtistId;references:Id"`
+}
+
+type Comment struct {
+	Id     uuid.UUID `json:"id"`
+	SongId uuid.UUID `json:"song_id"`
+	UserId uuid.UUID `json:"user_id"`
+	Body   string    `json:"body"`
+	Hidden bool      `json:"hidden"`
+
+	// Relationships
+	User *User `json:"user,omitempty" gorm:"foreignKey:UserId;references:Id"`
+}
+
+func NewReport(reporterId uuid.UUID, targetType string, targetId uuid.UUID, reason, details string) (*Report, error) {
+	if !slices.Contains(Reasons, reason) {
+		return nil, ErrInvalidReason
+	}
+	details = strings.TrimSpace(details)
+	if len(details) > MaxDetailsLength {
+		return nil, ErrDetailsTooLong
+	}
+
+	return &Report{
+		Id:         uuid.New(),
+		ReporterId: reporterId,
+		TargetType: targetType,
+		TargetId:   targetId,
+		Reason:     reason,
+		Details:    details,
+		Status:     ReportOpen,
+	}, nil
+}
+
+func NewAction(moderatorId *uuid.UUID, action, targetType string, targetId uuid.UUID, note string, reports int64) *Action {
+	return &Action{
+		Id:          uuid.New(),
+		ModeratorId: moderatorId,
+		Action:      action,
+		TargetType:  targetType,
+		TargetId:    targetId,
+		Note:        strings.TrimSpace(note),
+		Reports:     reports,
+	}
+}
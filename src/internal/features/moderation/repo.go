@@ -0,0 +1,230 @@
+package moderation
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type SqlModerationRepository struct {
+	db *gorm.DB
+}
+
+func NewSqlModerationRepository(db *gorm.DB) *SqlModerationRepository {
+	return &SqlModerationRepository{db: db}
+}
+
+func (r *SqlModerationRepository) GetUser(id string) (User, error) {
+	var user User
+	if err := r.db.First(&user, "id = ?", id).Error; err != nil {
+		return User{}, err
+	}
+	return user, nil
+}
+
+func (r *SqlModerationRepository) GetSong(id string) (Song, error) {
+	var song Song
+	if err := r.db.Preload("Artist").First(&song, "id = ?", id).Error; err != nil {
+		return Song{}, err
+	}
+	return song, nil
+}
+
+func (r *SqlModerationRepository) GetComment(id string) (Comment, error) {
+	var comment Comment
+	if err := r.db.Preload("User").First(&comment, "id = ?", id).Error; err != nil {
+		return Comment{}, err
+	}
+	return comment, nil
+}
+
+func (r *SqlModerationRepository) SetSongStatus(id uuid.UUID, status string) error {
+	return r.db.Model(&Song{}).Where("id = ?", id).Update("status", status).Error
+}
+
+func (r *SqlModerationRepository) SetCommentHidden(id uuid.UUID, hidden bool) error {
+	return r.db.Model(&Comment{}).Where("id = ?", id).Update("hidden", hidden).Error
+}
+
+// RemoveUserSongs takes down every song the user uploaded, returning the ones it changed
+func (r *SqlModerationRepository) RemoveUserSongs(userId uuid.UUID) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		query := tx.Model(&Song{}).Where("artist_id = ? AND status <> ?", userId, SongRemoved)
+		if err := query.Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		return tx.Model(&Song{}).Where("id IN ?", ids).Update("status", SongRemoved).Error
+	})
+	return ids, err
+}
+
+// HideUserComments hides every comment the user posted, returning the ones it changed
+func (r *SqlModerationRepository) HideUserComments(userId uuid.UUID) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		query := tx.Model(&Comment{}).Where("user_id = ? AND hidden = ?", userId, false)
+		if err := query.Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		return tx.Model(&Comment{}).Where("id IN ?", ids).Update("hidden", true).Error
+	})
+	return ids, err
+}
+
+// SaveReport adds a report, or reopens the user's earlier report on the same target with the
+// new reason
+func (r *SqlModerationRepository) SaveReport(report *Report) error {
+	return r.db.Omit("Reporter").Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "reporter_id"}, {Name: "target_type"}, {Name: "target_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"reason", "details", "status", "resolved_at", "created_at"}),
+	}).Create(report).Error
+}
+
+func (r *SqlModerationRepository) CountOpenReports(targetType string, targetId uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.Model(&Report{}).
+		Where("target_type = ? AND target_id = ? AND status = ?", targetType, targetId, ReportOpen).
+		Count(&count).Error
+	return count, err
+}
+
+// ResolveReports closes the open reports on the targets, returning how many it closed
+func (r *SqlModerationRepository) ResolveReports(targetType string, targetIds []uuid.UUID, status string) (int64, error) {
+	if len(targetIds) == 0 {
+		return 0, nil
+	}
+
+	result := r.db.Model(&Report{}).
+		Where("target_type = ? AND target_id IN ? AND status = ?", targetType, targetIds, ReportOpen).
+		Updates(map[string]any{"status": status, "resolved_at": time.Now()})
+	return result.RowsAffected, result.Error
+}
+
+func (r *SqlModerationRepository) GetReports(targetType, targetId string, page, limit int) ([]Report, int64, error) {
+	query := r.db.Model(&Report{}).Where("target_type = ? AND target_id = ?", targetType, targetId)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var reports []Report
+	err := query.Preload("Reporter").
+		Order("created_at DESC").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&reports).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return reports, total, nil
+}
+
+// GetOpenReports returns the open reports on the targets, oldest first
+func (r *SqlModerationRepository) GetOpenReports(targetType string, targetIds []uuid.UUID) ([]Report, error) {
+	var reports []Report
+	err := r.db.Where("target_type = ? AND target_id IN ? AND status = ?", targetType, targetIds, ReportOpen).
+		Order("created_at").
+		Find(&reports).Error
+	if err != nil {
+		return nil, err
+	}
+	return reports, nil
+}
+
+// GetCases groups the open reports by target, the most reported first
+func (r *SqlModerationRepository) GetCases(targetType string, page, limit int) ([]Case, int64, error) {
+	query := r.db.Model(&Report{}).
+		Select("target_type, target_id, COUNT(*) AS reports").
+		Where("status = ?", ReportOpen).
+		Group("target_type, target_id")
+	if targetType != "" {
+		query = query.Where("target_type = ?", targetType)
+	}
+
+	var total int64
+	if err := r.db.Table("(?) AS cases", query).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var cases []Case
+	err := query.Order("reports DESC, MIN(created_at) ASC").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Scan(&cases).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return cases, total, nil
+}
+
+func (r *SqlModerationRepository) Ban(ban *Ban) error {
+	return r.db.Omit("User").Clauses(clause.OnConflict{DoNothing: true}).Create(ban).Error
+}
+
+func (r *SqlModerationRepository) Unban(userId uuid.UUID) error {
+	return r.db.Where("user_id = ?", userId).Delete(&Ban{}).Error
+}
+
+func (r *SqlModerationRepository) IsBanned(userId string) (bool, error) {
+	var count int64
+	err := r.db.Model(&Ban{}).Where("user_id = ?", userId).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *SqlModerationRepository) GetBans(page, limit int) ([]Ban, int64, error) {
+	var total int64
+	if err := r.db.Model(&Ban{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var bans []Ban
+	err := r.db.Order("created_at DESC").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&bans).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return bans, total, nil
+}
+
+func (r *SqlModerationRepository) SaveAction(action *Action) error {
+	return r.db.Omit("Moderator").Create(action).Error
+}
+
+// GetActions returns the audit log newest first, only for one target when given
+func (r *SqlModerationRepository) GetActions(targetType, targetId string, page, limit int) ([]Action, int64, error) {
+	query := r.db.Model(&Action{})
+	if targetType != "" {
+		query = query.Where("target_type = ?", targetType)
+	}
+	if targetId != "" {
+		query = query.Where("target_id = ?", targetId)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var actions []Action
+	err := query.Preload("Moderator").
+		Order("created_at DESC").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&actions).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return actions, total, nil
+}
@@ -0,0 +1,30 @@
+package moderation
+
+import "github.com/gin-gonic/gin"
+
+// SetupRoutes mounts the moderator tools, only moderators get through
+func SetupRoutes(c *gin.RouterGroup, h *ModerationHandler, authMiddleware gin.HandlerFunc) {
+	c.Use(authMiddleware)
+	c.Use(h.RequireModerator)
+
+	c.GET("/queue", h.GetQueue)
+	c.GET("/songs/:id/reports", h.GetSongReports)
+	c.POST("/songs/:id/actions", h.ActOnSong)
+	c.GET("/comments/:id/reports", h.GetCommentReports)
+	c.POST("/comments/:id/actions", h.ActOnComment)
+	c.POST("/users/:id/actions", h.ActOnUser)
+	c.GET("/bans", h.GetBans)
+	c.GET("/actions", h.GetAudit)
+}
+
+func SetupSongRoutes(c *gin.RouterGroup, h *ModerationHandler, authMiddleware gin.HandlerFunc) {
+	c.Use(authMiddleware)
+
+	c.POST("/:id/reports", h.ReportSong)
+}
+
+func SetupCommentRoutes(c *gin.RouterGroup, h *ModerationHandler, authMiddleware gin.HandlerFunc) {
+	c.Use(authMiddleware)
+
+	c.POST("/:id/reports", h.ReportComment)
+}
@@ -0,0 +1,362 @@
+package moderation
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ModerationService struct {
+	repo ModerationRepository
+	// Ids of the users who can moderate
+	moderators map[uuid.UUID]bool
+}
+
+// NewModerationService takes the ids of the moderators. Ids that don't parse are ignored,
+// emails in particular, since anyone can sign up with any email
+func NewModerationService(repo ModerationRepository, moderators []string) *ModerationService {
+	ids := map[uuid.UUID]bool{}
+	for _, moderator := range moderators {
+		if id, err := uuid.Parse(strings.TrimSpace(moderator)); err == nil {
+			ids[id] = true
+		}
+	}
+	return &ModerationService{repo: repo, moderators: ids}
+}
+
+func (s *ModerationService) IsModerator(userId string) bool {
+	id, err := uuid.Parse(userId)
+	return err == nil && s.moderators[id]
+}
+
+// IsBanned tells whether a moderator banned the user from uploading and commenting
+func (s *ModerationService) IsBanned(userId string) (bool, error) {
+	return s.repo.IsBanned(userId)
+}
+
+// Screen lets comments through unless their author is banned
+func (s *ModerationService) Screen(userId string, songId uuid.UUID, body string) (bool, error) {
+	banned, err := s.repo.IsBanned(userId)
+	return !banned, err
+}
+
+// Report flags a song or comment for moderators. Once AutoHideReports people have reported
+// it, it's hidden until a moderator decides on it
+func (s *ModerationService) Report(userId, targetType, targetId, reason, details string) (Report, error) {
+	userUUID, err := uuid.Parse(userId)
+	if err != nil {
+		return Report{}, err
+	}
+
+	var id uuid.UUID
+	switch targetType {
+	case TargetSong:
+		song, err := s.getSong(targetId)
+		if err != nil {
+			return Report{}, err
+		}
+		if song.Status != SongActive && song.ArtistId != userUUID {
+			return Report{}, ErrSongNotFound
+		}
+		id = song.Id
+	case TargetComment:
+		comment, err := s.getComment(targetId)
+		if err != nil {
+			return Report{}, err
+		}
+		if comment.Hidden && comment.UserId != userUUID {
+			return Report{}, ErrCommentNotFound
+		}
+		id = comment.Id
+	default:
+		return Report{}, ErrInvalidAction
+	}
+
+	report, err := NewReport(userUUID, targetType, id, reason, details)
+	if err != nil {
+		return Report{}, err
+	}
+	if err := s.repo.SaveReport(report); err != nil {
+		return Report{}, err
+	}
+
+	if err := s.autoHide(targetType, id); err != nil {
+		return Report{}, err
+	}
+	return *report, nil
+}
+
+// Queue lists what has open reports, optionally only songs or comments, the most reported first
+func (s *ModerationService) Queue(targetType string, page, limit int) ([]Case, int64, error) {
+	if targetType != "" && targetType != TargetSong && targetType != TargetComment {
+		return nil, 0, ErrInvalidAction
+	}
+
+	cases, total, err := s.repo.GetCases(targetType, page, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ids := map[string][]uuid.UUID{}
+	for _, c := range cases {
+		ids[c.TargetType] = append(ids[c.TargetType], c.TargetId)
+	}
+	reports := map[uuid.UUID][]Report{}
+	for targetType, targetIds := range ids {
+		found, err := s.repo.GetOpenReports(targetType, targetIds)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, report := range found {
+			reports[report.TargetId] = append(reports[report.TargetId], report)
+		}
+	}
+
+	for i := range cases {
+		withReports(&cases[i], reports[cases[i].TargetId])
+		switch cases[i].TargetType {
+		case TargetSong:
+			if song, err := s.repo.GetSong(cases[i].TargetId.String()); err == nil {
+				cases[i].Song = &song
+			}
+		case TargetComment:
+			if comment, err := s.repo.GetComment(cases[i].TargetId.String()); err == nil {
+				cases[i].Comment = &comment
+			}
+		}
+	}
+	return cases, total, nil
+}
+
+// GetReports lists every report made on a song or comment, open or not
+func (s *ModerationService) GetReports(targetType, targetId string, page, limit int) ([]Report, int64, error) {
+	return s.repo.GetReports(targetType, targetId, page, limit)
+}
+
+// Act carries out a moderator's decision on a song, comment or user, closes the reports it
+// settles and records it in the audit log
+func (s *ModerationService) Act(moderatorId, targetType, targetId, action, note string) (Action, error) {
+	moderatorUUID, err := uuid.Parse(moderatorId)
+	if err != nil {
+		return Action{}, err
+	}
+	if !slices.Contains(Actions[targetType], action) {
+		return Action{}, ErrInvalidAction
+	}
+
+	var id uuid.UUID
+	var resolved int64
+	switch targetType {
+	case TargetSong:
+		song, err := s.getSong(targetId)
+		if err != nil {
+			return Action{}, err
+		}
+		id = song.Id
+		resolved, err = s.actOnSong(moderatorUUID, song, action, note)
+		if err != nil {
+			return Action{}, err
+		}
+	case TargetComment:
+		comment, err := s.getComment(targetId)
+		if err != nil {
+			return Action{}, err
+		}
+		id = comment.Id
+		resolved, err = s.actOnComment(moderatorUUID, comment, action, note)
+		if err != nil {
+			return Action{}, err
+		}
+	case TargetUser:
+		user, err := s.getUser(targetId)
+		if err != nil {
+			return Action{}, err
+		}
+		id = user.Id
+		if action == ActionUnban {
+			err = s.repo.Unban(user.Id)
+		} else {
+			resolved, err = s.ban(moderatorUUID, user, note)
+		}
+		if err != nil {
+			return Action{}, err
+		}
+	}
+
+	entry := NewAction(&moderatorUUID, action, targetType, id, note, resolved)
+	if err := s.repo.SaveAction(entry); err != nil {
+		return Action{}, err
+	}
+	return *entry, nil
+}
+
+func (s *ModerationService) GetBans(page, limit int) ([]Ban, int64, error) {
+	return s.repo.GetBans(page, limit)
+}
+
+// GetAudit lists moderator actions newest first, only on one target when given
+func (s *ModerationService) GetAudit(targetType, targetId string, page, limit int) ([]Action, int64, error) {
+	return s.repo.GetActions(targetType, targetId, page, limit)
+}
+
+func (s *ModerationService) actOnSong(moderatorId uuid.UUID, song Song, action, note string) (int64, error) {
+	ids := []uuid.UUID{song.Id}
+	switch action {
+	case ActionDismiss:
+		return s.repo.ResolveReports(TargetSong, ids, ReportDismissed)
+	case ActionHide:
+		if song.Status == SongRemoved {
+			return 0, ErrSongRemoved
+		}
+		if err := s.repo.SetSongStatus(song.Id, SongQuarantined); err != nil {
+			return 0, err
+		}
+		return s.repo.ResolveReports(TargetSong, ids, ReportActioned)
+	case ActionRestore:
+		if song.Status == SongRemoved {
+			return 0, ErrSongRemoved
+		}
+		if err := s.repo.SetSongStatus(song.Id, SongActive); err != nil {
+			return 0, err
+		}
+		return s.repo.ResolveReports(TargetSong, ids, ReportDismissed)
+	case ActionTakeDown:
+		if err := s.repo.SetSongStatus(song.Id, SongRemoved); err != nil {
+			return 0, err
+		}
+		return s.repo.ResolveReports(TargetSong, ids, ReportActioned)
+	default:
+		artist, err := s.getUser(song.ArtistId.String())
+		if err != nil {
+			return 0, err
+		}
+		return s.ban(moderatorId, artist, note)
+	}
+}
+
+func (s *ModerationService) actOnComment(moderatorId uuid.UUID, comment Comment, action, note string) (int64, error) {
+	ids := []uuid.UUID{comment.Id}
+	switch action {
+	case ActionDismiss:
+		return s.repo.ResolveReports(TargetComment, ids, ReportDismissed)
+	case ActionHide:
+		if err := s.repo.SetCommentHidden(comment.Id, true); err != nil {
+			return 0, err
+		}
+		return s.repo.ResolveReports(TargetComment, ids, ReportActioned)
+	case ActionRestore:
+		if err := s.repo.SetCommentHidden(comment.Id, false); err != nil {
+			return 0, err
+		}
+		return s.repo.ResolveReports(TargetComment, ids, ReportDismissed)
+	default:
+		author, err := s.getUser(comment.UserId.String())
+		if err != nil {
+			return 0, err
+		}
+		return s.ban(moderatorId, author, note)
+	}
+}
+
+// ban keeps the user from uploading and commenting, takes down all their songs and hides all
+// their comments. It returns how many reports that settled
+func (s *ModerationService) ban(moderatorId uuid.UUID, user User, note string) (int64, error) {
+	if s.moderators[user.Id] {
+		return 0, ErrBanModerator
+	}
+
+	ban := &Ban{UserId: user.Id, ModeratorId: moderatorId, Note: strings.TrimSpace(note)}
+	if err := s.repo.Ban(ban); err != nil {
+		return 0, err
+	}
+
+	songs, err := s.repo.RemoveUserSongs(user.Id)
+	if err != nil {
+		return 0, err
+	}
+	comments, err := s.repo.HideUserComments(user.Id)
+	if err != nil {
+		return 0, err
+	}
+
+	resolved, err := s.repo.ResolveReports(TargetSong, songs, ReportActioned)
+	if err != nil {
+		return 0, err
+	}
+	more, err := s.repo.ResolveReports(TargetComment, comments, ReportActioned)
+	return resolved + more, err
+}
+
+// autoHide quarantines a song or hides a comment that enough people reported, leaving the
+// reports open for a moderator
+func (s *ModerationService) autoHide(targetType string, id uuid.UUID) error {
+	count, err := s.repo.CountOpenReports(targetType, id)
+	if err != nil || count < AutoHideReports {
+		return err
+	}
+
+	if targetType == TargetSong {
+		song, err := s.getSong(id.String())
+		if err != nil || song.Status != SongActive {
+			return err
+		}
+		if err := s.repo.SetSongStatus(id, SongQuarantined); err != nil {
+			return err
+		}
+	} else {
+		comment, err := s.getComment(id.String())
+		if err != nil || comment.Hidden {
+			return err
+		}
+		if err := s.repo.SetCommentHidden(id, true); err != nil {
+			return err
+		}
+	}
+
+	note := fmt.Sprintf("Hidden automatically after %d reports", count)
+	return s.repo.SaveAction(NewAction(nil, ActionHide, targetType, id, note, 0))
+}
+
+func (s *ModerationService) getUser(id string) (User, error) {
+	user, err := s.repo.GetUser(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return User{}, ErrUserNotFound
+	}
+	return user, err
+}
+
+func (s *ModerationService) getSong(id string) (Song, error) {
+	song, err := s.repo.GetSong(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return Song{}, ErrSongNotFound
+	}
+	return song, err
+}
+
+func (s *ModerationService) getComment(id string) (Comment, error) {
+	comment, err := s.repo.GetComment(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return Comment{}, ErrCommentNotFound
+	}
+	return comment, err
+}
+
+// withReports fills in when a case was reported and the distinct reasons given, from its open
+// reports oldest first
+func withReports(c *Case, reports []Report) {
+	c.Reasons = []string{}
+	for _, report := range reports {
+		if !slices.Contains(c.Reasons, report.Reason) {
+			c.Reasons = append(c.Reasons, report.Reason)
+		}
+	}
+	slices.Sort(c.Reasons)
+	if len(reports) > 0 {
+		c.FirstReportedAt = reports[0].CreatedAt
+		c.LastReportedAt = reports[len(reports)-1].CreatedAt
+	}
+}
@@ -0,0 +1,218 @@
+package party
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yosp313/gotify/src/internal/pkg/hub"
+	"github.com/yosp313/gotify/src/internal/utils"
+	"golang.org/x/net/websocket"
+	"gorm.io/gorm"
+)
+
+type PartyRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+type QueueRequest struct {
+	SongId uuid.UUID `json:"song_id" binding:"required"`
+}
+
+type ChatRequest struct {
+	Text string `json:"text" binding:"required"`
+}
+
+// Command is a message from a listener over the WebSocket. Types are the playback actions,
+// which only the host may send, and queue, vote_skip, chat and ping
+type Command struct {
+	Type       string     `json:"type"`
+	PositionMs *int       `json:"position_ms"`
+	SongId     *uuid.UUID `json:"song_id"`
+	Text       string     `json:"text"`
+	// Echoed back in pong so clients can measure the round trip
+	ClientTime int64 `json:"client_time"`
+}
+
+type PartyHandler struct {
+	service *PartyService
+}
+
+func NewPartyHandler(service *PartyService) *PartyHandler {
+	return &PartyHandler{service: service}
+}
+
+func (h *PartyHandler) Create(c *gin.Context) {
+	var req PartyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.HandleErrorWithMessage(c, err, "Invalid request body", 400)
+		return
+	}
+
+	party, err := h.service.Create(c.GetString("user_id"), req.Name)
+	if err != nil {
+		utils.HandleErrorWithMessage(c, err, "Failed to create party", 500)
+		return
+	}
+
+	c.JSON(201, party)
+}
+
+func (h *PartyHandler) Get(c *gin.Context) {
+	state, err := h.service.Get(c.Param("id"))
+	if err != nil {
+		handlePartyError(c, err, "Failed to retrieve party")
+		return
+	}
+
+	c.JSON(200, state)
+}
+
+func (h *PartyHandler) End(c *gin.Context) {
+	if err := h.service.End(c.GetString("user_id"), c.Param("id")); err != nil {
+		handlePartyError(c, err, "Failed to end party")
+		return
+	}
+
+	c.Status(204)
+}
+
+func (h *PartyHandler) Control(c *gin.Context) {
+	var control Control
+	if err := c.ShouldBindJSON(&control); err != nil {
+		utils.HandleErrorWithMessage(c, err, "Invalid request body", 400)
+		return
+	}
+
+	playback, err := h.service.Control(c.GetString("user_id"), c.Param("id"), control)
+	if err != nil {
+		handlePartyError(c, err, "Failed to control playback")
+		return
+	}
+
+	c.JSON(200, playback)
+}
+
+func (h *PartyHandler) Enqueue(c *gin.Context) {
+	var req QueueRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.HandleErrorWithMessage(c, err, "Invalid request body", 400)
+		return
+	}
+
+	items, err := h.service.Enqueue(c.GetString("user_id"), c.Param("id"), req.SongId)
+	if err != nil {
+		handlePartyError(c, err, "Failed to add song to the queue")
+		return
+	}
+
+	c.JSON(201, gin.H{"queue": items})
+}
+
+func (h *PartyHandler) VoteSkip(c *gin.Context) {
+	skip, err := h.service.VoteSkip(c.GetString("user_id"), c.Param("id"))
+	if err != nil {
+		handlePartyError(c, err, "Failed to vote")
+		return
+	}
+
+	c.JSON(200, skip)
+}
+
+func (h *PartyHandler) Chat(c *gin.Context) {
+	var req ChatRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.HandleErrorWithMessage(c, err, "Invalid request body", 400)
+		return
+	}
+
+	message, err := h.service.Chat(c.GetString("user_id"), c.Param("id"), req.Text)
+	if err != nil {
+		handlePartyError(c, err, "Failed to send message")
+		return
+	}
+
+	c.JSON(201, message)
+}
+
+// Connect joins the party over a WebSocket. Browsers pass the JWT as ?token= since they
+// can't set headers here
+func (h *PartyHandler) Connect(c *gin.Context) {
+	userId := c.GetString("user_id")
+	partyId := c.Param("id")
+
+	// Fail with a proper status before upgrading when the party can't be joined
+	if _, err := h.service.Get(partyId); err != nil {
+		handlePartyError(c, err, "Failed to join party")
+		return
+	}
+
+	hub.Serve(c.Writer, c.Request, func(conn *websocket.Conn) {
+		client := hub.NewClient(conn, uuid.NewString(), c.GetString("user_full_name"), userId)
+		defer client.Close()
+
+		if err := h.service.Connect(partyId, client); err != nil {
+			client.Send(hub.NewMessage("error", gin.H{"error": err.Error()}))
+			return
+		}
+		defer func() {
+			if err := h.service.Disconnect(partyId, client); err != nil {
+				log.Printf("party: failed to disconnect listener from %s: %v", partyId, err)
+			}
+		}()
+
+		for {
+			data, err := client.Receive()
+			if err != nil {
+				return
+			}
+
+			var command Command
+			if err := json.Unmarshal(data, &command); err != nil {
+				client.Send(hub.NewMessage("error", gin.H{"error": "Invalid message"}))
+				continue
+			}
+
+			// Everything that succeeds reaches all listeners, this one included
+			switch command.Type {
+			case "ping":
+				client.Send(hub.NewMessage("pong", gin.H{"client_time": command.ClientTime}))
+				continue
+			case "queue":
+				if command.SongId == nil {
+					err = ErrUnknownSong
+				} else {
+					_, err = h.service.Enqueue(userId, partyId, *command.SongId)
+				}
+			case "vote_skip":
+				_, err = h.service.VoteSkip(userId, partyId)
+			case "chat":
+				_, err = h.service.Chat(userId, partyId, command.Text)
+			default:
+				_, err = h.service.Control(userId, partyId, Control{Action: command.Type, PositionMs: command.PositionMs, SongId: command.SongId})
+			}
+			if err != nil {
+				client.Send(hub.NewMessage("error", gin.H{"command": command.Type, "error": err.Error()}))
+			}
+		}
+	})
+}
+
+func handlePartyError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(404, gin.H{"error": "Party not found"})
+	case errors.Is(err, ErrUnknownSong):
+		c.JSON(404, gin.H{"error": "Song not found"})
+	case errors.Is(err, ErrNotHost):
+		utils.HandleErrorWithMessage(c, err, "Only the host can do this", 403)
+	case errors.Is(err, ErrPartyEnded), errors.Is(err, ErrNothingToPlay):
+		utils.HandleErrorWithMessage(c, err, message, 409)
+	case errors.Is(err, ErrInvalidControl), errors.Is(err, ErrEmptyMessage), errors.Is(err, ErrMessageTooLong):
+		utils.HandleErrorWithMessage(c, err, message, 400)
+	default:
+		utils.HandleErrorWithMessage(c, err, message, 500)
+	}
+}
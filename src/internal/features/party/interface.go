@@ -0,0 +1,17 @@
+package party
+
+import "time"
+
+type PartyRepository interface {
+	Create(party *Party) error
+	Save(party *Party) error
+	GetById(id string) (Party, error)
+	GetIdle(before time.Time) ([]Party, error)
+	SongExists(songId string) (bool, error)
+	AddToQueue(item *PartyQueueItem) error
+	GetQueue(partyId string) ([]PartyQueueItem, error)
+	PopQueue(partyId string) (*PartyQueueItem, error)
+	SaveMessage(message *PartyMessage) error
+	GetMessage(id string) (PartyMessage, error)
+	GetRecentMessages(partyId string, limit int) ([]PartyMessage, error)
+}
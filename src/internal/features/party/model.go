@@ -0,0 +1,207 @@
+package party
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Playback controls, only the host may send them
+const (
+	ActionPlay  = "play"
+	ActionPause = "pause"
+	ActionSeek  = "seek"
+	ActionTrack = "track"
+	ActionNext  = "next"
+	// Sent to listeners when the votes or the end of a song moved the party on
+	ActionSkip = "skip"
+)
+
+const (
+	MaxChatLength = 500
+	// Chat messages sent to listeners when they join
+	RecentMessages = 50
+	// Parties nobody has been in for this long are ended
+	IdleTimeout = 6 * time.Hour
+)
+
+var (
+	ErrNotHost        = errors.New("only the host can control playback")
+	ErrPartyEnded     = errors.New("party has ended")
+	ErrUnknownSong    = errors.New("song not found")
+	ErrEmptyMessage   = errors.New("message is empty")
+	ErrMessageTooLong = errors.New("message is too long")
+	ErrNothingToPlay  = errors.New("nothing is playing or queued")
+	ErrInvalidControl = errors.New("invalid playback control")
+)
+
+// Party is a room where everyone hears what the host plays, at the same time
+type Party struct {
+	Id         uuid.UUID  `json:"id" db:"id" gorm:"primaryKey"`
+	HostId     uuid.UUID  `json:"host_id" db:"host_id" gorm:"not null;index"`
+	Name       string     `json:"name" db:"name" gorm:"not null"`
+	SongId     *uuid.UUID `json:"song_id" db:"song_id"`
+	PositionMs int        `json:"position_ms" db:"position_ms" gorm:"not null;default:0"`
+	PositionAt time.Time  `json:"position_at" db:"position_at"` // when PositionMs was measured
+	Playing    bool       `json:"playing" db:"playing" gorm:"not null;default:false"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at" db:"updated_at"`
+	EndedAt    *time.Time `json:"ended_at,omitempty" db:"ended_at" gorm:"index"`
+
+	// Relationships
+	Host User  `json:"host" gorm:"foreignKey:HostId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Song *Song `json:"song,omitempty" gorm:"foreignKey:SongId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+}
+
+// PartyQueueItem is a song anyone in the party lined up, played in the order added
+type PartyQueueItem struct {
+	Id        uuid.UUID `json:"id" db:"id" gorm:"primaryKey"`
+	PartyId   uuid.UUID `json:"party_id" db:"party_id" gorm:"not null;index"`
+	SongId    uuid.UUID `json:"song_id" db:"song_id" gorm:"not null"`
+	AddedBy   uuid.UUID `json:"added_by" db:"added_by" gorm:"not null"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+
+	// Relationships
+	Song Song `json:"song" gorm:"foreignKey:SongId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	User User `json:"user" gorm:"foreignKey:AddedBy;references:Id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+type PartyMessage struct {
+	Id        uuid.UUID `json:"id" db:"id" gorm:"primaryKey"`
+	PartyId   uuid.UUID `json:"party_id" db:"party_id" gorm:"not null;index"`
+	UserId    uuid.UUID `json:"user_id" db:"user_id" gorm:"not null"`
+	Text      string    `json:"text" db:"text" gorm:"not null"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+
+	// Relationships
+	User User `json:"user" gorm:"foreignKey:UserId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+type Song struct {
+	Id       uuid.UUID `json:"id"`
+	Title    string    `json:"title"`
+	ArtistId uuid.UUID `json:"artist_id"`
+	Duration int       `json:"duration"`
+
+	// Relationships
+	Artist User `json:"artist" gorm:"foreignKey:ArtistId;references:Id"`
+}
+
+type User struct {
+	Id       uuid.UUID `json:"id"`
+	FullName string    `json:"full_name"`
+}
+
+// Playback tells listeners what to play and from where. PositionAt is the server time in
+// Unix milliseconds at which the song was at PositionMs, clients add the time since then
+// using their clock offset to the server and stream the song from /songs/:id/stream
+type Playback struct {
+	SongId     *uuid.UUID `json:"song_id"`
+	Song       *Song      `json:"song,omitempty"`
+	PositionMs int        `json:"position_ms"`
+	PositionAt int64      `json:"position_at"`
+	Playing    bool       `json:"playing"`
+}
+
+type Listener struct {
+	UserId string `json:"user_id"`
+	Name   string `json:"name"`
+	Host   bool   `json:"host"`
+}
+
+type SkipVotes struct {
+	Votes  int `json:"votes"`
+	Needed int `json:"needed"`
+}
+
+// State is what a listener gets when joining
+type State struct {
+	Party     Party            `json:"party"`
+	Playback  Playback         `json:"playback"`
+	Queue     []PartyQueueItem `json:"queue"`
+	Listeners []Listener       `json:"listeners"`
+	Skip      SkipVotes        `json:"skip"`
+	Messages  []PartyMessage   `json:"messages"`
+}
+
+func NewParty(hostId, name string) (*Party, error) {
+	hostUUID, err := uuid.Parse(hostId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Party{
+		Id:         uuid.New(),
+		HostId:     hostUUID,
+		Name:       name,
+		PositionAt: time.Now(),
+	}, nil
+}
+
+func (p *Party) IsHost(userId string) bool {
+	return p.HostId.String() == userId
+}
+
+func (p *Party) IsEnded() bool {
+	return p.EndedAt != nil
+}
+
+// CurrentPositionMs is how far into the current song the party is by now
+func (p *Party) CurrentPositionMs(now time.Time) int {
+	if !p.Playing || p.PositionAt.IsZero() {
+		return p.PositionMs
+	}
+	return p.PositionMs + int(now.Sub(p.PositionAt).Milliseconds())
+}
+
+func (p *Party) Seek(positionMs int, now time.Time) {
+	p.PositionMs = max(positionMs, 0)
+	p.PositionAt = now
+}
+
+// RemainingMs is how long until the current song ends, or -1 when that isn't known
+func (p *Party) RemainingMs(now time.Time) int {
+	if p.Song == nil || p.Song.Duration <= 0 {
+		return -1
+	}
+	return max(p.Song.Duration*1000-p.CurrentPositionMs(now), 0)
+}
+
+func (p *Party) Playback() Playback {
+	return Playback{
+		SongId:     p.SongId,
+		Song:       p.Song,
+		PositionMs: p.PositionMs,
+		PositionAt: p.PositionAt.UnixMilli(),
+		Playing:    p.Playing,
+	}
+}
+
+func NewPartyQueueItem(partyId, songId uuid.UUID, addedBy string) (*PartyQueueItem, error) {
+	userUUID, err := uuid.Parse(addedBy)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PartyQueueItem{
+		Id:      uuid.New(),
+		PartyId: partyId,
+		SongId:  songId,
+		AddedBy: userUUID,
+	}, nil
+}
+
+func NewPartyMessage(partyId uuid.UUID, userId, text string) (*PartyMessage, error) {
+	userUUID, err := uuid.Parse(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PartyMessage{
+		Id:      uuid.New(),
+		PartyId: partyId,
+		UserId:  userUUID,
+		Text:    text,
+	}, nil
+}
@@ -0,0 +1,115 @@
+package party
+
+import (
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type SqlPartyRepository struct {
+	db *gorm.DB
+}
+
+func NewSqlPartyRepository(db *gorm.DB) *SqlPartyRepository {
+	return &SqlPartyRepository{db: db}
+}
+
+func (r *SqlPartyRepository) Create(party *Party) error {
+	return r.db.Omit("Host", "Song").Create(party).Error
+}
+
+func (r *SqlPartyRepository) Save(party *Party) error {
+	return r.db.Model(party).
+		Select("song_id", "position_ms", "position_at", "playing", "updated_at", "ended_at").
+		Updates(party).Error
+}
+
+func (r *SqlPartyRepository) GetById(id string) (Party, error) {
+	var party Party
+	if err := r.db.Preload("Host").Preload("Song.Artist").First(&party, "id = ?", id).Error; err != nil {
+		return Party{}, err
+	}
+	return party, nil
+}
+
+// GetIdle returns running parties nothing happened in since before
+func (r *SqlPartyRepository) GetIdle(before time.Time) ([]Party, error) {
+	var parties []Party
+	if err := r.db.Where("ended_at IS NULL AND updated_at < ?", before).Find(&parties).Error; err != nil {
+		return nil, err
+	}
+	return parties, nil
+}
+
+func (r *SqlPartyRepository) SongExists(songId string) (bool, error) {
+	var count int64
+	if err := r.db.Model(&Song{}).Scopes(available).Where("id = ?", songId).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *SqlPartyRepository) AddToQueue(item *PartyQueueItem) error {
+	return r.db.Omit("Song", "User").Create(item).Error
+}
+
+func (r *SqlPartyRepository) GetQueue(partyId string) ([]PartyQueueItem, error) {
+	var items []PartyQueueItem
+	err := r.db.Preload("Song.Artist").
+		Preload("User").
+		Where("party_id = ?", partyId).
+		Order("created_at").
+		Find(&items).Error
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// PopQueue removes and returns the oldest queued song, or nil when the queue is empty
+func (r *SqlPartyRepository) PopQueue(partyId string) (*PartyQueueItem, error) {
+	var item PartyQueueItem
+	result := r.db.Where("party_id = ?", partyId).Order("created_at").Limit(1).Find(&item)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return nil, result.Error
+	}
+
+	if err := r.db.Delete(&item).Error; err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+func (r *SqlPartyRepository) SaveMessage(message *PartyMessage) error {
+	return r.db.Omit("User").Create(message).Error
+}
+
+func (r *SqlPartyRepository) GetMessage(id string) (PartyMessage, error) {
+	var message PartyMessage
+	if err := r.db.Preload("User").First(&message, "id = ?", id).Error; err != nil {
+		return PartyMessage{}, err
+	}
+	return message, nil
+}
+
+// GetRecentMessages returns the last limit messages, oldest first
+func (r *SqlPartyRepository) GetRecentMessages(partyId string, limit int) ([]PartyMessage, error) {
+	var messages []PartyMessage
+	err := r.db.Preload("User").
+		Where("party_id = ?", partyId).
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&messages).Error
+	if err != nil {
+		return nil, err
+	}
+
+	slices.Reverse(messages)
+	return messages, nil
+}
+
+// available leaves out songs moderators quarantined or took down
+func available(db *gorm.DB) *gorm.DB {
+	return db.Where("songs.status = ?", "active")
+}
@@ -0,0 +1,16 @@
+package party
+
+import "github.com/gin-gonic/gin"
+
+func SetupRoutes(c *gin.RouterGroup, h *PartyHandler, authMiddleware gin.HandlerFunc) {
+	c.Use(authMiddleware)
+
+	c.POST("", h.Create)
+	c.GET("/:id", h.Get)
+	c.DELETE("/:id", h.End)
+	c.PUT("/:id/playback", h.Control)
+	c.POST("/:id/queue", h.Enqueue)
+	c.POST("/:id/skip", h.VoteSkip)
+	c.POST("/:id/messages", h.Chat)
+	c.GET("/:id/ws", h.Connect)
+}
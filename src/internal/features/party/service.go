@@ -0,0 +1,518 @@
+package party
+
+import (
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/yosp313/gotify/src/internal/pkg/hub"
+)
+
+// Control is a playback change from the host, fields that don't apply to its action are ignored
+type Control struct {
+	Action     string     `json:"action"`
+	PositionMs *int       `json:"position_ms"`
+	SongId     *uuid.UUID `json:"song_id"`
+}
+
+// PlaybackEvent is pushed to listeners whenever playback changes. By is empty when the
+// server moved on by itself at the end of a song
+type PlaybackEvent struct {
+	Action   string   `json:"action"`
+	By       string   `json:"by,omitempty"`
+	Playback Playback `json:"playback"`
+}
+
+type PartyService struct {
+	repo PartyRepository
+	hub  *hub.Hub
+	// Guards the parties' playback, the votes and the timers
+	mu sync.Mutex
+	// Users voting to skip the current song, by party id
+	votes map[string]map[string]bool
+	// Fire at the end of the current song, by party id
+	timers map[string]*time.Timer
+}
+
+func NewPartyService(repo PartyRepository, hub *hub.Hub) *PartyService {
+	return &PartyService{
+		repo:   repo,
+		hub:    hub,
+		votes:  map[string]map[string]bool{},
+		timers: map[string]*time.Timer{},
+	}
+}
+
+func topic(partyId string) string {
+	return "party:" + partyId
+}
+
+func (s *PartyService) Create(userId, name string) (Party, error) {
+	party, err := NewParty(userId, name)
+	if err != nil {
+		return Party{}, err
+	}
+
+	if err := s.repo.Create(party); err != nil {
+		return Party{}, err
+	}
+	return s.repo.GetById(party.Id.String())
+}
+
+func (s *PartyService) Get(id string) (State, error) {
+	party, err := s.repo.GetById(id)
+	if err != nil {
+		return State{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state(party)
+}
+
+// Control applies a playback change from the host and pushes it to every listener
+func (s *PartyService) Control(userId, id string, control Control) (Playback, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	party, err := s.running(id)
+	if err != nil {
+		return Playback{}, err
+	}
+	if !party.IsHost(userId) {
+		return Playback{}, ErrNotHost
+	}
+
+	// Pin down where playback is before anything changes
+	now := time.Now()
+	party.Seek(party.CurrentPositionMs(now), now)
+	queueChanged := false
+
+	switch control.Action {
+	case ActionPlay:
+		if party.SongId == nil {
+			if queueChanged, err = s.advance(&party, now); err != nil {
+				return Playback{}, err
+			}
+			if party.SongId == nil {
+				return Playback{}, ErrNothingToPlay
+			}
+		}
+		party.Playing = true
+	case ActionPause:
+		party.Playing = false
+	case ActionSeek:
+		if control.PositionMs == nil || party.SongId == nil {
+			return Playback{}, ErrInvalidControl
+		}
+		party.Seek(*control.PositionMs, now)
+	case ActionTrack:
+		if control.SongId == nil {
+			return Playback{}, ErrInvalidControl
+		}
+		exists, err := s.repo.SongExists(control.SongId.String())
+		if err != nil {
+			return Playback{}, err
+		}
+		if !exists {
+			return Playback{}, ErrUnknownSong
+		}
+		party.SongId = control.SongId
+		party.Seek(0, now)
+		party.Playing = true
+		delete(s.votes, id)
+	case ActionNext:
+		if queueChanged, err = s.advance(&party, now); err != nil {
+			return Playback{}, err
+		}
+	default:
+		return Playback{}, ErrInvalidControl
+	}
+
+	if err := s.commit(&party, control.Action, userId, queueChanged); err != nil {
+		return Playback{}, err
+	}
+	return party.Playback(), nil
+}
+
+// Enqueue lines up a song for the party, anyone in it may add songs
+func (s *PartyService) Enqueue(userId, id string, songId uuid.UUID) ([]PartyQueueItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	party, err := s.running(id)
+	if err != nil {
+		return nil, err
+	}
+
+	exists, err := s.repo.SongExists(songId.String())
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrUnknownSong
+	}
+
+	item, err := NewPartyQueueItem(party.Id, songId, userId)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.AddToQueue(item); err != nil {
+		return nil, err
+	}
+	return s.publishQueue(id)
+}
+
+// VoteSkip counts a vote to skip the current song. Once a majority of the listeners voted
+// the party moves on to the next song in the queue
+func (s *PartyService) VoteSkip(userId, id string) (SkipVotes, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	party, err := s.running(id)
+	if err != nil {
+		return SkipVotes{}, err
+	}
+	if party.SongId == nil {
+		return SkipVotes{}, ErrNothingToPlay
+	}
+
+	if s.votes[id] == nil {
+		s.votes[id] = map[string]bool{}
+	}
+	s.votes[id][userId] = true
+
+	skip := s.skipVotes(id)
+	if skip.Votes < skip.Needed {
+		s.hub.Publish(topic(id), hub.NewMessage("skip", skip))
+		return skip, nil
+	}
+
+	now := time.Now()
+	party.Seek(party.CurrentPositionMs(now), now)
+	queueChanged, err := s.advance(&party, now)
+	if err != nil {
+		return SkipVotes{}, err
+	}
+	if err := s.commit(&party, ActionSkip, "", queueChanged); err != nil {
+		return SkipVotes{}, err
+	}
+	return s.skipVotes(id), nil
+}
+
+func (s *PartyService) Chat(userId, id, text string) (PartyMessage, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return PartyMessage{}, ErrEmptyMessage
+	}
+	if utf8.RuneCountInString(text) > MaxChatLength {
+		return PartyMessage{}, ErrMessageTooLong
+	}
+
+	party, err := s.running(id)
+	if err != nil {
+		return PartyMessage{}, err
+	}
+
+	message, err := NewPartyMessage(party.Id, userId, text)
+	if err != nil {
+		return PartyMessage{}, err
+	}
+	if err := s.repo.SaveMessage(message); err != nil {
+		return PartyMessage{}, err
+	}
+
+	saved, err := s.repo.GetMessage(message.Id.String())
+	if err != nil {
+		return PartyMessage{}, err
+	}
+	s.hub.Publish(topic(id), hub.NewMessage("chat", saved))
+	return saved, nil
+}
+
+// End stops the party for everyone, only the host may end it
+func (s *PartyService) End(userId, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	party, err := s.running(id)
+	if err != nil {
+		return err
+	}
+	if !party.IsHost(userId) {
+		return ErrNotHost
+	}
+	return s.end(&party)
+}
+
+// EndIdleParties ends parties nobody has been listening to for IdleTimeout
+func (s *PartyService) EndIdleParties() error {
+	parties, err := s.repo.GetIdle(time.Now().Add(-IdleTimeout))
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, party := range parties {
+		if len(s.hub.Clients(topic(party.Id.String()))) > 0 {
+			continue
+		}
+		if err := s.end(&party); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Connect adds a listener and sends them everything needed to catch up
+func (s *PartyService) Connect(id string, client *hub.Client) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	party, err := s.running(id)
+	if err != nil {
+		return err
+	}
+
+	s.hub.Join(topic(id), client)
+	state, err := s.state(party)
+	if err != nil {
+		s.hub.Leave(topic(id), client)
+		return err
+	}
+	client.Send(hub.NewMessage("state", state))
+
+	s.hub.Publish(topic(id), hub.NewMessage("listeners", state.Listeners))
+	s.hub.Publish(topic(id), hub.NewMessage("skip", state.Skip))
+	return nil
+}
+
+// Disconnect removes a listener. Once the last one leaves the party pauses, so it doesn't
+// play through the queue for nobody
+func (s *PartyService) Disconnect(id string, client *hub.Client) error {
+	s.hub.Leave(topic(id), client)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	party, err := s.repo.GetById(id)
+	if err != nil {
+		return err
+	}
+	if party.IsEnded() {
+		return nil
+	}
+
+	listeners := s.listeners(party)
+	if len(listeners) > 0 {
+		s.hub.Publish(topic(id), hub.NewMessage("listeners", listeners))
+		s.hub.Publish(topic(id), hub.NewMessage("skip", s.skipVotes(id)))
+		return nil
+	}
+
+	// Touching the party also starts the idle countdown
+	now := time.Now()
+	party.Seek(party.CurrentPositionMs(now), now)
+	party.Playing = false
+	return s.commit(&party, ActionPause, "", false)
+}
+
+// advance moves the party to the next queued song, or stops it when the queue is empty.
+// It reports whether the queue changed
+func (s *PartyService) advance(party *Party, now time.Time) (bool, error) {
+	delete(s.votes, party.Id.String())
+	party.Seek(0, now)
+
+	item, err := s.repo.PopQueue(party.Id.String())
+	if err != nil {
+		return false, err
+	}
+	if item == nil {
+		party.SongId = nil
+		party.Playing = false
+		return false, nil
+	}
+
+	party.SongId = &item.SongId
+	party.Playing = true
+	return true, nil
+}
+
+// commit saves a playback change, schedules the end of the song and tells the listeners
+func (s *PartyService) commit(party *Party, action, by string, queueChanged bool) error {
+	if err := s.repo.Save(party); err != nil {
+		return err
+	}
+
+	// Reload so the current song comes with its duration and artist
+	saved, err := s.repo.GetById(party.Id.String())
+	if err != nil {
+		return err
+	}
+	*party = saved
+	s.schedule(saved)
+
+	id := party.Id.String()
+	s.hub.Publish(topic(id), hub.NewMessage("playback", PlaybackEvent{Action: action, By: by, Playback: party.Playback()}))
+	if queueChanged {
+		if _, err := s.publishQueue(id); err != nil {
+			return err
+		}
+	}
+	if action == ActionTrack || action == ActionNext || action == ActionSkip {
+		s.hub.Publish(topic(id), hub.NewMessage("skip", s.skipVotes(id)))
+	}
+	return nil
+}
+
+// schedule arms a timer that moves the party on when the current song ends, so the music
+// keeps going when the host steps away
+func (s *PartyService) schedule(party Party) {
+	id := party.Id.String()
+	if timer, ok := s.timers[id]; ok {
+		timer.Stop()
+		delete(s.timers, id)
+	}
+
+	if party.IsEnded() || !party.Playing || party.SongId == nil {
+		return
+	}
+	remaining := party.RemainingMs(time.Now())
+	if remaining < 0 {
+		return
+	}
+
+	songId := *party.SongId
+	s.timers[id] = time.AfterFunc(time.Duration(remaining)*time.Millisecond, func() {
+		if err := s.songEnded(id, songId); err != nil {
+			log.Printf("party: failed to move party %s on: %v", id, err)
+		}
+	})
+}
+
+func (s *PartyService) songEnded(id string, songId uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	party, err := s.repo.GetById(id)
+	if err != nil {
+		return err
+	}
+	// The host changed something since the timer was set
+	if party.IsEnded() || !party.Playing || party.SongId == nil || *party.SongId != songId {
+		return nil
+	}
+
+	now := time.Now()
+	if party.RemainingMs(now) > 0 {
+		s.schedule(party)
+		return nil
+	}
+
+	queueChanged, err := s.advance(&party, now)
+	if err != nil {
+		return err
+	}
+	return s.commit(&party, ActionNext, "", queueChanged)
+}
+
+func (s *PartyService) end(party *Party) error {
+	id := party.Id.String()
+	now := time.Now()
+	party.Seek(party.CurrentPositionMs(now), now)
+	party.Playing = false
+	party.EndedAt = &now
+
+	if err := s.repo.Save(party); err != nil {
+		return err
+	}
+	s.schedule(*party)
+	delete(s.votes, id)
+
+	s.hub.Publish(topic(id), hub.NewMessage("ended", party))
+	for _, client := range s.hub.Clients(topic(id)) {
+		client.Close()
+	}
+	return nil
+}
+
+func (s *PartyService) publishQueue(id string) ([]PartyQueueItem, error) {
+	items, err := s.repo.GetQueue(id)
+	if err != nil {
+		return nil, err
+	}
+	s.hub.Publish(topic(id), hub.NewMessage("queue", items))
+	return items, nil
+}
+
+func (s *PartyService) state(party Party) (State, error) {
+	id := party.Id.String()
+
+	items, err := s.repo.GetQueue(id)
+	if err != nil {
+		return State{}, err
+	}
+	messages, err := s.repo.GetRecentMessages(id, RecentMessages)
+	if err != nil {
+		return State{}, err
+	}
+
+	return State{
+		Party:     party,
+		Playback:  party.Playback(),
+		Queue:     items,
+		Listeners: s.listeners(party),
+		Skip:      s.skipVotes(id),
+		Messages:  messages,
+	}, nil
+}
+
+// listeners returns everyone connected, once per user however many tabs they have open
+func (s *PartyService) listeners(party Party) []Listener {
+	listeners := []Listener{}
+	seen := map[string]bool{}
+	for _, client := range s.hub.Clients(topic(party.Id.String())) {
+		if seen[client.UserId] {
+			continue
+		}
+		seen[client.UserId] = true
+		listeners = append(listeners, Listener{UserId: client.UserId, Name: client.Name, Host: party.IsHost(client.UserId)})
+	}
+	slices.SortFunc(listeners, func(a, b Listener) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return listeners
+}
+
+// skipVotes counts the votes of users still listening, a majority of them is needed to skip
+func (s *PartyService) skipVotes(id string) SkipVotes {
+	listening := map[string]bool{}
+	for _, client := range s.hub.Clients(topic(id)) {
+		listening[client.UserId] = true
+	}
+
+	votes := 0
+	for userId := range s.votes[id] {
+		if listening[userId] {
+			votes++
+		}
+	}
+	return SkipVotes{Votes: votes, Needed: len(listening)/2 + 1}
+}
+
+func (s *PartyService) running(id string) (Party, error) {
+	party, err := s.repo.GetById(id)
+	if err != nil {
+		return Party{}, err
+	}
+	if party.IsEnded() {
+		return Party{}, ErrPartyEnded
+	}
+	return party, nil
+}
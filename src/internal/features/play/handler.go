@@ -0,0 +1,81 @@
+package play
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yosp313/gotify/src/internal/utils"
+	"gorm.io/gorm"
+)
+
+type PlayHandler struct {
+	service *PlayService
+}
+
+type PlayRequest struct {
+	SongId         string    `json:"song_id" binding:"required,uuid"`
+	StartedAt      time.Time `json:"started_at"`
+	DurationPlayed int       `json:"duration_played" binding:"min=0"`
+	Completed      bool      `json:"completed"`
+	Client         string    `json:"client" binding:"max=64"`
+}
+
+func NewPlayHandler(service *PlayService) *PlayHandler {
+	return &PlayHandler{service: service}
+}
+
+func (h *PlayHandler) Record(c *gin.Context) {
+	var req PlayRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.HandleErrorWithMessage(c, err, "Invalid request body", 400)
+		return
+	}
+
+	play, err := h.service.Record(c.GetString("user_id"), req.SongId, req.StartedAt, req.DurationPlayed, req.Completed, req.Client)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(404, gin.H{"error": "Song not found"})
+		return
+	}
+	if errors.Is(err, ErrStartedInFuture) {
+		utils.HandleErrorWithMessage(c, err, "Invalid request body", 400)
+		return
+	}
+	if err != nil {
+		utils.HandleErrorWithMessage(c, err, "Failed to record play", 500)
+		return
+	}
+
+	c.JSON(201, play)
+}
+
+func (h *PlayHandler) GetRecent(c *gin.Context) {
+	page, limit := utils.GetPagination(c)
+
+	plays, total, err := h.service.GetRecent(c.GetString("user_id"), page, limit)
+	if err != nil {
+		utils.HandleErrorWithMessage(c, err, "Failed to retrieve play history", 500)
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"plays": plays,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	})
+}
+
+func (h *PlayHandler) GetSongStats(c *gin.Context) {
+	stats, err := h.service.GetSongStats(c.Param("id"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(404, gin.H{"error": "Song not found"})
+		return
+	}
+	if err != nil {
+		utils.HandleErrorWithMessage(c, err, "Failed to retrieve play counts", 500)
+		return
+	}
+
+	c.JSON(200, stats)
+}
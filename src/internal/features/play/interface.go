@@ -0,0 +1,11 @@
+package play
+
+import "github.com/google/uuid"
+
+type PlayRepository interface {
+	Create(play *Play) error
+	ClearPosition(userId, songId uuid.UUID) error
+	GetRecent(userId string, page, limit int) ([]Play, int64, error)
+	GetSong(songId string) (Song, error)
+	GetSongStats(songId string) (SongStats, error)
+}
@@ -0,0 +1,98 @@
+package play
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// A listen counts as a play after 30 seconds or half of the song, whichever comes first
+const (
+	MinPlaySeconds  = 30
+	MinPlayFraction = 0.5
+)
+
+// Play sources
+const (
+	SourceClient = "client"
+	SourceStream = "stream"
+)
+
+var ErrStartedInFuture = errors.New("started_at is in the future")
+
+type Play struct {
+	Id             uuid.UUID `json:"id" db:"id" gorm:"primaryKey"`
+	UserId         uuid.UUID `json:"user_id" db:"user_id" gorm:"not null;index:idx_plays_user_started"`
+	SongId         uuid.UUID `json:"song_id" db:"song_id" gorm:"not null;index"`
+	StartedAt      time.Time `json:"started_at" db:"started_at" gorm:"not null;index:idx_plays_user_started"`
+	DurationPlayed int       `json:"duration_played" db:"duration_played" gorm:"not null"`
+	Completed      bool      `json:"completed" db:"completed"`
+	Counted        bool      `json:"counted" db:"counted" gorm:"index"`
+	Client         string    `json:"client" db:"client"`
+	Source         string    `json:"source" db:"source" gorm:"not null"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+
+	// Relationships
+	Song *Song `json:"song,omitempty" gorm:"foreignKey:SongId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+type Song struct {
+	Id       uuid.UUID `json:"id"`
+	Title    string    `json:"title"`
+	ArtistId uuid.UUID `json:"artist_id"`
+	Duration int       `json:"duration"`
+}
+
+// Position is where a user stopped in a song, kept by the song feature
+type Position struct {
+	UserId uuid.UUID
+	SongId uuid.UUID
+}
+
+func (Position) TableName() string {
+	return "song_positions"
+}
+
+// IsPlay applies the play rule to a listen of played seconds on a song of the given duration.
+// Songs with an unknown duration (0) only count after MinPlaySeconds
+func IsPlay(played, duration int) bool {
+	if played >= MinPlaySeconds {
+		return true
+	}
+	return duration > 0 && float64(played) >= float64(duration)*MinPlayFraction
+}
+
+// IsCompleted allows a few seconds of slack for players that stop just short of the end
+func IsCompleted(played, duration int) bool {
+	return duration > 0 && played >= duration-5
+}
+
+func NewPlay(userId, songId uuid.UUID, startedAt time.Time, played, duration int, completed bool, client, source string) (*Play, error) {
+	if startedAt.After(time.Now().Add(time.Minute)) {
+		return nil, ErrStartedInFuture
+	}
+
+	// A single play can't last longer than the song itself
+	if duration > 0 {
+		played = min(played, duration)
+	}
+
+	return &Play{
+		Id:             uuid.New(),
+		UserId:         userId,
+		SongId:         songId,
+		StartedAt:      startedAt.UTC(),
+		DurationPlayed: played,
+		Completed:      completed || IsCompleted(played, duration),
+		Counted:        IsPlay(played, duration),
+		Client:         client,
+		Source:         source,
+	}, nil
+}
+
+type SongStats struct {
+	PlayCount       int64 `json:"play_count"`
+	UniqueListeners int64 `json:"unique_listeners"`
+	CompletedCount  int64 `json:"completed_count"`
+}
@@ -0,0 +1,62 @@
+package play
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type SqlPlayRepository struct {
+	db *gorm.DB
+}
+
+func NewSqlPlayRepository(db *gorm.DB) *SqlPlayRepository {
+	return &SqlPlayRepository{db: db}
+}
+
+func (r *SqlPlayRepository) Create(play *Play) error {
+	return r.db.Create(play).Error
+}
+
+func (r *SqlPlayRepository) ClearPosition(userId, songId uuid.UUID) error {
+	return r.db.Where("user_id = ? AND song_id = ?", userId, songId).Delete(&Position{}).Error
+}
+
+func (r *SqlPlayRepository) GetRecent(userId string, page, limit int) ([]Play, int64, error) {
+	query := r.db.Model(&Play{}).Where("user_id = ? AND counted = ?", userId, true)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var plays []Play
+	err := query.Preload("Song").
+		Order("started_at DESC").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&plays).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return plays, total, nil
+}
+
+func (r *SqlPlayRepository) GetSong(songId string) (Song, error) {
+	var song Song
+	if err := r.db.First(&song, "id = ?", songId).Error; err != nil {
+		return Song{}, err
+	}
+	return song, nil
+}
+
+func (r *SqlPlayRepository) GetSongStats(songId string) (SongStats, error) {
+	var stats SongStats
+	err := r.db.Model(&Play{}).
+		Select("COUNT(*) AS play_count, COUNT(DISTINCT user_id) AS unique_listeners, COALESCE(SUM(CASE WHEN completed THEN 1 ELSE 0 END), 0) AS completed_count").
+		Where("song_id = ? AND counted = ?", songId, true).
+		Scan(&stats).Error
+	if err != nil {
+		return SongStats{}, err
+	}
+	return stats, nil
+}
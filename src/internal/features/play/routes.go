@@ -0,0 +1,16 @@
+package play
+
+import "github.com/gin-gonic/gin"
+
+func SetupRoutes(c *gin.RouterGroup, h *PlayHandler, authMiddleware gin.HandlerFunc) {
+	c.Use(authMiddleware)
+
+	c.POST("", h.Record)
+	c.GET("", h.GetRecent)
+}
+
+func SetupSongRoutes(c *gin.RouterGroup, h *PlayHandler, authMiddleware gin.HandlerFunc) {
+	c.Use(authMiddleware)
+
+	c.GET("/:id/plays", h.GetSongStats)
+}
@@ -0,0 +1,208 @@
+package play
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// A stream session without range requests for this long is considered over
+const StreamIdleTimeout = 10 * time.Minute
+
+type PlayService struct {
+	repo PlayRepository
+
+	mu       sync.Mutex
+	sessions map[string]*streamSession
+}
+
+// streamSession follows the range requests a user makes for one song.
+// Browsers buffer ahead, so the time spent listening is estimated as the lower
+// of the wall-clock time since the first request and the share of the file fetched
+type streamSession struct {
+	userId    uuid.UUID
+	songId    uuid.UUID
+	duration  int
+	client    string
+	startedAt time.Time
+	lastSeen  time.Time
+	firstByte int64
+	lastByte  int64
+	size      int64
+}
+
+func NewPlayService(repo PlayRepository) *PlayService {
+	return &PlayService{repo: repo, sessions: map[string]*streamSession{}}
+}
+
+func (s *PlayService) Record(userId, songId string, startedAt time.Time, played int, completed bool, client string) (Play, error) {
+	userUUID, err := uuid.Parse(userId)
+	if err != nil {
+		return Play{}, err
+	}
+
+	song, err := s.repo.GetSong(songId)
+	if err != nil {
+		return Play{}, err
+	}
+
+	if startedAt.IsZero() {
+		startedAt = time.Now().Add(-time.Duration(played) * time.Second)
+	}
+
+	play, err := NewPlay(userUUID, song.Id, startedAt, played, song.Duration, completed, client, SourceClient)
+	if err != nil {
+		return Play{}, err
+	}
+
+	// Clients that report plays themselves are trusted over the stream estimate
+	s.mu.Lock()
+	delete(s.sessions, sessionKey(userUUID, song.Id))
+	s.mu.Unlock()
+
+	if err := s.save(play); err != nil {
+		return Play{}, err
+	}
+	return *play, nil
+}
+
+// Scrobble records a play a client reports as listened through, the way Subsonic clients
+// report them
+func (s *PlayService) Scrobble(userId, songId string, startedAt time.Time, client string) error {
+	song, err := s.repo.GetSong(songId)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.Record(userId, songId, startedAt, song.Duration, true, client)
+	return err
+}
+
+// RecordStream registers a byte range served by the stream endpoint. Plays are
+// written once the session ends: when the user streams another song, restarts
+// the song after it should have finished, or goes idle
+func (s *PlayService) RecordStream(userId string, songId uuid.UUID, duration int, client string, from, to, size int64) {
+	userUUID, err := uuid.Parse(userId)
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	key := sessionKey(userUUID, songId)
+
+	s.mu.Lock()
+	var finished []*streamSession
+	for k, session := range s.sessions {
+		if session.userId == userUUID && session.songId != songId {
+			finished = append(finished, session)
+			delete(s.sessions, k)
+		}
+	}
+
+	session := s.sessions[key]
+	replayed := session != nil && from == 0 && duration > 0 &&
+		now.Sub(session.startedAt) > time.Duration(duration)*time.Second
+	if session != nil && (replayed || now.Sub(session.lastSeen) > StreamIdleTimeout) {
+		finished = append(finished, session)
+		session = nil
+	}
+
+	if session == nil {
+		session = &streamSession{
+			userId:    userUUID,
+			songId:    songId,
+			duration:  duration,
+			client:    client,
+			startedAt: now,
+			firstByte: from,
+			size:      size,
+		}
+		s.sessions[key] = session
+	}
+	session.lastSeen = now
+	session.firstByte = min(session.firstByte, from)
+	session.lastByte = max(session.lastByte, to)
+	s.mu.Unlock()
+
+	for _, session := range finished {
+		s.finish(session, now)
+	}
+}
+
+// SweepSessions finishes stream sessions that have gone idle
+func (s *PlayService) SweepSessions() error {
+	now := time.Now()
+
+	s.mu.Lock()
+	var finished []*streamSession
+	for key, session := range s.sessions {
+		if now.Sub(session.lastSeen) > StreamIdleTimeout {
+			finished = append(finished, session)
+			delete(s.sessions, key)
+		}
+	}
+	s.mu.Unlock()
+
+	var errs []error
+	for _, session := range finished {
+		errs = append(errs, s.finish(session, session.lastSeen.Add(StreamIdleTimeout)))
+	}
+	return errors.Join(errs...)
+}
+
+func (s *PlayService) finish(session *streamSession, endedAt time.Time) error {
+	played := int(endedAt.Sub(session.startedAt).Seconds())
+	if session.duration > 0 && session.size > 0 {
+		fetched := float64(session.lastByte-session.firstByte+1) / float64(session.size)
+		played = min(played, int(fetched*float64(session.duration)))
+	}
+
+	// Streams that didn't reach the play threshold are just noise, unlike client reports
+	if !IsPlay(played, session.duration) {
+		return nil
+	}
+
+	play, err := NewPlay(session.userId, session.songId, session.startedAt, played, session.duration, false, session.client, SourceStream)
+	if err != nil {
+		return err
+	}
+	return s.save(play)
+}
+
+// save stores a play. A song listened to the end starts from the top next time, so its
+// resume position is cleared
+func (s *PlayService) save(play *Play) error {
+	if err := s.repo.Create(play); err != nil {
+		return err
+	}
+	if play.Completed {
+		return s.repo.ClearPosition(play.UserId, play.SongId)
+	}
+	return nil
+}
+
+func (s *PlayService) GetRecent(userId string, page, limit int) ([]Play, int64, error) {
+	plays, total, err := s.repo.GetRecent(userId, page, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	return plays, total, nil
+}
+
+func (s *PlayService) GetSongStats(songId string) (SongStats, error) {
+	if _, err := s.repo.GetSong(songId); err != nil {
+		return SongStats{}, err
+	}
+
+	stats, err := s.repo.GetSongStats(songId)
+	if err != nil {
+		return SongStats{}, err
+	}
+	return stats, nil
+}
+
+func sessionKey(userId, songId uuid.UUID) string {
+	return userId.String() + ":" + songId.String()
+}
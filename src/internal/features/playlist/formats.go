@@ -0,0 +1,358 @@
+package playlist
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Playlist file formats for import and export
+const (
+	FormatM3U8 = "m3u8"
+	FormatXSPF = "xspf"
+	FormatJSPF = "jspf"
+)
+
+// MaxImportTracks is how many tracks an imported playlist file can have, as many as a smart
+// playlist holds. Each track can take a few lookups to match
+const MaxImportTracks = MaxRuleLimit
+
+var (
+	ErrUnknownFormat = errors.New("format must be m3u8, xspf or jspf")
+	ErrEmptyImport   = errors.New("playlist file has no tracks")
+	ErrInvalidFile   = errors.New("playlist file can't be read")
+	ErrTooManyTracks = fmt.Errorf("playlist file has more than %d tracks", MaxImportTracks)
+)
+
+// PortableTrack is a track as other players describe it, before it's matched to a song
+type PortableTrack struct {
+	Locations   []string
+	Identifiers []string
+	Title       string
+	Artist      string
+	Album       string
+	DurationMs  int
+}
+
+// PortablePlaylist is the format independent content of a playlist file
+type PortablePlaylist struct {
+	Title       string
+	Description string
+	Tracks      []PortableTrack
+}
+
+func ContentType(format string) string {
+	switch format {
+	case FormatXSPF:
+		return "application/xspf+xml"
+	case FormatJSPF:
+		return "application/jspf+json"
+	default:
+		return "audio/x-mpegurl"
+	}
+}
+
+// DetectFormat picks the format from the file name, or sniffs the content when the
+// extension doesn't tell
+func DetectFormat(fileName string, data []byte) string {
+	switch strings.ToLower(path.Ext(fileName)) {
+	case ".m3u", ".m3u8":
+		return FormatM3U8
+	case ".xspf":
+		return FormatXSPF
+	case ".jspf", ".json":
+		return FormatJSPF
+	}
+
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\ufeff")))
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("<")):
+		return FormatXSPF
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		return FormatJSPF
+	default:
+		return FormatM3U8
+	}
+}
+
+func ParsePlaylistFile(format string, data []byte) (PortablePlaylist, error) {
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+
+	var playlist PortablePlaylist
+	var err error
+	switch format {
+	case FormatM3U8:
+		playlist = parseM3U(data)
+	case FormatXSPF:
+		playlist, err = parseXSPF(data)
+	case FormatJSPF:
+		playlist, err = parseJSPF(data)
+	default:
+		return PortablePlaylist{}, ErrUnknownFormat
+	}
+	if err != nil {
+		return PortablePlaylist{}, err
+	}
+
+	if len(playlist.Tracks) == 0 {
+		return PortablePlaylist{}, ErrEmptyImport
+	}
+	if len(playlist.Tracks) > MaxImportTracks {
+		return PortablePlaylist{}, ErrTooManyTracks
+	}
+	return playlist, nil
+}
+
+func RenderPlaylistFile(format string, playlist PortablePlaylist) ([]byte, error) {
+	switch format {
+	case FormatM3U8:
+		return renderM3U(playlist), nil
+	case FormatXSPF:
+		return renderXSPF(playlist)
+	case FormatJSPF:
+		return renderJSPF(playlist)
+	default:
+		return nil, ErrUnknownFormat
+	}
+}
+
+func parseM3U(data []byte) PortablePlaylist {
+	var playlist PortablePlaylist
+	var pending *PortableTrack
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "#PLAYLIST:"):
+			playlist.Title = strings.TrimSpace(strings.TrimPrefix(line, "#PLAYLIST:"))
+		case strings.HasPrefix(line, "#EXTINF:"):
+			pending = parseExtinf(strings.TrimPrefix(line, "#EXTINF:"))
+		case strings.HasPrefix(line, "#"):
+			continue
+		default:
+			track := PortableTrack{}
+			if pending != nil {
+				track = *pending
+			}
+			track.Locations = []string{line}
+			playlist.Tracks = append(playlist.Tracks, track)
+			pending = nil
+		}
+	}
+
+	return playlist
+}
+
+// parseExtinf reads "<seconds>[ attributes],<artist> - <title>"
+func parseExtinf(value string) *PortableTrack {
+	info, display, _ := strings.Cut(value, ",")
+	track := &PortableTrack{}
+
+	seconds, _, _ := strings.Cut(strings.TrimSpace(info), " ")
+	if value, err := strconv.ParseFloat(seconds, 64); err == nil && value > 0 {
+		track.DurationMs = int(value * 1000)
+	}
+
+	if artist, title, ok := strings.Cut(display, " - "); ok {
+		track.Artist = strings.TrimSpace(artist)
+		track.Title = strings.TrimSpace(title)
+	} else {
+		track.Title = strings.TrimSpace(display)
+	}
+	return track
+}
+
+func renderM3U(playlist PortablePlaylist) []byte {
+	var b bytes.Buffer
+	b.WriteString("#EXTM3U\n")
+	if playlist.Title != "" {
+		fmt.Fprintf(&b, "#PLAYLIST:%s\n", oneLine(playlist.Title))
+	}
+
+	for _, track := range playlist.Tracks {
+		seconds := -1
+		if track.DurationMs > 0 {
+			seconds = (track.DurationMs + 500) / 1000
+		}
+		display := oneLine(track.Title)
+		if track.Artist != "" {
+			display = oneLine(track.Artist) + " - " + display
+		}
+
+		fmt.Fprintf(&b, "#EXTINF:%d,%s\n", seconds, display)
+		if len(track.Locations) > 0 {
+			b.WriteString(track.Locations[0] + "\n")
+		}
+	}
+	return b.Bytes()
+}
+
+type xspfPlaylist struct {
+	XMLName    xml.Name    `xml:"playlist"`
+	Version    string      `xml:"version,attr"`
+	Xmlns      string      `xml:"xmlns,attr,omitempty"`
+	Title      string      `xml:"title,omitempty"`
+	Annotation string      `xml:"annotation,omitempty"`
+	Tracks     []xspfTrack `xml:"trackList>track"`
+}
+
+type xspfTrack struct {
+	Locations   []string `xml:"location"`
+	Identifiers []string `xml:"identifier"`
+	Title       string   `xml:"title,omitempty"`
+	Creator     string   `xml:"creator,omitempty"`
+	Album       string   `xml:"album,omitempty"`
+	Duration    int      `xml:"duration,omitempty"` // milliseconds
+}
+
+func parseXSPF(data []byte) (PortablePlaylist, error) {
+	var file xspfPlaylist
+	if err := xml.Unmarshal(data, &file); err != nil {
+		return PortablePlaylist{}, fmt.Errorf("%w as XSPF: %v", ErrInvalidFile, err)
+	}
+
+	playlist := PortablePlaylist{Title: strings.TrimSpace(file.Title), Description: strings.TrimSpace(file.Annotation)}
+	for _, track := range file.Tracks {
+		playlist.Tracks = append(playlist.Tracks, PortableTrack{
+			Locations:   trimAll(track.Locations),
+			Identifiers: trimAll(track.Identifiers),
+			Title:       strings.TrimSpace(track.Title),
+			Artist:      strings.TrimSpace(track.Creator),
+			Album:       strings.TrimSpace(track.Album),
+			DurationMs:  track.Duration,
+		})
+	}
+	return playlist, nil
+}
+
+func renderXSPF(playlist PortablePlaylist) ([]byte, error) {
+	file := xspfPlaylist{
+		Version:    "1",
+		Xmlns:      "http://xspf.org/ns/0/",
+		Title:      playlist.Title,
+		Annotation: playlist.Description,
+	}
+	for _, track := range playlist.Tracks {
+		file.Tracks = append(file.Tracks, xspfTrack{
+			Locations:   track.Locations,
+			Identifiers: track.Identifiers,
+			Title:       track.Title,
+			Creator:     track.Artist,
+			Album:       track.Album,
+			Duration:    track.DurationMs,
+		})
+	}
+
+	data, err := xml.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(data, '\n')...), nil
+}
+
+type jspfFile struct {
+	Playlist jspfPlaylist `json:"playlist"`
+}
+
+type jspfPlaylist struct {
+	Title      string      `json:"title,omitempty"`
+	Annotation string      `json:"annotation,omitempty"`
+	Tracks     []jspfTrack `json:"track"`
+}
+
+type jspfTrack struct {
+	Locations   stringList `json:"location,omitempty"`
+	Identifiers stringList `json:"identifier,omitempty"`
+	Title       string     `json:"title,omitempty"`
+	Creator     string     `json:"creator,omitempty"`
+	Album       string     `json:"album,omitempty"`
+	Duration    int        `json:"duration,omitempty"` // milliseconds
+}
+
+// stringList accepts both the array form of the JSPF spec and the single
+// strings some players write instead
+type stringList []string
+
+func (l *stringList) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*l = stringList{single}
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+func parseJSPF(data []byte) (PortablePlaylist, error) {
+	var file jspfFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return PortablePlaylist{}, fmt.Errorf("%w as JSPF: %v", ErrInvalidFile, err)
+	}
+
+	playlist := PortablePlaylist{Title: strings.TrimSpace(file.Playlist.Title), Description: strings.TrimSpace(file.Playlist.Annotation)}
+	for _, track := range file.Playlist.Tracks {
+		playlist.Tracks = append(playlist.Tracks, PortableTrack{
+			Locations:   trimAll(track.Locations),
+			Identifiers: trimAll(track.Identifiers),
+			Title:       strings.TrimSpace(track.Title),
+			Artist:      strings.TrimSpace(track.Creator),
+			Album:       strings.TrimSpace(track.Album),
+			DurationMs:  track.Duration,
+		})
+	}
+	return playlist, nil
+}
+
+func renderJSPF(playlist PortablePlaylist) ([]byte, error) {
+	file := jspfFile{Playlist: jspfPlaylist{
+		Title:      playlist.Title,
+		Annotation: playlist.Description,
+		Tracks:     []jspfTrack{},
+	}}
+	for _, track := range playlist.Tracks {
+		file.Playlist.Tracks = append(file.Playlist.Tracks, jspfTrack{
+			Locations:   track.Locations,
+			Identifiers: track.Identifiers,
+			Title:       track.Title,
+			Creator:     track.Artist,
+			Album:       track.Album,
+			Duration:    track.DurationMs,
+		})
+	}
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+func trimAll(values []string) []string {
+	var trimmed []string
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			trimmed = append(trimmed, value)
+		}
+	}
+	return trimmed
+}
+
+func oneLine(value string) string {
+	return strings.Join(strings.Fields(value), " ")
+}
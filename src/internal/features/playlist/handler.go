@@ -0,0 +1,336 @@
+package playlist
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yosp313/gotify/src/internal/utils"
+	"gorm.io/gorm"
+)
+
+type PlaylistHandler struct {
+	service *PlaylistService
+}
+
+type PlaylistRequest struct {
+	Name        string `json:"name" binding:"required,max=100"`
+	Description string `json:"description" binding:"max=1000"`
+	Public      bool   `json:"public"`
+	Rules       string `json:"rules" binding:"max=2000"`
+}
+
+// Largest playlist file accepted for import
+const maxImportSize = 5 << 20
+
+type PreviewRequest struct {
+	Rules string `json:"rules" binding:"required,max=2000"`
+}
+
+type AddSongRequest struct {
+	SongId   string `json:"song_id" binding:"required,uuid"`
+	Position *int   `json:"position" binding:"omitempty,min=0"`
+	Version  *int   `json:"version" binding:"omitempty,min=0"` // optional, the playlist version the client saw
+}
+
+type CollaboratorRequest struct {
+	Role string `json:"role" binding:"required,oneof=editor viewer"`
+}
+
+type UndoRequest struct {
+	Count int `json:"count" binding:"omitempty,min=1"`
+}
+
+func NewPlaylistHandler(service *PlaylistService) *PlaylistHandler {
+	return &PlaylistHandler{service: service}
+}
+
+func (h *PlaylistHandler) Create(c *gin.Context) {
+	var req PlaylistRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.HandleErrorWithMessage(c, err, "Invalid request body", 400)
+		return
+	}
+
+	playlist, err := h.service.Create(c.GetString("user_id"), req.Name, req.Description, req.Public, req.Rules)
+	if err != nil {
+		handlePlaylistError(c, err, "Failed to create playlist")
+		return
+	}
+
+	c.JSON(201, playlist)
+}
+
+func (h *PlaylistHandler) GetMine(c *gin.Context) {
+	playlists, err := h.service.GetMine(c.GetString("user_id"))
+	if err != nil {
+		utils.HandleErrorWithMessage(c, err, "Failed to retrieve playlists", 500)
+		return
+	}
+
+	c.JSON(200, gin.H{"playlists": playlists})
+}
+
+func (h *PlaylistHandler) GetById(c *gin.Context) {
+	playlist, err := h.service.Get(c.GetString("user_id"), c.Param("id"))
+	if err != nil {
+		handlePlaylistError(c, err, "Failed to retrieve playlist")
+		return
+	}
+
+	c.JSON(200, playlist)
+}
+
+func (h *PlaylistHandler) Update(c *gin.Context) {
+	var req PlaylistRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.HandleErrorWithMessage(c, err, "Invalid request body", 400)
+		return
+	}
+
+	playlist, err := h.service.Update(c.GetString("user_id"), c.Param("id"), req.Name, req.Description, req.Public, req.Rules)
+	if err != nil {
+		handlePlaylistError(c, err, "Failed to update playlist")
+		return
+	}
+
+	c.JSON(200, playlist)
+}
+
+func (h *PlaylistHandler) Preview(c *gin.Context) {
+	var req PreviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.HandleErrorWithMessage(c, err, "Invalid request body", 400)
+		return
+	}
+
+	songs, err := h.service.Preview(c.GetString("user_id"), req.Rules)
+	if err != nil {
+		handlePlaylistError(c, err, "Failed to evaluate rules")
+		return
+	}
+
+	c.JSON(200, gin.H{"songs": songs})
+}
+
+func (h *PlaylistHandler) Delete(c *gin.Context) {
+	if err := h.service.Delete(c.GetString("user_id"), c.Param("id")); err != nil {
+		handlePlaylistError(c, err, "Failed to delete playlist")
+		return
+	}
+
+	c.Status(204)
+}
+
+func (h *PlaylistHandler) AddSong(c *gin.Context) {
+	var req AddSongRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.HandleErrorWithMessage(c, err, "Invalid request body", 400)
+		return
+	}
+
+	entry, err := h.service.AddSong(c.GetString("user_id"), c.Param("id"), req.SongId, req.Position, req.Version)
+	if err != nil {
+		handlePlaylistError(c, err, "Failed to add song to playlist")
+		return
+	}
+
+	c.JSON(201, entry)
+}
+
+func (h *PlaylistHandler) RemoveEntry(c *gin.Context) {
+	var version *int
+	if c.Query("version") != "" {
+		value, err := strconv.Atoi(c.Query("version"))
+		if err != nil {
+			utils.HandleErrorWithMessage(c, err, "Invalid version", 400)
+			return
+		}
+		version = &value
+	}
+
+	removed, err := h.service.RemoveEntry(c.GetString("user_id"), c.Param("id"), c.Param("entryId"), version)
+	if err != nil {
+		handlePlaylistError(c, err, "Failed to remove song from playlist")
+		return
+	}
+	if !removed {
+		c.JSON(404, gin.H{"error": "Playlist entry not found"})
+		return
+	}
+
+	c.Status(204)
+}
+
+func (h *PlaylistHandler) SetCollaborator(c *gin.Context) {
+	var req CollaboratorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.HandleErrorWithMessage(c, err, "Invalid request body", 400)
+		return
+	}
+	if _, err := uuid.Parse(c.Param("userId")); err != nil {
+		utils.HandleErrorWithMessage(c, err, "Invalid user id", 400)
+		return
+	}
+
+	collaborator, err := h.service.SetCollaborator(c.GetString("user_id"), c.Param("id"), c.Param("userId"), req.Role)
+	if err != nil {
+		handlePlaylistError(c, err, "Failed to save collaborator")
+		return
+	}
+
+	c.JSON(200, collaborator)
+}
+
+func (h *PlaylistHandler) RemoveCollaborator(c *gin.Context) {
+	removed, err := h.service.RemoveCollaborator(c.GetString("user_id"), c.Param("id"), c.Param("userId"))
+	if err != nil {
+		handlePlaylistError(c, err, "Failed to remove collaborator")
+		return
+	}
+	if !removed {
+		c.JSON(404, gin.H{"error": "Collaborator not found"})
+		return
+	}
+
+	c.Status(204)
+}
+
+func (h *PlaylistHandler) GetChanges(c *gin.Context) {
+	page, limit := utils.GetPagination(c)
+
+	changes, total, err := h.service.GetChanges(c.GetString("user_id"), c.Param("id"), page, limit)
+	if err != nil {
+		handlePlaylistError(c, err, "Failed to retrieve changes")
+		return
+	}
+
+	c.JSON(200, gin.H{"items": changes, "total": total, "page": page, "limit": limit})
+}
+
+func (h *PlaylistHandler) Undo(c *gin.Context) {
+	var req UndoRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		utils.HandleErrorWithMessage(c, err, "Invalid request body", 400)
+		return
+	}
+
+	reverts, err := h.service.Undo(c.GetString("user_id"), c.Param("id"), req.Count)
+	if err != nil {
+		handlePlaylistError(c, err, "Failed to undo changes")
+		return
+	}
+
+	c.JSON(200, gin.H{"reverted": reverts})
+}
+
+func (h *PlaylistHandler) Export(c *gin.Context) {
+	format := strings.ToLower(c.DefaultQuery("format", FormatM3U8))
+	if format == "m3u" {
+		format = FormatM3U8
+	}
+	if format != FormatM3U8 && format != FormatXSPF && format != FormatJSPF {
+		utils.HandleErrorWithMessage(c, ErrUnknownFormat, "Invalid format", 400)
+		return
+	}
+
+	portable, err := h.service.Export(c.GetString("user_id"), c.Param("id"), apiBaseUrl(c))
+	if err != nil {
+		handlePlaylistError(c, err, "Failed to export playlist")
+		return
+	}
+
+	data, err := RenderPlaylistFile(format, portable)
+	if err != nil {
+		utils.HandleErrorWithMessage(c, err, "Failed to export playlist", 500)
+		return
+	}
+
+	fileName := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`"\/:*?<>|`, r) || r < 0x20 {
+			return '_'
+		}
+		return r
+	}, portable.Title)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, fileName, format))
+	c.Data(200, ContentType(format)+"; charset=utf-8", data)
+}
+
+func (h *PlaylistHandler) Import(c *gin.Context) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		utils.HandleErrorWithMessage(c, err, "A playlist file is required", 400)
+		return
+	}
+	if file.Size > maxImportSize {
+		utils.HandleErrorWithMessage(c, errors.New("file too large"), "Playlist files can be at most 5MB", 400)
+		return
+	}
+
+	reader, err := file.Open()
+	if err != nil {
+		utils.HandleErrorWithMessage(c, err, "Failed to read playlist file", 400)
+		return
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(io.LimitReader(reader, maxImportSize))
+	if err != nil {
+		utils.HandleErrorWithMessage(c, err, "Failed to read playlist file", 400)
+		return
+	}
+
+	format := strings.ToLower(c.PostForm("format"))
+	if format == "" {
+		format = DetectFormat(file.Filename, data)
+	}
+
+	report, err := h.service.Import(c.GetString("user_id"), c.PostForm("name"), format, data)
+	if err != nil {
+		handlePlaylistError(c, err, "Failed to import playlist")
+		return
+	}
+
+	c.JSON(201, report)
+}
+
+// apiBaseUrl is the absolute URL of the API root the request came in on, e.g. https://host/api/v1
+func apiBaseUrl(c *gin.Context) string {
+	prefix := c.FullPath()
+	if i := strings.Index(prefix, "/playlists"); i >= 0 {
+		prefix = prefix[:i]
+	}
+	return utils.BaseUrl(c) + prefix
+}
+
+func handlePlaylistError(c *gin.Context, err error, message string) {
+	var ruleErr *RuleError
+
+	switch {
+	case errors.As(err, &ruleErr):
+		c.JSON(400, gin.H{"message": "Invalid playlist rules", "error": ruleErr.Message, "position": ruleErr.Position})
+	case errors.Is(err, ErrSmartPlaylist):
+		utils.HandleErrorWithMessage(c, err, "Songs can't be added or removed by hand", 409)
+	case errors.Is(err, ErrPlaylistConflict):
+		utils.HandleErrorWithMessage(c, err, "Playlist was changed by someone else, reload it and try again", 409)
+	case errors.Is(err, ErrNothingToUndo):
+		utils.HandleErrorWithMessage(c, err, "Nothing to undo", 409)
+	case errors.Is(err, ErrInvalidRole), errors.Is(err, ErrOwnerCollaborator):
+		utils.HandleErrorWithMessage(c, err, "Invalid collaborator", 400)
+	case errors.Is(err, ErrUnknownFormat), errors.Is(err, ErrEmptyImport), errors.Is(err, ErrInvalidFile), errors.Is(err, ErrTooManyTracks):
+		utils.HandleErrorWithMessage(c, err, "Invalid playlist file", 400)
+	case errors.Is(err, ErrUnknownUser):
+		c.JSON(404, gin.H{"error": "User not found"})
+	case errors.Is(err, gorm.ErrRecordNotFound), errors.Is(err, ErrPlaylistHidden):
+		// Private playlists look the same as missing ones to other users
+		c.JSON(404, gin.H{"error": "Playlist not found"})
+	case errors.Is(err, ErrNotPlaylistOwner), errors.Is(err, ErrNotPlaylistEditor):
+		utils.HandleErrorWithMessage(c, err, "Forbidden", 403)
+	default:
+		utils.HandleErrorWithMessage(c, err, message, 500)
+	}
+}
@@ -0,0 +1,26 @@
+package playlist
+
+import "github.com/google/uuid"
+
+type PlaylistRepository interface {
+	Create(playlist *Playlist) error
+	Update(playlist *Playlist) error
+	Delete(id string) error
+	GetById(id string) (Playlist, error)
+	GetWithEntries(id string) (Playlist, error)
+	GetAccessible(userId string) ([]Playlist, error)
+	SongExists(songId string) (bool, error)
+	UserExists(userId string) (bool, error)
+	AddEntry(entry *PlaylistEntry, position *int, version *int) error
+	RemoveEntry(playlistId, entryId, userId string, version *int) (bool, error)
+	AddEntries(playlistId, userId string, songIds []uuid.UUID) error
+	FindSongs(ownerId string, rule *Rule) ([]Song, error)
+	FindSongById(id string) (Song, error)
+	FindSongByFingerprint(fingerprint string) (Song, error)
+	FindSongsByFileName(fileName string) ([]Song, error)
+	FindSongsByTitle(title string) ([]Song, error)
+	SaveCollaborator(collaborator *PlaylistCollaborator) error
+	RemoveCollaborator(playlistId, userId string) (bool, error)
+	GetChanges(playlistId string, page, limit int) ([]PlaylistChange, int64, error)
+	Undo(playlistId, userId string, count int) ([]PlaylistChange, error)
+}
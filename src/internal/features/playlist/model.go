@@ -0,0 +1,193 @@
+package playlist
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrNotPlaylistOwner  = errors.New("only the owner can change this playlist")
+	ErrNotPlaylistEditor = errors.New("only the owner and editors can change the songs of this playlist")
+	ErrPlaylistHidden    = errors.New("playlist is private")
+	ErrSmartPlaylist     = errors.New("smart playlists are filled by their rules")
+	ErrPlaylistConflict  = errors.New("playlist was changed in the meantime")
+	ErrNothingToUndo     = errors.New("there are no changes to undo")
+	ErrInvalidRole       = errors.New("role must be editor or viewer")
+	ErrOwnerCollaborator = errors.New("the owner can't be added as a collaborator")
+	ErrUnknownUser       = errors.New("user not found")
+)
+
+const (
+	RoleOwner  = "owner"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+// Change log actions
+const (
+	ChangeAdd    = "add"
+	ChangeRemove = "remove"
+)
+
+// MaxUndo is how many changes a single undo request can revert
+const MaxUndo = 50
+
+type Playlist struct {
+	Id          uuid.UUID `json:"id" db:"id" gorm:"primaryKey"`
+	OwnerId     uuid.UUID `json:"owner_id" db:"owner_id" gorm:"not null;index"`
+	Name        string    `json:"name" db:"name" gorm:"not null"`
+	Description string    `json:"description" db:"description"`
+	Public      bool      `json:"public" db:"public" gorm:"not null;default:false"`
+	Rules       string    `json:"rules,omitempty" db:"rules"`                     // empty for hand-curated playlists
+	Version     int       `json:"version" db:"version" gorm:"not null;default:0"` // bumped on every change to the songs
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+
+	// Relationships
+	Owner         User                   `json:"owner" gorm:"foreignKey:OwnerId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Entries       []PlaylistEntry        `json:"entries,omitempty" gorm:"foreignKey:PlaylistId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Collaborators []PlaylistCollaborator `json:"collaborators,omitempty" gorm:"foreignKey:PlaylistId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+
+	// Songs currently matching the rules of a smart playlist, evaluated on read
+	Songs []Song `json:"songs,omitempty" gorm:"-"`
+}
+
+type PlaylistEntry struct {
+	Id         uuid.UUID `json:"id" db:"id" gorm:"primaryKey"`
+	PlaylistId uuid.UUID `json:"playlist_id" db:"playlist_id" gorm:"not null;index"`
+	SongId     uuid.UUID `json:"song_id" db:"song_id" gorm:"not null;index"`
+	Position   int       `json:"position" db:"position" gorm:"not null"`
+	AddedBy    uuid.UUID `json:"added_by" db:"added_by" gorm:"not null"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+
+	// Relationships
+	Song Song `json:"song" gorm:"foreignKey:SongId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+// PlaylistCollaborator gives another user access to a playlist, editors can add and remove songs
+type PlaylistCollaborator struct {
+	PlaylistId uuid.UUID `json:"playlist_id" db:"playlist_id" gorm:"primaryKey"`
+	UserId     uuid.UUID `json:"user_id" db:"user_id" gorm:"primaryKey;index"`
+	Role       string    `json:"role" db:"role" gorm:"not null"`
+	InvitedBy  uuid.UUID `json:"invited_by" db:"invited_by" gorm:"not null"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+
+	// Relationships
+	User User `json:"user" gorm:"foreignKey:UserId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+// PlaylistChange records a song being added to or removed from a playlist
+type PlaylistChange struct {
+	Id         uuid.UUID  `json:"id" db:"id" gorm:"primaryKey"`
+	PlaylistId uuid.UUID  `json:"playlist_id" db:"playlist_id" gorm:"not null;index"`
+	UserId     uuid.UUID  `json:"user_id" db:"user_id" gorm:"not null"`
+	Action     string     `json:"action" db:"action" gorm:"not null"`
+	EntryId    uuid.UUID  `json:"entry_id" db:"entry_id" gorm:"not null"`
+	SongId     uuid.UUID  `json:"song_id" db:"song_id" gorm:"not null"`
+	Position   int        `json:"position" db:"position" gorm:"not null"`
+	Version    int        `json:"version" db:"version" gorm:"not null"` // playlist version after the change
+	UndoOf     *uuid.UUID `json:"undo_of,omitempty" db:"undo_of"`
+	Undone     bool       `json:"undone" db:"undone" gorm:"not null;default:false"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+
+	// Relationships
+	User User  `json:"user" gorm:"foreignKey:UserId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Song *Song `json:"song,omitempty" gorm:"foreignKey:SongId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+type User struct {
+	Id       uuid.UUID `json:"id"`
+	FullName string    `json:"full_name"`
+}
+
+type Song struct {
+	Id       uuid.UUID `json:"id"`
+	Title    string    `json:"title"`
+	ArtistId uuid.UUID `json:"artist_id"`
+	Duration int       `json:"duration"`
+	Genre    string    `json:"genre"`
+	Bpm      int       `json:"bpm"`
+	// Quarantined and removed songs stay in playlists but can't be played
+	Status string `json:"status"`
+
+	Filename    string `json:"-"`
+	Fingerprint string `json:"-"`
+
+	// Relationships
+	Artist *User `json:"artist,omitempty" gorm:"foreignKey:ArtistId;references:Id"`
+}
+
+func NewPlaylist(ownerId, name, description string, public bool, rules string) (*Playlist, error) {
+	ownerUUID, err := uuid.Parse(ownerId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Playlist{
+		Id:          uuid.New(),
+		OwnerId:     ownerUUID,
+		Name:        name,
+		Description: description,
+		Public:      public,
+		Rules:       rules,
+	}, nil
+}
+
+func NewPlaylistEntry(playlistId, songId, addedBy uuid.UUID) *PlaylistEntry {
+	return &PlaylistEntry{
+		Id:         uuid.New(),
+		PlaylistId: playlistId,
+		SongId:     songId,
+		AddedBy:    addedBy,
+	}
+}
+
+func NewPlaylistChange(entry *PlaylistEntry, userId uuid.UUID, action string) *PlaylistChange {
+	return &PlaylistChange{
+		Id:         uuid.New(),
+		PlaylistId: entry.PlaylistId,
+		UserId:     userId,
+		Action:     action,
+		EntryId:    entry.Id,
+		SongId:     entry.SongId,
+		Position:   entry.Position,
+	}
+}
+
+func IsValidRole(role string) bool {
+	return role == RoleEditor || role == RoleViewer
+}
+
+// RoleOf returns the user's role on the playlist, or "" when they have none.
+// Collaborators must be loaded
+func (p *Playlist) RoleOf(userId string) string {
+	if p.OwnerId.String() == userId {
+		return RoleOwner
+	}
+	for _, collaborator := range p.Collaborators {
+		if collaborator.UserId.String() == userId {
+			return collaborator.Role
+		}
+	}
+	return ""
+}
+
+func (p *Playlist) CanView(userId string) bool {
+	return p.Public || p.RoleOf(userId) != ""
+}
+
+// CanEdit reports whether the user may add and remove songs
+func (p *Playlist) CanEdit(userId string) bool {
+	role := p.RoleOf(userId)
+	return role == RoleOwner || role == RoleEditor
+}
+
+func (p *Playlist) IsOwner(userId string) bool {
+	return p.OwnerId.String() == userId
+}
+
+func (p *Playlist) IsSmart() bool {
+	return p.Rules != ""
+}
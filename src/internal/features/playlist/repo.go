@@ -0,0 +1,407 @@
+package playlist
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type SqlPlaylistRepository struct {
+	db *gorm.DB
+}
+
+func NewSqlPlaylistRepository(db *gorm.DB) *SqlPlaylistRepository {
+	return &SqlPlaylistRepository{db: db}
+}
+
+func (r *SqlPlaylistRepository) Create(playlist *Playlist) error {
+	return r.db.Omit("Owner", "Entries").Create(playlist).Error
+}
+
+func (r *SqlPlaylistRepository) Update(playlist *Playlist) error {
+	// The version is left alone, it belongs to the song changes running alongside
+	return r.db.Model(playlist).
+		Select("name", "description", "public", "rules", "updated_at").
+		Updates(playlist).Error
+}
+
+func (r *SqlPlaylistRepository) Delete(id string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for _, model := range []any{&PlaylistEntry{}, &PlaylistCollaborator{}, &PlaylistChange{}} {
+			if err := tx.Where("playlist_id = ?", id).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Where("id = ?", id).Delete(&Playlist{}).Error
+	})
+}
+
+func (r *SqlPlaylistRepository) GetById(id string) (Playlist, error) {
+	var playlist Playlist
+	if err := r.db.Preload("Owner").Preload("Collaborators.User").First(&playlist, "id = ?", id).Error; err != nil {
+		return Playlist{}, err
+	}
+	return playlist, nil
+}
+
+func (r *SqlPlaylistRepository) GetWithEntries(id string) (Playlist, error) {
+	var playlist Playlist
+	err := r.db.Preload("Owner").
+		Preload("Collaborators.User").
+		Preload("Entries", func(db *gorm.DB) *gorm.DB {
+			return db.Order("position")
+		}).
+		Preload("Entries.Song.Artist").
+		First(&playlist, "id = ?", id).Error
+	if err != nil {
+		return Playlist{}, err
+	}
+	return playlist, nil
+}
+
+// GetAccessible returns the playlists the user owns or collaborates on
+func (r *SqlPlaylistRepository) GetAccessible(userId string) ([]Playlist, error) {
+	var playlists []Playlist
+	err := r.db.Preload("Owner").
+		Preload("Collaborators.User").
+		Where("owner_id = ? OR id IN (?)", userId,
+			r.db.Model(&PlaylistCollaborator{}).Select("playlist_id").Where("user_id = ?", userId)).
+		Order("name").
+		Find(&playlists).Error
+	if err != nil {
+		return nil, err
+	}
+	return playlists, nil
+}
+
+func (r *SqlPlaylistRepository) SongExists(songId string) (bool, error) {
+	var count int64
+	if err := r.db.Model(&Song{}).Scopes(available).Where("id = ?", songId).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *SqlPlaylistRepository) UserExists(userId string) (bool, error) {
+	var count int64
+	if err := r.db.Model(&User{}).Where("id = ?", userId).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// AddEntry inserts the entry at position, or appends it when position is nil,
+// shifting the entries after it down by one. When version is set the playlist
+// must still be at that version
+func (r *SqlPlaylistRepository) AddEntry(entry *PlaylistEntry, position *int, version *int) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		newVersion, err := lockPlaylist(tx, entry.PlaylistId.String(), version)
+		if err != nil {
+			return err
+		}
+
+		change, err := insertEntry(tx, entry, position)
+		if err != nil {
+			return err
+		}
+
+		change.Version = newVersion
+		return tx.Omit("User", "Song").Create(change).Error
+	})
+}
+
+func (r *SqlPlaylistRepository) RemoveEntry(playlistId, entryId, userId string, version *int) (bool, error) {
+	removed := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var entry PlaylistEntry
+		result := tx.Where("id = ? AND playlist_id = ?", entryId, playlistId).Limit(1).Find(&entry)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+
+		newVersion, err := lockPlaylist(tx, playlistId, version)
+		if err != nil {
+			return err
+		}
+
+		change, err := deleteEntry(tx, entryId, uuid.MustParse(userId))
+		if err != nil || change == nil {
+			return err
+		}
+
+		removed = true
+		change.Version = newVersion
+		return tx.Omit("User", "Song").Create(change).Error
+	})
+	return removed, err
+}
+
+// AddEntries appends songs in order as a single change to the playlist
+func (r *SqlPlaylistRepository) AddEntries(playlistId, userId string, songIds []uuid.UUID) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		newVersion, err := lockPlaylist(tx, playlistId, nil)
+		if err != nil {
+			return err
+		}
+
+		for _, songId := range songIds {
+			change, err := insertEntry(tx, NewPlaylistEntry(uuid.MustParse(playlistId), songId, uuid.MustParse(userId)), nil)
+			if err != nil {
+				return err
+			}
+
+			change.Version = newVersion
+			if err := tx.Omit("User", "Song").Create(change).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *SqlPlaylistRepository) FindSongById(id string) (Song, error) {
+	var song Song
+	if err := r.db.Scopes(available).Preload("Artist").First(&song, "id = ?", id).Error; err != nil {
+		return Song{}, err
+	}
+	return song, nil
+}
+
+func (r *SqlPlaylistRepository) FindSongByFingerprint(fingerprint string) (Song, error) {
+	var song Song
+	if err := r.db.Scopes(available).Preload("Artist").First(&song, "fingerprint = ?", fingerprint).Error; err != nil {
+		return Song{}, err
+	}
+	return song, nil
+}
+
+func (r *SqlPlaylistRepository) FindSongsByFileName(fileName string) ([]Song, error) {
+	var songs []Song
+	if err := r.db.Scopes(available).Preload("Artist").Where("filename = ?", fileName).Find(&songs).Error; err != nil {
+		return nil, err
+	}
+	return songs, nil
+}
+
+// FindSongsByTitle matches titles case-insensitively
+func (r *SqlPlaylistRepository) FindSongsByTitle(title string) ([]Song, error) {
+	var songs []Song
+	if err := r.db.Scopes(available).Preload("Artist").Where("LOWER(title) = LOWER(?)", title).Find(&songs).Error; err != nil {
+		return nil, err
+	}
+	return songs, nil
+}
+
+func (r *SqlPlaylistRepository) SaveCollaborator(collaborator *PlaylistCollaborator) error {
+	return r.db.Omit("User").Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "playlist_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"role"}),
+	}).Create(collaborator).Error
+}
+
+func (r *SqlPlaylistRepository) RemoveCollaborator(playlistId, userId string) (bool, error) {
+	result := r.db.Where("playlist_id = ? AND user_id = ?", playlistId, userId).Delete(&PlaylistCollaborator{})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *SqlPlaylistRepository) GetChanges(playlistId string, page, limit int) ([]PlaylistChange, int64, error) {
+	var total int64
+	if err := r.db.Model(&PlaylistChange{}).Where("playlist_id = ?", playlistId).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var changes []PlaylistChange
+	err := r.db.Preload("User").
+		Preload("Song").
+		Where("playlist_id = ?", playlistId).
+		Order("created_at DESC").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&changes).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return changes, total, nil
+}
+
+// Undo reverts the last count changes that weren't undone yet, newest first. Every
+// revert is logged as a change of its own pointing at the change it reverted
+func (r *SqlPlaylistRepository) Undo(playlistId, userId string, count int) ([]PlaylistChange, error) {
+	var reverts []PlaylistChange
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		newVersion, err := lockPlaylist(tx, playlistId, nil)
+		if err != nil {
+			return err
+		}
+
+		var changes []PlaylistChange
+		err = tx.Where("playlist_id = ? AND undone = ? AND undo_of IS NULL", playlistId, false).
+			Order("created_at DESC").
+			Limit(count).
+			Find(&changes).Error
+		if err != nil {
+			return err
+		}
+		if len(changes) == 0 {
+			return ErrNothingToUndo
+		}
+
+		userUUID := uuid.MustParse(userId)
+		for _, change := range changes {
+			var revert *PlaylistChange
+			switch change.Action {
+			case ChangeAdd:
+				revert, err = deleteEntry(tx, change.EntryId.String(), userUUID)
+			case ChangeRemove:
+				revert, err = restoreEntry(tx, change, userUUID)
+			}
+			if err != nil {
+				return err
+			}
+
+			if err := tx.Model(&change).Update("undone", true).Error; err != nil {
+				return err
+			}
+
+			// The entry may have been removed by hand already, then there's nothing to revert
+			if revert == nil {
+				continue
+			}
+			revert.UndoOf = &change.Id
+			revert.Version = newVersion
+			if err := tx.Omit("User", "Song").Create(revert).Error; err != nil {
+				return err
+			}
+			reverts = append(reverts, *revert)
+		}
+		return nil
+	})
+	return reverts, err
+}
+
+// lockPlaylist bumps the playlist version before its entries are touched. The update
+// takes a write lock on the row, so concurrent changes to the same playlist run one
+// after the other instead of interleaving their position shifts
+func lockPlaylist(tx *gorm.DB, playlistId string, version *int) (int, error) {
+	query := tx.Model(&Playlist{}).Where("id = ?", playlistId)
+	if version != nil {
+		query = query.Where("version = ?", *version)
+	}
+
+	result := query.Updates(map[string]any{"version": gorm.Expr("version + 1"), "updated_at": time.Now()})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if result.RowsAffected == 0 {
+		if version != nil {
+			return 0, ErrPlaylistConflict
+		}
+		return 0, gorm.ErrRecordNotFound
+	}
+
+	var playlist Playlist
+	if err := tx.Select("version").First(&playlist, "id = ?", playlistId).Error; err != nil {
+		return 0, err
+	}
+	return playlist.Version, nil
+}
+
+func insertEntry(tx *gorm.DB, entry *PlaylistEntry, position *int) (*PlaylistChange, error) {
+	var count int64
+	if err := tx.Model(&PlaylistEntry{}).Where("playlist_id = ?", entry.PlaylistId).Count(&count).Error; err != nil {
+		return nil, err
+	}
+
+	entry.Position = int(count)
+	if position != nil && *position < int(count) {
+		entry.Position = max(*position, 0)
+		err := tx.Model(&PlaylistEntry{}).
+			Where("playlist_id = ? AND position >= ?", entry.PlaylistId, entry.Position).
+			Update("position", gorm.Expr("position + 1")).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Omit("Song").Create(entry).Error; err != nil {
+		return nil, err
+	}
+	return NewPlaylistChange(entry, entry.AddedBy, ChangeAdd), nil
+}
+
+// restoreEntry puts a removed entry back where it was, under its old id so older changes
+// still find it, and credited to whoever added it in the first place
+func restoreEntry(tx *gorm.DB, removal PlaylistChange, userId uuid.UUID) (*PlaylistChange, error) {
+	entry := NewPlaylistEntry(removal.PlaylistId, removal.SongId, userId)
+	entry.Id = removal.EntryId
+
+	var added PlaylistChange
+	result := tx.Where("entry_id = ? AND action = ? AND undo_of IS NULL", removal.EntryId, ChangeAdd).
+		Order("created_at").
+		Limit(1).
+		Find(&added)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected > 0 {
+		entry.AddedBy = added.UserId
+	}
+
+	position := removal.Position
+	change, err := insertEntry(tx, entry, &position)
+	if err != nil {
+		return nil, err
+	}
+	// The revert is logged as the undoer's, not the adder's
+	change.UserId = userId
+	return change, nil
+}
+
+// deleteEntry removes an entry and closes the gap it leaves, it returns nil when the entry is gone already
+func deleteEntry(tx *gorm.DB, entryId string, userId uuid.UUID) (*PlaylistChange, error) {
+	var entry PlaylistEntry
+	result := tx.Where("id = ?", entryId).Limit(1).Find(&entry)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return nil, result.Error
+	}
+
+	if err := tx.Delete(&entry).Error; err != nil {
+		return nil, err
+	}
+
+	err := tx.Model(&PlaylistEntry{}).
+		Where("playlist_id = ? AND position > ?", entry.PlaylistId, entry.Position).
+		Update("position", gorm.Expr("position - 1")).Error
+	if err != nil {
+		return nil, err
+	}
+	return NewPlaylistChange(&entry, userId, ChangeRemove), nil
+}
+
+// FindSongs returns the songs matching a smart playlist rule, per-user fields refer to ownerId
+func (r *SqlPlaylistRepository) FindSongs(ownerId string, rule *Rule) ([]Song, error) {
+	condition, args := rule.Condition(ownerId)
+	order, orderArgs := rule.Order(ownerId)
+
+	var songs []Song
+	err := r.db.Model(&Song{}).
+		Preload("Artist").
+		Select("songs.*").
+		Joins("JOIN users ON users.id = songs.artist_id").
+		Scopes(available).
+		Where(condition, args...).
+		Order(clause.OrderBy{Expression: clause.Expr{SQL: order, Vars: orderArgs}}).
+		Limit(rule.Limit).
+		Find(&songs).Error
+	if err != nil {
+		return nil, err
+	}
+	return songs, nil
+}
+
+// available leaves out songs moderators quarantined or took down
+func available(db *gorm.DB) *gorm.DB {
+	return db.Where("songs.status = ?", "active")
+}
@@ -0,0 +1,22 @@
+package playlist
+
+import "github.com/gin-gonic/gin"
+
+func SetupRoutes(c *gin.RouterGroup, h *PlaylistHandler, authMiddleware gin.HandlerFunc) {
+	c.Use(authMiddleware)
+
+	c.POST("", h.Create)
+	c.GET("", h.GetMine)
+	c.POST("/preview", h.Preview)
+	c.POST("/import", h.Import)
+	c.GET("/:id", h.GetById)
+	c.PUT("/:id", h.Update)
+	c.DELETE("/:id", h.Delete)
+	c.POST("/:id/songs", h.AddSong)
+	c.DELETE("/:id/songs/:entryId", h.RemoveEntry)
+	c.PUT("/:id/collaborators/:userId", h.SetCollaborator)
+	c.DELETE("/:id/collaborators/:userId", h.RemoveCollaborator)
+	c.GET("/:id/changes", h.GetChanges)
+	c.POST("/:id/undo", h.Undo)
+	c.GET("/:id/export", h.Export)
+}
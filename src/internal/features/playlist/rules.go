@@ -0,0 +1,579 @@
+package playlist
+
+import (
+	"fmt"
+	"iter"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Smart playlist rules look like
+//
+//	genre = "rock" AND duration < 300 AND liked AND added_in_last 30d SORT BY plays DESC LIMIT 50
+//
+// Conditions combine with AND, OR, NOT and parentheses. Rules are compiled into
+// parameterized SQL over a fixed set of fields, user input never ends up in the query text
+const (
+	DefaultRuleLimit = 100
+	MaxRuleLimit     = 1000
+)
+
+type fieldKind int
+
+const (
+	fieldString fieldKind = iota
+	fieldNumber
+	fieldFlag
+)
+
+type ruleField struct {
+	kind fieldKind
+	// SQL expression for the field, a ? stands for the playlist owner's id
+	sql string
+}
+
+var ruleFields = map[string]ruleField{
+	"title":    {fieldString, "songs.title"},
+	"genre":    {fieldString, "songs.genre"},
+	"artist":   {fieldString, "users.full_name"},
+	"duration": {fieldNumber, "songs.duration"},
+	"bpm":      {fieldNumber, "songs.bpm"},
+	"plays":    {fieldNumber, "(SELECT COUNT(*) FROM plays WHERE plays.song_id = songs.id AND plays.user_id = ? AND plays.counted = true)"},
+	"liked":    {fieldFlag, "EXISTS (SELECT 1 FROM likes WHERE likes.song_id = songs.id AND likes.user_id = ?)"},
+}
+
+// Time window conditions, the first ? is the owner's id when present and the last one the cutoff
+var windowFields = map[string]string{
+	"added_in_last":  "songs.created_at >= ?",
+	"liked_in_last":  "EXISTS (SELECT 1 FROM likes WHERE likes.song_id = songs.id AND likes.user_id = ? AND likes.created_at >= ?)",
+	"played_in_last": "EXISTS (SELECT 1 FROM plays WHERE plays.song_id = songs.id AND plays.user_id = ? AND plays.counted = true AND plays.started_at >= ?)",
+}
+
+var sortFields = map[string]string{
+	"title":    "songs.title",
+	"artist":   "users.full_name",
+	"duration": "songs.duration",
+	"bpm":      "songs.bpm",
+	"added":    "songs.created_at",
+	"plays":    ruleFields["plays"].sql,
+	"random":   "RANDOM()",
+}
+
+var durationUnits = map[string]time.Duration{
+	"h": time.Hour,
+	"d": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour,
+	"m": 30 * 24 * time.Hour,
+	"y": 365 * 24 * time.Hour,
+}
+
+// RuleError points at the part of a rule that couldn't be understood
+type RuleError struct {
+	Position int    `json:"position"`
+	Message  string `json:"message"`
+}
+
+func (e *RuleError) Error() string {
+	return fmt.Sprintf("rule error at position %d: %s", e.Position, e.Message)
+}
+
+// Rule is a parsed smart playlist definition
+type Rule struct {
+	where    ruleNode
+	sortBy   string
+	sortDesc bool
+	Limit    int
+}
+
+// Condition renders the rule's filter as SQL with its arguments. Songs are joined with
+// their artist as users, and ownerId fills the per-user fields like liked and plays
+func (r *Rule) Condition(ownerId string) (string, []any) {
+	if r.where == nil {
+		return "1 = 1", nil
+	}
+	return r.where.sql(ownerId, time.Now())
+}
+
+// Order renders the ORDER BY expression and its arguments, newest songs come first by default
+func (r *Rule) Order(ownerId string) (string, []any) {
+	if r.sortBy == "" {
+		return "songs.created_at DESC", nil
+	}
+
+	expression := sortFields[r.sortBy]
+	if r.sortBy == "random" {
+		return expression, nil
+	}
+
+	direction := " ASC"
+	if r.sortDesc {
+		direction = " DESC"
+	}
+
+	var args []any
+	if strings.Contains(expression, "?") {
+		args = append(args, ownerId)
+	}
+	return expression + direction + ", songs.title ASC", args
+}
+
+type ruleNode interface {
+	sql(ownerId string, now time.Time) (string, []any)
+}
+
+type logicNode struct {
+	op          string
+	left, right ruleNode
+}
+
+func (n logicNode) sql(ownerId string, now time.Time) (string, []any) {
+	left, leftArgs := n.left.sql(ownerId, now)
+	right, rightArgs := n.right.sql(ownerId, now)
+	return "(" + left + " " + n.op + " " + right + ")", append(leftArgs, rightArgs...)
+}
+
+type notNode struct {
+	inner ruleNode
+}
+
+func (n notNode) sql(ownerId string, now time.Time) (string, []any) {
+	inner, args := n.inner.sql(ownerId, now)
+	return "NOT " + inner, args
+}
+
+type compareNode struct {
+	field  string
+	op     string
+	values []any
+}
+
+func (n compareNode) sql(ownerId string, now time.Time) (string, []any) {
+	field := ruleFields[n.field]
+
+	var args []any
+	if strings.Contains(field.sql, "?") {
+		args = append(args, ownerId)
+	}
+
+	switch {
+	case field.kind == fieldFlag:
+		if n.values[0] == true {
+			return field.sql, args
+		}
+		return "NOT " + field.sql, args
+	case n.op == "IN":
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(n.values)), ", ")
+		if field.kind == fieldString {
+			return "LOWER(" + field.sql + ") IN (" + placeholders + ")", append(args, n.values...)
+		}
+		return field.sql + " IN (" + placeholders + ")", append(args, n.values...)
+	case n.op == "~":
+		return "LOWER(" + field.sql + ") LIKE ? ESCAPE '\\'", append(args, "%"+escapeLike(n.values[0].(string))+"%")
+	case field.kind == fieldString:
+		return "LOWER(" + field.sql + ") " + n.op + " ?", append(args, n.values[0])
+	default:
+		return field.sql + " " + n.op + " ?", append(args, n.values[0])
+	}
+}
+
+type windowNode struct {
+	field  string
+	window time.Duration
+}
+
+func (n windowNode) sql(ownerId string, now time.Time) (string, []any) {
+	expression := windowFields[n.field]
+	cutoff := now.Add(-n.window)
+
+	if strings.Count(expression, "?") == 2 {
+		return expression, []any{ownerId, cutoff}
+	}
+	return expression, []any{cutoff}
+}
+
+// ParseRule parses and validates a smart playlist rule
+func ParseRule(text string) (*Rule, error) {
+	tokens, err := lexRule(text)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &ruleParser{tokens: tokens}
+	rule := &Rule{Limit: DefaultRuleLimit}
+
+	if !p.atKeyword("SORT") && !p.atKeyword("LIMIT") && !p.at(tokenEnd) {
+		rule.where, err = p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if p.atKeyword("SORT") {
+		p.next()
+		if !p.atKeyword("BY") {
+			return nil, p.errorf("expected BY after SORT")
+		}
+		p.next()
+
+		field := p.next()
+		if _, ok := sortFields[strings.ToLower(field.text)]; field.kind != tokenIdent || !ok {
+			return nil, &RuleError{field.pos, fmt.Sprintf("can't sort by %q, use one of %s", field.text, fieldList(maps.Keys(sortFields)))}
+		}
+		rule.sortBy = strings.ToLower(field.text)
+
+		if p.atKeyword("ASC") || p.atKeyword("DESC") {
+			rule.sortDesc = strings.EqualFold(p.next().text, "DESC")
+		}
+	}
+
+	if p.atKeyword("LIMIT") {
+		p.next()
+		limit := p.next()
+		value, err := strconv.Atoi(limit.text)
+		if limit.kind != tokenNumber || err != nil || value < 1 || value > MaxRuleLimit {
+			return nil, &RuleError{limit.pos, fmt.Sprintf("LIMIT must be a whole number between 1 and %d", MaxRuleLimit)}
+		}
+		rule.Limit = value
+	}
+
+	if !p.at(tokenEnd) {
+		return nil, p.errorf("unexpected %q", p.peek().text)
+	}
+	return rule, nil
+}
+
+type tokenKind int
+
+const (
+	tokenEnd tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenDuration
+	tokenOperator
+	tokenLeftParen
+	tokenRightParen
+	tokenComma
+)
+
+type ruleToken struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func lexRule(text string) ([]ruleToken, error) {
+	var tokens []ruleToken
+	runes := []rune(text)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		start := i
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+			continue
+		case r == '(':
+			tokens = append(tokens, ruleToken{tokenLeftParen, "(", start + 1})
+			i++
+		case r == ')':
+			tokens = append(tokens, ruleToken{tokenRightParen, ")", start + 1})
+			i++
+		case r == ',':
+			tokens = append(tokens, ruleToken{tokenComma, ",", start + 1})
+			i++
+		case r == '"' || r == '\'':
+			var b strings.Builder
+			i++
+			for i < len(runes) && runes[i] != r {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				b.WriteRune(runes[i])
+				i++
+			}
+			if i >= len(runes) {
+				return nil, &RuleError{start + 1, "unterminated string"}
+			}
+			i++
+			tokens = append(tokens, ruleToken{tokenString, b.String(), start + 1})
+		case unicode.IsDigit(r):
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			kind := tokenNumber
+			if i < len(runes) && unicode.IsLetter(runes[i]) {
+				for i < len(runes) && unicode.IsLetter(runes[i]) {
+					i++
+				}
+				kind = tokenDuration
+			}
+			tokens = append(tokens, ruleToken{kind, string(runes[start:i]), start + 1})
+		case unicode.IsLetter(r) || r == '_':
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, ruleToken{tokenIdent, string(runes[start:i]), start + 1})
+		case strings.ContainsRune("=!<>~", r):
+			i++
+			if i < len(runes) && runes[i] == '=' && r != '=' && r != '~' {
+				i++
+			}
+			op := string(runes[start:i])
+			if op == "!" {
+				return nil, &RuleError{start + 1, `unknown operator "!", did you mean "!="?`}
+			}
+			tokens = append(tokens, ruleToken{tokenOperator, op, start + 1})
+		default:
+			return nil, &RuleError{start + 1, fmt.Sprintf("unexpected character %q", r)}
+		}
+	}
+
+	return append(tokens, ruleToken{tokenEnd, "end of rule", len(runes) + 1}), nil
+}
+
+type ruleParser struct {
+	tokens []ruleToken
+	pos    int
+}
+
+func (p *ruleParser) peek() ruleToken {
+	return p.tokens[p.pos]
+}
+
+func (p *ruleParser) next() ruleToken {
+	token := p.tokens[p.pos]
+	if token.kind != tokenEnd {
+		p.pos++
+	}
+	return token
+}
+
+func (p *ruleParser) at(kind tokenKind) bool {
+	return p.peek().kind == kind
+}
+
+func (p *ruleParser) atKeyword(keyword string) bool {
+	return p.at(tokenIdent) && strings.EqualFold(p.peek().text, keyword)
+}
+
+func (p *ruleParser) errorf(format string, args ...any) error {
+	return &RuleError{p.peek().pos, fmt.Sprintf(format, args...)}
+}
+
+func (p *ruleParser) parseOr() (ruleNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.atKeyword("OR") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = logicNode{"OR", left, right}
+	}
+	return left, nil
+}
+
+func (p *ruleParser) parseAnd() (ruleNode, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.atKeyword("AND") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = logicNode{"AND", left, right}
+	}
+	return left, nil
+}
+
+func (p *ruleParser) parseNot() (ruleNode, error) {
+	if p.atKeyword("NOT") {
+		p.next()
+		inner, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{inner}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *ruleParser) parsePrimary() (ruleNode, error) {
+	if p.at(tokenLeftParen) {
+		p.next()
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.at(tokenRightParen) {
+			return nil, p.errorf("expected \")\" but found %q", p.peek().text)
+		}
+		p.next()
+		return inner, nil
+	}
+
+	if !p.at(tokenIdent) {
+		return nil, p.errorf("expected a field but found %q", p.peek().text)
+	}
+	name := p.next()
+	fieldName := strings.ToLower(name.text)
+
+	if _, ok := windowFields[fieldName]; ok {
+		window := p.next()
+		duration, err := parseWindow(window)
+		if err != nil {
+			return nil, err
+		}
+		return windowNode{fieldName, duration}, nil
+	}
+
+	field, ok := ruleFields[fieldName]
+	if !ok {
+		return nil, &RuleError{name.pos, fmt.Sprintf("unknown field %q, use one of %s", name.text, fieldList(maps.Keys(ruleFields), maps.Keys(windowFields)))}
+	}
+
+	if field.kind == fieldFlag {
+		return p.parseFlag(fieldName)
+	}
+
+	if p.atKeyword("IN") {
+		p.next()
+		return p.parseIn(fieldName, field)
+	}
+	if p.atKeyword("CONTAINS") {
+		p.next()
+		return p.parseComparison(fieldName, field, "~")
+	}
+
+	op := p.peek()
+	if op.kind != tokenOperator {
+		return nil, p.errorf("expected an operator after %q but found %q", name.text, op.text)
+	}
+	p.next()
+	return p.parseComparison(fieldName, field, op.text)
+}
+
+// parseFlag handles bare flags like "liked" as well as "liked = false"
+func (p *ruleParser) parseFlag(name string) (ruleNode, error) {
+	if !p.at(tokenOperator) {
+		return compareNode{name, "=", []any{true}}, nil
+	}
+
+	op := p.next()
+	if op.text != "=" && op.text != "!=" {
+		return nil, &RuleError{op.pos, fmt.Sprintf("%s can only be compared with = or !=", name)}
+	}
+
+	value := p.next()
+	if value.kind != tokenIdent || (!strings.EqualFold(value.text, "true") && !strings.EqualFold(value.text, "false")) {
+		return nil, &RuleError{value.pos, fmt.Sprintf("%s is either true or false", name)}
+	}
+
+	return compareNode{name, "=", []any{strings.EqualFold(value.text, "true") == (op.text == "=")}}, nil
+}
+
+func (p *ruleParser) parseComparison(name string, field ruleField, op string) (ruleNode, error) {
+	opPos := p.tokens[p.pos-1].pos
+	if op == "==" {
+		op = "="
+	}
+
+	switch {
+	case field.kind == fieldString && op != "=" && op != "!=" && op != "~":
+		return nil, &RuleError{opPos, fmt.Sprintf("%s is text and can only be compared with =, !=, ~ or CONTAINS", name)}
+	case field.kind == fieldNumber && op == "~":
+		return nil, &RuleError{opPos, fmt.Sprintf("%s is a number and can't be searched with ~", name)}
+	}
+
+	value, err := p.parseValue(name, field)
+	if err != nil {
+		return nil, err
+	}
+	return compareNode{name, op, []any{value}}, nil
+}
+
+func (p *ruleParser) parseIn(name string, field ruleField) (ruleNode, error) {
+	if !p.at(tokenLeftParen) {
+		return nil, p.errorf("expected \"(\" after IN")
+	}
+	p.next()
+
+	var values []any
+	for {
+		value, err := p.parseValue(name, field)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+
+		if p.at(tokenRightParen) {
+			p.next()
+			return compareNode{name, "IN", values}, nil
+		}
+		if !p.at(tokenComma) {
+			return nil, p.errorf("expected \",\" or \")\" but found %q", p.peek().text)
+		}
+		p.next()
+	}
+}
+
+func (p *ruleParser) parseValue(name string, field ruleField) (any, error) {
+	token := p.next()
+
+	if field.kind == fieldString {
+		if token.kind != tokenString {
+			return nil, &RuleError{token.pos, fmt.Sprintf("%s needs a quoted text value like \"rock\"", name)}
+		}
+		return strings.ToLower(token.text), nil
+	}
+
+	if token.kind != tokenNumber {
+		return nil, &RuleError{token.pos, fmt.Sprintf("%s needs a number", name)}
+	}
+	value, err := strconv.ParseFloat(token.text, 64)
+	if err != nil {
+		return nil, &RuleError{token.pos, fmt.Sprintf("%q is not a valid number", token.text)}
+	}
+	return value, nil
+}
+
+func parseWindow(token ruleToken) (time.Duration, error) {
+	if token.kind != tokenDuration {
+		return 0, &RuleError{token.pos, "expected a time window like 12h, 30d, 2w, 6m or 1y"}
+	}
+
+	i := strings.IndexFunc(token.text, unicode.IsLetter)
+	amount, err := strconv.Atoi(token.text[:i])
+	unit, ok := durationUnits[strings.ToLower(token.text[i:])]
+	if err != nil || !ok || amount < 1 {
+		return 0, &RuleError{token.pos, fmt.Sprintf("%q is not a valid time window, use h, d, w, m or y like 30d", token.text)}
+	}
+	return time.Duration(amount) * unit, nil
+}
+
+func fieldList(fieldSets ...iter.Seq[string]) string {
+	var names []string
+	for _, fields := range fieldSets {
+		names = slices.AppendSeq(names, fields)
+	}
+	slices.Sort(names)
+	return strings.Join(names, ", ")
+}
+
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
@@ -0,0 +1,391 @@
+package playlist
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type PlaylistService struct {
+	repo PlaylistRepository
+}
+
+func NewPlaylistService(repo PlaylistRepository) *PlaylistService {
+	return &PlaylistService{repo: repo}
+}
+
+func (s *PlaylistService) Create(ownerId, name, description string, public bool, rules string) (Playlist, error) {
+	rules = strings.TrimSpace(rules)
+	if rules != "" {
+		if _, err := ParseRule(rules); err != nil {
+			return Playlist{}, err
+		}
+	}
+
+	playlist, err := NewPlaylist(ownerId, name, description, public, rules)
+	if err != nil {
+		return Playlist{}, err
+	}
+
+	if err := s.repo.Create(playlist); err != nil {
+		return Playlist{}, err
+	}
+	return s.Get(ownerId, playlist.Id.String())
+}
+
+// GetMine returns the playlists the user owns or was invited to
+func (s *PlaylistService) GetMine(userId string) ([]Playlist, error) {
+	playlists, err := s.repo.GetAccessible(userId)
+	if err != nil {
+		return nil, err
+	}
+	return playlists, nil
+}
+
+func (s *PlaylistService) Get(userId, id string) (Playlist, error) {
+	playlist, err := s.repo.GetWithEntries(id)
+	if err != nil {
+		return Playlist{}, err
+	}
+
+	if !playlist.CanView(userId) {
+		return Playlist{}, ErrPlaylistHidden
+	}
+
+	if playlist.IsSmart() {
+		return s.withRuleSongs(playlist)
+	}
+	return playlist, nil
+}
+
+func (s *PlaylistService) Update(userId, id, name, description string, public bool, rules string) (Playlist, error) {
+	rules = strings.TrimSpace(rules)
+	if rules != "" {
+		if _, err := ParseRule(rules); err != nil {
+			return Playlist{}, err
+		}
+	}
+
+	playlist, err := s.owned(userId, id)
+	if err != nil {
+		return Playlist{}, err
+	}
+
+	playlist.Name = name
+	playlist.Description = description
+	playlist.Public = public
+	playlist.Rules = rules
+
+	if err := s.repo.Update(&playlist); err != nil {
+		return Playlist{}, err
+	}
+	return s.Get(userId, id)
+}
+
+// Preview evaluates rules for the user without saving them
+func (s *PlaylistService) Preview(userId, rules string) ([]Song, error) {
+	rule, err := ParseRule(strings.TrimSpace(rules))
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.FindSongs(userId, rule)
+}
+
+// SongIds lists the songs of a playlist the user can see in playing order, smart playlists
+// included. Other features use it to play playlists
+func (s *PlaylistService) SongIds(userId, id string) ([]uuid.UUID, error) {
+	playlist, err := s.Get(userId, id)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uuid.UUID, 0, len(playlist.Entries)+len(playlist.Songs))
+	for _, entry := range playlist.Entries {
+		ids = append(ids, entry.SongId)
+	}
+	for _, song := range playlist.Songs {
+		ids = append(ids, song.Id)
+	}
+	return ids, nil
+}
+
+// RuleSongIds lists the songs matching smart playlist rules for the user
+func (s *PlaylistService) RuleSongIds(userId, rules string) ([]uuid.UUID, error) {
+	songs, err := s.Preview(userId, rules)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uuid.UUID, len(songs))
+	for i, song := range songs {
+		ids[i] = song.Id
+	}
+	return ids, nil
+}
+
+// CreateWithSongs creates a private playlist holding songIds, for clients that send a
+// whole playlist at once
+func (s *PlaylistService) CreateWithSongs(userId, name string, songIds []uuid.UUID) (uuid.UUID, error) {
+	playlist, err := NewPlaylist(userId, name, "", false, "")
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if err := s.repo.Create(playlist); err != nil {
+		return uuid.Nil, err
+	}
+
+	if len(songIds) > 0 {
+		if err := s.repo.AddEntries(playlist.Id.String(), userId, songIds); err != nil {
+			return uuid.Nil, err
+		}
+	}
+	return playlist.Id, nil
+}
+
+// AddSongs appends songs for an owner or editor
+func (s *PlaylistService) AddSongs(userId, id string, songIds []uuid.UUID) error {
+	playlist, err := s.editable(userId, id)
+	if err != nil {
+		return err
+	}
+	if playlist.IsSmart() {
+		return ErrSmartPlaylist
+	}
+	if len(songIds) == 0 {
+		return nil
+	}
+
+	return s.repo.AddEntries(id, userId, songIds)
+}
+
+// RemovePositions removes the entries at the given positions for an owner or editor,
+// positions past the end are ignored
+func (s *PlaylistService) RemovePositions(userId, id string, positions []int) error {
+	playlist, err := s.editable(userId, id)
+	if err != nil {
+		return err
+	}
+	if playlist.IsSmart() {
+		return ErrSmartPlaylist
+	}
+
+	playlist, err = s.repo.GetWithEntries(id)
+	if err != nil {
+		return err
+	}
+
+	// Back to front so removing one doesn't move the others
+	positions = slices.Clone(positions)
+	slices.Sort(positions)
+	positions = slices.Compact(positions)
+	slices.Reverse(positions)
+	for _, position := range positions {
+		if position < 0 || position >= len(playlist.Entries) {
+			continue
+		}
+		if _, err := s.repo.RemoveEntry(id, playlist.Entries[position].Id.String(), userId, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetDetails changes the fields that aren't nil, for the owner
+func (s *PlaylistService) SetDetails(userId, id string, name, description *string, public *bool) error {
+	playlist, err := s.owned(userId, id)
+	if err != nil {
+		return err
+	}
+
+	if name != nil {
+		playlist.Name = *name
+	}
+	if description != nil {
+		playlist.Description = *description
+	}
+	if public != nil {
+		playlist.Public = *public
+	}
+	return s.repo.Update(&playlist)
+}
+
+// withRuleSongs fills in the songs matching a smart playlist's rules, stored entries are
+// left out since they aren't part of the playlist anymore
+func (s *PlaylistService) withRuleSongs(playlist Playlist) (Playlist, error) {
+	rule, err := ParseRule(playlist.Rules)
+	if err != nil {
+		return Playlist{}, err
+	}
+
+	songs, err := s.repo.FindSongs(playlist.OwnerId.String(), rule)
+	if err != nil {
+		return Playlist{}, err
+	}
+
+	playlist.Entries = nil
+	playlist.Songs = songs
+	return playlist, nil
+}
+
+func (s *PlaylistService) Delete(userId, id string) error {
+	if _, err := s.owned(userId, id); err != nil {
+		return err
+	}
+	return s.repo.Delete(id)
+}
+
+// AddSong adds a song for an owner or editor. A version makes the change fail with
+// ErrPlaylistConflict when someone else changed the playlist since it was read
+func (s *PlaylistService) AddSong(userId, id, songId string, position *int, version *int) (PlaylistEntry, error) {
+	playlist, err := s.editable(userId, id)
+	if err != nil {
+		return PlaylistEntry{}, err
+	}
+	if playlist.IsSmart() {
+		return PlaylistEntry{}, ErrSmartPlaylist
+	}
+
+	exists, err := s.repo.SongExists(songId)
+	if err != nil {
+		return PlaylistEntry{}, err
+	}
+	if !exists {
+		return PlaylistEntry{}, gorm.ErrRecordNotFound
+	}
+
+	entry := NewPlaylistEntry(playlist.Id, uuid.MustParse(songId), uuid.MustParse(userId))
+	if err := s.repo.AddEntry(entry, position, version); err != nil {
+		return PlaylistEntry{}, err
+	}
+	return *entry, nil
+}
+
+func (s *PlaylistService) RemoveEntry(userId, id, entryId string, version *int) (bool, error) {
+	playlist, err := s.editable(userId, id)
+	if err != nil {
+		return false, err
+	}
+	if playlist.IsSmart() {
+		return false, ErrSmartPlaylist
+	}
+	return s.repo.RemoveEntry(id, entryId, userId, version)
+}
+
+// SetCollaborator invites a user to the playlist or changes their role
+func (s *PlaylistService) SetCollaborator(userId, id, collaboratorId, role string) (PlaylistCollaborator, error) {
+	if !IsValidRole(role) {
+		return PlaylistCollaborator{}, ErrInvalidRole
+	}
+
+	playlist, err := s.owned(userId, id)
+	if err != nil {
+		return PlaylistCollaborator{}, err
+	}
+	if playlist.IsOwner(collaboratorId) {
+		return PlaylistCollaborator{}, ErrOwnerCollaborator
+	}
+
+	exists, err := s.repo.UserExists(collaboratorId)
+	if err != nil {
+		return PlaylistCollaborator{}, err
+	}
+	if !exists {
+		return PlaylistCollaborator{}, ErrUnknownUser
+	}
+
+	collaborator := PlaylistCollaborator{
+		PlaylistId: playlist.Id,
+		UserId:     uuid.MustParse(collaboratorId),
+		Role:       role,
+		InvitedBy:  playlist.OwnerId,
+	}
+	if err := s.repo.SaveCollaborator(&collaborator); err != nil {
+		return PlaylistCollaborator{}, err
+	}
+
+	saved, err := s.repo.GetById(id)
+	if err != nil {
+		return PlaylistCollaborator{}, err
+	}
+	for _, c := range saved.Collaborators {
+		if c.UserId == collaborator.UserId {
+			return c, nil
+		}
+	}
+	return collaborator, nil
+}
+
+// RemoveCollaborator lets the owner remove anyone, and collaborators leave on their own
+func (s *PlaylistService) RemoveCollaborator(userId, id, collaboratorId string) (bool, error) {
+	playlist, err := s.repo.GetById(id)
+	if err != nil {
+		return false, err
+	}
+
+	if !playlist.IsOwner(userId) && userId != collaboratorId {
+		if !playlist.CanView(userId) {
+			return false, ErrPlaylistHidden
+		}
+		return false, ErrNotPlaylistOwner
+	}
+	return s.repo.RemoveCollaborator(id, collaboratorId)
+}
+
+func (s *PlaylistService) GetChanges(userId, id string, page, limit int) ([]PlaylistChange, int64, error) {
+	playlist, err := s.repo.GetById(id)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !playlist.CanView(userId) {
+		return nil, 0, ErrPlaylistHidden
+	}
+
+	return s.repo.GetChanges(id, page, limit)
+}
+
+// Undo reverts the last count song changes, whoever made them
+func (s *PlaylistService) Undo(userId, id string, count int) ([]PlaylistChange, error) {
+	playlist, err := s.editable(userId, id)
+	if err != nil {
+		return nil, err
+	}
+	if playlist.IsSmart() {
+		return nil, ErrSmartPlaylist
+	}
+
+	return s.repo.Undo(id, userId, min(max(count, 1), MaxUndo))
+}
+
+// editable loads a playlist whose songs the user may change
+func (s *PlaylistService) editable(userId, id string) (Playlist, error) {
+	playlist, err := s.repo.GetById(id)
+	if err != nil {
+		return Playlist{}, err
+	}
+
+	switch {
+	case !playlist.CanView(userId):
+		return Playlist{}, ErrPlaylistHidden
+	case !playlist.CanEdit(userId):
+		return Playlist{}, ErrNotPlaylistEditor
+	}
+	return playlist, nil
+}
+
+// owned loads a playlist the user may rename, share or delete
+func (s *PlaylistService) owned(userId, id string) (Playlist, error) {
+	playlist, err := s.repo.GetById(id)
+	if err != nil {
+		return Playlist{}, err
+	}
+
+	switch {
+	case !playlist.CanView(userId):
+		return Playlist{}, ErrPlaylistHidden
+	case !playlist.IsOwner(userId):
+		return Playlist{}, ErrNotPlaylistOwner
+	}
+	return playlist, nil
+}
@@ -0,0 +1,252 @@
+package playlist
+
+import (
+	"errors"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// How far apart, in seconds, a file's duration and a song's may be to still match by title
+const importDurationTolerance = 5
+
+// Ways an imported track was matched to a song, from most to least certain
+const (
+	MatchById          = "id"
+	MatchByFingerprint = "fingerprint"
+	MatchByPath        = "path"
+	MatchByMetadata    = "metadata"
+)
+
+var streamUrlPattern = regexp.MustCompile(`/songs/([0-9a-fA-F-]{36})/stream`)
+
+type TrackMatch struct {
+	Index  int       `json:"index"`
+	SongId uuid.UUID `json:"song_id"`
+	By     string    `json:"by"`
+}
+
+type UnmatchedTrack struct {
+	Index    int    `json:"index"`
+	Title    string `json:"title,omitempty"`
+	Artist   string `json:"artist,omitempty"`
+	Location string `json:"location,omitempty"`
+}
+
+// ImportReport tells which tracks of an imported file made it into the playlist, indexes start at 1
+type ImportReport struct {
+	Playlist  Playlist         `json:"playlist"`
+	Total     int              `json:"total"`
+	Matched   []TrackMatch     `json:"matched"`
+	Unmatched []UnmatchedTrack `json:"unmatched"`
+}
+
+// Export describes a playlist for other players, streamBaseUrl is where song streams are served from
+func (s *PlaylistService) Export(userId, id, streamBaseUrl string) (PortablePlaylist, error) {
+	playlist, err := s.Get(userId, id)
+	if err != nil {
+		return PortablePlaylist{}, err
+	}
+
+	songs := playlist.Songs
+	if !playlist.IsSmart() {
+		for _, entry := range playlist.Entries {
+			songs = append(songs, entry.Song)
+		}
+	}
+
+	portable := PortablePlaylist{Title: playlist.Name, Description: playlist.Description}
+	for _, song := range songs {
+		track := PortableTrack{
+			Locations:  []string{strings.TrimSuffix(streamBaseUrl, "/") + "/songs/" + song.Id.String() + "/stream"},
+			Title:      song.Title,
+			DurationMs: song.Duration * 1000,
+		}
+		if song.Artist != nil {
+			track.Artist = song.Artist.FullName
+		}
+		if song.Fingerprint != "" {
+			track.Identifiers = []string{"urn:sha256:" + song.Fingerprint}
+		}
+		portable.Tracks = append(portable.Tracks, track)
+	}
+	return portable, nil
+}
+
+// Import creates a playlist from a playlist file, keeping the tracks that match songs in the library
+func (s *PlaylistService) Import(userId, name, format string, data []byte) (ImportReport, error) {
+	portable, err := ParsePlaylistFile(format, data)
+	if err != nil {
+		return ImportReport{}, err
+	}
+
+	report := ImportReport{Total: len(portable.Tracks), Matched: []TrackMatch{}, Unmatched: []UnmatchedTrack{}}
+	var songIds []uuid.UUID
+	for i, track := range portable.Tracks {
+		song, by, err := s.matchTrack(track)
+		if err != nil {
+			return ImportReport{}, err
+		}
+
+		if by == "" {
+			unmatched := UnmatchedTrack{Index: i + 1, Title: track.Title, Artist: track.Artist}
+			if len(track.Locations) > 0 {
+				unmatched.Location = track.Locations[0]
+			}
+			report.Unmatched = append(report.Unmatched, unmatched)
+			continue
+		}
+
+		report.Matched = append(report.Matched, TrackMatch{Index: i + 1, SongId: song.Id, By: by})
+		songIds = append(songIds, song.Id)
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = portable.Title
+	}
+	if name == "" {
+		name = "Imported playlist"
+	}
+
+	playlist, err := NewPlaylist(userId, truncate(name, 100), truncate(portable.Description, 1000), false, "")
+	if err != nil {
+		return ImportReport{}, err
+	}
+	if err := s.repo.Create(playlist); err != nil {
+		return ImportReport{}, err
+	}
+	if len(songIds) > 0 {
+		if err := s.repo.AddEntries(playlist.Id.String(), userId, songIds); err != nil {
+			return ImportReport{}, err
+		}
+	}
+
+	report.Playlist, err = s.Get(userId, playlist.Id.String())
+	if err != nil {
+		return ImportReport{}, err
+	}
+	return report, nil
+}
+
+// matchTrack finds the song a track refers to, returning an empty match kind when there's none
+func (s *PlaylistService) matchTrack(track PortableTrack) (Song, string, error) {
+	// Our own stream URLs carry the song id
+	for _, location := range append(track.Locations, track.Identifiers...) {
+		if match := streamUrlPattern.FindStringSubmatch(location); match != nil {
+			song, err := s.repo.FindSongById(match[1])
+			if err == nil {
+				return song, MatchById, nil
+			}
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return Song{}, "", err
+			}
+		}
+	}
+
+	for _, identifier := range track.Identifiers {
+		fingerprint, ok := strings.CutPrefix(strings.ToLower(identifier), "urn:sha256:")
+		if !ok {
+			continue
+		}
+		song, err := s.repo.FindSongByFingerprint(fingerprint)
+		if err == nil {
+			return song, MatchByFingerprint, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return Song{}, "", err
+		}
+	}
+
+	for _, location := range track.Locations {
+		fileName := uploadFileName(location)
+		if fileName == "" {
+			continue
+		}
+
+		candidates, err := s.repo.FindSongsByFileName(fileName)
+		if err != nil {
+			return Song{}, "", err
+		}
+		if song, ok := bestMatch(candidates, track, len(candidates) == 1); ok {
+			return song, MatchByPath, nil
+		}
+	}
+
+	if track.Title == "" {
+		return Song{}, "", nil
+	}
+	candidates, err := s.repo.FindSongsByTitle(track.Title)
+	if err != nil {
+		return Song{}, "", err
+	}
+	// Without an artist a title alone is too weak, the duration has to agree as well
+	if song, ok := bestMatch(candidates, track, track.Artist != ""); ok {
+		return song, MatchByMetadata, nil
+	}
+	return Song{}, "", nil
+}
+
+// bestMatch keeps the candidates whose artist and duration agree with the track and picks
+// the one closest in duration. Unknown fields on either side don't rule a candidate out
+func bestMatch(candidates []Song, track PortableTrack, trustAlone bool) (Song, bool) {
+	best, bestGap, found := Song{}, -1, false
+	for _, song := range candidates {
+		if track.Artist != "" && (song.Artist == nil || !strings.EqualFold(song.Artist.FullName, track.Artist)) {
+			continue
+		}
+
+		gap := 0
+		if track.DurationMs > 0 && song.Duration > 0 {
+			gap = abs(song.Duration - (track.DurationMs+500)/1000)
+			if gap > importDurationTolerance {
+				continue
+			}
+		} else if !trustAlone {
+			continue
+		}
+
+		if !found || gap < bestGap {
+			best, bestGap, found = song, gap, true
+		}
+	}
+	return best, found
+}
+
+// uploadFileName turns a file path or URL into the name an upload of that file is stored
+// under, the same clean up song uploads go through
+func uploadFileName(location string) string {
+	if parsed, err := url.Parse(location); err == nil && parsed.Scheme != "" && len(parsed.Scheme) > 1 {
+		location = parsed.Path
+	} else if unescaped, err := url.PathUnescape(location); err == nil {
+		location = unescaped
+	}
+
+	name := path.Base(strings.ReplaceAll(location, `\`, "/"))
+	if name == "." || name == "/" {
+		return ""
+	}
+
+	name = strings.ReplaceAll(name, " ", "_")
+	name = strings.ReplaceAll(name, "(", "")
+	return strings.ReplaceAll(name, ")", "")
+}
+
+func truncate(value string, length int) string {
+	runes := []rune(value)
+	if len(runes) <= length {
+		return value
+	}
+	return string(runes[:length])
+}
+
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
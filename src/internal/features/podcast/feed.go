@@ -0,0 +1,426 @@
+package podcast
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html/charset"
+)
+
+// XML namespaces podcast feeds use
+const (
+	nsAtom    = "http://www.w3.org/2005/Atom"
+	nsItunes  = "http://www.itunes.com/dtds/podcast-1.0.dtd"
+	nsPodlove = "http://podlove.org/simple-chapters"
+	nsPodcast = "https://podcastindex.org/namespace/1.0"
+)
+
+// Feed is a parsed RSS or Atom feed
+type Feed struct {
+	Title       string
+	Description string
+	Author      string
+	Link        string
+	ImageUrl    string
+	Language    string
+	Explicit    bool
+	Items       []Item
+}
+
+// Item is an episode as a feed lists it
+type Item struct {
+	Guid            string
+	Title           string
+	Description     string
+	Link            string
+	ImageUrl        string
+	PublishedAt     time.Time
+	Duration        int
+	Season          int
+	Number          int
+	Explicit        bool
+	EnclosureUrl    string
+	EnclosureType   string
+	EnclosureLength int64
+	ChaptersUrl     string
+	Chapters        []Chapter
+}
+
+// element captures elements sharing a name across namespaces, like RSS link next to
+// atom:link, which encoding/xml can't tell apart by field tags alone
+type element struct {
+	XMLName xml.Name
+	Href    string `xml:"href,attr"`
+	Rel     string `xml:"rel,attr"`
+	Type    string `xml:"type,attr"`
+	Length  string `xml:"length,attr"`
+	Url     string `xml:"url,attr"`
+	// Child elements of RSS image and Atom author
+	ImageUrl string `xml:"url"`
+	Name     string `xml:"name"`
+	Value    string `xml:",chardata"`
+}
+
+// chapterList is podcast:chapters, pointing at a chapters file, or psc:chapters listing
+// the chapters inline
+type chapterList struct {
+	XMLName xml.Name
+	Url     string `xml:"url,attr"`
+	Chapter []struct {
+		Start string `xml:"start,attr"`
+		Title string `xml:"title,attr"`
+		Href  string `xml:"href,attr"`
+		Image string `xml:"image,attr"`
+	} `xml:"chapter"`
+}
+
+type rssFeed struct {
+	Channel struct {
+		Title       []element `xml:"title"`
+		Link        []element `xml:"link"`
+		Description []element `xml:"description"`
+		Summary     []element `xml:"summary"`
+		Author      []element `xml:"author"`
+		Image       []element `xml:"image"`
+		Language    string    `xml:"language"`
+		Explicit    string    `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd explicit"`
+		Items       []rssItem `xml:"item"`
+	} `xml:"channel"`
+}
+
+type rssItem struct {
+	Title       []element     `xml:"title"`
+	Link        []element     `xml:"link"`
+	Description []element     `xml:"description"`
+	Summary     []element     `xml:"summary"`
+	Image       []element     `xml:"image"`
+	Encoded     string        `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	Guid        string        `xml:"guid"`
+	PubDate     string        `xml:"pubDate"`
+	Enclosure   []element     `xml:"enclosure"`
+	Content     []element     `xml:"http://search.yahoo.com/mrss/ content"`
+	Duration    string        `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd duration"`
+	Season      string        `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd season"`
+	Episode     string        `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd episode"`
+	Explicit    string        `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd explicit"`
+	Chapters    []chapterList `xml:"chapters"`
+}
+
+type atomFeed struct {
+	Title    string      `xml:"http://www.w3.org/2005/Atom title"`
+	Subtitle string      `xml:"http://www.w3.org/2005/Atom subtitle"`
+	Links    []element   `xml:"http://www.w3.org/2005/Atom link"`
+	Author   element     `xml:"http://www.w3.org/2005/Atom author"`
+	Logo     string      `xml:"http://www.w3.org/2005/Atom logo"`
+	Icon     string      `xml:"http://www.w3.org/2005/Atom icon"`
+	Entries  []atomEntry `xml:"http://www.w3.org/2005/Atom entry"`
+}
+
+type atomEntry struct {
+	Id        string    `xml:"http://www.w3.org/2005/Atom id"`
+	Title     string    `xml:"http://www.w3.org/2005/Atom title"`
+	Summary   string    `xml:"http://www.w3.org/2005/Atom summary"`
+	Content   string    `xml:"http://www.w3.org/2005/Atom content"`
+	Links     []element `xml:"http://www.w3.org/2005/Atom link"`
+	Published string    `xml:"http://www.w3.org/2005/Atom published"`
+	Updated   string    `xml:"http://www.w3.org/2005/Atom updated"`
+	Duration  string    `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd duration"`
+}
+
+// ParseFeed reads an RSS 2.0 or Atom feed, with the iTunes, Podlove Simple Chapters and
+// Podcasting 2.0 extensions podcasts use. Items without audio are left out
+func ParseFeed(r io.Reader) (Feed, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return Feed{}, err
+	}
+
+	var root struct{ XMLName xml.Name }
+	if err := decode(data, &root); err != nil {
+		return Feed{}, ErrInvalidFeed
+	}
+
+	switch {
+	case root.XMLName.Local == "rss":
+		var feed rssFeed
+		if err := decode(data, &feed); err != nil {
+			return Feed{}, ErrInvalidFeed
+		}
+		return feed.parse(), nil
+	case root.XMLName.Local == "feed" && root.XMLName.Space == nsAtom:
+		var feed atomFeed
+		if err := decode(data, &feed); err != nil {
+			return Feed{}, ErrInvalidFeed
+		}
+		return feed.parse(), nil
+	default:
+		return Feed{}, ErrInvalidFeed
+	}
+}
+
+func decode(data []byte, v any) error {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.CharsetReader = charset.NewReaderLabel
+	// Feeds in the wild are full of HTML entities and other sloppiness
+	decoder.Strict = false
+	decoder.Entity = xml.HTMLEntity
+	return decoder.Decode(v)
+}
+
+func (f rssFeed) parse() Feed {
+	channel := f.Channel
+	feed := Feed{
+		Title:       text(pick(channel.Title, "", nsItunes)),
+		Description: firstOf(text(pick(channel.Description, "")), text(pick(channel.Summary, nsItunes))),
+		Author:      text(pick(channel.Author, nsItunes, "")),
+		Link:        text(pick(channel.Link, "")),
+		Language:    strings.TrimSpace(channel.Language),
+		Explicit:    parseExplicit(channel.Explicit),
+	}
+	if image, ok := find(channel.Image, nsItunes); ok {
+		feed.ImageUrl = strings.TrimSpace(image.Href)
+	}
+	if image, ok := find(channel.Image, ""); ok && feed.ImageUrl == "" {
+		feed.ImageUrl = strings.TrimSpace(image.ImageUrl)
+	}
+
+	for _, rss := range channel.Items {
+		item := Item{
+			Title:       text(pick(rss.Title, "", nsItunes)),
+			Description: firstOf(rss.Encoded, text(pick(rss.Description, "")), text(pick(rss.Summary, nsItunes))),
+			Link:        text(pick(rss.Link, "")),
+			PublishedAt: parseDate(rss.PubDate),
+			Duration:    parseDuration(rss.Duration),
+			Season:      parseInt(rss.Season),
+			Number:      parseInt(rss.Episode),
+			Explicit:    parseExplicit(rss.Explicit),
+		}
+		if image, ok := find(rss.Image, nsItunes); ok {
+			item.ImageUrl = strings.TrimSpace(image.Href)
+		}
+
+		if enclosure, ok := find(rss.Enclosure, ""); ok {
+			item.EnclosureUrl = strings.TrimSpace(enclosure.Url)
+			item.EnclosureType = strings.TrimSpace(enclosure.Type)
+			item.EnclosureLength = parseLength(enclosure.Length)
+		}
+		// Some feeds only carry Media RSS
+		for _, content := range rss.Content {
+			if item.EnclosureUrl == "" && isAudio(content.Type) {
+				item.EnclosureUrl = strings.TrimSpace(content.Url)
+				item.EnclosureType = content.Type
+			}
+		}
+		for _, chapters := range rss.Chapters {
+			switch chapters.XMLName.Space {
+			case nsPodcast:
+				item.ChaptersUrl = strings.TrimSpace(chapters.Url)
+			case nsPodlove:
+				for _, chapter := range chapters.Chapter {
+					item.Chapters = append(item.Chapters, Chapter{
+						StartMs:  parseNormalPlayTime(chapter.Start),
+						Title:    strings.TrimSpace(chapter.Title),
+						Url:      chapter.Href,
+						ImageUrl: chapter.Image,
+					})
+				}
+			}
+		}
+
+		item.Guid = firstOf(rss.Guid, item.EnclosureUrl, item.Link, item.Title)
+		if item.EnclosureUrl != "" {
+			feed.Items = append(feed.Items, item)
+		}
+	}
+	return feed
+}
+
+func (f atomFeed) parse() Feed {
+	feed := Feed{
+		Title:       strings.TrimSpace(f.Title),
+		Description: strings.TrimSpace(f.Subtitle),
+		Author:      strings.TrimSpace(f.Author.Name),
+		ImageUrl:    firstOf(f.Logo, f.Icon),
+	}
+	for _, link := range f.Links {
+		if link.Rel == "" || link.Rel == "alternate" {
+			feed.Link = link.Href
+		}
+	}
+
+	for _, entry := range f.Entries {
+		item := Item{
+			Guid:        strings.TrimSpace(entry.Id),
+			Title:       strings.TrimSpace(entry.Title),
+			Description: firstOf(entry.Content, entry.Summary),
+			PublishedAt: parseDate(firstOf(entry.Published, entry.Updated)),
+			Duration:    parseDuration(entry.Duration),
+		}
+		for _, link := range entry.Links {
+			switch link.Rel {
+			case "enclosure":
+				if item.EnclosureUrl == "" {
+					item.EnclosureUrl = link.Href
+					item.EnclosureType = link.Type
+					item.EnclosureLength = parseLength(link.Length)
+				}
+			case "", "alternate":
+				item.Link = link.Href
+			}
+		}
+
+		item.Guid = firstOf(item.Guid, item.EnclosureUrl, item.Link, item.Title)
+		if item.EnclosureUrl != "" {
+			feed.Items = append(feed.Items, item)
+		}
+	}
+	return feed
+}
+
+// ParseChapters reads a Podcasting 2.0 JSON chapters file
+func ParseChapters(r io.Reader) ([]Chapter, error) {
+	var file struct {
+		Chapters []struct {
+			StartTime float64 `json:"startTime"`
+			Title     string  `json:"title"`
+			Url       string  `json:"url"`
+			Img       string  `json:"img"`
+			Toc       *bool   `json:"toc"`
+		} `json:"chapters"`
+	}
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return nil, err
+	}
+
+	chapters := make([]Chapter, 0, len(file.Chapters))
+	for _, chapter := range file.Chapters {
+		// Chapters left out of the table of contents only change artwork or links
+		if chapter.Toc != nil && !*chapter.Toc {
+			continue
+		}
+		chapters = append(chapters, Chapter{
+			StartMs:  int64(chapter.StartTime * 1000),
+			Title:    chapter.Title,
+			Url:      chapter.Url,
+			ImageUrl: chapter.Img,
+		})
+	}
+	return chapters, nil
+}
+
+// pick returns the first element in the first of the namespaces that has one
+func pick(elements []element, spaces ...string) element {
+	for _, space := range spaces {
+		if e, ok := find(elements, space); ok {
+			return e
+		}
+	}
+	return element{}
+}
+
+func find(elements []element, space string) (element, bool) {
+	for _, e := range elements {
+		if e.XMLName.Space == space {
+			return e, true
+		}
+	}
+	return element{}, false
+}
+
+func text(e element) string {
+	return strings.TrimSpace(e.Value)
+}
+
+func firstOf(values ...string) string {
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func isAudio(contentType string) bool {
+	return strings.HasPrefix(contentType, "audio/")
+}
+
+func parseInt(value string) int {
+	n, _ := strconv.Atoi(strings.TrimSpace(value))
+	return n
+}
+
+func parseLength(value string) int64 {
+	n, _ := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	return n
+}
+
+func parseExplicit(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "yes", "true", "explicit":
+		return true
+	default:
+		return false
+	}
+}
+
+// parseDuration reads itunes:duration, which is either seconds or [HH:]MM:SS
+func parseDuration(value string) int {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+
+	seconds := 0
+	for _, part := range strings.Split(value, ":") {
+		n, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return 0
+		}
+		seconds = seconds*60 + int(n)
+	}
+	return seconds
+}
+
+// parseNormalPlayTime reads a Podlove chapter start like 01:02:03.500 into milliseconds
+func parseNormalPlayTime(value string) int64 {
+	var ms float64
+	for _, part := range strings.Split(strings.TrimSpace(value), ":") {
+		n, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return 0
+		}
+		ms = ms*60 + n
+	}
+	return int64(ms * 1000)
+}
+
+// Date layouts seen in feeds, RFC 822 in its many variations and RFC 3339 for Atom
+var dateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"Mon, 02 Jan 2006 15:04 -0700",
+	"Mon, 2 Jan 2006 15:04 -0700",
+	"02 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006 15:04:05 -0700",
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// parseDate reads a publication date, falling back to the zero time
+func parseDate(value string) time.Time {
+	value = strings.TrimSpace(value)
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC()
+		}
+	}
+	return time.Time{}
+}
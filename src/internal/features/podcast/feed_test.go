@@ -0,0 +1,211 @@
+package podcast
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serveFixtures serves the files in testdata the way a podcast host would, with an ETag so
+// conditional requests can be checked. CHAPTERS_URL in feeds points back at the server
+func serveFixtures(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := os.ReadFile("testdata" + r.URL.Path)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		etag := `"` + r.URL.Path + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Last-Modified", "Tue, 07 Jan 2025 06:00:00 GMT")
+		w.Write([]byte(strings.ReplaceAll(string(data), "CHAPTERS_URL", server.URL+"/chapters.json")))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestService(server *httptest.Server) *PodcastService {
+	return NewPodcastService(nil, nil, server.Client(), nil)
+}
+
+func TestFetchRssFeed(t *testing.T) {
+	server := serveFixtures(t)
+	service := newTestService(server)
+
+	podcast := &Podcast{FeedUrl: server.URL + "/feed.xml"}
+	feed, changed, err := service.fetch(podcast)
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	if !changed {
+		t.Fatal("fetch reported an unchanged feed on the first request")
+	}
+
+	if feed.Title != "Night Shift" {
+		t.Errorf("Title = %q, want %q", feed.Title, "Night Shift")
+	}
+	if feed.Description != "Stories told after midnight & before dawn" {
+		t.Errorf("Description = %q", feed.Description)
+	}
+	if feed.Link != "https://example.com/night-shift" {
+		t.Errorf("Link = %q, the atom:link next to it must not win", feed.Link)
+	}
+	if feed.Language != "en-us" {
+		t.Errorf("Language = %q, want %q", feed.Language, "en-us")
+	}
+	if feed.Author != "Dana Reyes" {
+		t.Errorf("Author = %q, want the itunes:author", feed.Author)
+	}
+	if !feed.Explicit {
+		t.Error("Explicit = false, want itunes:explicit yes to be explicit")
+	}
+	if feed.ImageUrl != "https://example.com/cover.jpg" {
+		t.Errorf("ImageUrl = %q, want the itunes:image over the RSS image", feed.ImageUrl)
+	}
+
+	// The item without audio is left out
+	if len(feed.Items) != 2 {
+		t.Fatalf("got %d items, want 2", len(feed.Items))
+	}
+
+	item := feed.Items[0]
+	if item.Guid != "night-shift-2" || item.Title != "The Lighthouse" {
+		t.Errorf("first item = %q %q", item.Guid, item.Title)
+	}
+	if item.Description != "<p>Keeper of the <b>light</b></p>" {
+		t.Errorf("Description = %q, want content:encoded", item.Description)
+	}
+	if want := time.Date(2025, 1, 7, 5, 0, 0, 0, time.UTC); !item.PublishedAt.Equal(want) {
+		t.Errorf("PublishedAt = %v, want %v", item.PublishedAt, want)
+	}
+	if item.Duration != 3723 || item.Season != 1 || item.Number != 2 || item.Explicit {
+		t.Errorf("itunes fields = duration %d, season %d, episode %d, explicit %v", item.Duration, item.Season, item.Number, item.Explicit)
+	}
+	if item.ImageUrl != "https://example.com/2.jpg" {
+		t.Errorf("ImageUrl = %q", item.ImageUrl)
+	}
+	if item.EnclosureUrl != "https://cdn.example.com/2.mp3" || item.EnclosureType != "audio/mpeg" || item.EnclosureLength != 31415926 {
+		t.Errorf("enclosure = %q %q %d", item.EnclosureUrl, item.EnclosureType, item.EnclosureLength)
+	}
+	if len(item.Chapters) != 2 || item.Chapters[1].StartMs != 90500 || item.Chapters[1].Url != "https://example.com/storm" {
+		t.Errorf("inline chapters = %+v", item.Chapters)
+	}
+
+	// Without a guid or an enclosure element the Media RSS audio stands in for both
+	item = feed.Items[1]
+	if item.EnclosureUrl != "https://cdn.example.com/1.m4a" || item.EnclosureType != "audio/mp4" {
+		t.Errorf("media:content enclosure = %q %q", item.EnclosureUrl, item.EnclosureType)
+	}
+	if item.Guid != item.EnclosureUrl {
+		t.Errorf("Guid = %q, want the enclosure url", item.Guid)
+	}
+	if item.Duration != 754 {
+		t.Errorf("Duration = %d, want 754", item.Duration)
+	}
+	if item.ChaptersUrl != server.URL+"/chapters.json" {
+		t.Errorf("ChaptersUrl = %q", item.ChaptersUrl)
+	}
+}
+
+func TestFetchAtomFeed(t *testing.T) {
+	server := serveFixtures(t)
+	service := newTestService(server)
+
+	feed, _, err := service.fetch(&Podcast{FeedUrl: server.URL + "/atom.xml"})
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+
+	if feed.Title != "Field Notes" || feed.Description != "Recordings from the field" || feed.Author != "Sam Okafor" {
+		t.Errorf("feed = %q %q %q", feed.Title, feed.Description, feed.Author)
+	}
+	if feed.Link != "https://example.org/field-notes" {
+		t.Errorf("Link = %q, want the alternate link", feed.Link)
+	}
+	if feed.ImageUrl != "https://example.org/logo.png" {
+		t.Errorf("ImageUrl = %q", feed.ImageUrl)
+	}
+
+	if len(feed.Items) != 1 {
+		t.Fatalf("got %d items, want 1", len(feed.Items))
+	}
+	item := feed.Items[0]
+	if item.Guid != "urn:uuid:3a1b7c1e-0d2f-4e0a-9d3e-2f6a1b0c9d8e" || item.Link != "https://example.org/field-notes/birdsong" {
+		t.Errorf("item = %q %q", item.Guid, item.Link)
+	}
+	if item.EnclosureUrl != "https://cdn.example.org/birdsong.ogg" || item.EnclosureType != "audio/ogg" || item.EnclosureLength != 2048000 {
+		t.Errorf("enclosure = %q %q %d", item.EnclosureUrl, item.EnclosureType, item.EnclosureLength)
+	}
+	if item.Duration != 1200 {
+		t.Errorf("Duration = %d, want the itunes:duration 1200", item.Duration)
+	}
+	if want := time.Date(2025, 3, 1, 5, 30, 0, 0, time.UTC); !item.PublishedAt.Equal(want) {
+		t.Errorf("PublishedAt = %v, want %v", item.PublishedAt, want)
+	}
+}
+
+func TestFetchSendsValidators(t *testing.T) {
+	server := serveFixtures(t)
+	service := newTestService(server)
+
+	podcast := &Podcast{FeedUrl: server.URL + "/feed.xml"}
+	if _, _, err := service.fetch(podcast); err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	if podcast.ETag == "" || podcast.LastModified == "" {
+		t.Fatalf("validators not kept: ETag %q, Last-Modified %q", podcast.ETag, podcast.LastModified)
+	}
+
+	_, changed, err := service.fetch(podcast)
+	if err != nil {
+		t.Fatalf("second fetch: %v", err)
+	}
+	if changed {
+		t.Error("second fetch reported a change, want the 304 to be reported unchanged")
+	}
+}
+
+func TestFetchRejectsBadFeeds(t *testing.T) {
+	server := serveFixtures(t)
+	service := newTestService(server)
+
+	_, _, err := service.fetch(&Podcast{FeedUrl: server.URL + "/missing.xml"})
+	if !errors.Is(err, ErrFeedUnavailable) {
+		t.Errorf("missing feed: err = %v, want ErrFeedUnavailable", err)
+	}
+
+	_, _, err = service.fetch(&Podcast{FeedUrl: server.URL + "/chapters.json"})
+	if !errors.Is(err, ErrInvalidFeed) {
+		t.Errorf("not a feed: err = %v, want ErrInvalidFeed", err)
+	}
+}
+
+func TestFetchChapters(t *testing.T) {
+	server := serveFixtures(t)
+	service := newTestService(server)
+
+	chapters, err := service.fetchChapters(server.URL + "/chapters.json")
+	if err != nil {
+		t.Fatalf("fetchChapters: %v", err)
+	}
+
+	// The artwork-only chapter isn't in the table of contents
+	if len(chapters) != 2 {
+		t.Fatalf("got %d chapters, want 2", len(chapters))
+	}
+	if chapters[1].StartMs != 95250 || chapters[1].Title != "Crossing" || chapters[1].ImageUrl != "https://example.com/crossing.jpg" {
+		t.Errorf("chapter = %+v", chapters[1])
+	}
+}
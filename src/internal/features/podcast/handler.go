@@ -0,0 +1,210 @@
+package podcast
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yosp313/gotify/src/internal/pkg/auth"
+	"github.com/yosp313/gotify/src/internal/utils"
+	"gorm.io/gorm"
+)
+
+type PodcastHandler struct {
+	service *PodcastService
+}
+
+type SubscribeRequest struct {
+	FeedUrl string `json:"feed_url" binding:"required,max=2000"`
+}
+
+type ProgressRequest struct {
+	Position *int  `json:"position" binding:"required"` // seconds
+	Played   *bool `json:"played"`
+}
+
+func NewPodcastHandler(service *PodcastService) *PodcastHandler {
+	return &PodcastHandler{service: service}
+}
+
+func (h *PodcastHandler) Subscribe(c *gin.Context) {
+	var req SubscribeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.HandleErrorWithMessage(c, err, "Invalid request body", 400)
+		return
+	}
+
+	podcast, err := h.service.Subscribe(c.GetString("user_id"), req.FeedUrl)
+	if err != nil {
+		handlePodcastError(c, err, "Failed to subscribe")
+		return
+	}
+
+	c.JSON(201, podcast)
+}
+
+func (h *PodcastHandler) GetAll(c *gin.Context) {
+	podcasts, err := h.service.GetSubscribed(c.GetString("user_id"))
+	if err != nil {
+		utils.HandleErrorWithMessage(c, err, "Failed to retrieve podcasts", 500)
+		return
+	}
+
+	c.JSON(200, gin.H{"podcasts": podcasts})
+}
+
+func (h *PodcastHandler) GetById(c *gin.Context) {
+	podcast, err := h.service.Get(c.GetString("user_id"), c.Param("id"))
+	if err != nil {
+		handlePodcastError(c, err, "Failed to retrieve podcast")
+		return
+	}
+
+	c.JSON(200, podcast)
+}
+
+func (h *PodcastHandler) Unsubscribe(c *gin.Context) {
+	if err := h.service.Unsubscribe(c.GetString("user_id"), c.Param("id")); err != nil {
+		handlePodcastError(c, err, "Failed to unsubscribe")
+		return
+	}
+
+	c.Status(204)
+}
+
+func (h *PodcastHandler) Refresh(c *gin.Context) {
+	podcast, err := h.service.Refresh(c.GetString("user_id"), c.Param("id"))
+	if err != nil {
+		handlePodcastError(c, err, "Failed to refresh podcast")
+		return
+	}
+
+	c.JSON(200, podcast)
+}
+
+func (h *PodcastHandler) GetEpisodes(c *gin.Context) {
+	page, limit := utils.GetPagination(c)
+
+	episodes, total, err := h.service.GetEpisodes(c.GetString("user_id"), c.Param("id"), page, limit)
+	if err != nil {
+		handlePodcastError(c, err, "Failed to retrieve episodes")
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"episodes": episodes,
+		"total":    total,
+		"page":     page,
+		"limit":    limit,
+	})
+}
+
+func (h *PodcastHandler) GetEpisode(c *gin.Context) {
+	episode, err := h.service.GetEpisode(c.GetString("user_id"), c.Param("id"), c.Param("episodeId"))
+	if err != nil {
+		handlePodcastError(c, err, "Failed to retrieve episode")
+		return
+	}
+
+	c.JSON(200, episode)
+}
+
+func (h *PodcastHandler) Download(c *gin.Context) {
+	episode, err := h.service.Download(c.GetString("user_id"), c.Param("id"), c.Param("episodeId"))
+	if err != nil {
+		handlePodcastError(c, err, "Failed to download episode")
+		return
+	}
+
+	c.JSON(200, episode)
+}
+
+// Stream plays a downloaded episode from storage, episodes that aren't downloaded yet are
+// played from the publisher
+func (h *PodcastHandler) Stream(c *gin.Context) {
+	episode, err := h.service.GetEpisode(c.GetString("user_id"), c.Param("id"), c.Param("episodeId"))
+	if err != nil {
+		handlePodcastError(c, err, "Failed to retrieve episode")
+		return
+	}
+
+	filePath := h.service.EpisodeFile(episode)
+	if filePath == "" {
+		c.Redirect(307, episode.EnclosureUrl)
+		return
+	}
+
+	c.Header("Content-Type", episode.ContentType())
+	c.Header("Accept-Ranges", "bytes")
+	c.Header("Cache-Control", "private, max-age=3600")
+	c.File(filePath)
+}
+
+func (h *PodcastHandler) CreateStreamToken(c *gin.Context) {
+	token, expiresAt, err := h.service.StreamToken(c.GetString("user_id"), c.Param("id"), c.Param("episodeId"))
+	if err != nil {
+		handlePodcastError(c, err, "Failed to create stream token")
+		return
+	}
+
+	url := utils.BaseUrl(c) + strings.TrimSuffix(c.Request.URL.Path, "-token") + "?stream_token=" + token
+	c.JSON(200, StreamToken{Token: token, Url: url, ExpiresAt: expiresAt})
+}
+
+// RequireStream lets players that can't send headers stream an episode with a stream token
+// in ?stream_token=, other requests log in as usual
+func (h *PodcastHandler) RequireStream(authMiddleware gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.Query("stream_token")
+		if token == "" {
+			authMiddleware(c)
+			return
+		}
+
+		userId, err := h.service.AuthorizeStream(token, c.Param("episodeId"))
+		if err != nil {
+			handlePodcastError(c, err, "Failed to open stream")
+			c.Abort()
+			return
+		}
+		c.Set("user_id", userId)
+		c.Next()
+	}
+}
+
+func (h *PodcastHandler) SetProgress(c *gin.Context) {
+	var req ProgressRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.HandleErrorWithMessage(c, err, "Invalid request body", 400)
+		return
+	}
+
+	progress, err := h.service.SetProgress(c.GetString("user_id"), c.Param("id"), c.Param("episodeId"), *req.Position, req.Played)
+	if err != nil {
+		handlePodcastError(c, err, "Failed to save progress")
+		return
+	}
+
+	c.JSON(200, progress)
+}
+
+func handlePodcastError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, ErrNotSubscribed):
+		c.JSON(404, gin.H{"error": "Podcast not found"})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(404, gin.H{"error": "Episode not found"})
+	case errors.Is(err, ErrInvalidFeedUrl), errors.Is(err, ErrInvalidPosition):
+		utils.HandleErrorWithMessage(c, err, message, 400)
+	case errors.Is(err, ErrInvalidFeed), errors.Is(err, ErrNoEnclosure), errors.Is(err, ErrEpisodeTooLarge):
+		utils.HandleErrorWithMessage(c, err, message, 422)
+	case errors.Is(err, ErrFeedUnavailable), errors.Is(err, ErrDownloadFailed):
+		utils.HandleErrorWithMessage(c, err, message, 502)
+	case errors.Is(err, auth.ErrInvalidLink):
+		utils.HandleErrorWithMessage(c, err, message, 401)
+	case errors.Is(err, auth.ErrLinkExpired):
+		utils.HandleErrorWithMessage(c, err, message, 410)
+	default:
+		utils.HandleErrorWithMessage(c, err, message, 500)
+	}
+}
@@ -0,0 +1,29 @@
+package podcast
+
+import "github.com/google/uuid"
+
+type PodcastRepository interface {
+	CreatePodcast(podcast *Podcast) error
+	SavePodcast(podcast *Podcast) error
+	GetPodcast(id string) (Podcast, error)
+	GetPodcastByUrl(feedUrl string) (Podcast, error)
+	GetSubscribedPodcasts() ([]Podcast, error)
+
+	Subscribe(subscription *Subscription) error
+	Unsubscribe(userId, podcastId string) (bool, error)
+	IsSubscribed(userId, podcastId string) (bool, error)
+	GetSubscribed(userId string) ([]Podcast, error)
+	UnplayedCounts(userId string, podcastIds []uuid.UUID) (map[uuid.UUID]int64, error)
+
+	CreateEpisode(episode *Episode) error
+	UpdateEpisode(episode *Episode) error
+	SaveDownload(episode *Episode) error
+	ReplaceChapters(episodeId uuid.UUID, chapters []Chapter) error
+	GetEpisode(podcastId, id string) (Episode, error)
+	GetEpisodes(podcastId string, page, limit int) ([]Episode, int64, error)
+	GetAllEpisodes(podcastId uuid.UUID) ([]Episode, error)
+	GetLatestEpisodes(podcastId uuid.UUID, limit int) ([]Episode, error)
+
+	GetProgress(userId string, episodeIds []uuid.UUID) (map[uuid.UUID]Progress, error)
+	SaveProgress(progress *Progress) error
+}
@@ -0,0 +1,237 @@
+package podcast
+
+import (
+	"errors"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// How often a feed is fetched again while someone is subscribed
+	RefreshInterval = time.Hour
+	// Newest episodes of each podcast downloaded ahead of time
+	AutoDownload = 3
+	// Largest feed and episode accepted
+	MaxFeedSize    = 10 << 20
+	MaxEpisodeSize = 1 << 30
+	// An episode counts as played once listened to this close to its end
+	PlayedMargin = 30
+)
+
+// What stream tokens stream
+const TypeEpisode = "episode"
+
+var (
+	ErrInvalidFeedUrl  = errors.New("feed url must be an http or https url")
+	ErrInvalidFeed     = errors.New("not an RSS or Atom feed")
+	ErrFeedUnavailable = errors.New("feed could not be fetched")
+	ErrNotSubscribed   = errors.New("not subscribed to this podcast")
+	ErrNoEnclosure     = errors.New("episode has no audio to download")
+	ErrEpisodeTooLarge = errors.New("episode is larger than 1 GB")
+	ErrDownloadFailed  = errors.New("episode could not be downloaded")
+	ErrInvalidPosition = errors.New("position can't be negative")
+)
+
+// Podcast is a feed shared by everyone subscribed to it, fetched once for all of them
+type Podcast struct {
+	Id            uuid.UUID  `json:"id" db:"id" gorm:"primaryKey"`
+	FeedUrl       string     `json:"feed_url" db:"feed_url" gorm:"not null;uniqueIndex"`
+	Title         string     `json:"title" db:"title"`
+	Description   string     `json:"description" db:"description"`
+	Author        string     `json:"author" db:"author"`
+	Link          string     `json:"link" db:"link"`
+	ImageUrl      string     `json:"image_url" db:"image_url"`
+	Language      string     `json:"language" db:"language"`
+	Explicit      bool       `json:"explicit" db:"explicit"`
+	ETag          string     `json:"-" db:"etag"`
+	LastModified  string     `json:"-" db:"last_modified"`
+	LastFetchedAt *time.Time `json:"last_fetched_at" db:"last_fetched_at" gorm:"index"`
+	LastError     string     `json:"last_error,omitempty" db:"last_error"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at" db:"updated_at"`
+
+	// Episodes the user hasn't played yet, counted on read
+	Unplayed int64 `json:"unplayed" gorm:"-"`
+}
+
+type Subscription struct {
+	UserId    uuid.UUID `json:"user_id" db:"user_id" gorm:"primaryKey"`
+	PodcastId uuid.UUID `json:"podcast_id" db:"podcast_id" gorm:"primaryKey;index"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+
+	// Relationships
+	User    User    `json:"-" gorm:"foreignKey:UserId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Podcast Podcast `json:"-" gorm:"foreignKey:PodcastId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+type Episode struct {
+	Id              uuid.UUID  `json:"id" db:"id" gorm:"primaryKey"`
+	PodcastId       uuid.UUID  `json:"podcast_id" db:"podcast_id" gorm:"not null;uniqueIndex:idx_episode_guid"`
+	Guid            string     `json:"guid" db:"guid" gorm:"not null;uniqueIndex:idx_episode_guid"`
+	Title           string     `json:"title" db:"title"`
+	Description     string     `json:"description" db:"description"`
+	Link            string     `json:"link" db:"link"`
+	ImageUrl        string     `json:"image_url" db:"image_url"`
+	PublishedAt     time.Time  `json:"published_at" db:"published_at" gorm:"index"`
+	Duration        int        `json:"duration" db:"duration"` // seconds
+	Season          int        `json:"season,omitempty" db:"season"`
+	Number          int        `json:"number,omitempty" db:"number"`
+	Explicit        bool       `json:"explicit" db:"explicit"`
+	EnclosureUrl    string     `json:"enclosure_url" db:"enclosure_url"`
+	EnclosureType   string     `json:"enclosure_type" db:"enclosure_type"`
+	EnclosureLength int64      `json:"enclosure_length" db:"enclosure_length"`
+	ChaptersUrl     string     `json:"chapters_url,omitempty" db:"chapters_url"`
+	Filename        string     `json:"-" db:"filename"`
+	DownloadedAt    *time.Time `json:"downloaded_at" db:"downloaded_at"`
+	DownloadError   string     `json:"download_error,omitempty" db:"download_error"`
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at" db:"updated_at"`
+
+	// Relationships
+	Podcast  Podcast   `json:"-" gorm:"foreignKey:PodcastId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Chapters []Chapter `json:"chapters,omitempty" gorm:"foreignKey:EpisodeId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+
+	// The user's progress, filled in on read
+	Progress *Progress `json:"progress,omitempty" gorm:"-"`
+}
+
+// Chapter marks where a part of an episode starts, from Podlove Simple Chapters in the feed
+// or a Podcasting 2.0 chapters file
+type Chapter struct {
+	Id        uuid.UUID `json:"-" db:"id" gorm:"primaryKey"`
+	EpisodeId uuid.UUID `json:"-" db:"episode_id" gorm:"not null;index"`
+	StartMs   int64     `json:"start_ms" db:"start_ms" gorm:"not null"`
+	Title     string    `json:"title" db:"title"`
+	Url       string    `json:"url,omitempty" db:"url"`
+	ImageUrl  string    `json:"image_url,omitempty" db:"image_url"`
+}
+
+// Progress is how far a user got into an episode
+type Progress struct {
+	UserId    uuid.UUID `json:"-" db:"user_id" gorm:"primaryKey"`
+	EpisodeId uuid.UUID `json:"-" db:"episode_id" gorm:"primaryKey;index"`
+	Position  int       `json:"position" db:"position" gorm:"not null;default:0"` // seconds
+	Played    bool      `json:"played" db:"played" gorm:"not null;default:false"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+func (Progress) TableName() string {
+	return "episode_progress"
+}
+
+// StreamToken lets the user's own player stream an episode without the session in its URL
+type StreamToken struct {
+	Token     string    `json:"token"`
+	Url       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type User struct {
+	Id       uuid.UUID `json:"id"`
+	FullName string    `json:"full_name"`
+}
+
+func NewPodcast(feedUrl string) (*Podcast, error) {
+	feedUrl, err := NormalizeFeedUrl(feedUrl)
+	if err != nil {
+		return nil, err
+	}
+	return &Podcast{Id: uuid.New(), FeedUrl: feedUrl}, nil
+}
+
+func NewSubscription(userId, podcastId uuid.UUID) *Subscription {
+	return &Subscription{UserId: userId, PodcastId: podcastId}
+}
+
+func NewProgress(userId, episodeId uuid.UUID, position int, played bool) *Progress {
+	return &Progress{UserId: userId, EpisodeId: episodeId, Position: position, Played: played}
+}
+
+// NormalizeFeedUrl checks a feed url and trims it, so the same feed isn't stored twice
+func NormalizeFeedUrl(feedUrl string) (string, error) {
+	parsed, err := url.Parse(strings.TrimSpace(feedUrl))
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return "", ErrInvalidFeedUrl
+	}
+	parsed.Fragment = ""
+	return parsed.String(), nil
+}
+
+// Apply copies what the latest fetch of the feed says about the podcast
+func (p *Podcast) Apply(feed Feed) {
+	p.Title = feed.Title
+	p.Description = feed.Description
+	p.Author = feed.Author
+	p.Link = feed.Link
+	p.ImageUrl = feed.ImageUrl
+	p.Language = feed.Language
+	p.Explicit = feed.Explicit
+}
+
+// Apply copies what the feed says about an episode, keeping its id and download
+func (e *Episode) Apply(item Item) {
+	e.Title = item.Title
+	e.Description = item.Description
+	e.Link = item.Link
+	e.ImageUrl = item.ImageUrl
+	e.PublishedAt = item.PublishedAt
+	e.Duration = item.Duration
+	e.Season = item.Season
+	e.Number = item.Number
+	e.Explicit = item.Explicit
+	e.ChaptersUrl = item.ChaptersUrl
+
+	// A new enclosure means the old download is stale
+	if e.EnclosureUrl != item.EnclosureUrl {
+		e.DownloadedAt = nil
+		e.DownloadError = ""
+	}
+	e.EnclosureUrl = item.EnclosureUrl
+	e.EnclosureType = item.EnclosureType
+	e.EnclosureLength = item.EnclosureLength
+}
+
+func (e *Episode) Downloaded() bool {
+	return e.DownloadedAt != nil && e.Filename != ""
+}
+
+// ContentType is how an episode is served, the type the feed gave or else one going by the
+// downloaded file's extension
+func (e *Episode) ContentType() string {
+	if isAudio(e.EnclosureType) {
+		return e.EnclosureType
+	}
+
+	switch strings.ToLower(path.Ext(e.Filename)) {
+	case ".m4a":
+		return "audio/mp4"
+	case ".aac":
+		return "audio/aac"
+	case ".ogg":
+		return "audio/ogg"
+	case ".opus":
+		return "audio/opus"
+	case ".wav":
+		return "audio/wav"
+	case ".flac":
+		return "audio/flac"
+	default:
+		return "audio/mpeg"
+	}
+}
+
+// Update moves the user's position, marking the episode played near its end. Setting
+// played explicitly wins over the position
+func (p *Progress) Update(episode Episode, position int, played *bool) {
+	p.Position = position
+	if episode.Duration > 0 && position >= episode.Duration-PlayedMargin {
+		p.Played = true
+	}
+	if played != nil {
+		p.Played = *played
+	}
+}
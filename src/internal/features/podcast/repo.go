@@ -0,0 +1,210 @@
+package podcast
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type SqlPodcastRepository struct {
+	db *gorm.DB
+}
+
+func NewSqlPodcastRepository(db *gorm.DB) *SqlPodcastRepository {
+	return &SqlPodcastRepository{db: db}
+}
+
+func (r *SqlPodcastRepository) CreatePodcast(podcast *Podcast) error {
+	return r.db.Create(podcast).Error
+}
+
+func (r *SqlPodcastRepository) SavePodcast(podcast *Podcast) error {
+	return r.db.Model(podcast).
+		Select("title", "description", "author", "link", "image_url", "language", "explicit", "etag",
+			"last_modified", "last_fetched_at", "last_error", "updated_at").
+		Updates(podcast).Error
+}
+
+func (r *SqlPodcastRepository) GetPodcast(id string) (Podcast, error) {
+	var podcast Podcast
+	if err := r.db.First(&podcast, "id = ?", id).Error; err != nil {
+		return Podcast{}, err
+	}
+	return podcast, nil
+}
+
+func (r *SqlPodcastRepository) GetPodcastByUrl(feedUrl string) (Podcast, error) {
+	var podcast Podcast
+	if err := r.db.First(&podcast, "feed_url = ?", feedUrl).Error; err != nil {
+		return Podcast{}, err
+	}
+	return podcast, nil
+}
+
+// GetSubscribedPodcasts returns the podcasts at least one user is subscribed to
+func (r *SqlPodcastRepository) GetSubscribedPodcasts() ([]Podcast, error) {
+	var podcasts []Podcast
+	err := r.db.
+		Where("id IN (SELECT podcast_id FROM subscriptions)").
+		Find(&podcasts).Error
+	if err != nil {
+		return nil, err
+	}
+	return podcasts, nil
+}
+
+func (r *SqlPodcastRepository) Subscribe(subscription *Subscription) error {
+	return r.db.Omit("User", "Podcast").Clauses(clause.OnConflict{DoNothing: true}).Create(subscription).Error
+}
+
+func (r *SqlPodcastRepository) Unsubscribe(userId, podcastId string) (bool, error) {
+	result := r.db.Delete(&Subscription{}, "user_id = ? AND podcast_id = ?", userId, podcastId)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *SqlPodcastRepository) IsSubscribed(userId, podcastId string) (bool, error) {
+	var count int64
+	err := r.db.Model(&Subscription{}).Where("user_id = ? AND podcast_id = ?", userId, podcastId).Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *SqlPodcastRepository) GetSubscribed(userId string) ([]Podcast, error) {
+	var podcasts []Podcast
+	err := r.db.
+		Joins("JOIN subscriptions ON subscriptions.podcast_id = podcasts.id").
+		Where("subscriptions.user_id = ?", userId).
+		Order("podcasts.title").
+		Find(&podcasts).Error
+	if err != nil {
+		return nil, err
+	}
+	return podcasts, nil
+}
+
+// UnplayedCounts counts the episodes of each podcast the user hasn't marked played
+func (r *SqlPodcastRepository) UnplayedCounts(userId string, podcastIds []uuid.UUID) (map[uuid.UUID]int64, error) {
+	var rows []struct {
+		PodcastId uuid.UUID
+		Count     int64
+	}
+	err := r.db.Model(&Episode{}).
+		Select("podcast_id, COUNT(*) AS count").
+		Where("podcast_id IN ?", podcastIds).
+		Where("id NOT IN (SELECT episode_id FROM episode_progress WHERE user_id = ? AND played = ?)", userId, true).
+		Group("podcast_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[uuid.UUID]int64, len(rows))
+	for _, row := range rows {
+		counts[row.PodcastId] = row.Count
+	}
+	return counts, nil
+}
+
+func (r *SqlPodcastRepository) CreateEpisode(episode *Episode) error {
+	return r.db.Omit("Podcast").Create(episode).Error
+}
+
+func (r *SqlPodcastRepository) UpdateEpisode(episode *Episode) error {
+	return r.db.Model(episode).
+		Select("title", "description", "link", "image_url", "published_at", "duration", "season", "number",
+			"explicit", "enclosure_url", "enclosure_type", "enclosure_length", "chapters_url", "downloaded_at",
+			"download_error", "updated_at").
+		Updates(episode).Error
+}
+
+func (r *SqlPodcastRepository) SaveDownload(episode *Episode) error {
+	return r.db.Model(episode).
+		Select("filename", "downloaded_at", "download_error", "updated_at").
+		Updates(episode).Error
+}
+
+func (r *SqlPodcastRepository) ReplaceChapters(episodeId uuid.UUID, chapters []Chapter) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&Chapter{}, "episode_id = ?", episodeId).Error; err != nil {
+			return err
+		}
+		if len(chapters) == 0 {
+			return nil
+		}
+		return tx.Create(&chapters).Error
+	})
+}
+
+func (r *SqlPodcastRepository) GetEpisode(podcastId, id string) (Episode, error) {
+	var episode Episode
+	err := r.db.
+		Preload("Chapters", func(db *gorm.DB) *gorm.DB { return db.Order("start_ms") }).
+		First(&episode, "id = ? AND podcast_id = ?", id, podcastId).Error
+	if err != nil {
+		return Episode{}, err
+	}
+	return episode, nil
+}
+
+// GetEpisodes pages through a podcast's episodes, newest first
+func (r *SqlPodcastRepository) GetEpisodes(podcastId string, page, limit int) ([]Episode, int64, error) {
+	query := r.db.Model(&Episode{}).Where("podcast_id = ?", podcastId)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var episodes []Episode
+	err := query.
+		Order("published_at DESC").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&episodes).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return episodes, total, nil
+}
+
+func (r *SqlPodcastRepository) GetAllEpisodes(podcastId uuid.UUID) ([]Episode, error) {
+	var episodes []Episode
+	if err := r.db.Where("podcast_id = ?", podcastId).Find(&episodes).Error; err != nil {
+		return nil, err
+	}
+	return episodes, nil
+}
+
+func (r *SqlPodcastRepository) GetLatestEpisodes(podcastId uuid.UUID, limit int) ([]Episode, error) {
+	var episodes []Episode
+	err := r.db.Where("podcast_id = ?", podcastId).
+		Order("published_at DESC").
+		Limit(limit).
+		Find(&episodes).Error
+	if err != nil {
+		return nil, err
+	}
+	return episodes, nil
+}
+
+func (r *SqlPodcastRepository) GetProgress(userId string, episodeIds []uuid.UUID) (map[uuid.UUID]Progress, error) {
+	var rows []Progress
+	if err := r.db.Where("user_id = ? AND episode_id IN ?", userId, episodeIds).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	progress := make(map[uuid.UUID]Progress, len(rows))
+	for _, row := range rows {
+		progress[row.EpisodeId] = row
+	}
+	return progress, nil
+}
+
+func (r *SqlPodcastRepository) SaveProgress(progress *Progress) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "episode_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"position", "played", "updated_at"}),
+	}).Create(progress).Error
+}
@@ -0,0 +1,23 @@
+package podcast
+
+import "github.com/gin-gonic/gin"
+
+func SetupRoutes(c *gin.RouterGroup, h *PodcastHandler, authMiddleware gin.HandlerFunc) {
+	c.Use(authMiddleware)
+
+	c.POST("", h.Subscribe)
+	c.GET("", h.GetAll)
+	c.GET("/:id", h.GetById)
+	c.DELETE("/:id", h.Unsubscribe)
+	c.POST("/:id/refresh", h.Refresh)
+	c.GET("/:id/episodes", h.GetEpisodes)
+	c.GET("/:id/episodes/:episodeId", h.GetEpisode)
+	c.POST("/:id/episodes/:episodeId/download", h.Download)
+	c.POST("/:id/episodes/:episodeId/stream-token", h.CreateStreamToken)
+	c.PUT("/:id/episodes/:episodeId/progress", h.SetProgress)
+}
+
+// SetupStreamRoutes serves episodes to players with a login or a stream token
+func SetupStreamRoutes(c *gin.RouterGroup, h *PodcastHandler, authMiddleware gin.HandlerFunc) {
+	c.GET("/:id/episodes/:episodeId/stream", h.RequireStream(authMiddleware), h.Stream)
+}
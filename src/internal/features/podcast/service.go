@@ -0,0 +1,571 @@
+package podcast
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yosp313/gotify/src/internal/pkg/auth"
+	filestorage "github.com/yosp313/gotify/src/internal/pkg/file_storage"
+	"gorm.io/gorm"
+)
+
+const (
+	feedTimeout     = 30 * time.Second
+	downloadTimeout = 30 * time.Minute
+	userAgent       = "gotify"
+)
+
+type PodcastService struct {
+	repo    PodcastRepository
+	storage filestorage.FileStorageService
+	// Fetches feeds, chapters and episodes, each request gets its own deadline
+	client *http.Client
+	links  *auth.LinkSigner
+}
+
+func NewPodcastService(repo PodcastRepository, storage filestorage.FileStorageService, client *http.Client, links *auth.LinkSigner) *PodcastService {
+	return &PodcastService{repo: repo, storage: storage, client: client, links: links}
+}
+
+// Subscribe subscribes the user to a feed. The first subscriber to a feed fetches it, so a
+// url that isn't a feed fails right away
+func (s *PodcastService) Subscribe(userId, feedUrl string) (Podcast, error) {
+	feedUrl, err := NormalizeFeedUrl(feedUrl)
+	if err != nil {
+		return Podcast{}, err
+	}
+
+	podcast, err := s.repo.GetPodcastByUrl(feedUrl)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		podcast, err = s.add(feedUrl)
+	}
+	if err != nil {
+		return Podcast{}, err
+	}
+
+	if err := s.repo.Subscribe(NewSubscription(uuid.MustParse(userId), podcast.Id)); err != nil {
+		return Podcast{}, err
+	}
+	return s.Get(userId, podcast.Id.String())
+}
+
+func (s *PodcastService) Unsubscribe(userId, id string) error {
+	deleted, err := s.repo.Unsubscribe(userId, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrNotSubscribed
+	}
+	return nil
+}
+
+func (s *PodcastService) GetSubscribed(userId string) ([]Podcast, error) {
+	podcasts, err := s.repo.GetSubscribed(userId)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.withUnplayed(userId, podcasts); err != nil {
+		return nil, err
+	}
+	return podcasts, nil
+}
+
+func (s *PodcastService) Get(userId, id string) (Podcast, error) {
+	podcast, err := s.subscribed(userId, id)
+	if err != nil {
+		return Podcast{}, err
+	}
+
+	podcasts := []Podcast{podcast}
+	if err := s.withUnplayed(userId, podcasts); err != nil {
+		return Podcast{}, err
+	}
+	return podcasts[0], nil
+}
+
+// Refresh fetches a podcast's feed now rather than waiting for the schedule
+func (s *PodcastService) Refresh(userId, id string) (Podcast, error) {
+	podcast, err := s.subscribed(userId, id)
+	if err != nil {
+		return Podcast{}, err
+	}
+
+	if err := s.refresh(&podcast); err != nil {
+		return Podcast{}, err
+	}
+	return s.Get(userId, id)
+}
+
+func (s *PodcastService) GetEpisodes(userId, podcastId string, page, limit int) ([]Episode, int64, error) {
+	if _, err := s.subscribed(userId, podcastId); err != nil {
+		return nil, 0, err
+	}
+
+	episodes, total, err := s.repo.GetEpisodes(podcastId, page, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := s.withProgress(userId, episodes); err != nil {
+		return nil, 0, err
+	}
+	return episodes, total, nil
+}
+
+// StreamToken signs a short-lived link for the user's own player, so it can stream an
+// episode without the user's session in its URL
+func (s *PodcastService) StreamToken(userId, podcastId, id string) (string, time.Time, error) {
+	if _, err := s.subscribed(userId, podcastId); err != nil {
+		return "", time.Time{}, err
+	}
+	episode, err := s.repo.GetEpisode(podcastId, id)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	token, expiresAt := s.links.SignStream(TypeEpisode, episode.Id.String(), userId)
+	return token, expiresAt, nil
+}
+
+// AuthorizeStream checks a stream token for the episode and returns the user it streams as
+func (s *PodcastService) AuthorizeStream(token, id string) (string, error) {
+	return s.links.ParseStream(token, TypeEpisode, id)
+}
+
+func (s *PodcastService) GetEpisode(userId, podcastId, id string) (Episode, error) {
+	if _, err := s.subscribed(userId, podcastId); err != nil {
+		return Episode{}, err
+	}
+
+	episode, err := s.repo.GetEpisode(podcastId, id)
+	if err != nil {
+		return Episode{}, err
+	}
+
+	episodes := []Episode{episode}
+	if err := s.withProgress(userId, episodes); err != nil {
+		return Episode{}, err
+	}
+	return episodes[0], nil
+}
+
+// Download fetches an episode into storage now. It also retries episodes the scheduled
+// download gave up on
+func (s *PodcastService) Download(userId, podcastId, id string) (Episode, error) {
+	episode, err := s.GetEpisode(userId, podcastId, id)
+	if err != nil {
+		return Episode{}, err
+	}
+
+	if !episode.Downloaded() {
+		if err := s.download(&episode); err != nil {
+			return Episode{}, err
+		}
+	}
+	return episode, nil
+}
+
+// EpisodeFile is where a downloaded episode is kept, empty when it isn't downloaded
+func (s *PodcastService) EpisodeFile(episode Episode) string {
+	if !episode.Downloaded() {
+		return ""
+	}
+	return s.storage.FilePath(episode.Filename)
+}
+
+// SetProgress saves how far the user got into an episode
+func (s *PodcastService) SetProgress(userId, podcastId, id string, position int, played *bool) (Progress, error) {
+	if position < 0 {
+		return Progress{}, ErrInvalidPosition
+	}
+
+	episode, err := s.GetEpisode(userId, podcastId, id)
+	if err != nil {
+		return Progress{}, err
+	}
+
+	progress := NewProgress(uuid.MustParse(userId), episode.Id, 0, false)
+	if episode.Progress != nil {
+		progress = episode.Progress
+	}
+	progress.Update(episode, position, played)
+
+	if err := s.repo.SaveProgress(progress); err != nil {
+		return Progress{}, err
+	}
+	return *progress, nil
+}
+
+// RefreshFeeds fetches the feeds that are due and downloads the newest episodes of every
+// podcast. A feed that fails keeps its error for subscribers to see and doesn't stop the others
+func (s *PodcastService) RefreshFeeds() error {
+	podcasts, err := s.repo.GetSubscribedPodcasts()
+	if err != nil {
+		return err
+	}
+
+	due := time.Now().Add(-RefreshInterval)
+	for i := range podcasts {
+		podcast := &podcasts[i]
+		if podcast.LastFetchedAt == nil || podcast.LastFetchedAt.Before(due) {
+			if err := s.refresh(podcast); err != nil {
+				log.Printf("podcast: refreshing %s failed: %v", podcast.FeedUrl, err)
+				continue
+			}
+		}
+		if err := s.downloadLatest(podcast.Id); err != nil {
+			log.Printf("podcast: downloading episodes of %s failed: %v", podcast.FeedUrl, err)
+		}
+	}
+	return nil
+}
+
+// add fetches a feed nobody subscribed to yet and stores it with its episodes
+func (s *PodcastService) add(feedUrl string) (Podcast, error) {
+	podcast, err := NewPodcast(feedUrl)
+	if err != nil {
+		return Podcast{}, err
+	}
+
+	feed, _, err := s.fetch(podcast)
+	if err != nil {
+		return Podcast{}, err
+	}
+	now := time.Now()
+	podcast.LastFetchedAt = &now
+	podcast.Apply(feed)
+
+	if err := s.repo.CreatePodcast(podcast); err != nil {
+		return Podcast{}, err
+	}
+	if err := s.storeEpisodes(podcast.Id, feed.Items); err != nil {
+		return Podcast{}, err
+	}
+	return *podcast, nil
+}
+
+func (s *PodcastService) refresh(podcast *Podcast) error {
+	feed, modified, err := s.fetch(podcast)
+
+	now := time.Now()
+	podcast.LastFetchedAt = &now
+	if err != nil {
+		podcast.LastError = err.Error()
+		if saveErr := s.repo.SavePodcast(podcast); saveErr != nil {
+			return saveErr
+		}
+		return err
+	}
+
+	podcast.LastError = ""
+	if modified {
+		podcast.Apply(feed)
+		if err := s.storeEpisodes(podcast.Id, feed.Items); err != nil {
+			return err
+		}
+	}
+	return s.repo.SavePodcast(podcast)
+}
+
+// fetch gets and parses a podcast's feed. It asks for the feed only if it changed since the
+// last fetch, reporting false when it didn't
+func (s *PodcastService) fetch(podcast *Podcast) (Feed, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), feedTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, podcast.FeedUrl, nil)
+	if err != nil {
+		return Feed{}, false, ErrInvalidFeedUrl
+	}
+	req.Header.Set("User-Agent", userAgent)
+	if podcast.ETag != "" {
+		req.Header.Set("If-None-Match", podcast.ETag)
+	}
+	if podcast.LastModified != "" {
+		req.Header.Set("If-Modified-Since", podcast.LastModified)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return Feed{}, false, fmt.Errorf("%w: %v", ErrFeedUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified:
+		return Feed{}, false, nil
+	case resp.StatusCode != http.StatusOK:
+		return Feed{}, false, fmt.Errorf("%w: %s", ErrFeedUnavailable, resp.Status)
+	}
+
+	feed, err := ParseFeed(io.LimitReader(resp.Body, MaxFeedSize))
+	if err != nil {
+		return Feed{}, false, err
+	}
+
+	podcast.ETag = resp.Header.Get("ETag")
+	podcast.LastModified = resp.Header.Get("Last-Modified")
+	return feed, true, nil
+}
+
+// storeEpisodes adds new episodes and updates the ones the feed changed, matched by guid.
+// Episodes that dropped out of the feed are kept
+func (s *PodcastService) storeEpisodes(podcastId uuid.UUID, items []Item) error {
+	existing, err := s.repo.GetAllEpisodes(podcastId)
+	if err != nil {
+		return err
+	}
+	byGuid := make(map[string]Episode, len(existing))
+	for _, episode := range existing {
+		byGuid[episode.Guid] = episode
+	}
+
+	seen := map[string]bool{}
+	for _, item := range items {
+		if seen[item.Guid] {
+			continue
+		}
+		seen[item.Guid] = true
+
+		if episode, ok := byGuid[item.Guid]; ok {
+			episode.Apply(item)
+			if err := s.repo.UpdateEpisode(&episode); err != nil {
+				return err
+			}
+			if len(item.Chapters) > 0 {
+				if err := s.repo.ReplaceChapters(episode.Id, newChapters(episode.Id, item.Chapters)); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		episode := Episode{Id: uuid.New(), PodcastId: podcastId, Guid: item.Guid}
+		episode.Apply(item)
+		chapters := item.Chapters
+		if len(chapters) == 0 && item.ChaptersUrl != "" {
+			// Chapters are a nice to have, the episode is stored without them if they fail
+			chapters, _ = s.fetchChapters(item.ChaptersUrl)
+		}
+		episode.Chapters = newChapters(episode.Id, chapters)
+
+		if err := s.repo.CreateEpisode(&episode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *PodcastService) fetchChapters(chaptersUrl string) ([]Chapter, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), feedTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, chaptersUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("chapters: %s", resp.Status)
+	}
+	return ParseChapters(io.LimitReader(resp.Body, MaxFeedSize))
+}
+
+// downloadLatest downloads the newest episodes of a podcast ahead of time. Episodes that
+// failed before are left for a manual download
+func (s *PodcastService) downloadLatest(podcastId uuid.UUID) error {
+	episodes, err := s.repo.GetLatestEpisodes(podcastId, AutoDownload)
+	if err != nil {
+		return err
+	}
+
+	for i := range episodes {
+		episode := &episodes[i]
+		if episode.Downloaded() || episode.DownloadError != "" {
+			continue
+		}
+		if err := s.download(episode); err != nil {
+			log.Printf("podcast: downloading episode %s failed: %v", episode.Id, err)
+		}
+	}
+	return nil
+}
+
+// download fetches an episode's enclosure into storage, recording why when it fails
+func (s *PodcastService) download(episode *Episode) error {
+	if episode.EnclosureUrl == "" {
+		return ErrNoEnclosure
+	}
+
+	filename, err := s.fetchEnclosure(*episode)
+	if err != nil {
+		episode.DownloadError = err.Error()
+		if saveErr := s.repo.SaveDownload(episode); saveErr != nil {
+			return saveErr
+		}
+		return err
+	}
+
+	// A changed enclosure can come with another extension
+	if episode.Filename != "" && episode.Filename != filename {
+		s.storage.DeleteFile(episode.Filename)
+	}
+
+	now := time.Now()
+	episode.Filename = filename
+	episode.DownloadedAt = &now
+	episode.DownloadError = ""
+	return s.repo.SaveDownload(episode)
+}
+
+func (s *PodcastService) fetchEnclosure(episode Episode) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), downloadTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, episode.EnclosureUrl, nil)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrDownloadFailed, err)
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrDownloadFailed, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%w: %s", ErrDownloadFailed, resp.Status)
+	}
+	if resp.ContentLength > MaxEpisodeSize {
+		return "", ErrEpisodeTooLarge
+	}
+
+	filename := episode.Id.String() + enclosureExtension(episode.EnclosureUrl, resp.Header.Get("Content-Type"))
+	// One byte over the limit is enough to tell the episode is too large
+	filename, err = s.storage.SaveFile(io.LimitReader(resp.Body, MaxEpisodeSize+1), filename)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrDownloadFailed, err)
+	}
+
+	info, err := os.Stat(s.storage.FilePath(filename))
+	if err != nil {
+		return "", err
+	}
+	if info.Size() > MaxEpisodeSize {
+		s.storage.DeleteFile(filename)
+		return "", ErrEpisodeTooLarge
+	}
+	return filename, nil
+}
+
+func (s *PodcastService) subscribed(userId, id string) (Podcast, error) {
+	subscribed, err := s.repo.IsSubscribed(userId, id)
+	if err != nil {
+		return Podcast{}, err
+	}
+	if !subscribed {
+		return Podcast{}, ErrNotSubscribed
+	}
+	return s.repo.GetPodcast(id)
+}
+
+func (s *PodcastService) withUnplayed(userId string, podcasts []Podcast) error {
+	if len(podcasts) == 0 {
+		return nil
+	}
+
+	ids := make([]uuid.UUID, len(podcasts))
+	for i, podcast := range podcasts {
+		ids[i] = podcast.Id
+	}
+	counts, err := s.repo.UnplayedCounts(userId, ids)
+	if err != nil {
+		return err
+	}
+
+	for i := range podcasts {
+		podcasts[i].Unplayed = counts[podcasts[i].Id]
+	}
+	return nil
+}
+
+func (s *PodcastService) withProgress(userId string, episodes []Episode) error {
+	if len(episodes) == 0 {
+		return nil
+	}
+
+	ids := make([]uuid.UUID, len(episodes))
+	for i, episode := range episodes {
+		ids[i] = episode.Id
+	}
+	progress, err := s.repo.GetProgress(userId, ids)
+	if err != nil {
+		return err
+	}
+
+	for i := range episodes {
+		if p, ok := progress[episodes[i].Id]; ok {
+			episodes[i].Progress = &p
+		}
+	}
+	return nil
+}
+
+func newChapters(episodeId uuid.UUID, chapters []Chapter) []Chapter {
+	stored := make([]Chapter, len(chapters))
+	for i, chapter := range chapters {
+		chapter.Id = uuid.New()
+		chapter.EpisodeId = episodeId
+		stored[i] = chapter
+	}
+	return stored
+}
+
+// Extensions of audio formats podcasts are published in
+var audioExtensions = map[string]string{
+	"audio/mpeg":  ".mp3",
+	"audio/mp3":   ".mp3",
+	"audio/mp4":   ".m4a",
+	"audio/x-m4a": ".m4a",
+	"audio/aac":   ".aac",
+	"audio/ogg":   ".ogg",
+	"audio/opus":  ".opus",
+	"audio/wav":   ".wav",
+	"audio/flac":  ".flac",
+}
+
+// enclosureExtension names a downloaded episode's format, from its url or else its content type
+func enclosureExtension(enclosureUrl, contentType string) string {
+	if parsed, err := url.Parse(enclosureUrl); err == nil {
+		ext := strings.ToLower(path.Ext(parsed.Path))
+		for _, known := range audioExtensions {
+			if ext == known {
+				return ext
+			}
+		}
+	}
+
+	contentType, _, _ = strings.Cut(contentType, ";")
+	if ext, ok := audioExtensions[strings.TrimSpace(strings.ToLower(contentType))]; ok {
+		return ext
+	}
+	return ".mp3"
+}
@@ -0,0 +1,146 @@
+package queue
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yosp313/gotify/src/internal/pkg/hub"
+	"github.com/yosp313/gotify/src/internal/utils"
+	"golang.org/x/net/websocket"
+)
+
+type QueueHandler struct {
+	service *QueueService
+}
+
+func NewQueueHandler(service *QueueService) *QueueHandler {
+	return &QueueHandler{service: service}
+}
+
+func (h *QueueHandler) Get(c *gin.Context) {
+	state, err := h.service.Get(c.GetString("user_id"))
+	if err != nil {
+		utils.HandleErrorWithMessage(c, err, "Failed to retrieve queue", 500)
+		return
+	}
+
+	c.JSON(200, state)
+}
+
+func (h *QueueHandler) Replace(c *gin.Context) {
+	h.apply(c, CommandReplace)
+}
+
+func (h *QueueHandler) Add(c *gin.Context) {
+	h.apply(c, CommandAdd)
+}
+
+func (h *QueueHandler) Move(c *gin.Context) {
+	h.apply(c, CommandMove)
+}
+
+func (h *QueueHandler) Playback(c *gin.Context) {
+	h.apply(c, CommandPlayback)
+}
+
+func (h *QueueHandler) Transfer(c *gin.Context) {
+	h.apply(c, CommandTransfer)
+}
+
+func (h *QueueHandler) Remove(c *gin.Context) {
+	position, err := strconv.Atoi(c.Param("position"))
+	if err != nil {
+		utils.HandleErrorWithMessage(c, err, "Invalid position", 400)
+		return
+	}
+
+	state, err := h.service.Apply(c.GetString("user_id"), "", Command{Type: CommandRemove, From: &position})
+	if err != nil {
+		handleQueueError(c, err)
+		return
+	}
+
+	c.JSON(200, state)
+}
+
+func (h *QueueHandler) apply(c *gin.Context, commandType string) {
+	var command Command
+	if err := c.ShouldBindJSON(&command); err != nil {
+		utils.HandleErrorWithMessage(c, err, "Invalid request body", 400)
+		return
+	}
+	command.Type = commandType
+
+	state, err := h.service.Apply(c.GetString("user_id"), "", command)
+	if err != nil {
+		handleQueueError(c, err)
+		return
+	}
+
+	c.JSON(200, state)
+}
+
+// Connect upgrades to a WebSocket that pushes queue changes and takes the same commands as
+// the REST endpoints. Browsers pass the JWT as ?token= since they can't set headers here
+func (h *QueueHandler) Connect(c *gin.Context) {
+	userId := c.GetString("user_id")
+	deviceId := strings.TrimSpace(c.Query("device_id"))
+	deviceName := strings.TrimSpace(c.DefaultQuery("device_name", deviceId))
+	if deviceId == "" || len(deviceId) > 100 || len(deviceName) > 100 {
+		c.JSON(400, gin.H{"error": "device_id is required and device names can be at most 100 characters"})
+		return
+	}
+
+	hub.Serve(c.Writer, c.Request, func(conn *websocket.Conn) {
+		client := hub.NewClient(conn, deviceId, deviceName, userId)
+		defer client.Close()
+
+		if err := h.service.Connect(userId, client); err != nil {
+			log.Printf("queue: failed to connect device %s: %v", deviceId, err)
+			return
+		}
+		defer func() {
+			if err := h.service.Disconnect(userId, client); err != nil {
+				log.Printf("queue: failed to disconnect device %s: %v", deviceId, err)
+			}
+		}()
+
+		for {
+			data, err := client.Receive()
+			if err != nil {
+				return
+			}
+
+			var command Command
+			if err := json.Unmarshal(data, &command); err != nil {
+				client.Send(hub.NewMessage("error", gin.H{"error": "Invalid message"}))
+				continue
+			}
+			if command.Type == "ping" {
+				client.Send(hub.NewMessage("pong", nil))
+				continue
+			}
+
+			// Successful commands reach every device, this one included, as a state message
+			if _, err := h.service.Apply(userId, deviceId, command); err != nil {
+				client.Send(hub.NewMessage("error", gin.H{"command": command.Type, "error": err.Error()}))
+			}
+		}
+	})
+}
+
+func handleQueueError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrUnknownSong), errors.Is(err, ErrUnknownDevice):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrOutOfRange), errors.Is(err, ErrQueueTooLong),
+		errors.Is(err, ErrInvalidRepeat), errors.Is(err, ErrUnknownCommand):
+		utils.HandleErrorWithMessage(c, err, "Invalid queue change", 400)
+	default:
+		utils.HandleErrorWithMessage(c, err, "Failed to update queue", 500)
+	}
+}
@@ -0,0 +1,10 @@
+package queue
+
+import "github.com/google/uuid"
+
+type QueueRepository interface {
+	GetQueue(userId string) (Queue, error)
+	GetItems(userId string) ([]QueueItem, error)
+	SaveQueue(queue *Queue, items []QueueItem) error
+	ExistingSongIds(ids []uuid.UUID) (map[uuid.UUID]bool, error)
+}
@@ -0,0 +1,100 @@
+package queue
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const MaxQueueLength = 1000
+
+// Repeat modes
+const (
+	RepeatOff = "off"
+	RepeatAll = "all"
+	RepeatOne = "one"
+)
+
+var (
+	ErrQueueTooLong   = errors.New("a queue can hold at most 1000 songs")
+	ErrUnknownSong    = errors.New("song not found")
+	ErrOutOfRange     = errors.New("position is outside the queue")
+	ErrInvalidRepeat  = errors.New("repeat must be off, all or one")
+	ErrUnknownDevice  = errors.New("device is not connected")
+	ErrUnknownCommand = errors.New("unknown command")
+)
+
+// Queue is the playback state of a user, shared by all of their devices
+type Queue struct {
+	UserId       uuid.UUID `json:"-" db:"user_id" gorm:"primaryKey"`
+	Index        int       `json:"index" db:"index" gorm:"not null;default:0"` // position of the current song
+	PositionMs   int       `json:"position_ms" db:"position_ms" gorm:"not null;default:0"`
+	PositionAt   time.Time `json:"position_at" db:"position_at"` // when PositionMs was measured
+	Playing      bool      `json:"playing" db:"playing" gorm:"not null;default:false"`
+	Shuffle      bool      `json:"shuffle" db:"shuffle" gorm:"not null;default:false"`
+	Repeat       string    `json:"repeat" db:"repeat" gorm:"not null;default:off"`
+	ActiveDevice string    `json:"active_device" db:"active_device"`
+	Version      int       `json:"version" db:"version" gorm:"not null;default:0"`
+	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type QueueItem struct {
+	UserId   uuid.UUID `json:"-" db:"user_id" gorm:"primaryKey"`
+	Position int       `json:"position" db:"position" gorm:"primaryKey"`
+	SongId   uuid.UUID `json:"song_id" db:"song_id" gorm:"not null"`
+
+	// Relationships
+	Song Song `json:"song" gorm:"foreignKey:SongId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+type Song struct {
+	Id       uuid.UUID `json:"id"`
+	Title    string    `json:"title"`
+	ArtistId uuid.UUID `json:"artist_id"`
+	Duration int       `json:"duration"`
+
+	// Relationships
+	Artist User `json:"artist" gorm:"foreignKey:ArtistId;references:Id"`
+}
+
+type User struct {
+	Id       uuid.UUID `json:"id"`
+	FullName string    `json:"full_name"`
+}
+
+// Device is a connected client that can play the queue
+type Device struct {
+	Id     string `json:"id"`
+	Name   string `json:"name"`
+	Active bool   `json:"active"`
+}
+
+// State is everything a device needs to show and resume playback
+type State struct {
+	Queue   Queue       `json:"queue"`
+	Items   []QueueItem `json:"items"`
+	Devices []Device    `json:"devices"`
+}
+
+func NewQueue(userId uuid.UUID) Queue {
+	return Queue{UserId: userId, Repeat: RepeatOff, PositionAt: time.Now()}
+}
+
+// CurrentPositionMs is how far into the current song playback is by now
+func (q *Queue) CurrentPositionMs(now time.Time) int {
+	if !q.Playing || q.PositionAt.IsZero() {
+		return q.PositionMs
+	}
+	return q.PositionMs + int(now.Sub(q.PositionAt).Milliseconds())
+}
+
+// Seek sets the playback position as of now
+func (q *Queue) Seek(positionMs int, now time.Time) {
+	q.PositionMs = max(positionMs, 0)
+	q.PositionAt = now
+}
+
+func IsValidRepeat(repeat string) bool {
+	return repeat == RepeatOff || repeat == RepeatAll || repeat == RepeatOne
+}
@@ -0,0 +1,79 @@
+package queue
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type SqlQueueRepository struct {
+	db *gorm.DB
+}
+
+func NewSqlQueueRepository(db *gorm.DB) *SqlQueueRepository {
+	return &SqlQueueRepository{db: db}
+}
+
+func (r *SqlQueueRepository) GetQueue(userId string) (Queue, error) {
+	var queue Queue
+	result := r.db.Where("user_id = ?", userId).Limit(1).Find(&queue)
+	if result.Error != nil {
+		return Queue{}, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return Queue{}, gorm.ErrRecordNotFound
+	}
+	return queue, nil
+}
+
+func (r *SqlQueueRepository) GetItems(userId string) ([]QueueItem, error) {
+	var items []QueueItem
+	err := r.db.Preload("Song.Artist").
+		Where("user_id = ?", userId).
+		Order("position").
+		Find(&items).Error
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// SaveQueue stores the playback state, and replaces the queued songs unless items is nil
+func (r *SqlQueueRepository) SaveQueue(queue *Queue, items []QueueItem) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(queue).Error; err != nil {
+			return err
+		}
+		if items == nil {
+			return nil
+		}
+
+		if err := tx.Where("user_id = ?", queue.UserId).Delete(&QueueItem{}).Error; err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			return nil
+		}
+		return tx.Omit("Song").CreateInBatches(items, 200).Error
+	})
+}
+
+func (r *SqlQueueRepository) ExistingSongIds(ids []uuid.UUID) (map[uuid.UUID]bool, error) {
+	existing := map[uuid.UUID]bool{}
+	if len(ids) == 0 {
+		return existing, nil
+	}
+
+	var found []uuid.UUID
+	if err := r.db.Model(&Song{}).Scopes(available).Where("id IN ?", ids).Pluck("id", &found).Error; err != nil {
+		return nil, err
+	}
+	for _, id := range found {
+		existing[id] = true
+	}
+	return existing, nil
+}
+
+// available leaves out songs moderators quarantined or took down
+func available(db *gorm.DB) *gorm.DB {
+	return db.Where("songs.status = ?", "active")
+}
@@ -0,0 +1,16 @@
+package queue
+
+import "github.com/gin-gonic/gin"
+
+func SetupRoutes(c *gin.RouterGroup, h *QueueHandler, authMiddleware gin.HandlerFunc) {
+	c.Use(authMiddleware)
+
+	c.GET("", h.Get)
+	c.PUT("", h.Replace)
+	c.POST("/songs", h.Add)
+	c.DELETE("/songs/:position", h.Remove)
+	c.POST("/move", h.Move)
+	c.PUT("/playback", h.Playback)
+	c.PUT("/device", h.Transfer)
+	c.GET("/ws", h.Connect)
+}
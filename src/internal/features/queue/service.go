@@ -0,0 +1,342 @@
+package queue
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yosp313/gotify/src/internal/pkg/hub"
+	"gorm.io/gorm"
+)
+
+// Command types, the same commands come in over REST and the WebSocket
+const (
+	CommandReplace  = "replace"
+	CommandAdd      = "add"
+	CommandRemove   = "remove"
+	CommandMove     = "move"
+	CommandPlayback = "playback"
+	CommandTransfer = "transfer"
+)
+
+// Command is a change to a queue, fields that don't apply to its type are ignored
+type Command struct {
+	Type       string      `json:"type"`
+	SongIds    []uuid.UUID `json:"song_ids"`
+	Next       bool        `json:"next"` // add right after the current song instead of at the end
+	From       *int        `json:"from"` // position to remove or move
+	To         *int        `json:"to"`
+	Index      *int        `json:"index"`
+	PositionMs *int        `json:"position_ms"`
+	Playing    *bool       `json:"playing"`
+	Shuffle    *bool       `json:"shuffle"`
+	Repeat     *string     `json:"repeat"`
+	DeviceId   string      `json:"device_id"`
+}
+
+type QueueService struct {
+	repo QueueRepository
+	hub  *hub.Hub
+	// Commands of different devices are applied one at a time
+	mu sync.Mutex
+}
+
+func NewQueueService(repo QueueRepository, hub *hub.Hub) *QueueService {
+	return &QueueService{repo: repo, hub: hub}
+}
+
+func topic(userId string) string {
+	return "queue:" + userId
+}
+
+func (s *QueueService) Get(userId string) (State, error) {
+	queue, err := s.load(userId)
+	if err != nil {
+		return State{}, err
+	}
+	return s.state(userId, queue)
+}
+
+// Apply runs a command against the user's queue and pushes the new state to all of their
+// devices. device is the id of the device sending the command, empty for REST calls
+func (s *QueueService) Apply(userId, device string, command Command) (State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	queue, err := s.load(userId)
+	if err != nil {
+		return State{}, err
+	}
+	items, err := s.repo.GetItems(userId)
+	if err != nil {
+		return State{}, err
+	}
+
+	// Pin down where playback is before anything changes
+	now := time.Now()
+	queue.Seek(queue.CurrentPositionMs(now), now)
+
+	songIds := make([]uuid.UUID, len(items))
+	for i, item := range items {
+		songIds[i] = item.SongId
+	}
+	changedSongs := true
+
+	switch command.Type {
+	case CommandReplace:
+		if err := s.checkSongs(command.SongIds); err != nil {
+			return State{}, err
+		}
+		songIds = command.SongIds
+		queue.Index = 0
+		queue.Seek(0, now)
+		if command.Index != nil {
+			queue.Index = *command.Index
+		}
+		if command.PositionMs != nil {
+			queue.Seek(*command.PositionMs, now)
+		}
+		if queue.Index < 0 || len(songIds) > 0 && queue.Index >= len(songIds) {
+			return State{}, ErrOutOfRange
+		}
+		if command.Playing != nil {
+			queue.Playing = *command.Playing && len(songIds) > 0
+		}
+	case CommandAdd:
+		if err := s.checkSongs(command.SongIds); err != nil {
+			return State{}, err
+		}
+		at := len(songIds)
+		if command.Next && len(songIds) > 0 {
+			at = queue.Index + 1
+		}
+		songIds = slices.Insert(songIds, at, command.SongIds...)
+	case CommandRemove:
+		if command.From == nil || *command.From < 0 || *command.From >= len(songIds) {
+			return State{}, ErrOutOfRange
+		}
+		from := *command.From
+		songIds = slices.Delete(songIds, from, from+1)
+		switch {
+		case from < queue.Index:
+			queue.Index--
+		case from == queue.Index:
+			// The next song takes the place of the removed one
+			queue.Seek(0, now)
+			if queue.Index >= len(songIds) {
+				queue.Index = 0
+				queue.Playing = false
+			}
+		}
+	case CommandMove:
+		if command.From == nil || command.To == nil ||
+			*command.From < 0 || *command.From >= len(songIds) || *command.To < 0 || *command.To >= len(songIds) {
+			return State{}, ErrOutOfRange
+		}
+		from, to := *command.From, *command.To
+		moved := songIds[from]
+		songIds = slices.Insert(slices.Delete(songIds, from, from+1), to, moved)
+		// The current song stays current wherever it ends up
+		switch {
+		case from == queue.Index:
+			queue.Index = to
+		case from < queue.Index && to >= queue.Index:
+			queue.Index--
+		case from > queue.Index && to <= queue.Index:
+			queue.Index++
+		}
+	case CommandPlayback:
+		changedSongs = false
+		if err := applyPlayback(&queue, command, len(songIds), now); err != nil {
+			return State{}, err
+		}
+	case CommandTransfer:
+		changedSongs = false
+		if !s.isConnected(userId, command.DeviceId) {
+			return State{}, ErrUnknownDevice
+		}
+		queue.ActiveDevice = command.DeviceId
+		if command.Playing != nil {
+			queue.Playing = *command.Playing && len(songIds) > 0
+		}
+	default:
+		return State{}, ErrUnknownCommand
+	}
+
+	if len(songIds) > MaxQueueLength {
+		return State{}, ErrQueueTooLong
+	}
+	if len(songIds) == 0 {
+		queue.Index = 0
+		queue.Playing = false
+	}
+	// Pressing play on a device with nothing playing elsewhere makes it the active one
+	if queue.Playing && device != "" && !s.isConnected(userId, queue.ActiveDevice) {
+		queue.ActiveDevice = device
+	}
+
+	var newItems []QueueItem
+	if changedSongs {
+		newItems = make([]QueueItem, len(songIds))
+		for i, songId := range songIds {
+			newItems[i] = QueueItem{UserId: queue.UserId, Position: i, SongId: songId}
+		}
+	}
+
+	queue.Version++
+	if err := s.repo.SaveQueue(&queue, newItems); err != nil {
+		return State{}, err
+	}
+	return s.publish(userId, queue)
+}
+
+func applyPlayback(queue *Queue, command Command, length int, now time.Time) error {
+	if command.Repeat != nil {
+		if !IsValidRepeat(*command.Repeat) {
+			return ErrInvalidRepeat
+		}
+		queue.Repeat = *command.Repeat
+	}
+	if command.Shuffle != nil {
+		queue.Shuffle = *command.Shuffle
+	}
+
+	if command.Index != nil {
+		if *command.Index < 0 || *command.Index >= length {
+			return ErrOutOfRange
+		}
+		queue.Index = *command.Index
+		queue.Seek(0, now)
+	}
+	if command.PositionMs != nil {
+		queue.Seek(*command.PositionMs, now)
+	}
+	if command.Playing != nil {
+		queue.Playing = *command.Playing && length > 0
+	}
+	return nil
+}
+
+// Connect registers a device and sends it the current state
+func (s *QueueService) Connect(userId string, client *hub.Client) error {
+	s.hub.Join(topic(userId), client)
+
+	state, err := s.Get(userId)
+	if err != nil {
+		return err
+	}
+	client.Send(hub.NewMessage("state", state))
+
+	// The other devices learn about the new one
+	s.hub.Publish(topic(userId), hub.NewMessage("devices", state.Devices))
+	return nil
+}
+
+// Disconnect removes a device. When the device that was playing goes away playback pauses
+// where it was, so another device can pick it up from there
+func (s *QueueService) Disconnect(userId string, client *hub.Client) error {
+	s.hub.Leave(topic(userId), client)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	queue, err := s.load(userId)
+	if err != nil {
+		return err
+	}
+
+	if queue.ActiveDevice == client.Id && !s.isConnected(userId, client.Id) {
+		now := time.Now()
+		queue.Seek(queue.CurrentPositionMs(now), now)
+		queue.Playing = false
+		queue.ActiveDevice = ""
+		queue.Version++
+		if err := s.repo.SaveQueue(&queue, nil); err != nil {
+			return err
+		}
+		_, err := s.publish(userId, queue)
+		return err
+	}
+
+	state, err := s.state(userId, queue)
+	if err != nil {
+		return err
+	}
+	s.hub.Publish(topic(userId), hub.NewMessage("devices", state.Devices))
+	return nil
+}
+
+func (s *QueueService) publish(userId string, queue Queue) (State, error) {
+	state, err := s.state(userId, queue)
+	if err != nil {
+		return State{}, err
+	}
+	s.hub.Publish(topic(userId), hub.NewMessage("state", state))
+	return state, nil
+}
+
+func (s *QueueService) state(userId string, queue Queue) (State, error) {
+	items, err := s.repo.GetItems(userId)
+	if err != nil {
+		return State{}, err
+	}
+
+	devices := []Device{}
+	seen := map[string]bool{}
+	for _, client := range s.hub.Clients(topic(userId)) {
+		if seen[client.Id] {
+			continue
+		}
+		seen[client.Id] = true
+		devices = append(devices, Device{Id: client.Id, Name: client.Name, Active: client.Id == queue.ActiveDevice})
+	}
+	slices.SortFunc(devices, func(a, b Device) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return State{Queue: queue, Items: items, Devices: devices}, nil
+}
+
+func (s *QueueService) load(userId string) (Queue, error) {
+	queue, err := s.repo.GetQueue(userId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		userUUID, err := uuid.Parse(userId)
+		if err != nil {
+			return Queue{}, err
+		}
+		return NewQueue(userUUID), nil
+	}
+	return queue, err
+}
+
+func (s *QueueService) isConnected(userId, device string) bool {
+	if device == "" {
+		return false
+	}
+	for _, client := range s.hub.Clients(topic(userId)) {
+		if client.Id == device {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *QueueService) checkSongs(ids []uuid.UUID) error {
+	if len(ids) > MaxQueueLength {
+		return ErrQueueTooLong
+	}
+
+	existing, err := s.repo.ExistingSongIds(ids)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if !existing[id] {
+			return ErrUnknownSong
+		}
+	}
+	return nil
+}
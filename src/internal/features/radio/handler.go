@@ -0,0 +1,110 @@
+package radio
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yosp313/gotify/src/internal/utils"
+	"gorm.io/gorm"
+)
+
+type RadioHandler struct {
+	service *RadioService
+}
+
+type StartRequest struct {
+	SeedType string `json:"seed_type" binding:"required,oneof=song artist playlist"`
+	SeedId   string `json:"seed_id" binding:"required,uuid"`
+	Count    int    `json:"count" binding:"omitempty,min=1,max=50"`
+}
+
+type TracksResponse struct {
+	SessionId string  `json:"session_id"`
+	SeedType  string  `json:"seed_type"`
+	SeedId    string  `json:"seed_id"`
+	Tracks    []Track `json:"tracks"`
+	Next      string  `json:"next"`
+}
+
+func NewRadioHandler(service *RadioService) *RadioHandler {
+	return &RadioHandler{service: service}
+}
+
+func (h *RadioHandler) Start(c *gin.Context) {
+	var req StartRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.HandleErrorWithMessage(c, err, "Invalid request body", 400)
+		return
+	}
+	if req.Count == 0 {
+		req.Count = 10
+	}
+
+	session, tracks, err := h.service.Start(c.GetString("user_id"), req.SeedType, req.SeedId, req.Count)
+	if err != nil {
+		handleRadioError(c, err, "Failed to start radio")
+		return
+	}
+
+	c.JSON(201, newTracksResponse(c, session, tracks, req.Count))
+}
+
+func (h *RadioHandler) Next(c *gin.Context) {
+	count, err := strconv.Atoi(c.DefaultQuery("count", "10"))
+	if err != nil || count < 1 {
+		count = 10
+	}
+	count = min(count, MaxPageSize)
+
+	session, tracks, err := h.service.Next(c.GetString("user_id"), c.Param("id"), count)
+	if err != nil {
+		handleRadioError(c, err, "Failed to retrieve next tracks")
+		return
+	}
+
+	c.JSON(200, newTracksResponse(c, session, tracks, count))
+}
+
+func (h *RadioHandler) Dislike(c *gin.Context) {
+	if err := h.service.Dislike(c.GetString("user_id"), c.Param("songId")); err != nil {
+		handleRadioError(c, err, "Failed to dislike song")
+		return
+	}
+
+	c.JSON(200, gin.H{"disliked": true})
+}
+
+func (h *RadioHandler) Undislike(c *gin.Context) {
+	if err := h.service.Undislike(c.GetString("user_id"), c.Param("songId")); err != nil {
+		utils.HandleErrorWithMessage(c, err, "Failed to remove dislike", 500)
+		return
+	}
+
+	c.JSON(200, gin.H{"disliked": false})
+}
+
+func newTracksResponse(c *gin.Context, session Session, tracks []Track, count int) TracksResponse {
+	return TracksResponse{
+		SessionId: session.Id.String(),
+		SeedType:  session.SeedType,
+		SeedId:    session.SeedId.String(),
+		Tracks:    tracks,
+		Next:      fmt.Sprintf("%s/%s/tracks?count=%d", strings.TrimSuffix(c.FullPath(), "/:id/tracks"), session.Id, count),
+	}
+}
+
+func handleRadioError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(404, gin.H{"error": "Seed or radio session not found"})
+	case errors.Is(err, ErrEmptySeed):
+		utils.HandleErrorWithMessage(c, err, "The seed has no songs to start from", 422)
+	case errors.Is(err, ErrNotSessionOwner):
+		utils.HandleErrorWithMessage(c, err, "Forbidden", 403)
+	default:
+		utils.HandleErrorWithMessage(c, err, message, 500)
+	}
+}
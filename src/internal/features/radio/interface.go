@@ -0,0 +1,29 @@
+package radio
+
+import "github.com/google/uuid"
+
+type RadioRepository interface {
+	CreateSession(session *Session) error
+	GetSession(id string) (Session, error)
+	TouchSession(id uuid.UUID) error
+	GetSong(id string) (Song, error)
+	GetSongs(ids []uuid.UUID) ([]Song, error)
+	GetArtistSongs(artistId string) ([]Song, error)
+	GetPlaylist(id string) (Playlist, error)
+	GetRecentTracks(sessionId uuid.UUID, limit int) ([]Track, error)
+	AddTracks(tracks []Track) error
+	GetTracks(sessionId uuid.UUID, from int) ([]Track, error)
+	GetSimilarities(songIds []uuid.UUID) ([]SongSimilarity, error)
+	GetRelatedSongs(seeds []Song, limit int) ([]Song, error)
+	GetRandomSongs(excluding []uuid.UUID, limit int) ([]Song, error)
+	GetLikedIds(userId string) (map[uuid.UUID]bool, error)
+	GetDislikedIds(userId string) (map[uuid.UUID]bool, error)
+	Dislike(dislike *Dislike) error
+	Undislike(userId, songId string) error
+}
+
+// SongSource resolves the songs of a playlist the user can see, smart playlists included. The
+// playlist feature provides it
+type SongSource interface {
+	SongIds(userId, playlistId string) ([]uuid.UUID, error)
+}
@@ -0,0 +1,108 @@
+package radio
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Seed types
+const (
+	SeedSong     = "song"
+	SeedArtist   = "artist"
+	SeedPlaylist = "playlist"
+)
+
+const (
+	// A song isn't repeated within this many tracks of a session, unless the catalog runs dry
+	RepeatWindow = 50
+	MaxPageSize  = 50
+)
+
+var (
+	ErrEmptySeed       = errors.New("seed has no songs")
+	ErrNotSessionOwner = errors.New("radio session belongs to another user")
+)
+
+type Session struct {
+	Id        uuid.UUID `json:"id" db:"id" gorm:"primaryKey"`
+	UserId    uuid.UUID `json:"user_id" db:"user_id" gorm:"not null;index"`
+	SeedType  string    `json:"seed_type" db:"seed_type" gorm:"not null"`
+	SeedId    uuid.UUID `json:"seed_id" db:"seed_id" gorm:"not null"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type Track struct {
+	SessionId uuid.UUID `json:"-" db:"session_id" gorm:"primaryKey"`
+	Position  int       `json:"position" db:"position" gorm:"primaryKey"`
+	SongId    uuid.UUID `json:"song_id" db:"song_id" gorm:"not null"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+
+	// Relationships
+	Session Session `json:"-" gorm:"foreignKey:SessionId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Song    Song    `json:"song" gorm:"foreignKey:SongId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+// Dislike keeps a song out of the user's radio stations
+type Dislike struct {
+	UserId    uuid.UUID `json:"user_id" db:"user_id" gorm:"primaryKey"`
+	SongId    uuid.UUID `json:"song_id" db:"song_id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+type Song struct {
+	Id       uuid.UUID `json:"id"`
+	Title    string    `json:"title"`
+	ArtistId uuid.UUID `json:"artist_id"`
+	Duration int       `json:"duration"`
+	Genre    string    `json:"genre"`
+	Bpm      int       `json:"bpm"`
+}
+
+type SongSimilarity struct {
+	SongId    uuid.UUID
+	SimilarId uuid.UUID
+	Score     float64
+}
+
+type Playlist struct {
+	Id      uuid.UUID
+	OwnerId uuid.UUID
+	Public  bool
+}
+
+func NewSession(userId, seedType string, seedId uuid.UUID) (*Session, error) {
+	userUUID, err := uuid.Parse(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Session{
+		Id:       uuid.New(),
+		UserId:   userUUID,
+		SeedType: seedType,
+		SeedId:   seedId,
+	}, nil
+}
+
+// affinity scores how well a candidate fits the station's seed songs
+func affinity(seeds []Song, candidate Song) float64 {
+	best := 0.0
+	for _, seed := range seeds {
+		score := 0.0
+		if candidate.ArtistId == seed.ArtistId {
+			score += 0.4
+		}
+		if seed.Genre != "" && candidate.Genre == seed.Genre {
+			score += 0.3
+		}
+		if seed.Bpm > 0 && candidate.Bpm > 0 {
+			diff := float64(max(seed.Bpm-candidate.Bpm, candidate.Bpm-seed.Bpm))
+			score += 0.2 * max(1-diff/20, 0)
+		}
+		best = max(best, score)
+	}
+	return best
+}
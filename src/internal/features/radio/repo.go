@@ -0,0 +1,171 @@
+package radio
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type SqlRadioRepository struct {
+	db *gorm.DB
+}
+
+func NewSqlRadioRepository(db *gorm.DB) *SqlRadioRepository {
+	return &SqlRadioRepository{db: db}
+}
+
+func (r *SqlRadioRepository) CreateSession(session *Session) error {
+	return r.db.Create(session).Error
+}
+
+func (r *SqlRadioRepository) GetSession(id string) (Session, error) {
+	var session Session
+	if err := r.db.First(&session, "id = ?", id).Error; err != nil {
+		return Session{}, err
+	}
+	return session, nil
+}
+
+func (r *SqlRadioRepository) TouchSession(id uuid.UUID) error {
+	return r.db.Model(&Session{}).Where("id = ?", id).Update("updated_at", time.Now()).Error
+}
+
+func (r *SqlRadioRepository) GetSong(id string) (Song, error) {
+	var song Song
+	if err := r.db.Scopes(available).First(&song, "id = ?", id).Error; err != nil {
+		return Song{}, err
+	}
+	return song, nil
+}
+
+func (r *SqlRadioRepository) GetSongs(ids []uuid.UUID) ([]Song, error) {
+	var songs []Song
+	if err := r.db.Scopes(available).Where("id IN ?", ids).Find(&songs).Error; err != nil {
+		return nil, err
+	}
+	return songs, nil
+}
+
+func (r *SqlRadioRepository) GetArtistSongs(artistId string) ([]Song, error) {
+	var songs []Song
+	if err := r.db.Scopes(available).Where("artist_id = ?", artistId).Find(&songs).Error; err != nil {
+		return nil, err
+	}
+	return songs, nil
+}
+
+func (r *SqlRadioRepository) GetPlaylist(id string) (Playlist, error) {
+	var playlist Playlist
+	if err := r.db.First(&playlist, "id = ?", id).Error; err != nil {
+		return Playlist{}, err
+	}
+	return playlist, nil
+}
+
+func (r *SqlRadioRepository) GetRecentTracks(sessionId uuid.UUID, limit int) ([]Track, error) {
+	var tracks []Track
+	err := r.db.Where("session_id = ?", sessionId).
+		Order("position DESC").
+		Limit(limit).
+		Find(&tracks).Error
+	if err != nil {
+		return nil, err
+	}
+	return tracks, nil
+}
+
+func (r *SqlRadioRepository) AddTracks(tracks []Track) error {
+	return r.db.Omit("Session", "Song").Create(&tracks).Error
+}
+
+func (r *SqlRadioRepository) GetTracks(sessionId uuid.UUID, from int) ([]Track, error) {
+	var tracks []Track
+	err := r.db.Preload("Song").
+		Where("session_id = ? AND position >= ?", sessionId, from).
+		Order("position").
+		Find(&tracks).Error
+	if err != nil {
+		return nil, err
+	}
+	return tracks, nil
+}
+
+func (r *SqlRadioRepository) GetSimilarities(songIds []uuid.UUID) ([]SongSimilarity, error) {
+	var similarities []SongSimilarity
+	if err := r.db.Where("song_id IN ?", songIds).Find(&similarities).Error; err != nil {
+		return nil, err
+	}
+	return similarities, nil
+}
+
+// GetRelatedSongs finds songs sharing an artist or a genre with any of the seeds
+func (r *SqlRadioRepository) GetRelatedSongs(seeds []Song, limit int) ([]Song, error) {
+	var artistIds []uuid.UUID
+	var genres []string
+	for _, seed := range seeds {
+		artistIds = append(artistIds, seed.ArtistId)
+		if seed.Genre != "" {
+			genres = append(genres, seed.Genre)
+		}
+	}
+
+	related := r.db.Where("artist_id IN ?", artistIds)
+	if len(genres) > 0 {
+		related = related.Or("genre IN ?", genres)
+	}
+
+	var songs []Song
+	if err := r.db.Scopes(available).Where(related).Limit(limit).Find(&songs).Error; err != nil {
+		return nil, err
+	}
+	return songs, nil
+}
+
+func (r *SqlRadioRepository) GetRandomSongs(excluding []uuid.UUID, limit int) ([]Song, error) {
+	query := r.db.Model(&Song{}).Scopes(available)
+	if len(excluding) > 0 {
+		query = query.Where("id NOT IN ?", excluding)
+	}
+
+	var songs []Song
+	if err := query.Order("RANDOM()").Limit(limit).Find(&songs).Error; err != nil {
+		return nil, err
+	}
+	return songs, nil
+}
+
+func (r *SqlRadioRepository) GetLikedIds(userId string) (map[uuid.UUID]bool, error) {
+	return r.songIdSet("likes", userId)
+}
+
+func (r *SqlRadioRepository) GetDislikedIds(userId string) (map[uuid.UUID]bool, error) {
+	return r.songIdSet("dislikes", userId)
+}
+
+func (r *SqlRadioRepository) songIdSet(table, userId string) (map[uuid.UUID]bool, error) {
+	var ids []uuid.UUID
+	if err := r.db.Table(table).Where("user_id = ?", userId).Pluck("song_id", &ids).Error; err != nil {
+		return nil, err
+	}
+
+	set := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set, nil
+}
+
+func (r *SqlRadioRepository) Dislike(dislike *Dislike) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(dislike).Error
+}
+
+func (r *SqlRadioRepository) Undislike(userId, songId string) error {
+	return r.db.Where("user_id = ? AND song_id = ?", userId, songId).Delete(&Dislike{}).Error
+}
+
+// available leaves out songs moderators quarantined or took down
+func available(db *gorm.DB) *gorm.DB {
+	return db.Where("songs.status = ?", "active")
+}
@@ -0,0 +1,17 @@
+package radio
+
+import "github.com/gin-gonic/gin"
+
+func SetupRoutes(c *gin.RouterGroup, h *RadioHandler, authMiddleware gin.HandlerFunc) {
+	c.Use(authMiddleware)
+
+	c.POST("", h.Start)
+	c.GET("/:id/tracks", h.Next)
+}
+
+func SetupDislikeRoutes(c *gin.RouterGroup, h *RadioHandler, authMiddleware gin.HandlerFunc) {
+	c.Use(authMiddleware)
+
+	c.PUT("/:songId", h.Dislike)
+	c.DELETE("/:songId", h.Undislike)
+}
@@ -0,0 +1,348 @@
+package radio
+
+import (
+	"math/rand/v2"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// How many of the latest tracks also steer the station, so it drifts naturally
+	driftTracks   = 3
+	relatedLimit  = 300
+	likeBoost     = 0.15
+	fillerScore   = 0.05
+	similarWeight = 0.8
+)
+
+type RadioService struct {
+	repo  RadioRepository
+	songs SongSource
+}
+
+func NewRadioService(repo RadioRepository, songs SongSource) *RadioService {
+	return &RadioService{repo: repo, songs: songs}
+}
+
+type candidate struct {
+	song  Song
+	score float64
+}
+
+// Start creates a station from a seed song, artist or playlist and returns its first tracks.
+// A song station always opens with the seed song itself
+func (s *RadioService) Start(userId, seedType, seedId string, count int) (Session, []Track, error) {
+	seedUUID, err := uuid.Parse(seedId)
+	if err != nil {
+		return Session{}, nil, gorm.ErrRecordNotFound
+	}
+
+	seeds, err := s.seedSongs(userId, seedType, seedId)
+	if err != nil {
+		return Session{}, nil, err
+	}
+
+	session, err := NewSession(userId, seedType, seedUUID)
+	if err != nil {
+		return Session{}, nil, err
+	}
+	if err := s.repo.CreateSession(session); err != nil {
+		return Session{}, nil, err
+	}
+
+	tracks, err := s.extend(*session, seeds, count)
+	if err != nil {
+		return Session{}, nil, err
+	}
+	return *session, tracks, nil
+}
+
+// Next picks the following tracks of a station, there is always a next page
+func (s *RadioService) Next(userId, sessionId string, count int) (Session, []Track, error) {
+	session, err := s.repo.GetSession(sessionId)
+	if err != nil {
+		return Session{}, nil, err
+	}
+	if session.UserId.String() != userId {
+		return Session{}, nil, ErrNotSessionOwner
+	}
+
+	seeds, err := s.seedSongs(userId, session.SeedType, session.SeedId.String())
+	if err != nil {
+		return Session{}, nil, err
+	}
+
+	tracks, err := s.extend(session, seeds, count)
+	if err != nil {
+		return Session{}, nil, err
+	}
+	return session, tracks, nil
+}
+
+func (s *RadioService) Dislike(userId, songId string) error {
+	song, err := s.repo.GetSong(songId)
+	if err != nil {
+		return err
+	}
+	return s.repo.Dislike(&Dislike{UserId: uuid.MustParse(userId), SongId: song.Id})
+}
+
+func (s *RadioService) Undislike(userId, songId string) error {
+	return s.repo.Undislike(userId, songId)
+}
+
+func (s *RadioService) seedSongs(userId, seedType, seedId string) ([]Song, error) {
+	var seeds []Song
+	switch seedType {
+	case SeedSong:
+		song, err := s.repo.GetSong(seedId)
+		if err != nil {
+			return nil, err
+		}
+		seeds = []Song{song}
+	case SeedArtist:
+		songs, err := s.repo.GetArtistSongs(seedId)
+		if err != nil {
+			return nil, err
+		}
+		seeds = songs
+	case SeedPlaylist:
+		if _, err := s.repo.GetPlaylist(seedId); err != nil {
+			return nil, err
+		}
+		// Smart playlists are filled by their rules, so the playlist feature resolves the
+		// songs. Someone else's private playlist is reported as missing
+		ids, err := s.songs.SongIds(userId, seedId)
+		if err != nil {
+			return nil, gorm.ErrRecordNotFound
+		}
+
+		songs, err := s.repo.GetSongs(ids)
+		if err != nil {
+			return nil, err
+		}
+		seeds = songs
+	}
+
+	if len(seeds) == 0 {
+		return nil, ErrEmptySeed
+	}
+	return seeds, nil
+}
+
+// extend scores candidates from the seeds, collaborative filtering similarities and
+// metadata, then draws count tracks weighted by score. Disliked songs never play and
+// songs in the repeat window only come back when nothing else is left
+func (s *RadioService) extend(session Session, seeds []Song, count int) ([]Track, error) {
+	userId := session.UserId.String()
+
+	recent, err := s.repo.GetRecentTracks(session.Id, RepeatWindow)
+	if err != nil {
+		return nil, err
+	}
+	disliked, err := s.repo.GetDislikedIds(userId)
+	if err != nil {
+		return nil, err
+	}
+	liked, err := s.repo.GetLikedIds(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	position := 0
+	recentIds := make([]uuid.UUID, 0, len(recent))
+	played := map[uuid.UUID]bool{}
+	for _, track := range recent {
+		recentIds = append(recentIds, track.SongId)
+		played[track.SongId] = true
+	}
+	if len(recent) > 0 {
+		position = recent[0].Position + 1
+	}
+
+	candidates := map[uuid.UUID]*candidate{}
+	add := func(song Song, score float64) {
+		if disliked[song.Id] || played[song.Id] || score <= 0 {
+			return
+		}
+		if candidates[song.Id] == nil {
+			candidates[song.Id] = &candidate{song: song}
+		}
+		candidates[song.Id].score += score
+	}
+
+	var picked []Song
+	if session.SeedType == SeedSong && position == 0 && !disliked[seeds[0].Id] {
+		picked = append(picked, seeds[0])
+		played[seeds[0].Id] = true
+	}
+
+	// Artist and playlist stations play their own songs too
+	if session.SeedType != SeedSong {
+		for _, seed := range seeds {
+			add(seed, 1)
+		}
+	}
+
+	steering := seeds
+	steeringIds := make([]uuid.UUID, 0, len(seeds)+driftTracks)
+	for _, seed := range seeds {
+		steeringIds = append(steeringIds, seed.Id)
+	}
+	if drift := recentIds[:min(len(recentIds), driftTracks)]; len(drift) > 0 {
+		driftSongs, err := s.repo.GetSongs(drift)
+		if err != nil {
+			return nil, err
+		}
+		steering = append(steering, driftSongs...)
+		steeringIds = append(steeringIds, drift...)
+	}
+
+	if err := s.addSimilar(steeringIds, add); err != nil {
+		return nil, err
+	}
+
+	related, err := s.repo.GetRelatedSongs(steering, relatedLimit)
+	if err != nil {
+		return nil, err
+	}
+	for _, song := range related {
+		add(song, affinity(steering, song))
+	}
+
+	for id, c := range candidates {
+		if liked[id] {
+			c.score += likeBoost
+		}
+	}
+
+	needed := count - len(picked)
+	if len(candidates) < 2*needed {
+		excluded := append(append([]uuid.UUID{}, recentIds...), keys(disliked)...)
+		filler, err := s.repo.GetRandomSongs(excluded, 2*needed)
+		if err != nil {
+			return nil, err
+		}
+		for _, song := range filler {
+			add(song, fillerScore)
+		}
+	}
+
+	// A small catalog runs out of fresh songs, so fall back to anything but the last track
+	if len(candidates) < needed {
+		var last uuid.UUID
+		if len(recentIds) > 0 {
+			last = recentIds[0]
+		}
+		played = map[uuid.UUID]bool{last: true}
+
+		filler, err := s.repo.GetRandomSongs(keys(disliked), 2*needed)
+		if err != nil {
+			return nil, err
+		}
+		for _, song := range filler {
+			add(song, fillerScore)
+		}
+	}
+
+	picked = append(picked, draw(candidates, needed, picked)...)
+
+	tracks := make([]Track, 0, len(picked))
+	for i, song := range picked {
+		tracks = append(tracks, Track{SessionId: session.Id, Position: position + i, SongId: song.Id, Song: song})
+	}
+	if len(tracks) == 0 {
+		return tracks, nil
+	}
+
+	if err := s.repo.AddTracks(tracks); err != nil {
+		return nil, err
+	}
+	if err := s.repo.TouchSession(session.Id); err != nil {
+		return nil, err
+	}
+	return tracks, nil
+}
+
+func (s *RadioService) addSimilar(songIds []uuid.UUID, add func(Song, float64)) error {
+	similarities, err := s.repo.GetSimilarities(songIds)
+	if err != nil || len(similarities) == 0 {
+		return err
+	}
+
+	scores := map[uuid.UUID]float64{}
+	ids := make([]uuid.UUID, 0, len(similarities))
+	for _, similarity := range similarities {
+		if _, ok := scores[similarity.SimilarId]; !ok {
+			ids = append(ids, similarity.SimilarId)
+		}
+		scores[similarity.SimilarId] += similarity.Score * similarWeight
+	}
+
+	songs, err := s.repo.GetSongs(ids)
+	if err != nil {
+		return err
+	}
+	for _, song := range songs {
+		add(song, scores[song.Id])
+	}
+	return nil
+}
+
+// draw samples n songs without replacement, weighted by the square of their score,
+// and avoids playing the same artist twice in a row while there's a choice
+func draw(candidates map[uuid.UUID]*candidate, n int, before []Song) []Song {
+	pool := make([]*candidate, 0, len(candidates))
+	for _, c := range candidates {
+		pool = append(pool, c)
+	}
+
+	var lastArtist uuid.UUID
+	if len(before) > 0 {
+		lastArtist = before[len(before)-1].ArtistId
+	}
+
+	var picked []Song
+	for len(picked) < n && len(pool) > 0 {
+		eligible := make([]int, 0, len(pool))
+		for i, c := range pool {
+			if c.song.ArtistId != lastArtist {
+				eligible = append(eligible, i)
+			}
+		}
+		if len(eligible) == 0 {
+			for i := range pool {
+				eligible = append(eligible, i)
+			}
+		}
+
+		total := 0.0
+		for _, i := range eligible {
+			total += pool[i].score * pool[i].score
+		}
+
+		choice := eligible[len(eligible)-1]
+		target := rand.Float64() * total
+		for _, i := range eligible {
+			target -= pool[i].score * pool[i].score
+			if target <= 0 {
+				choice = i
+				break
+			}
+		}
+
+		picked = append(picked, pool[choice].song)
+		lastArtist = pool[choice].song.ArtistId
+		pool = append(pool[:choice], pool[choice+1:]...)
+	}
+	return picked
+}
+
+func keys(set map[uuid.UUID]bool) []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(set))
+	for id := range set {
+		ids = append(ids, id)
+	}
+	return ids
+}
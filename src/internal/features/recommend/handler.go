@@ -0,0 +1,47 @@
+package recommend
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yosp313/gotify/src/internal/utils"
+	"gorm.io/gorm"
+)
+
+type RecommendHandler struct {
+	service *RecommendService
+}
+
+func NewRecommendHandler(service *RecommendService) *RecommendHandler {
+	return &RecommendHandler{service: service}
+}
+
+func (h *RecommendHandler) GetSimilar(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 {
+		limit = 20
+	}
+
+	recommendations, err := h.service.GetSimilar(c.Param("id"), min(limit, 100))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(404, gin.H{"error": "Song not found"})
+		return
+	}
+	if err != nil {
+		utils.HandleErrorWithMessage(c, err, "Failed to retrieve similar songs", 500)
+		return
+	}
+
+	c.JSON(200, gin.H{"songs": recommendations})
+}
+
+func (h *RecommendHandler) GetMixes(c *gin.Context) {
+	mixes, err := h.service.GetMixes(c.GetString("user_id"))
+	if err != nil {
+		utils.HandleErrorWithMessage(c, err, "Failed to retrieve mixes", 500)
+		return
+	}
+
+	c.JSON(200, gin.H{"mixes": mixes})
+}
@@ -0,0 +1,21 @@
+package recommend
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type RecommendRepository interface {
+	GetInteractions(since time.Time) ([]Interaction, error)
+	GetUserInteractions(userId string, since time.Time) ([]Interaction, error)
+	ReplaceSimilarities(similarities []SongSimilarity) error
+	GetSimilarities(songIds []uuid.UUID) ([]SongSimilarity, error)
+	GetSong(id string) (Song, error)
+	GetSongs(ids []uuid.UUID) ([]Song, error)
+	GetMetadataCandidates(seed Song, limit int) ([]Song, error)
+	GetSongsByGenre(genre string, limit int) ([]Song, error)
+	GetPopularSongIds(since time.Time, limit int) ([]uuid.UUID, error)
+	ReplaceMixes(userId uuid.UUID, mixes []Mix) error
+	GetMixes(userId string) ([]Mix, error)
+}
@@ -0,0 +1,94 @@
+package recommend
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Mix kinds
+const (
+	MixDiscover = "discover"
+	MixOnRepeat = "on_repeat"
+	MixGenre    = "genre"
+)
+
+// Recommendation reasons
+const (
+	ReasonListeners = "listeners_also_played"
+	ReasonArtist    = "same_artist"
+	ReasonGenre     = "same_genre"
+	ReasonTempo     = "similar_tempo"
+	ReasonPopular   = "popular"
+)
+
+// SongSimilarity is a precomputed item-item collaborative filtering score
+type SongSimilarity struct {
+	SongId    uuid.UUID `json:"song_id" db:"song_id" gorm:"primaryKey"`
+	SimilarId uuid.UUID `json:"similar_id" db:"similar_id" gorm:"primaryKey"`
+	Score     float64   `json:"score" db:"score" gorm:"not null"`
+	UpdatedAt time.Time `json:"-" db:"updated_at"`
+}
+
+type Mix struct {
+	Id          uuid.UUID `json:"id" db:"id" gorm:"primaryKey"`
+	UserId      uuid.UUID `json:"user_id" db:"user_id" gorm:"not null;index"`
+	Kind        string    `json:"kind" db:"kind" gorm:"not null"`
+	Title       string    `json:"title" db:"title" gorm:"not null"`
+	GeneratedAt time.Time `json:"generated_at" db:"generated_at" gorm:"not null"`
+
+	// Relationships
+	Songs []MixSong `json:"songs" gorm:"foreignKey:MixId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	User  User      `json:"-" gorm:"foreignKey:UserId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+type MixSong struct {
+	MixId    uuid.UUID `json:"-" db:"mix_id" gorm:"primaryKey"`
+	Position int       `json:"position" db:"position" gorm:"primaryKey"`
+	SongId   uuid.UUID `json:"song_id" db:"song_id" gorm:"not null"`
+
+	// Relationships
+	Song Song `json:"song" gorm:"foreignKey:SongId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+type Song struct {
+	Id       uuid.UUID `json:"id"`
+	Title    string    `json:"title"`
+	ArtistId uuid.UUID `json:"artist_id"`
+	Duration int       `json:"duration"`
+	Genre    string    `json:"genre"`
+	Bpm      int       `json:"bpm"`
+}
+
+type User struct {
+	Id uuid.UUID
+}
+
+// Interaction sums up what one user did with one song
+type Interaction struct {
+	UserId uuid.UUID
+	SongId uuid.UUID
+	Plays  int64
+	Liked  bool
+}
+
+type Recommendation struct {
+	Song   Song    `json:"song"`
+	Score  float64 `json:"score"`
+	Reason string  `json:"reason"`
+}
+
+func NewMix(userId uuid.UUID, kind, title string, songIds []uuid.UUID) Mix {
+	mix := Mix{
+		Id:          uuid.New(),
+		UserId:      userId,
+		Kind:        kind,
+		Title:       title,
+		GeneratedAt: time.Now().UTC(),
+	}
+
+	for i, songId := range songIds {
+		mix.Songs = append(mix.Songs, MixSong{MixId: mix.Id, Position: i, SongId: songId})
+	}
+	return mix
+}
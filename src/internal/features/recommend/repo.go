@@ -0,0 +1,185 @@
+package recommend
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type SqlRecommendRepository struct {
+	db *gorm.DB
+}
+
+func NewSqlRecommendRepository(db *gorm.DB) *SqlRecommendRepository {
+	return &SqlRecommendRepository{db: db}
+}
+
+func (r *SqlRecommendRepository) GetInteractions(since time.Time) ([]Interaction, error) {
+	return r.interactions(r.db, r.db, since)
+}
+
+func (r *SqlRecommendRepository) GetUserInteractions(userId string, since time.Time) ([]Interaction, error) {
+	return r.interactions(r.db.Where("user_id = ?", userId), r.db.Where("user_id = ?", userId), since)
+}
+
+// interactions merges play counts since the given time with all likes
+func (r *SqlRecommendRepository) interactions(plays, likes *gorm.DB, since time.Time) ([]Interaction, error) {
+	var playRows []Interaction
+	err := plays.Table("plays").
+		Select("user_id, song_id, COUNT(*) AS plays").
+		Where("counted = ? AND started_at >= ?", true, since).
+		Group("user_id, song_id").
+		Scan(&playRows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var likeRows []Interaction
+	err = likes.Table("likes").
+		Select("user_id, song_id").
+		Scan(&likeRows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	type key struct{ userId, songId uuid.UUID }
+	merged := map[key]*Interaction{}
+	for i := range playRows {
+		merged[key{playRows[i].UserId, playRows[i].SongId}] = &playRows[i]
+	}
+	for _, like := range likeRows {
+		k := key{like.UserId, like.SongId}
+		if merged[k] == nil {
+			merged[k] = &Interaction{UserId: like.UserId, SongId: like.SongId}
+		}
+		merged[k].Liked = true
+	}
+
+	interactions := make([]Interaction, 0, len(merged))
+	for _, interaction := range merged {
+		interactions = append(interactions, *interaction)
+	}
+	return interactions, nil
+}
+
+func (r *SqlRecommendRepository) ReplaceSimilarities(similarities []SongSimilarity) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("1 = 1").Delete(&SongSimilarity{}).Error; err != nil {
+			return err
+		}
+		if len(similarities) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(similarities, 500).Error
+	})
+}
+
+func (r *SqlRecommendRepository) GetSimilarities(songIds []uuid.UUID) ([]SongSimilarity, error) {
+	var similarities []SongSimilarity
+	err := r.db.Where("song_id IN ?", songIds).Order("score DESC").Find(&similarities).Error
+	if err != nil {
+		return nil, err
+	}
+	return similarities, nil
+}
+
+func (r *SqlRecommendRepository) GetSong(id string) (Song, error) {
+	var song Song
+	if err := r.db.Scopes(available).First(&song, "id = ?", id).Error; err != nil {
+		return Song{}, err
+	}
+	return song, nil
+}
+
+func (r *SqlRecommendRepository) GetSongs(ids []uuid.UUID) ([]Song, error) {
+	var songs []Song
+	if err := r.db.Scopes(available).Where("id IN ?", ids).Find(&songs).Error; err != nil {
+		return nil, err
+	}
+	return songs, nil
+}
+
+func (r *SqlRecommendRepository) GetMetadataCandidates(seed Song, limit int) ([]Song, error) {
+	query := r.db.Scopes(available).Where("id <> ?", seed.Id)
+
+	conditions := r.db.Where("artist_id = ?", seed.ArtistId)
+	if seed.Genre != "" {
+		conditions = conditions.Or("genre = ?", seed.Genre)
+	}
+	if seed.Bpm > 0 {
+		conditions = conditions.Or("bpm BETWEEN ? AND ?", seed.Bpm-20, seed.Bpm+20)
+	}
+
+	var songs []Song
+	if err := query.Where(conditions).Limit(limit).Find(&songs).Error; err != nil {
+		return nil, err
+	}
+	return songs, nil
+}
+
+func (r *SqlRecommendRepository) GetSongsByGenre(genre string, limit int) ([]Song, error) {
+	var songs []Song
+	if err := r.db.Scopes(available).Where("genre = ?", genre).Limit(limit).Find(&songs).Error; err != nil {
+		return nil, err
+	}
+	return songs, nil
+}
+
+func (r *SqlRecommendRepository) GetPopularSongIds(since time.Time, limit int) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := r.db.Table("plays").
+		Where("counted = ? AND started_at >= ?", true, since).
+		Group("song_id").
+		Order("COUNT(*) DESC").
+		Limit(limit).
+		Pluck("song_id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (r *SqlRecommendRepository) ReplaceMixes(userId uuid.UUID, mixes []Mix) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var oldIds []uuid.UUID
+		if err := tx.Model(&Mix{}).Where("user_id = ?", userId).Pluck("id", &oldIds).Error; err != nil {
+			return err
+		}
+
+		if len(oldIds) > 0 {
+			if err := tx.Where("mix_id IN ?", oldIds).Delete(&MixSong{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("id IN ?", oldIds).Delete(&Mix{}).Error; err != nil {
+				return err
+			}
+		}
+
+		for i := range mixes {
+			if err := tx.Create(&mixes[i]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *SqlRecommendRepository) GetMixes(userId string) ([]Mix, error) {
+	var mixes []Mix
+	err := r.db.Preload("Songs", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position")
+	}).Preload("Songs.Song").
+		Where("user_id = ?", userId).
+		Order("kind, title").
+		Find(&mixes).Error
+	if err != nil {
+		return nil, err
+	}
+	return mixes, nil
+}
+
+// available leaves out songs moderators quarantined or took down
+func available(db *gorm.DB) *gorm.DB {
+	return db.Where("songs.status = ?", "active")
+}
@@ -0,0 +1,15 @@
+package recommend
+
+import "github.com/gin-gonic/gin"
+
+func SetupRoutes(c *gin.RouterGroup, h *RecommendHandler, authMiddleware gin.HandlerFunc) {
+	c.Use(authMiddleware)
+
+	c.GET("/mixes", h.GetMixes)
+}
+
+func SetupSongRoutes(c *gin.RouterGroup, h *RecommendHandler, authMiddleware gin.HandlerFunc) {
+	c.Use(authMiddleware)
+
+	c.GET("/:id/similar", h.GetSimilar)
+}
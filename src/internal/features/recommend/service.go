@@ -0,0 +1,324 @@
+package recommend
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	similarNeighbours = 50
+	mixLength         = 25
+	maxGenreMixes     = 3
+	// Plays older than this no longer shape recommendations
+	interactionWindow = 180 * 24 * time.Hour
+	onRepeatWindow    = 30 * 24 * time.Hour
+)
+
+type RecommendService struct {
+	repo RecommendRepository
+}
+
+func NewRecommendService(repo RecommendRepository) *RecommendService {
+	return &RecommendService{repo: repo}
+}
+
+// GetSimilar returns songs that listeners of the seed also played, topped up
+// with metadata matches when there's not enough listening data yet
+func (s *RecommendService) GetSimilar(songId string, limit int) ([]Recommendation, error) {
+	seed, err := s.repo.GetSong(songId)
+	if err != nil {
+		return nil, err
+	}
+
+	similarities, err := s.repo.GetSimilarities([]uuid.UUID{seed.Id})
+	if err != nil {
+		return nil, err
+	}
+	similarities = similarities[:min(len(similarities), limit)]
+
+	ids := make([]uuid.UUID, len(similarities))
+	for i, similarity := range similarities {
+		ids[i] = similarity.SimilarId
+	}
+	songs, err := s.songsById(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[uuid.UUID]bool{seed.Id: true}
+	recommendations := make([]Recommendation, 0, limit)
+	for _, similarity := range similarities {
+		if song, ok := songs[similarity.SimilarId]; ok {
+			recommendations = append(recommendations, Recommendation{Song: song, Score: similarity.Score, Reason: ReasonListeners})
+			seen[song.Id] = true
+		}
+	}
+
+	if len(recommendations) >= limit {
+		return recommendations, nil
+	}
+
+	candidates, err := s.repo.GetMetadataCandidates(seed, 500)
+	if err != nil {
+		return nil, err
+	}
+
+	var fallback []Recommendation
+	for _, candidate := range candidates {
+		if seen[candidate.Id] {
+			continue
+		}
+		if score, reason := MetadataScore(seed, candidate); score > 0 {
+			fallback = append(fallback, Recommendation{Song: candidate, Score: score, Reason: reason})
+		}
+	}
+	sort.SliceStable(fallback, func(i, j int) bool { return fallback[i].Score > fallback[j].Score })
+
+	return append(recommendations, fallback[:min(len(fallback), limit-len(recommendations))]...), nil
+}
+
+// GetMixes returns the user's mixes, generating them on the spot for users the job hasn't seen yet
+func (s *RecommendService) GetMixes(userId string) ([]Mix, error) {
+	mixes, err := s.repo.GetMixes(userId)
+	if err != nil {
+		return nil, err
+	}
+	if len(mixes) > 0 {
+		return mixes, nil
+	}
+
+	if err := s.GenerateMixes(userId); err != nil {
+		return nil, err
+	}
+	return s.repo.GetMixes(userId)
+}
+
+// RefreshRecommendations rebuilds the similarity table and regenerates every active listener's mixes
+func (s *RecommendService) RefreshRecommendations() error {
+	interactions, err := s.repo.GetInteractions(time.Now().Add(-interactionWindow))
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.ReplaceSimilarities(ItemSimilarities(interactions, similarNeighbours)); err != nil {
+		return err
+	}
+
+	users := map[uuid.UUID]bool{}
+	for _, interaction := range interactions {
+		users[interaction.UserId] = true
+	}
+
+	var errs []error
+	for userId := range users {
+		errs = append(errs, s.GenerateMixes(userId.String()))
+	}
+	return errors.Join(errs...)
+}
+
+// GenerateMixes builds a discovery mix of unheard songs close to what the user
+// listens to, an "on repeat" mix of recent favourites, and a mix per top genre
+func (s *RecommendService) GenerateMixes(userId string) error {
+	userUUID, err := uuid.Parse(userId)
+	if err != nil {
+		return err
+	}
+
+	interactions, err := s.repo.GetUserInteractions(userId, time.Now().Add(-interactionWindow))
+	if err != nil {
+		return err
+	}
+	sort.Slice(interactions, func(i, j int) bool { return interactions[i].Weight() > interactions[j].Weight() })
+
+	heard := map[uuid.UUID]bool{}
+	heardIds := make([]uuid.UUID, 0, len(interactions))
+	for _, interaction := range interactions {
+		heard[interaction.SongId] = true
+		heardIds = append(heardIds, interaction.SongId)
+	}
+
+	discover, err := s.discoverCandidates(interactions, heard)
+	if err != nil {
+		return err
+	}
+
+	var mixes []Mix
+	if len(discover) > 0 {
+		mixes = append(mixes, NewMix(userUUID, MixDiscover, "Discover Mix", discover[:min(len(discover), mixLength)]))
+	}
+
+	onRepeat, err := s.onRepeat(userId)
+	if err != nil {
+		return err
+	}
+	if len(onRepeat) >= 5 {
+		mixes = append(mixes, NewMix(userUUID, MixOnRepeat, "On Repeat", onRepeat))
+	}
+
+	genreMixes, err := s.genreMixes(userUUID, heardIds, heard, discover)
+	if err != nil {
+		return err
+	}
+	mixes = append(mixes, genreMixes...)
+
+	return s.repo.ReplaceMixes(userUUID, mixes)
+}
+
+// discoverCandidates ranks unheard songs by their similarity to the user's strongest
+// interactions, falling back to what's popular for listeners without history
+func (s *RecommendService) discoverCandidates(interactions []Interaction, heard map[uuid.UUID]bool) ([]uuid.UUID, error) {
+	seeds := interactions[:min(len(interactions), 20)]
+	seedWeights := map[uuid.UUID]float64{}
+	seedIds := make([]uuid.UUID, 0, len(seeds))
+	for _, seed := range seeds {
+		seedWeights[seed.SongId] = seed.Weight()
+		seedIds = append(seedIds, seed.SongId)
+	}
+
+	scores := map[uuid.UUID]float64{}
+	if len(seedIds) > 0 {
+		similarities, err := s.repo.GetSimilarities(seedIds)
+		if err != nil {
+			return nil, err
+		}
+		for _, similarity := range similarities {
+			if !heard[similarity.SimilarId] {
+				scores[similarity.SimilarId] += similarity.Score * seedWeights[similarity.SongId]
+			}
+		}
+	}
+
+	candidates := make([]uuid.UUID, 0, len(scores))
+	for songId := range scores {
+		candidates = append(candidates, songId)
+	}
+	sort.Slice(candidates, func(i, j int) bool { return scores[candidates[i]] > scores[candidates[j]] })
+
+	if len(candidates) < mixLength {
+		popular, err := s.repo.GetPopularSongIds(time.Now().Add(-onRepeatWindow), 2*mixLength)
+		if err != nil {
+			return nil, err
+		}
+		for _, songId := range popular {
+			if !heard[songId] && scores[songId] == 0 {
+				candidates = append(candidates, songId)
+			}
+		}
+	}
+
+	return candidates, nil
+}
+
+func (s *RecommendService) onRepeat(userId string) ([]uuid.UUID, error) {
+	recent, err := s.repo.GetUserInteractions(userId, time.Now().Add(-onRepeatWindow))
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(recent, func(i, j int) bool { return recent[i].Plays > recent[j].Plays })
+
+	var ids []uuid.UUID
+	for _, interaction := range recent {
+		if interaction.Plays > 1 && len(ids) < mixLength {
+			ids = append(ids, interaction.SongId)
+		}
+	}
+	return ids, nil
+}
+
+// genreMixes alternates familiar songs with new ones for each of the user's top genres
+func (s *RecommendService) genreMixes(userId uuid.UUID, heardIds []uuid.UUID, heard map[uuid.UUID]bool, discover []uuid.UUID) ([]Mix, error) {
+	if len(heardIds) == 0 {
+		return nil, nil
+	}
+
+	heardSongs, err := s.repo.GetSongs(heardIds)
+	if err != nil {
+		return nil, err
+	}
+	songs := map[uuid.UUID]Song{}
+	for _, song := range heardSongs {
+		songs[song.Id] = song
+	}
+
+	// heardIds is sorted by interaction weight, so genres are ranked by their first songs
+	genreWeight := map[string]int{}
+	familiar := map[string][]uuid.UUID{}
+	for i, songId := range heardIds {
+		genre := songs[songId].Genre
+		if genre == "" {
+			continue
+		}
+		genreWeight[genre] += len(heardIds) - i
+		familiar[genre] = append(familiar[genre], songId)
+	}
+
+	genres := make([]string, 0, len(genreWeight))
+	for genre := range genreWeight {
+		genres = append(genres, genre)
+	}
+	sort.Slice(genres, func(i, j int) bool { return genreWeight[genres[i]] > genreWeight[genres[j]] })
+
+	discoverRank := map[uuid.UUID]int{}
+	for i, songId := range discover {
+		discoverRank[songId] = i + 1
+	}
+
+	var mixes []Mix
+	for _, genre := range genres[:min(len(genres), maxGenreMixes)] {
+		candidates, err := s.repo.GetSongsByGenre(genre, 200)
+		if err != nil {
+			return nil, err
+		}
+
+		var fresh []uuid.UUID
+		for _, candidate := range candidates {
+			if !heard[candidate.Id] {
+				fresh = append(fresh, candidate.Id)
+			}
+		}
+		// Songs the discovery ranking likes come first, the rest keep their order
+		sort.SliceStable(fresh, func(i, j int) bool {
+			a, b := discoverRank[fresh[i]], discoverRank[fresh[j]]
+			return a != 0 && (b == 0 || a < b)
+		})
+
+		ids := interleave(familiar[genre], fresh, mixLength)
+		if len(ids) >= 5 {
+			mixes = append(mixes, NewMix(userId, MixGenre, genre+" Mix", ids))
+		}
+	}
+	return mixes, nil
+}
+
+func (s *RecommendService) songsById(ids []uuid.UUID) (map[uuid.UUID]Song, error) {
+	songs := map[uuid.UUID]Song{}
+	if len(ids) == 0 {
+		return songs, nil
+	}
+
+	found, err := s.repo.GetSongs(ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, song := range found {
+		songs[song.Id] = song
+	}
+	return songs, nil
+}
+
+func interleave(a, b []uuid.UUID, limit int) []uuid.UUID {
+	result := make([]uuid.UUID, 0, limit)
+	for i := 0; len(result) < limit && (i < len(a) || i < len(b)); i++ {
+		if i < len(a) {
+			result = append(result, a[i])
+		}
+		if i < len(b) && len(result) < limit {
+			result = append(result, b[i])
+		}
+	}
+	return result
+}
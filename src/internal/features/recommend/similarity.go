@@ -0,0 +1,107 @@
+package recommend
+
+import (
+	"math"
+	"sort"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// Only the strongest interactions of heavy listeners are used, pairs grow quadratically
+	maxItemsPerUser = 200
+	// Pairs seen by few listeners are damped towards zero
+	similarityShrinkage = 2.0
+	likeWeight          = 2.0
+)
+
+// Weight scores an interaction, repeated plays count less and less while a like counts double
+func (i Interaction) Weight() float64 {
+	weight := math.Log1p(float64(i.Plays))
+	if i.Liked {
+		weight += likeWeight
+	}
+	return weight
+}
+
+type scoredSong struct {
+	songId uuid.UUID
+	score  float64
+}
+
+// ItemSimilarities computes cosine similarity between songs over the users who
+// interacted with them, keeping the topK most similar songs for each song
+func ItemSimilarities(interactions []Interaction, topK int) []SongSimilarity {
+	byUser := map[uuid.UUID][]scoredSong{}
+	for _, interaction := range interactions {
+		if weight := interaction.Weight(); weight > 0 {
+			byUser[interaction.UserId] = append(byUser[interaction.UserId], scoredSong{interaction.SongId, weight})
+		}
+	}
+
+	type pair struct{ a, b uuid.UUID }
+	norms := map[uuid.UUID]float64{}
+	dots := map[pair]float64{}
+	support := map[pair]int{}
+
+	for _, songs := range byUser {
+		sort.Slice(songs, func(i, j int) bool { return songs[i].score > songs[j].score })
+		songs = songs[:min(len(songs), maxItemsPerUser)]
+
+		for i, a := range songs {
+			norms[a.songId] += a.score * a.score
+			for _, b := range songs[i+1:] {
+				key := pair{a.songId, b.songId}
+				if b.songId.String() < a.songId.String() {
+					key = pair{b.songId, a.songId}
+				}
+				dots[key] += a.score * b.score
+				support[key]++
+			}
+		}
+	}
+
+	neighbours := map[uuid.UUID][]scoredSong{}
+	for key, dot := range dots {
+		n := float64(support[key])
+		score := dot / math.Sqrt(norms[key.a]*norms[key.b]) * n / (n + similarityShrinkage)
+
+		neighbours[key.a] = append(neighbours[key.a], scoredSong{key.b, score})
+		neighbours[key.b] = append(neighbours[key.b], scoredSong{key.a, score})
+	}
+
+	var result []SongSimilarity
+	for songId, similar := range neighbours {
+		sort.Slice(similar, func(i, j int) bool { return similar[i].score > similar[j].score })
+		for _, s := range similar[:min(len(similar), topK)] {
+			result = append(result, SongSimilarity{SongId: songId, SimilarId: s.songId, Score: s.score})
+		}
+	}
+	return result
+}
+
+// MetadataScore is the cold-start fallback: songs sharing an artist, a genre or a tempo
+func MetadataScore(seed, candidate Song) (float64, string) {
+	score, reason, best := 0.0, "", 0.0
+	add := func(value float64, why string) {
+		score += value
+		if value > best {
+			best, reason = value, why
+		}
+	}
+
+	if candidate.ArtistId == seed.ArtistId {
+		add(0.5, ReasonArtist)
+	}
+	if seed.Genre != "" && candidate.Genre == seed.Genre {
+		add(0.3, ReasonGenre)
+	}
+	if seed.Bpm > 0 && candidate.Bpm > 0 {
+		// Full marks at the same tempo, nothing beyond 20 BPM apart
+		if diff := math.Abs(float64(seed.Bpm - candidate.Bpm)); diff < 20 {
+			add(0.2*(1-diff/20), ReasonTempo)
+		}
+	}
+
+	return score, reason
+}
@@ -0,0 +1,168 @@
+package share
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yosp313/gotify/src/internal/pkg/auth"
+	"github.com/yosp313/gotify/src/internal/utils"
+)
+
+type ShareHandler struct {
+	service *ShareService
+}
+
+type ShareRequest struct {
+	// Seconds until the link stops working, 7 days by default
+	ExpiresIn *int `json:"expires_in"`
+	// How many times the link can be played, unlimited by default
+	MaxPlays *int `json:"max_plays"`
+}
+
+func NewShareHandler(service *ShareService) *ShareHandler {
+	return &ShareHandler{service: service}
+}
+
+func (h *ShareHandler) ShareSong(c *gin.Context) {
+	var req ShareRequest
+	if !bindShareRequest(c, &req) {
+		return
+	}
+
+	share, err := h.service.ShareSong(c.GetString("user_id"), c.Param("id"), req.ExpiresIn, req.MaxPlays)
+	if err != nil {
+		handleShareError(c, err, "Failed to share song")
+		return
+	}
+
+	c.JSON(201, withUrl(c, share))
+}
+
+func (h *ShareHandler) SharePlaylist(c *gin.Context) {
+	var req ShareRequest
+	if !bindShareRequest(c, &req) {
+		return
+	}
+
+	share, err := h.service.SharePlaylist(c.GetString("user_id"), c.Param("id"), req.ExpiresIn, req.MaxPlays)
+	if err != nil {
+		handleShareError(c, err, "Failed to share playlist")
+		return
+	}
+
+	c.JSON(201, withUrl(c, share))
+}
+
+func (h *ShareHandler) CreateStreamToken(c *gin.Context) {
+	token, expiresAt, err := h.service.StreamToken(c.GetString("user_id"), c.Param("id"))
+	if err != nil {
+		handleShareError(c, err, "Failed to create stream token")
+		return
+	}
+
+	c.JSON(200, StreamToken{Token: token, Url: LinkUrl(utils.BaseUrl(c), token) + "/stream", ExpiresAt: expiresAt})
+}
+
+func (h *ShareHandler) GetMine(c *gin.Context) {
+	page, limit := utils.GetPagination(c)
+
+	shares, total, err := h.service.GetMine(c.GetString("user_id"), page, limit)
+	if err != nil {
+		utils.HandleErrorWithMessage(c, err, "Failed to retrieve shares", 500)
+		return
+	}
+
+	for i := range shares {
+		shares[i] = withUrl(c, shares[i])
+	}
+	c.JSON(200, gin.H{"shares": shares, "total": total, "page": page, "limit": limit})
+}
+
+func (h *ShareHandler) Revoke(c *gin.Context) {
+	if err := h.service.Revoke(c.GetString("user_id"), c.Param("id")); err != nil {
+		handleShareError(c, err, "Failed to revoke share")
+		return
+	}
+
+	c.Status(204)
+}
+
+// Open shows what a link streams. It needs no login, the link is what grants access
+func (h *ShareHandler) Open(c *gin.Context) {
+	token := c.Param("token")
+	shared, err := h.service.Open(token)
+	if err != nil {
+		handleShareError(c, err, "Failed to open link")
+		return
+	}
+
+	base := LinkUrl(utils.BaseUrl(c), token)
+	if shared.Song != nil {
+		shared.Song.StreamUrl = base + "/stream"
+	}
+	for i := range shared.Songs {
+		shared.Songs[i].StreamUrl = base + "/songs/" + shared.Songs[i].Id.String() + "/stream"
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(200, shared)
+}
+
+// RequireLink lets a request stream a song with a link instead of a login. It sets
+// shared_song_id to the song, and user_id when the link plays as a user
+func (h *ShareHandler) RequireLink(c *gin.Context) {
+	songId, userId, err := h.service.Authorize(c.Param("token"), c.Param("id"), c.ClientIP())
+	if err != nil {
+		handleShareError(c, err, "Failed to open link")
+		c.Abort()
+		return
+	}
+
+	c.Set("shared_song_id", songId)
+	if userId != "" {
+		c.Set("user_id", userId)
+	}
+	c.Next()
+}
+
+// LinkUrl is where a link opens, streams are under it
+func LinkUrl(baseUrl, token string) string {
+	return baseUrl + "/shared/" + token
+}
+
+func bindShareRequest(c *gin.Context, req *ShareRequest) bool {
+	// The body is optional, a link with the defaults needs none
+	if c.Request.ContentLength == 0 {
+		return true
+	}
+	if err := c.ShouldBindJSON(req); err != nil {
+		utils.HandleErrorWithMessage(c, err, "Invalid request body", 400)
+		return false
+	}
+	return true
+}
+
+func withUrl(c *gin.Context, share Share) Share {
+	share.Url = LinkUrl(utils.BaseUrl(c), share.Token)
+	return share
+}
+
+func handleShareError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, ErrSongNotFound):
+		c.JSON(404, gin.H{"error": "Song not found"})
+	case errors.Is(err, ErrPlaylistNotFound):
+		c.JSON(404, gin.H{"error": "Playlist not found"})
+	case errors.Is(err, ErrShareNotFound):
+		c.JSON(404, gin.H{"error": "Share not found"})
+	case errors.Is(err, ErrNotAllowed):
+		utils.HandleErrorWithMessage(c, err, message, 403)
+	case errors.Is(err, ErrInvalidExpiry), errors.Is(err, ErrInvalidMaxPlays):
+		utils.HandleErrorWithMessage(c, err, message, 400)
+	case errors.Is(err, auth.ErrInvalidLink):
+		utils.HandleErrorWithMessage(c, err, message, 401)
+	case errors.Is(err, auth.ErrLinkExpired), errors.Is(err, ErrLinkUsedUp):
+		utils.HandleErrorWithMessage(c, err, message, 410)
+	default:
+		utils.HandleErrorWithMessage(c, err, message, 500)
+	}
+}
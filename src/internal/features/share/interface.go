@@ -0,0 +1,26 @@
+package share
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ShareRepository interface {
+	Create(share *Share) error
+	Get(id string) (Share, error)
+	GetByUser(userId string, page, limit int) ([]Share, int64, error)
+	Revoke(userId, id string) (bool, error)
+	CountPlay(play *SharePlay) (bool, error)
+	DeleteExpired(before time.Time) error
+	DeleteEndedPlays(before time.Time) error
+	GetSong(id string) (Song, error)
+	GetSongs(ids []uuid.UUID) ([]Song, error)
+	GetPlaylist(id string) (Playlist, error)
+}
+
+// SongSource resolves the songs of a playlist as its owner sees them, the playlist feature
+// provides it
+type SongSource interface {
+	SongIds(userId, playlistId string) ([]uuid.UUID, error)
+}
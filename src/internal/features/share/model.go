@@ -0,0 +1,157 @@
+package share
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultExpiry is how long a share link works when no expiry is asked for
+	DefaultExpiry = 7 * 24 * time.Hour
+	// MaxExpiry is the longest a share link can work
+	MaxExpiry = 30 * 24 * time.Hour
+	// KeepExpired is how long expired and revoked shares stay listed before they're dropped
+	KeepExpired = 30 * 24 * time.Hour
+	// DefaultPlayWindow stands in for the length of songs whose duration isn't known
+	DefaultPlayWindow = 10 * time.Minute
+)
+
+// What can be shared
+const (
+	TypeSong     = "song"
+	TypePlaylist = "playlist"
+)
+
+var (
+	ErrSongNotFound     = errors.New("song not found")
+	ErrPlaylistNotFound = errors.New("playlist not found")
+	ErrShareNotFound    = errors.New("share not found")
+	ErrNotAllowed       = errors.New("you can't share this playlist")
+	ErrInvalidExpiry    = errors.New("expires_in must be between 1 second and 30 days")
+	ErrInvalidMaxPlays  = errors.New("max_plays must be at least 1")
+	ErrLinkUsedUp       = errors.New("link was revoked or ran out of plays")
+)
+
+// Share is a signed link someone made to let others stream a song or playlist without an
+// account. Its token names only that song or playlist, and stops working when it expires, is
+// revoked or has been played MaxPlays times
+type Share struct {
+	Id           uuid.UUID  `json:"id" db:"id" gorm:"primaryKey"`
+	UserId       uuid.UUID  `json:"user_id" db:"user_id" gorm:"not null;index"`
+	ResourceType string     `json:"resource_type" db:"resource_type" gorm:"not null"`
+	ResourceId   uuid.UUID  `json:"resource_id" db:"resource_id" gorm:"not null"`
+	ExpiresAt    time.Time  `json:"expires_at" db:"expires_at" gorm:"not null;index"`
+	MaxPlays     *int       `json:"max_plays" db:"max_plays"`
+	Plays        int        `json:"plays" db:"plays" gorm:"not null;default:0"`
+	RevokedAt    *time.Time `json:"revoked_at" db:"revoked_at"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+
+	// The link itself, signed when the share is read
+	Token string `json:"token" gorm:"-"`
+	Url   string `json:"url,omitempty" gorm:"-"`
+
+	// Relationships
+	User *User `json:"-" gorm:"foreignKey:UserId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+// IsActive tells whether the link still plays
+func (s *Share) IsActive() bool {
+	return s.RevokedAt == nil && time.Now().Before(s.ExpiresAt) && (s.MaxPlays == nil || s.Plays < *s.MaxPlays)
+}
+
+// SharePlay is a play counted on a share. Requests from the same client for the same song
+// until EndsAt are part of that play, players fetch a song with many range requests and
+// seek and resume with more
+type SharePlay struct {
+	ShareId uuid.UUID `json:"share_id" db:"share_id" gorm:"primaryKey"`
+	SongId  uuid.UUID `json:"song_id" db:"song_id" gorm:"primaryKey"`
+	Client  string    `json:"client" db:"client" gorm:"primaryKey"`
+	EndsAt  time.Time `json:"ends_at" db:"ends_at" gorm:"not null;index"`
+}
+
+// NewSharePlay starts a play of the song on the share for the client, it lasts as long as
+// the song
+func NewSharePlay(shareId uuid.UUID, song Song, client string) *SharePlay {
+	window := DefaultPlayWindow
+	if song.Duration > 0 {
+		window = time.Duration(song.Duration) * time.Second
+	}
+	return &SharePlay{ShareId: shareId, SongId: song.Id, Client: client, EndsAt: time.Now().Add(window)}
+}
+
+// Shared is what a link shows to whoever opens it
+type Shared struct {
+	Type      string    `json:"type"`
+	ExpiresAt time.Time `json:"expires_at"`
+	// Plays left before the link stops working, null when it isn't limited
+	PlaysLeft *int      `json:"plays_left"`
+	Song      *Song     `json:"song,omitempty"`
+	Playlist  *Playlist `json:"playlist,omitempty"`
+	Songs     []Song    `json:"songs,omitempty"`
+}
+
+// StreamToken lets the user's own player stream a song without putting their session in the URL
+type StreamToken struct {
+	Token     string    `json:"token"`
+	Url       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type User struct {
+	Id       uuid.UUID `json:"id"`
+	FullName string    `json:"full_name"`
+}
+
+type Song struct {
+	Id       uuid.UUID `json:"id"`
+	Title    string    `json:"title"`
+	ArtistId uuid.UUID `json:"artist_id"`
+	Duration int       `json:"duration"`
+	Status   string    `json:"-"`
+	Public   bool      `json:"-"`
+	// Where the link streams it from
+	StreamUrl string `json:"stream_url" gorm:"-"`
+
+	// Relationships
+	Artist *User `json:"artist,omitempty" gorm:"foreignKey:ArtistId;references:Id"`
+}
+
+// VisibleTo tells whether the user can play the song, artists still play their pulled songs
+func (s *Song) VisibleTo(userId string) bool {
+	return s.Status == "active" || s.ArtistId.String() == userId
+}
+
+type Playlist struct {
+	Id          uuid.UUID `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	OwnerId     uuid.UUID `json:"owner_id"`
+
+	// Relationships
+	Owner *User `json:"owner,omitempty" gorm:"foreignKey:OwnerId;references:Id"`
+}
+
+// NewShare makes a share link, expiresIn defaults to DefaultExpiry and maxPlays to no limit
+func NewShare(userId uuid.UUID, resourceType string, resourceId uuid.UUID, expiresIn *int, maxPlays *int) (*Share, error) {
+	expiry := DefaultExpiry
+	if expiresIn != nil {
+		expiry = time.Duration(*expiresIn) * time.Second
+		if expiry <= 0 || expiry > MaxExpiry {
+			return nil, ErrInvalidExpiry
+		}
+	}
+	if maxPlays != nil && *maxPlays < 1 {
+		return nil, ErrInvalidMaxPlays
+	}
+
+	return &Share{
+		Id:           uuid.New(),
+		UserId:       userId,
+		ResourceType: resourceType,
+		ResourceId:   resourceId,
+		ExpiresAt:    time.Now().Add(expiry),
+		MaxPlays:     maxPlays,
+	}, nil
+}
@@ -0,0 +1,154 @@
+package share
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type SqlShareRepository struct {
+	db *gorm.DB
+}
+
+func NewSqlShareRepository(db *gorm.DB) *SqlShareRepository {
+	return &SqlShareRepository{db: db}
+}
+
+func (r *SqlShareRepository) Create(share *Share) error {
+	return r.db.Omit("User").Create(share).Error
+}
+
+func (r *SqlShareRepository) Get(id string) (Share, error) {
+	var share Share
+	if err := r.db.First(&share, "id = ?", id).Error; err != nil {
+		return Share{}, err
+	}
+	return share, nil
+}
+
+// GetByUser returns the shares the user made, newest first
+func (r *SqlShareRepository) GetByUser(userId string, page, limit int) ([]Share, int64, error) {
+	query := r.db.Model(&Share{}).Where("user_id = ?", userId)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var shares []Share
+	err := query.Order("created_at DESC").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&shares).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return shares, total, nil
+}
+
+func (r *SqlShareRepository) Revoke(userId, id string) (bool, error) {
+	result := r.db.Model(&Share{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userId).
+		Update("revoked_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+// CountPlay adds a play to a share, unless it's revoked, expired or out of plays. Requests
+// during a play the client already started aren't counted again. It tells whether the
+// request may play
+func (r *SqlShareRepository) CountPlay(play *SharePlay) (bool, error) {
+	allowed := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		// Starts the play unless one is still going, claiming it first keeps concurrent
+		// requests from counting twice
+		result := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "share_id"}, {Name: "song_id"}, {Name: "client"}},
+			DoUpdates: clause.AssignmentColumns([]string{"ends_at"}),
+			Where:     clause.Where{Exprs: []clause.Expression{clause.Lte{Column: "share_plays.ends_at", Value: now}}},
+		}).Create(play)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			allowed = true
+			return nil
+		}
+
+		result = tx.Model(&Share{}).
+			Where("id = ? AND revoked_at IS NULL AND expires_at > ?", play.ShareId, now).
+			Where("max_plays IS NULL OR plays < max_plays").
+			Update("plays", gorm.Expr("plays + 1"))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			// Out of plays, the play isn't started either
+			return ErrLinkUsedUp
+		}
+		allowed = true
+		return nil
+	})
+	if errors.Is(err, ErrLinkUsedUp) {
+		return false, nil
+	}
+	return allowed, err
+}
+
+// DeleteExpired drops shares that expired or were revoked before the given time
+func (r *SqlShareRepository) DeleteExpired(before time.Time) error {
+	return r.db.Where("expires_at < ? OR revoked_at < ?", before, before).Delete(&Share{}).Error
+}
+
+func (r *SqlShareRepository) DeleteEndedPlays(before time.Time) error {
+	return r.db.Where("ends_at < ?", before).Delete(&SharePlay{}).Error
+}
+
+func (r *SqlShareRepository) GetSong(id string) (Song, error) {
+	var song Song
+	if err := r.db.Preload("Artist").First(&song, "id = ?", id).Error; err != nil {
+		return Song{}, err
+	}
+	return song, nil
+}
+
+// GetSongs loads the songs that can be played in the order of ids, others are skipped
+func (r *SqlShareRepository) GetSongs(ids []uuid.UUID) ([]Song, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	var found []Song
+	if err := r.db.Scopes(available).Preload("Artist").Where("id IN ?", ids).Find(&found).Error; err != nil {
+		return nil, err
+	}
+
+	byId := make(map[uuid.UUID]Song, len(found))
+	for _, song := range found {
+		byId[song.Id] = song
+	}
+
+	songs := make([]Song, 0, len(ids))
+	for _, id := range ids {
+		if song, ok := byId[id]; ok {
+			songs = append(songs, song)
+		}
+	}
+	return songs, nil
+}
+
+func (r *SqlShareRepository) GetPlaylist(id string) (Playlist, error) {
+	var playlist Playlist
+	if err := r.db.Preload("Owner").First(&playlist, "id = ?", id).Error; err != nil {
+		return Playlist{}, err
+	}
+	return playlist, nil
+}
+
+// available leaves out songs moderators quarantined or took down
+func available(db *gorm.DB) *gorm.DB {
+	return db.Where("songs.status = ?", "active")
+}
@@ -0,0 +1,30 @@
+package share
+
+import "github.com/gin-gonic/gin"
+
+// SetupRoutes lets users see and revoke the links they made
+func SetupRoutes(c *gin.RouterGroup, h *ShareHandler, authMiddleware gin.HandlerFunc) {
+	c.Use(authMiddleware)
+
+	c.GET("", h.GetMine)
+	c.DELETE("/:id", h.Revoke)
+}
+
+func SetupSongRoutes(c *gin.RouterGroup, h *ShareHandler, authMiddleware gin.HandlerFunc) {
+	c.Use(authMiddleware)
+
+	c.POST("/:id/shares", h.ShareSong)
+	c.POST("/:id/stream-token", h.CreateStreamToken)
+}
+
+func SetupPlaylistRoutes(c *gin.RouterGroup, h *ShareHandler, authMiddleware gin.HandlerFunc) {
+	c.Use(authMiddleware)
+
+	c.POST("/:id/shares", h.SharePlaylist)
+}
+
+// SetupLinkRoutes opens links without a login, outside the API so links stay short. The
+// song feature serves their streams
+func SetupLinkRoutes(c *gin.RouterGroup, h *ShareHandler) {
+	c.GET("/:token", h.Open)
+}
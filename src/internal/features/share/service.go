@@ -0,0 +1,277 @@
+package share
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yosp313/gotify/src/internal/pkg/auth"
+	"gorm.io/gorm"
+)
+
+type ShareService struct {
+	repo   ShareRepository
+	signer *auth.LinkSigner
+	songs  SongSource
+}
+
+func NewShareService(repo ShareRepository, signer *auth.LinkSigner, songs SongSource) *ShareService {
+	return &ShareService{repo: repo, signer: signer, songs: songs}
+}
+
+// ShareSong makes a link that streams one song. expiresIn is in seconds
+func (s *ShareService) ShareSong(userId, songId string, expiresIn, maxPlays *int) (Share, error) {
+	song, err := s.getSong(songId)
+	if err != nil {
+		return Share{}, err
+	}
+	// Pulled songs can't be shared, not even by their artist
+	if !song.VisibleTo("") {
+		return Share{}, ErrSongNotFound
+	}
+	return s.create(userId, TypeSong, song.Id, expiresIn, maxPlays)
+}
+
+// SharePlaylist makes a link that streams the songs of a playlist the user can see, as the
+// user sees them when the link is played
+func (s *ShareService) SharePlaylist(userId, playlistId string, expiresIn, maxPlays *int) (Share, error) {
+	playlist, err := s.getPlaylist(playlistId)
+	if err != nil {
+		return Share{}, err
+	}
+	if _, err := s.songs.SongIds(userId, playlist.Id.String()); err != nil {
+		return Share{}, ErrNotAllowed
+	}
+	return s.create(userId, TypePlaylist, playlist.Id, expiresIn, maxPlays)
+}
+
+// StreamToken signs a short-lived link for the user's own player, so the audio element can
+// stream a song without the user's session in its URL
+func (s *ShareService) StreamToken(userId, songId string) (string, time.Time, error) {
+	song, err := s.getSong(songId)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if !song.VisibleTo(userId) {
+		return "", time.Time{}, ErrSongNotFound
+	}
+
+	token, expiresAt := s.signer.SignStream(TypeSong, song.Id.String(), userId)
+	return token, expiresAt, nil
+}
+
+// PublicStreamToken signs a short-lived link that streams a song to anyone, for players
+// embedded in other sites. Only songs their artist made public get one
+func (s *ShareService) PublicStreamToken(songId string) (string, time.Time, error) {
+	song, err := s.getSong(songId)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if !song.Public || !song.VisibleTo("") {
+		return "", time.Time{}, ErrSongNotFound
+	}
+
+	token, expiresAt := s.signer.SignStream(TypeSong, song.Id.String(), "")
+	return token, expiresAt, nil
+}
+
+// GetMine lists the shares the user made, newest first, revoked and expired ones included
+func (s *ShareService) GetMine(userId string, page, limit int) ([]Share, int64, error) {
+	shares, total, err := s.repo.GetByUser(userId, page, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := range shares {
+		shares[i].Token = s.sign(shares[i])
+	}
+	return shares, total, nil
+}
+
+// Revoke stops one of the user's links from working
+func (s *ShareService) Revoke(userId, id string) error {
+	revoked, err := s.repo.Revoke(userId, id)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return ErrShareNotFound
+	}
+	return nil
+}
+
+// Open shows what a link streams to whoever has it
+func (s *ShareService) Open(token string) (Shared, error) {
+	claims, share, err := s.parse(token)
+	if err != nil {
+		return Shared{}, err
+	}
+
+	shared := Shared{Type: claims.Type, ExpiresAt: time.Unix(claims.ExpiresAt, 0)}
+	if share != nil && share.MaxPlays != nil {
+		left := *share.MaxPlays - share.Plays
+		shared.PlaysLeft = &left
+	}
+
+	if claims.Type == TypeSong {
+		song, err := s.getSong(claims.Id)
+		if err != nil {
+			return Shared{}, err
+		}
+		if !song.VisibleTo(claims.UserId) {
+			return Shared{}, ErrSongNotFound
+		}
+		shared.Song = &song
+		return shared, nil
+	}
+
+	playlist, err := s.getPlaylist(claims.Id)
+	if err != nil {
+		return Shared{}, err
+	}
+	songs, err := s.playlistSongs(*share)
+	if err != nil {
+		return Shared{}, err
+	}
+	shared.Playlist = &playlist
+	shared.Songs = songs
+	return shared, nil
+}
+
+// Authorize checks that a link may stream a song, and counts a play on shares unless the
+// client is still playing it. songId is only needed for playlist links. It returns the song
+// and the user the link plays as, if any
+func (s *ShareService) Authorize(token, songId, client string) (string, string, error) {
+	claims, share, err := s.parse(token)
+	if err != nil {
+		return "", "", err
+	}
+
+	switch {
+	case claims.Type == TypeSong && songId == "":
+		songId = claims.Id
+	case claims.Type == TypeSong && songId != claims.Id:
+		return "", "", ErrSongNotFound
+	case claims.Type == TypePlaylist:
+		ids, err := s.songs.SongIds(share.UserId.String(), claims.Id)
+		if err != nil {
+			return "", "", ErrPlaylistNotFound
+		}
+		if !slices.ContainsFunc(ids, func(id uuid.UUID) bool { return id.String() == songId }) {
+			return "", "", ErrSongNotFound
+		}
+	}
+
+	if share == nil && claims.UserId == "" {
+		// Public stream tokens stop working once the artist makes the song private again
+		song, err := s.getSong(songId)
+		if err != nil {
+			return "", "", err
+		}
+		if !song.Public {
+			return "", "", ErrSongNotFound
+		}
+	}
+	if share != nil {
+		song, err := s.getSong(songId)
+		if err != nil {
+			return "", "", err
+		}
+		counted, err := s.repo.CountPlay(NewSharePlay(share.Id, song, client))
+		if err != nil {
+			return "", "", err
+		}
+		if !counted {
+			return "", "", ErrLinkUsedUp
+		}
+	}
+	return songId, claims.UserId, nil
+}
+
+// SweepShares drops shares that stopped working a while ago, and plays that ended
+func (s *ShareService) SweepShares() error {
+	if err := s.repo.DeleteEndedPlays(time.Now()); err != nil {
+		return err
+	}
+	return s.repo.DeleteExpired(time.Now().Add(-KeepExpired))
+}
+
+func (s *ShareService) create(userId, resourceType string, resourceId uuid.UUID, expiresIn, maxPlays *int) (Share, error) {
+	userUUID, err := uuid.Parse(userId)
+	if err != nil {
+		return Share{}, err
+	}
+
+	share, err := NewShare(userUUID, resourceType, resourceId, expiresIn, maxPlays)
+	if err != nil {
+		return Share{}, err
+	}
+	if err := s.repo.Create(share); err != nil {
+		return Share{}, err
+	}
+
+	share.Token = s.sign(*share)
+	return *share, nil
+}
+
+// parse checks a link and, for shares, that it still plays. The share is nil for stream tokens
+func (s *ShareService) parse(token string) (auth.LinkClaims, *Share, error) {
+	claims, err := s.signer.Parse(token)
+	if err != nil {
+		return auth.LinkClaims{}, nil, err
+	}
+	if claims.ShareId == "" {
+		if claims.Type != TypeSong {
+			return auth.LinkClaims{}, nil, auth.ErrInvalidLink
+		}
+		return claims, nil, nil
+	}
+
+	share, err := s.repo.Get(claims.ShareId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return auth.LinkClaims{}, nil, ErrLinkUsedUp
+	}
+	if err != nil {
+		return auth.LinkClaims{}, nil, err
+	}
+	if share.ResourceType != claims.Type || share.ResourceId.String() != claims.Id {
+		return auth.LinkClaims{}, nil, auth.ErrInvalidLink
+	}
+	if !share.IsActive() {
+		return auth.LinkClaims{}, nil, ErrLinkUsedUp
+	}
+	return claims, &share, nil
+}
+
+func (s *ShareService) sign(share Share) string {
+	return s.signer.Sign(auth.LinkClaims{
+		Type:      share.ResourceType,
+		Id:        share.ResourceId.String(),
+		ShareId:   share.Id.String(),
+		ExpiresAt: share.ExpiresAt.Unix(),
+	})
+}
+
+func (s *ShareService) playlistSongs(share Share) ([]Song, error) {
+	ids, err := s.songs.SongIds(share.UserId.String(), share.ResourceId.String())
+	if err != nil {
+		return nil, ErrPlaylistNotFound
+	}
+	return s.repo.GetSongs(ids)
+}
+
+func (s *ShareService) getSong(id string) (Song, error) {
+	song, err := s.repo.GetSong(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return Song{}, ErrSongNotFound
+	}
+	return song, err
+}
+
+func (s *ShareService) getPlaylist(id string) (Playlist, error) {
+	playlist, err := s.repo.GetPlaylist(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return Playlist{}, ErrPlaylistNotFound
+	}
+	return playlist, err
+}
@@ -0,0 +1,175 @@
+package show
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The feed follows RSS 2.0 with the iTunes tags podcast apps and directories read
+const (
+	itunesNamespace = "http://www.itunes.com/dtds/podcast-1.0.dtd"
+	atomNamespace   = "http://www.w3.org/2005/Atom"
+	generator       = "gotify"
+)
+
+type rss struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Itunes  string     `xml:"xmlns:itunes,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title          string          `xml:"title"`
+	Link           string          `xml:"link"`
+	Description    string          `xml:"description"`
+	Language       string          `xml:"language"`
+	Generator      string          `xml:"generator"`
+	LastBuildDate  string          `xml:"lastBuildDate"`
+	Self           atomLink        `xml:"atom:link"`
+	Image          *rssImage       `xml:"image,omitempty"`
+	ItunesAuthor   string          `xml:"itunes:author,omitempty"`
+	ItunesImage    *itunesImage    `xml:"itunes:image,omitempty"`
+	ItunesCategory *itunesCategory `xml:"itunes:category,omitempty"`
+	ItunesExplicit string          `xml:"itunes:explicit"`
+	ItunesType     string          `xml:"itunes:type"`
+	Items          []rssItem       `xml:"item"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type rssImage struct {
+	Url   string `xml:"url"`
+	Title string `xml:"title"`
+	Link  string `xml:"link"`
+}
+
+type itunesImage struct {
+	Href string `xml:"href,attr"`
+}
+
+type itunesCategory struct {
+	Text string `xml:"text,attr"`
+}
+
+type rssItem struct {
+	Title             string       `xml:"title"`
+	Description       string       `xml:"description,omitempty"`
+	Guid              rssGuid      `xml:"guid"`
+	PubDate           string       `xml:"pubDate"`
+	Enclosure         rssEnclosure `xml:"enclosure"`
+	ItunesTitle       string       `xml:"itunes:title"`
+	ItunesDuration    int          `xml:"itunes:duration,omitempty"`
+	ItunesSeason      int          `xml:"itunes:season,omitempty"`
+	ItunesEpisode     int          `xml:"itunes:episode,omitempty"`
+	ItunesEpisodeType string       `xml:"itunes:episodeType"`
+	ItunesExplicit    string       `xml:"itunes:explicit"`
+}
+
+type rssGuid struct {
+	IsPermaLink string `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssEnclosure struct {
+	Url    string `xml:"url,attr"`
+	Length int64  `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+// FeedUrl is the public address of a show's feed, baseUrl being the server root
+func FeedUrl(baseUrl string, show Show) string {
+	return showUrl(baseUrl, show) + "/feed.xml"
+}
+
+// EnclosureUrl is the public address of an episode's audio
+func EnclosureUrl(baseUrl string, show Show, episode Episode) string {
+	return showUrl(baseUrl, show) + "/episodes/" + episode.Filename()
+}
+
+func showUrl(baseUrl string, show Show) string {
+	return strings.TrimSuffix(baseUrl, "/") + "/shows/" + show.FeedToken
+}
+
+// WriteFeed writes the show's RSS feed with the given episodes, newest first. Enclosures
+// point back at this server under baseUrl
+func WriteFeed(w io.Writer, show Show, episodes []Episode, baseUrl string) error {
+	feedUrl := FeedUrl(baseUrl, show)
+	link := show.Link
+	if link == "" {
+		link = feedUrl
+	}
+
+	channel := rssChannel{
+		Title:          show.Title,
+		Link:           link,
+		Description:    show.Description,
+		Language:       show.Language,
+		Generator:      generator,
+		LastBuildDate:  lastBuildDate(show, episodes).Format(time.RFC1123Z),
+		Self:           atomLink{Href: feedUrl, Rel: "self", Type: "application/rss+xml"},
+		ItunesAuthor:   show.AuthorName(),
+		ItunesExplicit: strconv.FormatBool(show.Explicit),
+		ItunesType:     "episodic",
+		Items:          make([]rssItem, 0, len(episodes)),
+	}
+	if show.ImageUrl != "" {
+		channel.Image = &rssImage{Url: show.ImageUrl, Title: show.Title, Link: link}
+		channel.ItunesImage = &itunesImage{Href: show.ImageUrl}
+	}
+	if show.Category != "" {
+		channel.ItunesCategory = &itunesCategory{Text: show.Category}
+	}
+
+	for _, episode := range episodes {
+		channel.Items = append(channel.Items, rssItem{
+			Title:       episode.Title,
+			Description: episode.Description,
+			Guid:        rssGuid{IsPermaLink: "false", Value: episode.Guid()},
+			PubDate:     episode.PublishedAt.UTC().Format(time.RFC1123Z),
+			Enclosure: rssEnclosure{
+				Url:    EnclosureUrl(baseUrl, show, episode),
+				Length: episode.Length,
+				Type:   episode.ContentType(),
+			},
+			ItunesTitle:       episode.Title,
+			ItunesDuration:    episode.Song.Duration,
+			ItunesSeason:      episode.Season,
+			ItunesEpisode:     episode.Number,
+			ItunesEpisodeType: "full",
+			ItunesExplicit:    strconv.FormatBool(episode.Explicit || show.Explicit),
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	feed := rss{Version: "2.0", Itunes: itunesNamespace, Atom: atomNamespace, Channel: channel}
+	if err := encoder.Encode(feed); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// lastBuildDate is when the feed last changed, the latest of the show and its episodes
+func lastBuildDate(show Show, episodes []Episode) time.Time {
+	latest := show.UpdatedAt
+	for _, episode := range episodes {
+		for _, t := range []time.Time{episode.PublishedAt, episode.UpdatedAt} {
+			if t.After(latest) {
+				latest = t
+			}
+		}
+	}
+	return latest.UTC()
+}
@@ -0,0 +1,235 @@
+package show
+
+import (
+	"errors"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yosp313/gotify/src/internal/utils"
+	"gorm.io/gorm"
+)
+
+type ShowHandler struct {
+	service *ShowService
+	// Base URL of feeds and enclosures, taken from each request when empty
+	baseUrl string
+}
+
+func NewShowHandler(service *ShowService, baseUrl string) *ShowHandler {
+	return &ShowHandler{service: service, baseUrl: baseUrl}
+}
+
+func (h *ShowHandler) Create(c *gin.Context) {
+	var settings Settings
+	if err := c.ShouldBindJSON(&settings); err != nil {
+		utils.HandleErrorWithMessage(c, err, "Invalid request body", 400)
+		return
+	}
+
+	show, err := h.service.Create(c.GetString("user_id"), settings)
+	if err != nil {
+		handleShowError(c, err, "Failed to create show")
+		return
+	}
+
+	c.JSON(201, h.withFeedUrl(c, show))
+}
+
+func (h *ShowHandler) GetAll(c *gin.Context) {
+	shows, err := h.service.GetOwned(c.GetString("user_id"))
+	if err != nil {
+		utils.HandleErrorWithMessage(c, err, "Failed to retrieve shows", 500)
+		return
+	}
+
+	for i := range shows {
+		shows[i] = h.withFeedUrl(c, shows[i])
+	}
+	c.JSON(200, gin.H{"shows": shows})
+}
+
+func (h *ShowHandler) GetById(c *gin.Context) {
+	show, err := h.service.Get(c.GetString("user_id"), c.Param("id"))
+	if err != nil {
+		handleShowError(c, err, "Failed to retrieve show")
+		return
+	}
+
+	c.JSON(200, h.withFeedUrl(c, show))
+}
+
+func (h *ShowHandler) Update(c *gin.Context) {
+	var settings Settings
+	if err := c.ShouldBindJSON(&settings); err != nil {
+		utils.HandleErrorWithMessage(c, err, "Invalid request body", 400)
+		return
+	}
+
+	show, err := h.service.Update(c.GetString("user_id"), c.Param("id"), settings)
+	if err != nil {
+		handleShowError(c, err, "Failed to update show")
+		return
+	}
+
+	c.JSON(200, h.withFeedUrl(c, show))
+}
+
+func (h *ShowHandler) Delete(c *gin.Context) {
+	if err := h.service.Delete(c.GetString("user_id"), c.Param("id")); err != nil {
+		handleShowError(c, err, "Failed to delete show")
+		return
+	}
+
+	c.Status(204)
+}
+
+func (h *ShowHandler) RotateFeedToken(c *gin.Context) {
+	show, err := h.service.RotateFeedToken(c.GetString("user_id"), c.Param("id"))
+	if err != nil {
+		handleShowError(c, err, "Failed to rotate feed URL")
+		return
+	}
+
+	c.JSON(200, h.withFeedUrl(c, show))
+}
+
+func (h *ShowHandler) GetEpisodes(c *gin.Context) {
+	episodes, err := h.service.GetEpisodes(c.GetString("user_id"), c.Param("id"))
+	if err != nil {
+		handleShowError(c, err, "Failed to retrieve episodes")
+		return
+	}
+
+	c.JSON(200, gin.H{"episodes": episodes})
+}
+
+func (h *ShowHandler) AddEpisode(c *gin.Context) {
+	var req EpisodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.HandleErrorWithMessage(c, err, "Invalid request body", 400)
+		return
+	}
+	if req.SongId == uuid.Nil {
+		c.JSON(400, gin.H{"error": "song_id is required"})
+		return
+	}
+
+	episode, err := h.service.AddEpisode(c.GetString("user_id"), c.Param("id"), req)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(404, gin.H{"error": "Song not found"})
+		return
+	}
+	if err != nil {
+		handleShowError(c, err, "Failed to add episode")
+		return
+	}
+
+	c.JSON(201, episode)
+}
+
+func (h *ShowHandler) UpdateEpisode(c *gin.Context) {
+	var req EpisodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.HandleErrorWithMessage(c, err, "Invalid request body", 400)
+		return
+	}
+
+	episode, err := h.service.UpdateEpisode(c.GetString("user_id"), c.Param("id"), c.Param("episodeId"), req)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(404, gin.H{"error": "Episode not found"})
+		return
+	}
+	if err != nil {
+		handleShowError(c, err, "Failed to update episode")
+		return
+	}
+
+	c.JSON(200, episode)
+}
+
+func (h *ShowHandler) RemoveEpisode(c *gin.Context) {
+	err := h.service.RemoveEpisode(c.GetString("user_id"), c.Param("id"), c.Param("episodeId"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(404, gin.H{"error": "Episode not found"})
+		return
+	}
+	if err != nil {
+		handleShowError(c, err, "Failed to remove episode")
+		return
+	}
+
+	c.Status(204)
+}
+
+// Feed serves the show's podcast RSS feed. It needs no login, the feed token in the URL is
+// what keeps a show from being found
+func (h *ShowHandler) Feed(c *gin.Context) {
+	show, episodes, err := h.service.GetFeed(c.Param("token"))
+	if err != nil {
+		handleShowError(c, err, "Failed to retrieve feed")
+		return
+	}
+
+	baseUrl, scope := utils.PublicBaseUrl(c, h.baseUrl)
+	c.Header("Content-Type", "application/rss+xml; charset=utf-8")
+	c.Header("Cache-Control", scope+", max-age=300")
+	c.Status(200)
+	if err := WriteFeed(c.Writer, show, episodes, baseUrl); err != nil {
+		c.Error(err)
+	}
+}
+
+// Enclosure serves an episode's audio to podcast apps, without a login like the feed
+func (h *ShowHandler) Enclosure(c *gin.Context) {
+	// The file is named by the episode id with the song's extension
+	file := c.Param("file")
+	id := strings.TrimSuffix(file, path.Ext(file))
+
+	episode, err := h.service.GetPublishedEpisode(c.Param("token"), id)
+	if err != nil {
+		handleShowError(c, err, "Failed to retrieve episode")
+		return
+	}
+
+	audio, content, _, err := EpisodeAudio(episode)
+	if err != nil {
+		c.JSON(404, gin.H{"error": "Audio file not found on disk"})
+		return
+	}
+	defer audio.Close()
+
+	modified := time.Time{}
+	if info, err := audio.Stat(); err == nil {
+		modified = info.ModTime()
+	}
+
+	c.Header("Content-Type", episode.ContentType())
+	c.Header("Accept-Ranges", "bytes")
+	c.Header("Cache-Control", "public, max-age=3600")
+	http.ServeContent(c.Writer, c.Request, file, modified, content)
+}
+
+// withFeedUrl fills in where podcast apps subscribe to the show
+func (h *ShowHandler) withFeedUrl(c *gin.Context, show Show) Show {
+	baseUrl, _ := utils.PublicBaseUrl(c, h.baseUrl)
+	show.FeedUrl = FeedUrl(baseUrl, show)
+	return show
+}
+
+func handleShowError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound), errors.Is(err, ErrNotShowOwner):
+		// Other people's shows look like they don't exist
+		c.JSON(404, gin.H{"error": "Show not found"})
+	case errors.Is(err, ErrNotSongArtist), errors.Is(err, ErrBanned):
+		utils.HandleErrorWithMessage(c, err, message, 403)
+	case errors.Is(err, ErrAlreadyPublished):
+		utils.HandleErrorWithMessage(c, err, message, 409)
+	default:
+		utils.HandleErrorWithMessage(c, err, message, 500)
+	}
+}
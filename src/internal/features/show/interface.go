@@ -0,0 +1,27 @@
+package show
+
+import "time"
+
+type ShowRepository interface {
+	Create(show *Show) error
+	Update(show *Show) error
+	SetFeedToken(id, token string) error
+	Delete(id string) error
+	GetById(id string) (Show, error)
+	GetByFeedToken(token string) (Show, error)
+	GetOwned(userId string) ([]Show, error)
+
+	GetSong(id string) (Song, error)
+	IsPublished(showId, songId string) (bool, error)
+	CreateEpisode(episode *Episode) error
+	UpdateEpisode(episode *Episode) error
+	DeleteEpisode(showId, id string) (bool, error)
+	GetEpisode(showId, id string) (Episode, error)
+	GetEpisodes(showId string) ([]Episode, error)
+	GetPublishedEpisodes(showId string, before time.Time) ([]Episode, error)
+}
+
+// BanList tells whether moderation banned a user from uploading
+type BanList interface {
+	IsBanned(userId string) (bool, error)
+}
@@ -0,0 +1,220 @@
+package show
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrNotShowOwner     = errors.New("only the owner can change a show")
+	ErrNotSongArtist    = errors.New("only songs you uploaded can be published")
+	ErrAlreadyPublished = errors.New("song is already an episode of this show")
+	ErrBanned           = errors.New("you were banned from uploading")
+)
+
+// Show is a podcast an artist publishes from their uploaded songs. Its feed is public to
+// anyone who has the feed URL, so podcast apps can subscribe without logging in
+type Show struct {
+	Id          uuid.UUID `json:"id" db:"id" gorm:"primaryKey"`
+	OwnerId     uuid.UUID `json:"owner_id" db:"owner_id" gorm:"not null;index"`
+	Title       string    `json:"title" db:"title" gorm:"not null"`
+	Description string    `json:"description" db:"description"`
+	Author      string    `json:"author" db:"author"`
+	Link        string    `json:"link" db:"link"`
+	ImageUrl    string    `json:"image_url" db:"image_url"`
+	Language    string    `json:"language" db:"language" gorm:"not null;default:en"`
+	Category    string    `json:"category" db:"category"`
+	Explicit    bool      `json:"explicit" db:"explicit" gorm:"not null;default:false"`
+	// Secret part of the public feed and enclosure URLs, a new one retires the old URLs
+	FeedToken string    `json:"-" db:"feed_token" gorm:"not null;uniqueIndex"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+
+	// Relationships
+	Owner User `json:"owner" gorm:"foreignKey:OwnerId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+
+	// Where podcast apps subscribe, filled in by the handler
+	FeedUrl string `json:"feed_url" gorm:"-"`
+}
+
+// Settings are what the owner chooses for a show
+type Settings struct {
+	Title       string `json:"title" binding:"required,max=200"`
+	Description string `json:"description" binding:"max=4000"`
+	Author      string `json:"author" binding:"max=200"`
+	Link        string `json:"link" binding:"omitempty,url,max=2000"`
+	ImageUrl    string `json:"image_url" binding:"omitempty,url,max=2000"`
+	Language    string `json:"language" binding:"max=20"`
+	Category    string `json:"category" binding:"max=100"`
+	Explicit    bool   `json:"explicit"`
+}
+
+// Episode publishes one of the owner's songs in a show
+type Episode struct {
+	Id          uuid.UUID `json:"id" db:"id" gorm:"primaryKey"`
+	ShowId      uuid.UUID `json:"show_id" db:"show_id" gorm:"not null;uniqueIndex:idx_show_song"`
+	SongId      uuid.UUID `json:"song_id" db:"song_id" gorm:"not null;uniqueIndex:idx_show_song"`
+	Title       string    `json:"title" db:"title" gorm:"not null"`
+	Description string    `json:"description" db:"description"`
+	Season      int       `json:"season,omitempty" db:"season"`
+	Number      int       `json:"number,omitempty" db:"number"`
+	Explicit    bool      `json:"explicit" db:"explicit" gorm:"not null;default:false"`
+	// Episodes published in the future stay out of the feed until then
+	PublishedAt time.Time `json:"published_at" db:"published_at" gorm:"not null;index"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+
+	// Relationships
+	Show Show `json:"-" gorm:"foreignKey:ShowId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Song Song `json:"song" gorm:"foreignKey:SongId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+
+	// Size of the audio file in bytes, read from disk
+	Length int64 `json:"length" gorm:"-"`
+}
+
+// TableName keeps show episodes apart from the episodes of subscribed podcasts
+func (Episode) TableName() string {
+	return "show_episodes"
+}
+
+// EpisodeRequest adds a song to a show or changes its episode. The title defaults to the
+// song's and the publish date to now
+type EpisodeRequest struct {
+	SongId      uuid.UUID  `json:"song_id"`
+	Title       string     `json:"title" binding:"max=200"`
+	Description string     `json:"description" binding:"max=4000"`
+	Season      int        `json:"season" binding:"min=0"`
+	Number      int        `json:"number" binding:"min=0"`
+	Explicit    bool       `json:"explicit"`
+	PublishedAt *time.Time `json:"published_at"`
+}
+
+type Song struct {
+	Id       uuid.UUID `json:"id"`
+	Title    string    `json:"title"`
+	ArtistId uuid.UUID `json:"artist_id"`
+	Duration int       `json:"duration"`
+	Filename string    `json:"-"`
+	StartMs  int64     `json:"-"`
+	EndMs    int64     `json:"-"`
+	Status   string    `json:"-"`
+}
+
+// IsAvailable tells whether moderators left the song up
+func (s *Song) IsAvailable() bool {
+	return s.Status == "active"
+}
+
+// IsCut tells whether the song is a track of a single-file album rather than its whole file
+func (s *Song) IsCut() bool {
+	return s.StartMs > 0 || s.EndMs > 0
+}
+
+type User struct {
+	Id       uuid.UUID `json:"id"`
+	FullName string    `json:"full_name"`
+}
+
+func NewShow(ownerId string, settings Settings) (*Show, error) {
+	ownerUUID, err := uuid.Parse(ownerId)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := NewFeedToken()
+	if err != nil {
+		return nil, err
+	}
+
+	show := &Show{Id: uuid.New(), OwnerId: ownerUUID, FeedToken: token}
+	show.Apply(settings)
+	return show, nil
+}
+
+// NewFeedToken generates the secret that goes into a show's public URLs
+func NewFeedToken() (string, error) {
+	secret := make([]byte, 16)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+func (s *Show) Apply(settings Settings) {
+	s.Title = strings.TrimSpace(settings.Title)
+	s.Description = settings.Description
+	s.Author = strings.TrimSpace(settings.Author)
+	s.Link = settings.Link
+	s.ImageUrl = settings.ImageUrl
+	s.Language = strings.TrimSpace(settings.Language)
+	s.Category = strings.TrimSpace(settings.Category)
+	s.Explicit = settings.Explicit
+	if s.Language == "" {
+		s.Language = "en"
+	}
+}
+
+func (s *Show) IsOwner(userId string) bool {
+	return s.OwnerId.String() == userId
+}
+
+// AuthorName is who the feed credits, the owner unless the show names someone else
+func (s *Show) AuthorName() string {
+	if s.Author != "" {
+		return s.Author
+	}
+	return s.Owner.FullName
+}
+
+func NewEpisode(showId uuid.UUID, song Song, req EpisodeRequest) *Episode {
+	episode := &Episode{Id: uuid.New(), ShowId: showId, SongId: song.Id, PublishedAt: time.Now()}
+	episode.Apply(song, req)
+	return episode
+}
+
+func (e *Episode) Apply(song Song, req EpisodeRequest) {
+	e.Title = strings.TrimSpace(req.Title)
+	e.Description = req.Description
+	e.Season = req.Season
+	e.Number = req.Number
+	e.Explicit = req.Explicit
+	if e.Title == "" {
+		e.Title = song.Title
+	}
+	if req.PublishedAt != nil {
+		e.PublishedAt = *req.PublishedAt
+	}
+}
+
+// Guid identifies the episode to podcast apps for good, even if the show's URLs change
+func (e *Episode) Guid() string {
+	return "urn:uuid:" + e.Id.String()
+}
+
+// Filename is the last part of the enclosure URL, apps like to see the extension
+func (e *Episode) Filename() string {
+	return e.Id.String() + strings.ToLower(filepath.Ext(e.Song.Filename))
+}
+
+func (e *Episode) ContentType() string {
+	switch strings.ToLower(filepath.Ext(e.Song.Filename)) {
+	case ".wav":
+		return "audio/wav"
+	case ".ogg":
+		return "audio/ogg"
+	case ".m4a":
+		return "audio/mp4"
+	case ".aac":
+		return "audio/aac"
+	case ".flac":
+		return "audio/flac"
+	default:
+		return "audio/mpeg"
+	}
+}
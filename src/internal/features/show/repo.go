@@ -0,0 +1,123 @@
+package show
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type SqlShowRepository struct {
+	db *gorm.DB
+}
+
+func NewSqlShowRepository(db *gorm.DB) *SqlShowRepository {
+	return &SqlShowRepository{db: db}
+}
+
+func (r *SqlShowRepository) Create(show *Show) error {
+	return r.db.Omit("Owner").Create(show).Error
+}
+
+func (r *SqlShowRepository) Update(show *Show) error {
+	return r.db.Model(show).
+		Select("title", "description", "author", "link", "image_url", "language", "category", "explicit", "updated_at").
+		Updates(show).Error
+}
+
+func (r *SqlShowRepository) SetFeedToken(id, token string) error {
+	return r.db.Model(&Show{}).Where("id = ?", id).Update("feed_token", token).Error
+}
+
+func (r *SqlShowRepository) Delete(id string) error {
+	return r.db.Delete(&Show{}, "id = ?", id).Error
+}
+
+func (r *SqlShowRepository) GetById(id string) (Show, error) {
+	var show Show
+	if err := r.db.Preload("Owner").First(&show, "id = ?", id).Error; err != nil {
+		return Show{}, err
+	}
+	return show, nil
+}
+
+func (r *SqlShowRepository) GetByFeedToken(token string) (Show, error) {
+	var show Show
+	if err := r.db.Preload("Owner").First(&show, "feed_token = ?", token).Error; err != nil {
+		return Show{}, err
+	}
+	return show, nil
+}
+
+func (r *SqlShowRepository) GetOwned(userId string) ([]Show, error) {
+	var shows []Show
+	if err := r.db.Preload("Owner").Where("owner_id = ?", userId).Order("title").Find(&shows).Error; err != nil {
+		return nil, err
+	}
+	return shows, nil
+}
+
+func (r *SqlShowRepository) GetSong(id string) (Song, error) {
+	var song Song
+	if err := r.db.First(&song, "id = ? AND status = ?", id, "active").Error; err != nil {
+		return Song{}, err
+	}
+	return song, nil
+}
+
+func (r *SqlShowRepository) IsPublished(showId, songId string) (bool, error) {
+	var count int64
+	err := r.db.Model(&Episode{}).Where("show_id = ? AND song_id = ?", showId, songId).Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *SqlShowRepository) CreateEpisode(episode *Episode) error {
+	return r.db.Omit("Show", "Song").Create(episode).Error
+}
+
+func (r *SqlShowRepository) UpdateEpisode(episode *Episode) error {
+	return r.db.Model(episode).
+		Select("title", "description", "season", "number", "explicit", "published_at", "updated_at").
+		Updates(episode).Error
+}
+
+func (r *SqlShowRepository) DeleteEpisode(showId, id string) (bool, error) {
+	result := r.db.Delete(&Episode{}, "id = ? AND show_id = ?", id, showId)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *SqlShowRepository) GetEpisode(showId, id string) (Episode, error) {
+	var episode Episode
+	if err := r.db.Preload("Song").First(&episode, "id = ? AND show_id = ?", id, showId).Error; err != nil {
+		return Episode{}, err
+	}
+	return episode, nil
+}
+
+// GetEpisodes returns all of a show's episodes, scheduled ones included, newest first
+func (r *SqlShowRepository) GetEpisodes(showId string) ([]Episode, error) {
+	var episodes []Episode
+	err := r.db.Preload("Song").
+		Where("show_id = ?", showId).
+		Order("published_at DESC").
+		Find(&episodes).Error
+	if err != nil {
+		return nil, err
+	}
+	return episodes, nil
+}
+
+// GetPublishedEpisodes returns the episodes out by the given time, newest first
+func (r *SqlShowRepository) GetPublishedEpisodes(showId string, before time.Time) ([]Episode, error) {
+	var episodes []Episode
+	err := r.db.Preload("Song").
+		Where("show_id = ? AND published_at <= ?", showId, before).
+		Order("published_at DESC").
+		Find(&episodes).Error
+	if err != nil {
+		return nil, err
+	}
+	return episodes, nil
+}
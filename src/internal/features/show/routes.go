@@ -0,0 +1,29 @@
+package show
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func SetupRoutes(c *gin.RouterGroup, h *ShowHandler, authMiddleware gin.HandlerFunc) {
+	c.Use(authMiddleware)
+
+	c.POST("", h.Create)
+	c.GET("", h.GetAll)
+	c.GET("/:id", h.GetById)
+	c.PUT("/:id", h.Update)
+	c.DELETE("/:id", h.Delete)
+	c.POST("/:id/feed-token", h.RotateFeedToken)
+	c.GET("/:id/episodes", h.GetEpisodes)
+	c.POST("/:id/episodes", h.AddEpisode)
+	c.PUT("/:id/episodes/:episodeId", h.UpdateEpisode)
+	c.DELETE("/:id/episodes/:episodeId", h.RemoveEpisode)
+}
+
+// SetupFeedRoutes serves feeds and their audio publicly, outside the API so podcast apps
+// get short URLs. Apps check enclosures with HEAD before downloading
+func SetupFeedRoutes(c *gin.RouterGroup, h *ShowHandler) {
+	c.GET("/:token/feed.xml", h.Feed)
+	c.Match([]string{http.MethodGet, http.MethodHead}, "/:token/episodes/:file", h.Enclosure)
+}
@@ -0,0 +1,273 @@
+package show
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	audiotags "github.com/yosp313/gotify/src/internal/pkg/audio_tags"
+	"gorm.io/gorm"
+)
+
+type ShowService struct {
+	repo ShowRepository
+	bans BanList
+}
+
+func NewShowService(repo ShowRepository, bans BanList) *ShowService {
+	return &ShowService{repo: repo, bans: bans}
+}
+
+func (s *ShowService) Create(userId string, settings Settings) (Show, error) {
+	if err := s.refuseBanned(userId); err != nil {
+		return Show{}, err
+	}
+
+	show, err := NewShow(userId, settings)
+	if err != nil {
+		return Show{}, err
+	}
+
+	if err := s.repo.Create(show); err != nil {
+		return Show{}, err
+	}
+	return s.Get(userId, show.Id.String())
+}
+
+func (s *ShowService) GetOwned(userId string) ([]Show, error) {
+	return s.repo.GetOwned(userId)
+}
+
+// Get returns one of the user's shows. Shows are only managed by their owner, everyone
+// else knows them by their feed
+func (s *ShowService) Get(userId, id string) (Show, error) {
+	show, err := s.repo.GetById(id)
+	if err != nil {
+		return Show{}, err
+	}
+	if !show.IsOwner(userId) {
+		return Show{}, ErrNotShowOwner
+	}
+	return show, nil
+}
+
+func (s *ShowService) Update(userId, id string, settings Settings) (Show, error) {
+	show, err := s.Get(userId, id)
+	if err != nil {
+		return Show{}, err
+	}
+
+	show.Apply(settings)
+	if err := s.repo.Update(&show); err != nil {
+		return Show{}, err
+	}
+	return s.Get(userId, id)
+}
+
+func (s *ShowService) Delete(userId, id string) error {
+	if _, err := s.Get(userId, id); err != nil {
+		return err
+	}
+	return s.repo.Delete(id)
+}
+
+// RotateFeedToken gives the show new public URLs. Apps subscribed to the old feed stop
+// getting episodes, for when a feed URL got out to people it wasn't meant for
+func (s *ShowService) RotateFeedToken(userId, id string) (Show, error) {
+	if _, err := s.Get(userId, id); err != nil {
+		return Show{}, err
+	}
+
+	token, err := NewFeedToken()
+	if err != nil {
+		return Show{}, err
+	}
+	if err := s.repo.SetFeedToken(id, token); err != nil {
+		return Show{}, err
+	}
+	return s.Get(userId, id)
+}
+
+// GetEpisodes returns every episode of the user's show, scheduled ones included
+func (s *ShowService) GetEpisodes(userId, showId string) ([]Episode, error) {
+	if _, err := s.Get(userId, showId); err != nil {
+		return nil, err
+	}
+
+	episodes, err := s.repo.GetEpisodes(showId)
+	if err != nil {
+		return nil, err
+	}
+	return withLength(episodes), nil
+}
+
+// AddEpisode publishes one of the user's songs in their show
+func (s *ShowService) AddEpisode(userId, showId string, req EpisodeRequest) (Episode, error) {
+	show, err := s.Get(userId, showId)
+	if err != nil {
+		return Episode{}, err
+	}
+
+	song, err := s.repo.GetSong(req.SongId.String())
+	if err != nil {
+		return Episode{}, err
+	}
+	if song.ArtistId != show.OwnerId {
+		return Episode{}, ErrNotSongArtist
+	}
+
+	published, err := s.repo.IsPublished(showId, song.Id.String())
+	if err != nil {
+		return Episode{}, err
+	}
+	if published {
+		return Episode{}, ErrAlreadyPublished
+	}
+
+	episode := NewEpisode(show.Id, song, req)
+	if err := s.repo.CreateEpisode(episode); err != nil {
+		return Episode{}, err
+	}
+	return s.getEpisode(showId, episode.Id.String())
+}
+
+func (s *ShowService) UpdateEpisode(userId, showId, id string, req EpisodeRequest) (Episode, error) {
+	if _, err := s.Get(userId, showId); err != nil {
+		return Episode{}, err
+	}
+
+	episode, err := s.repo.GetEpisode(showId, id)
+	if err != nil {
+		return Episode{}, err
+	}
+
+	episode.Apply(episode.Song, req)
+	if err := s.repo.UpdateEpisode(&episode); err != nil {
+		return Episode{}, err
+	}
+	return s.getEpisode(showId, id)
+}
+
+func (s *ShowService) RemoveEpisode(userId, showId, id string) error {
+	if _, err := s.Get(userId, showId); err != nil {
+		return err
+	}
+
+	removed, err := s.repo.DeleteEpisode(showId, id)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// GetFeed returns a show by its feed token with the episodes that are out, for the public
+// feed
+func (s *ShowService) GetFeed(token string) (Show, []Episode, error) {
+	show, err := s.repo.GetByFeedToken(token)
+	if err != nil {
+		return Show{}, nil, err
+	}
+
+	episodes, err := s.repo.GetPublishedEpisodes(show.Id.String(), time.Now())
+	if err != nil {
+		return Show{}, nil, err
+	}
+
+	// Apps can't play episodes whose file is gone or whose song moderators pulled
+	playable := make([]Episode, 0, len(episodes))
+	for _, episode := range withLength(episodes) {
+		if episode.Length > 0 && episode.Song.IsAvailable() {
+			playable = append(playable, episode)
+		}
+	}
+	return show, playable, nil
+}
+
+// GetPublishedEpisode finds an episode that's out by the show's feed token, for serving its
+// audio publicly
+func (s *ShowService) GetPublishedEpisode(token, id string) (Episode, error) {
+	show, err := s.repo.GetByFeedToken(token)
+	if err != nil {
+		return Episode{}, err
+	}
+
+	episode, err := s.repo.GetEpisode(show.Id.String(), id)
+	if err != nil {
+		return Episode{}, err
+	}
+	if episode.PublishedAt.After(time.Now()) || !episode.Song.IsAvailable() {
+		return Episode{}, gorm.ErrRecordNotFound
+	}
+	return episode, nil
+}
+
+// EpisodeFile is where an episode's audio lives on disk
+func EpisodeFile(episode Episode) string {
+	return filepath.Join("songs", episode.Song.Filename)
+}
+
+// EpisodeAudio opens an episode's audio. A song that is a track of a single-file album is cut
+// out of it, so the episode is just that track. The caller closes the file
+func EpisodeAudio(episode Episode) (*os.File, io.ReadSeeker, int64, error) {
+	file, err := os.Open(EpisodeFile(episode))
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, 0, err
+	}
+	if !episode.Song.IsCut() {
+		return file, file, info.Size(), nil
+	}
+
+	song := episode.Song
+	clip, err := audiotags.Cut(file, time.Duration(song.StartMs)*time.Millisecond, time.Duration(song.EndMs)*time.Millisecond)
+	if err != nil {
+		file.Close()
+		return nil, nil, 0, err
+	}
+	return file, clip, clip.Size(), nil
+}
+
+func (s *ShowService) getEpisode(showId, id string) (Episode, error) {
+	episode, err := s.repo.GetEpisode(showId, id)
+	if err != nil {
+		return Episode{}, err
+	}
+	return withLength([]Episode{episode})[0], nil
+}
+
+// withLength fills in the size of each episode's file, which the feed's enclosures need.
+// It stays 0 when the file is missing
+func withLength(episodes []Episode) []Episode {
+	for i := range episodes {
+		if file, _, size, err := EpisodeAudio(episodes[i]); err == nil {
+			episodes[i].Length = size
+			file.Close()
+		}
+	}
+	return episodes
+}
+
+// refuseBanned fails with ErrBanned when moderation banned the user from uploading
+func (s *ShowService) refuseBanned(userId string) error {
+	if s.bans == nil {
+		return nil
+	}
+
+	banned, err := s.bans.IsBanned(userId)
+	if err != nil {
+		return err
+	}
+	if banned {
+		return ErrBanned
+	}
+	return nil
+}
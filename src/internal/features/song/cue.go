@@ -0,0 +1,63 @@
+package song
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/yosp313/gotify/src/internal/pkg/cue"
+)
+
+var (
+	ErrCueFiles      = errors.New("cue sheet must describe a single file")
+	ErrCueBeyondFile = errors.New("cue sheet has tracks past the end of the file")
+	ErrAlbumFormat   = errors.New("only FLAC and WAV albums can be split by a cue sheet")
+	ErrAlbumTooLarge = errors.New("album is larger than 2 GB")
+	ErrCueTooLarge   = errors.New("cue sheet is larger than 1 MB")
+	ErrFileExists    = errors.New("file with this name already exists")
+)
+
+// CueSongs makes a song of each track of a cue sheet describing a single-file album of the
+// given length. A track starts at its INDEX 01, so any pregap plays at the end of the track
+// before it, the way a CD plays straight through
+func CueSongs(sheet *cue.Sheet, artistId, filename string, durationMs int64) ([]*Song, error) {
+	if len(sheet.Files) != 1 {
+		return nil, ErrCueFiles
+	}
+
+	tracks := sheet.Files[0].Tracks
+	sort.SliceStable(tracks, func(i, j int) bool { return tracks[i].Start < tracks[j].Start })
+
+	genre := strings.TrimSpace(sheet.Comments["GENRE"])
+	songs := make([]*Song, 0, len(tracks))
+	for i, track := range tracks {
+		startMs := track.Start.Milliseconds()
+		if durationMs > 0 && startMs >= durationMs {
+			return nil, ErrCueBeyondFile
+		}
+
+		var endMs int64
+		if i+1 < len(tracks) {
+			endMs = tracks[i+1].Start.Milliseconds()
+		}
+
+		title := strings.TrimSpace(track.Title)
+		if title == "" {
+			title = fmt.Sprintf("Track %02d", track.Number)
+		}
+
+		song := NewSong(title, artistId, filename)
+		song.Track = track.Number
+		song.StartMs = startMs
+		song.EndMs = endMs
+		song.Genre = genre[:min(len(genre), 64)]
+		if endMs > 0 {
+			song.Duration = int((endMs - startMs + 500) / 1000)
+		} else if durationMs > 0 {
+			song.Duration = int((durationMs - startMs + 500) / 1000)
+		}
+		songs = append(songs, song)
+	}
+	return songs, nil
+}
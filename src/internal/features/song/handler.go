@@ -1,29 +1,80 @@
 package song
 
 import (
+	"errors"
+	"io"
 	"mime/multipart"
+	"net/http"
 	"os"
 	"path/filepath"
 	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	audiotags "github.com/yosp313/gotify/src/internal/pkg/audio_tags"
 	"github.com/yosp313/gotify/src/internal/pkg/auth"
+	"github.com/yosp313/gotify/src/internal/pkg/cue"
 	"github.com/yosp313/gotify/src/internal/utils"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrNotSongOwner = errors.New("only the uploader can change this song")
+	ErrBanned       = errors.New("you were banned from uploading")
+	ErrTakenDown    = errors.New("song was taken down")
+	ErrNoLyrics     = errors.New("either a lyrics file or lyrics text is required")
 )
 
 type SongHandler struct {
 	service     *SongService
 	authService *auth.JwtAuthService
+	plays       PlayRecorder
+	genres      GenreIndex
+	bans        BanList
 }
 
 type SongCreateRequest struct {
-	Title string                `form:"title" binding:"required"`
-	File  *multipart.FileHeader `form:"file" binding:"required"`
+	Title    string                `form:"title" binding:"required"`
+	Duration int                   `form:"duration" binding:"omitempty,min=0"`
+	Genre    string                `form:"genre" binding:"max=64"`
+	Bpm      int                   `form:"bpm" binding:"omitempty,min=1,max=400"`
+	Public   bool                  `form:"public"`
+	File     *multipart.FileHeader `form:"file" binding:"required"`
 }
 
-func NewSongHandler(service *SongService, authService *auth.JwtAuthService) *SongHandler {
-	return &SongHandler{service: service, authService: authService}
+// AlbumCreateRequest uploads a single-file album with the cue sheet that splits it into songs
+type AlbumCreateRequest struct {
+	Genre  string                `form:"genre" binding:"max=64"`
+	Public bool                  `form:"public"`
+	File   *multipart.FileHeader `form:"file" binding:"required"`
+	Cue    *multipart.FileHeader `form:"cue" binding:"required"`
+}
+
+// PublicRequest makes a song embeddable in other sites, or stops it from being
+type PublicRequest struct {
+	Public *bool `json:"public" binding:"required"`
+}
+
+type LyricsRequest struct {
+	Lyrics   string                `form:"lyrics" json:"lyrics"`
+	Language string                `form:"language" json:"language" binding:"omitempty,len=3"`
+	File     *multipart.FileHeader `form:"file" json:"-"`
+}
+
+type LyricsResponse struct {
+	SongId    string      `json:"song_id"`
+	Language  string      `json:"language"`
+	Source    string      `json:"source"`
+	Synced    bool        `json:"synced"`
+	Plain     string      `json:"plain"`
+	Lines     []LyricLine `json:"lines"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+func NewSongHandler(service *SongService, authService *auth.JwtAuthService, plays PlayRecorder, genres GenreIndex, bans BanList) *SongHandler {
+	return &SongHandler{service: service, authService: authService, plays: plays, genres: genres, bans: bans}
 }
 
 func (h *SongHandler) Create(c *gin.Context) {
@@ -33,6 +84,9 @@ func (h *SongHandler) Create(c *gin.Context) {
 		utils.HandleErrorWithMessage(c, err, "Invalid form data", 400)
 		return
 	}
+	if h.refuseBanned(c) {
+		return
+	}
 
 	// Validate file type
 	if !isValidAudioFile(songReq.File.Filename) {
@@ -77,6 +131,29 @@ func (h *SongHandler) Create(c *gin.Context) {
 
 	// Create song record in database
 	song := NewSong(songReq.Title, userDetails.Subject, safeFilename)
+	song.Duration = songReq.Duration
+	song.Public = songReq.Public
+	if song.Duration == 0 {
+		if duration, err := audiotags.Duration(filePath); err == nil {
+			song.Duration = int(duration.Round(time.Second).Seconds())
+		}
+	}
+
+	// Genre and tempo fall back to the file's tags when the form leaves them out
+	song.Genre = strings.TrimSpace(songReq.Genre)
+	song.Bpm = songReq.Bpm
+	if fingerprint, err := audiotags.Fingerprint(filePath); err == nil {
+		song.Fingerprint = fingerprint
+	}
+	if tags, err := audiotags.ReadFile(filePath); err == nil {
+		if song.Genre == "" {
+			song.Genre = tags.Genre()
+		}
+		if song.Bpm == 0 {
+			song.Bpm = tags.Bpm()
+		}
+	}
+	song.Genre = h.canonicalGenre(song.Genre)
 	id, err := h.service.Create(song)
 	if err != nil {
 		// If database creation fails, remove the uploaded file
@@ -85,6 +162,9 @@ func (h *SongHandler) Create(c *gin.Context) {
 		return
 	}
 
+	// Lyrics embedded in the file are optional, so a failed import doesn't fail the upload
+	h.service.ImportTaggedLyrics(*song, filePath)
+
 	c.JSON(201, gin.H{
 		"message":  "Song uploaded successfully",
 		"id":       id,
@@ -92,6 +172,130 @@ func (h *SongHandler) Create(c *gin.Context) {
 	})
 }
 
+// CreateAlbum adds each track of a FLAC or WAV album image as its own song, all sharing the
+// one file
+func (h *SongHandler) CreateAlbum(c *gin.Context) {
+	var req AlbumCreateRequest
+	if err := c.ShouldBind(&req); err != nil {
+		utils.HandleErrorWithMessage(c, err, "Invalid form data", 400)
+		return
+	}
+	if h.refuseBanned(c) {
+		return
+	}
+
+	switch strings.ToLower(filepath.Ext(req.File.Filename)) {
+	case ".flac", ".wav":
+	default:
+		utils.HandleErrorWithMessage(c, ErrAlbumFormat, "Invalid file type", 400)
+		return
+	}
+
+	// Album images are much larger than single songs
+	maxSize := int64(2 << 30) // 2GB
+	if req.File.Size > maxSize {
+		utils.HandleErrorWithMessage(c, ErrAlbumTooLarge, "File too large", 400)
+		return
+	}
+
+	// Cue sheets are small text files, 1MB is plenty
+	if req.Cue.Size > 1<<20 {
+		utils.HandleErrorWithMessage(c, ErrCueTooLarge, "Cue sheet too large", 400)
+		return
+	}
+
+	text, err := readUploadedFile(req.Cue)
+	if err != nil {
+		utils.HandleErrorWithMessage(c, err, "Failed to read cue sheet", 400)
+		return
+	}
+	sheet, err := cue.Parse(strings.NewReader(text))
+	if err != nil {
+		utils.HandleErrorWithMessage(c, err, "Invalid cue sheet", 400)
+		return
+	}
+
+	safeFilename := generateSafeFilename(req.File.Filename)
+	filePath := filepath.Join("songs", safeFilename)
+
+	if err := os.MkdirAll("songs", 0755); err != nil {
+		utils.HandleErrorWithMessage(c, err, "Failed to create songs directory", 500)
+		return
+	}
+	if _, err := os.Stat(filePath); !os.IsNotExist(err) {
+		utils.HandleErrorWithMessage(c, ErrFileExists, "Failed to save file", 409)
+		return
+	}
+	if err := c.SaveUploadedFile(req.File, filePath); err != nil {
+		utils.HandleErrorWithMessage(c, err, "Failed to save file", 500)
+		return
+	}
+
+	songs, err := h.albumSongs(c.GetString("user_id"), sheet, safeFilename, filePath)
+	if err != nil {
+		os.Remove(filePath)
+		utils.HandleErrorWithMessage(c, err, "Invalid album", 400)
+		return
+	}
+
+	genre := songs[0].Genre
+	if form := strings.TrimSpace(req.Genre); form != "" {
+		genre = form
+	}
+	genre = h.canonicalGenre(genre)
+	for _, song := range songs {
+		song.Genre = genre
+		song.Public = req.Public
+	}
+
+	if err := h.service.CreateMany(songs); err != nil {
+		os.Remove(filePath)
+		utils.HandleErrorWithMessage(c, err, "Failed to create song records", 500)
+		return
+	}
+
+	ids := make([]string, len(songs))
+	for i, song := range songs {
+		ids[i] = song.Id.String()
+	}
+	c.JSON(201, gin.H{
+		"message":  "Album uploaded successfully",
+		"ids":      ids,
+		"filename": safeFilename,
+	})
+}
+
+// canonicalGenre spells a genre the way the taxonomy does, adding it there when it's new. The
+// genre is kept as given when it can't be filed
+func (h *SongHandler) canonicalGenre(genre string) string {
+	if h.genres == nil {
+		return genre
+	}
+	if canonical, err := h.genres.Ensure(genre); err == nil {
+		return canonical
+	}
+	return genre
+}
+
+// albumSongs checks that the saved album can be cut and splits it by the cue sheet
+func (h *SongHandler) albumSongs(userId string, sheet *cue.Sheet, filename, filePath string) ([]*Song, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if _, err := audiotags.Cut(file, 0, 0); err != nil {
+		return nil, ErrAlbumFormat
+	}
+
+	duration, err := audiotags.Duration(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return CueSongs(sheet, userId, filename, duration.Milliseconds())
+}
+
 // Helper function to validate audio file extensions
 func isValidAudioFile(filename string) bool {
 	ext := strings.ToLower(filepath.Ext(filename))
@@ -121,13 +325,23 @@ func generateSafeFilename(originalFilename string) string {
 
 func (h *SongHandler) GetById(c *gin.Context) {
 	id := c.Param("id")
-	song, err := h.service.GetById(id)
+	song, err := h.service.GetVisible(c.GetString("user_id"), id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(404, gin.H{"error": "Song not found"})
+		return
+	}
 	if err != nil {
 		utils.HandleErrorWithMessage(c, err, "Failed to retrieve song", 500)
 		return
 	}
 
-	c.JSON(200, song)
+	songs := []Song{song}
+	if err := h.service.AnnotateForUser(c.GetString("user_id"), songs); err != nil {
+		utils.HandleErrorWithMessage(c, err, "Failed to retrieve song", 500)
+		return
+	}
+
+	c.JSON(200, songs[0])
 }
 
 func (h *SongHandler) GetByTitle(c *gin.Context) {
@@ -143,6 +357,11 @@ func (h *SongHandler) GetByTitle(c *gin.Context) {
 		return
 	}
 
+	if err := h.service.AnnotateForUser(c.GetString("user_id"), songs); err != nil {
+		utils.HandleErrorWithMessage(c, err, "Failed to retrieve songs by title", 500)
+		return
+	}
+
 	c.JSON(200, songs)
 }
 
@@ -153,32 +372,53 @@ func (h *SongHandler) GetByArtistId(c *gin.Context) {
 		return
 	}
 
-	songs, err := h.service.GetByArtistId(id)
+	songs, err := h.service.GetByArtistId(c.GetString("user_id"), id)
 	if err != nil {
 		utils.HandleErrorWithMessage(c, err, "Failed to retrieve songs by artist ID", 500)
 		return
 	}
 
+	if err := h.service.AnnotateForUser(c.GetString("user_id"), songs); err != nil {
+		utils.HandleErrorWithMessage(c, err, "Failed to retrieve songs by artist ID", 500)
+		return
+	}
+
 	c.JSON(200, songs)
 }
 
 func (h *SongHandler) GetAll(c *gin.Context) {
-	songs, err := h.service.GetAll()
+	var songs []Song
+	var err error
+	if tags := c.QueryArray("tag"); len(tags) > 0 {
+		songs, err = h.service.GetByTags(tags)
+	} else {
+		songs, err = h.service.GetAll()
+	}
 	if err != nil {
 		utils.HandleErrorWithMessage(c, err, "Failed to retrieve all songs", 500)
 		return
 	}
 
+	if err := h.service.AnnotateForUser(c.GetString("user_id"), songs); err != nil {
+		utils.HandleErrorWithMessage(c, err, "Failed to retrieve all songs", 500)
+		return
+	}
+
 	c.JSON(200, songs)
 }
 
 func (h *SongHandler) StreamSong(c *gin.Context) {
-	songID := c.Param("id")
+	h.stream(c, c.Param("id"))
+}
 
-	// Get song from database
-	song, err := h.service.GetById(songID)
-	if err != nil {
-		c.JSON(404, gin.H{"error": "Song not found"})
+// StreamShared streams the song a link grants, checked by the link middleware
+func (h *SongHandler) StreamShared(c *gin.Context) {
+	h.stream(c, c.GetString("shared_song_id"))
+}
+
+func (h *SongHandler) stream(c *gin.Context, songID string) {
+	song, ok := h.requirePlayable(c, songID)
+	if !ok {
 		return
 	}
 
@@ -186,11 +426,36 @@ func (h *SongHandler) StreamSong(c *gin.Context) {
 	filePath := filepath.Join("songs", song.Filename)
 
 	// Check if file exists
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+	info, err := os.Stat(filePath)
+	if err != nil {
 		c.JSON(404, gin.H{"error": "Audio file not found on disk"})
 		return
 	}
 
+	// Tracks of a single-file album are cut out of it as they're streamed
+	var clip *audiotags.Clip
+	size := info.Size()
+	if song.IsCut() {
+		file, err := os.Open(filePath)
+		if err != nil {
+			utils.HandleErrorWithMessage(c, err, "Failed to open audio file", 500)
+			return
+		}
+		defer file.Close()
+
+		clip, err = audiotags.Cut(file, time.Duration(song.StartMs)*time.Millisecond, time.Duration(song.EndMs)*time.Millisecond)
+		if err != nil {
+			utils.HandleErrorWithMessage(c, err, "Failed to cut track from album", 500)
+			return
+		}
+		size = clip.Size()
+	}
+
+	if h.plays != nil {
+		from, to := parseByteRange(c.GetHeader("Range"), size)
+		h.plays.RecordStream(c.GetString("user_id"), song.Id, song.Duration, getClientName(c), from, to, size)
+	}
+
 	// Determine content type based on file extension
 	contentType := getContentType(song.Filename)
 
@@ -200,9 +465,47 @@ func (h *SongHandler) StreamSong(c *gin.Context) {
 	c.Header("Cache-Control", "public, max-age=3600")
 
 	// Stream the file
+	if clip != nil {
+		http.ServeContent(c.Writer, c.Request, song.Filename, info.ModTime(), clip)
+		return
+	}
 	c.File(filePath)
 }
 
+// Helper function to read the first range of a Range header, defaulting to the whole file
+func parseByteRange(header string, size int64) (int64, int64) {
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok {
+		return 0, size - 1
+	}
+	spec, _, _ = strings.Cut(spec, ",")
+
+	startStr, endStr, _ := strings.Cut(strings.TrimSpace(spec), "-")
+	start, startErr := strconv.ParseInt(startStr, 10, 64)
+	end, endErr := strconv.ParseInt(endStr, 10, 64)
+
+	switch {
+	case startErr != nil && endErr == nil:
+		// Suffix range, e.g. bytes=-500 is the last 500 bytes
+		return max(size-end, 0), size - 1
+	case startErr != nil:
+		return 0, size - 1
+	case endErr != nil || end >= size:
+		return start, size - 1
+	default:
+		return start, end
+	}
+}
+
+// Helper function to name the client for play history, the bundled web player sends no header
+func getClientName(c *gin.Context) string {
+	client := c.GetHeader("X-Client-Name")
+	if client == "" {
+		return "web"
+	}
+	return client[:min(len(client), 64)]
+}
+
 // Helper function to get content type based on file extension
 func getContentType(filename string) string {
 	ext := strings.ToLower(filepath.Ext(filename))
@@ -223,3 +526,283 @@ func getContentType(filename string) string {
 		return "audio/mpeg"
 	}
 }
+
+func (h *SongHandler) GetLyrics(c *gin.Context) {
+	if _, ok := h.requirePlayable(c, c.Param("id")); !ok {
+		return
+	}
+
+	lyrics, err := h.service.GetLyrics(c.Param("id"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.HandleErrorWithMessage(c, err, "Lyrics not found", 404)
+		return
+	}
+	if err != nil {
+		utils.HandleErrorWithMessage(c, err, "Failed to retrieve lyrics", 500)
+		return
+	}
+
+	c.JSON(200, newLyricsResponse(lyrics))
+}
+
+func (h *SongHandler) SetLyrics(c *gin.Context) {
+	var req LyricsRequest
+	if err := c.ShouldBind(&req); err != nil {
+		utils.HandleErrorWithMessage(c, err, "Invalid lyrics data", 400)
+		return
+	}
+
+	if _, ok := h.requireOwner(c); !ok {
+		return
+	}
+
+	text := req.Lyrics
+	if req.File != nil {
+		// Lyrics files are small, 1MB is plenty
+		if req.File.Size > 1<<20 {
+			utils.HandleErrorWithMessage(c, errors.New("file too large"), "Lyrics file too large. Maximum size is 1MB", 400)
+			return
+		}
+
+		content, err := readUploadedFile(req.File)
+		if err != nil {
+			utils.HandleErrorWithMessage(c, err, "Failed to read lyrics file", 400)
+			return
+		}
+		text = content
+	}
+
+	if strings.TrimSpace(text) == "" {
+		utils.HandleErrorWithMessage(c, ErrNoLyrics, "Invalid lyrics data", 400)
+		return
+	}
+
+	lyrics, err := h.service.SetLyrics(c.Param("id"), req.Language, text)
+	if err != nil {
+		utils.HandleErrorWithMessage(c, err, "Invalid lyrics", 400)
+		return
+	}
+
+	c.JSON(200, newLyricsResponse(lyrics))
+}
+
+func (h *SongHandler) ExtractLyrics(c *gin.Context) {
+	song, ok := h.requireOwner(c)
+	if !ok {
+		return
+	}
+
+	lyrics, err := h.service.ImportTaggedLyrics(song, filepath.Join("songs", song.Filename))
+	if err != nil {
+		utils.HandleErrorWithMessage(c, err, "Failed to extract lyrics from audio tags", 422)
+		return
+	}
+
+	c.JSON(200, newLyricsResponse(lyrics))
+}
+
+// SetPublic lets the artist choose whether the song can be embedded in other sites
+func (h *SongHandler) SetPublic(c *gin.Context) {
+	var req PublicRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.HandleErrorWithMessage(c, err, "Invalid request body", 400)
+		return
+	}
+
+	song, ok := h.requireOwner(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.SetPublic(song.Id.String(), *req.Public); err != nil {
+		utils.HandleErrorWithMessage(c, err, "Failed to update song", 500)
+		return
+	}
+
+	c.JSON(200, gin.H{"id": song.Id, "public": *req.Public})
+}
+
+func (h *SongHandler) Like(c *gin.Context) {
+	count, err := h.service.Like(c.GetString("user_id"), c.Param("songId"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(404, gin.H{"error": "Song not found"})
+		return
+	}
+	if err != nil {
+		utils.HandleErrorWithMessage(c, err, "Failed to like song", 500)
+		return
+	}
+
+	c.JSON(200, gin.H{"liked": true, "like_count": count})
+}
+
+func (h *SongHandler) Unlike(c *gin.Context) {
+	count, err := h.service.Unlike(c.GetString("user_id"), c.Param("songId"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(404, gin.H{"error": "Song not found"})
+		return
+	}
+	if err != nil {
+		utils.HandleErrorWithMessage(c, err, "Failed to unlike song", 500)
+		return
+	}
+
+	c.JSON(200, gin.H{"liked": false, "like_count": count})
+}
+
+func (h *SongHandler) GetLiked(c *gin.Context) {
+	page, limit := utils.GetPagination(c)
+
+	songs, total, err := h.service.GetLiked(c.GetString("user_id"), page, limit)
+	if err != nil {
+		utils.HandleErrorWithMessage(c, err, "Failed to retrieve liked songs", 500)
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"songs": songs,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	})
+}
+
+type PositionRequest struct {
+	Position *int `json:"position" binding:"required,min=0"` // seconds
+}
+
+func (h *SongHandler) GetInProgress(c *gin.Context) {
+	page, limit := utils.GetPagination(c)
+
+	songs, total, err := h.service.GetInProgress(c.GetString("user_id"), page, limit)
+	if err != nil {
+		utils.HandleErrorWithMessage(c, err, "Failed to retrieve songs in progress", 500)
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"songs": songs,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	})
+}
+
+func (h *SongHandler) SavePosition(c *gin.Context) {
+	var req PositionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.HandleErrorWithMessage(c, err, "Invalid request body", 400)
+		return
+	}
+
+	resumeAt, err := h.service.SavePosition(c.GetString("user_id"), c.Param("songId"), *req.Position)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(404, gin.H{"error": "Song not found"})
+		return
+	}
+	if err != nil {
+		utils.HandleErrorWithMessage(c, err, "Failed to save position", 500)
+		return
+	}
+
+	c.JSON(200, gin.H{"song_id": c.Param("songId"), "resume_at": resumeAt})
+}
+
+func (h *SongHandler) ClearPosition(c *gin.Context) {
+	err := h.service.ClearPosition(c.GetString("user_id"), c.Param("songId"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(404, gin.H{"error": "Song not found"})
+		return
+	}
+	if err != nil {
+		utils.HandleErrorWithMessage(c, err, "Failed to clear position", 500)
+		return
+	}
+
+	c.Status(204)
+}
+
+// refuseBanned answers 403 when moderation banned the current user from uploading
+func (h *SongHandler) refuseBanned(c *gin.Context) bool {
+	if h.bans == nil {
+		return false
+	}
+
+	banned, err := h.bans.IsBanned(c.GetString("user_id"))
+	if err != nil {
+		utils.HandleErrorWithMessage(c, err, "Failed to check upload permission", 500)
+		return true
+	}
+	if banned {
+		utils.HandleErrorWithMessage(c, ErrBanned, "Forbidden", 403)
+		return true
+	}
+	return false
+}
+
+// requireOwner loads the song from the :id param and checks that the current user uploaded it
+// requirePlayable loads a song the user can listen to. Taken down songs say so, quarantined
+// ones are only there for their artist
+func (h *SongHandler) requirePlayable(c *gin.Context, songID string) (Song, bool) {
+	song, err := h.service.GetById(songID)
+	if err != nil {
+		c.JSON(404, gin.H{"error": "Song not found"})
+		return Song{}, false
+	}
+	if song.Status == StatusRemoved {
+		utils.HandleErrorWithMessage(c, ErrTakenDown, "Song is unavailable", 451)
+		return Song{}, false
+	}
+	if !song.VisibleTo(c.GetString("user_id")) {
+		c.JSON(404, gin.H{"error": "Song not found"})
+		return Song{}, false
+	}
+
+	return song, true
+}
+
+func (h *SongHandler) requireOwner(c *gin.Context) (Song, bool) {
+	song, err := h.service.GetById(c.Param("id"))
+	if err != nil {
+		c.JSON(404, gin.H{"error": "Song not found"})
+		return Song{}, false
+	}
+
+	if song.ArtistId.String() != c.GetString("user_id") {
+		utils.HandleErrorWithMessage(c, ErrNotSongOwner, "Forbidden", 403)
+		return Song{}, false
+	}
+
+	return song, true
+}
+
+func newLyricsResponse(lyrics Lyrics) LyricsResponse {
+	lines := lyrics.Lines()
+	if lines == nil {
+		lines = []LyricLine{}
+	}
+
+	return LyricsResponse{
+		SongId:    lyrics.SongId.String(),
+		Language:  lyrics.Language,
+		Source:    lyrics.Source,
+		Synced:    lyrics.Synced != "",
+		Plain:     lyrics.Plain,
+		Lines:     lines,
+		UpdatedAt: lyrics.UpdatedAt,
+	}
+}
+
+func readUploadedFile(fileHeader *multipart.FileHeader) (string, error) {
+	file, err := fileHeader.Open()
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(string(content), "\uFEFF"), nil
+}
@@ -1,9 +1,41 @@
 package song
 
+import "github.com/google/uuid"
+
 type SongRepository interface {
 	Create(song *Song) (string, error)
+	CreateMany(songs []*Song) error
 	GetAll() ([]Song, error)
+	GetByTags(tags []string) ([]Song, error)
 	GetById(id string) (Song, error)
 	GetByTitle(title string) ([]Song, error)
-	GetByArtistId(id string) ([]Song, error)
+	GetByArtistId(id string, all bool) ([]Song, error)
+	SetPublic(id string, public bool) error
+	SaveLyrics(lyrics *Lyrics) error
+	GetLyrics(songId string) (Lyrics, error)
+	Like(userId, songId string) error
+	Unlike(userId, songId string) error
+	GetLiked(userId string, page, limit int) ([]Song, int64, error)
+	LikeCounts(songIds []uuid.UUID) (map[uuid.UUID]int64, error)
+	LikedSongIds(userId string, songIds []uuid.UUID) (map[uuid.UUID]bool, error)
+	SavePosition(position *Position) error
+	DeletePosition(userId, songId string) error
+	GetInProgress(userId string, page, limit int) ([]Song, int64, error)
+	Positions(userId string, songIds []uuid.UUID) (map[uuid.UUID]int, error)
+}
+
+// PlayRecorder is told about every byte range served by StreamSong
+type PlayRecorder interface {
+	RecordStream(userId string, songId uuid.UUID, duration int, client string, from, to, size int64)
+}
+
+// BanList tells whether moderation banned a user from uploading
+type BanList interface {
+	IsBanned(userId string) (bool, error)
+}
+
+// GenreIndex files uploaded songs under the genre taxonomy, returning the genre spelled the
+// way the taxonomy spells it
+type GenreIndex interface {
+	Ensure(name string) (string, error)
 }
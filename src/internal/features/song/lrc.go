@@ -0,0 +1,141 @@
+package song
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrEmptyLyrics    = errors.New("lyrics are empty")
+	ErrUnsyncedLyrics = errors.New("lyrics contain no timestamped lines")
+)
+
+type LyricLine struct {
+	TimeMs int    `json:"time_ms"`
+	Text   string `json:"text"`
+}
+
+var (
+	lrcTimeTag     = regexp.MustCompile(`^\[(\d{1,3}):(\d{1,2})(?:[.:](\d{1,3}))?\]`)
+	lrcMetaTag     = regexp.MustCompile(`^\[([a-zA-Z#]+):(.*)\]$`)
+	lrcWordTimeTag = regexp.MustCompile(`<\d{1,3}:\d{1,2}(?:[.:]\d{1,3})?>`)
+)
+
+// IsSyncedLyrics reports whether the text looks like LRC, i.e. has at least one timestamped line
+func IsSyncedLyrics(text string) bool {
+	for _, line := range strings.Split(text, "\n") {
+		if lrcTimeTag.MatchString(strings.TrimSpace(line)) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseLrc parses LRC text into lines sorted by time. The [offset:] tag is applied
+// and enhanced (word-level) timestamps are stripped
+func ParseLrc(text string) ([]LyricLine, error) {
+	var lines []LyricLine
+	offset := 0
+
+	for i, raw := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		line := strings.TrimSpace(raw)
+		if line == "" {
+			continue
+		}
+
+		if !lrcTimeTag.MatchString(line) {
+			if meta := lrcMetaTag.FindStringSubmatch(line); meta != nil {
+				if strings.EqualFold(meta[1], "offset") {
+					value, err := strconv.Atoi(strings.TrimSpace(meta[2]))
+					if err != nil {
+						return nil, fmt.Errorf("line %d: invalid offset %q", i+1, meta[2])
+					}
+					offset = value
+				}
+				continue
+			}
+			return nil, fmt.Errorf("line %d: missing timestamp", i+1)
+		}
+
+		// A line may carry several timestamps, e.g. a repeated chorus
+		var times []int
+		for {
+			match := lrcTimeTag.FindStringSubmatch(line)
+			if match == nil {
+				break
+			}
+
+			ms, err := parseLrcTimestamp(match[1], match[2], match[3])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			times = append(times, ms)
+			line = line[len(match[0]):]
+		}
+
+		lyric := strings.TrimSpace(lrcWordTimeTag.ReplaceAllString(line, ""))
+		for _, ms := range times {
+			lines = append(lines, LyricLine{TimeMs: ms, Text: lyric})
+		}
+	}
+
+	if len(lines) == 0 {
+		return nil, ErrUnsyncedLyrics
+	}
+
+	// A positive offset makes lyrics appear sooner
+	for i := range lines {
+		lines[i].TimeMs = max(lines[i].TimeMs-offset, 0)
+	}
+
+	sort.SliceStable(lines, func(a, b int) bool {
+		return lines[a].TimeMs < lines[b].TimeMs
+	})
+
+	return lines, nil
+}
+
+func parseLrcTimestamp(minutes, seconds, fraction string) (int, error) {
+	mins, _ := strconv.Atoi(minutes)
+	secs, _ := strconv.Atoi(seconds)
+	if secs >= 60 {
+		return 0, fmt.Errorf("invalid timestamp %s:%s", minutes, seconds)
+	}
+
+	ms := 0
+	if fraction != "" {
+		// .5 is 500ms, .05 is 50ms, .005 is 5ms
+		value, _ := strconv.Atoi(fraction)
+		for range 3 - len(fraction) {
+			value *= 10
+		}
+		ms = value
+	}
+
+	return (mins*60+secs)*1000 + ms, nil
+}
+
+// FormatLrc renders lines as normalized LRC with [mm:ss.xx] timestamps
+func FormatLrc(lines []LyricLine) string {
+	var b strings.Builder
+	for _, line := range lines {
+		centis := (line.TimeMs + 5) / 10
+		fmt.Fprintf(&b, "[%02d:%02d.%02d]%s\n", centis/6000, centis/100%60, centis%100, line.Text)
+	}
+	return b.String()
+}
+
+// PlainFromLines joins the text of timed lines, dropping empty instrumental markers
+func PlainFromLines(lines []LyricLine) string {
+	texts := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if line.Text != "" {
+			texts = append(texts, line.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
@@ -1,6 +1,9 @@
 package song
 
 import (
+	"strings"
+	"time"
+
 	"github.com/google/uuid"
 	"github.com/yosp313/gotify/src/internal/utils"
 )
@@ -10,10 +13,95 @@ type Song struct {
 	Title    string    `json:"title" db:"title" gorm:"not null"`
 	ArtistId uuid.UUID `json:"artist_id" db:"artist_id" gorm:"not null;foreignKey"`
 	Filename string    `json:"-" db:"file_name" gorm:"not null"`
+	Duration int       `json:"duration" db:"duration"` // seconds, 0 when unknown
+	Genre    string    `json:"genre" db:"genre" gorm:"index"`
+	Bpm      int       `json:"bpm" db:"bpm"`
+
+	// Hash of the audio data without tags, see audiotags.Fingerprint
+	Fingerprint string `json:"-" db:"fingerprint" gorm:"index"`
+
+	// Tracks of a single-file album share its file, each playing its own part of it. EndMs
+	// is 0 for the last track, which runs to the end of the file
+	Track   int   `json:"track,omitempty" db:"track"`
+	StartMs int64 `json:"start_ms,omitempty" db:"start_ms"`
+	EndMs   int64 `json:"end_ms,omitempty" db:"end_ms"`
+
+	// Moderation can quarantine a song while it's looked into, or take it down for good.
+	// Either way only its uploader still sees it
+	Status string `json:"status" db:"status" gorm:"not null;default:active;index"`
+
+	// Public songs can be embedded in other sites and streamed there without a login, artists
+	// opt in to it
+	Public bool `json:"public" db:"public" gorm:"not null;default:false"`
+
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+
+	// Per-listener state, filled in by the service
+	Liked     bool  `json:"liked" gorm:"-"`
+	LikeCount int64 `json:"like_count" gorm:"-"`
+	ResumeAt  *int  `json:"resume_at" gorm:"-"` // seconds, nil when starting from the top
 
 	// Relationships
 	Artist User `json:"artist" gorm:"foreignKey:ArtistId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
 }
+
+// Lyrics keeps the plain text and, when available, the normalized LRC of a song
+type Lyrics struct {
+	SongId    uuid.UUID `json:"song_id" db:"song_id" gorm:"primaryKey"`
+	Language  string    `json:"language" db:"language"`
+	Plain     string    `json:"plain" db:"plain" gorm:"not null"`
+	Synced    string    `json:"-" db:"synced"`
+	Source    string    `json:"source" db:"source" gorm:"not null"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+
+	// Relationships
+	Song Song `json:"-" gorm:"foreignKey:SongId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+// Like marks a song as saved to a user's library
+type Like struct {
+	UserId    uuid.UUID `json:"user_id" db:"user_id" gorm:"primaryKey"`
+	SongId    uuid.UUID `json:"song_id" db:"song_id" gorm:"primaryKey;index"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+
+	// Relationships
+	User User `json:"-" gorm:"foreignKey:UserId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Song Song `json:"-" gorm:"foreignKey:SongId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+// Position is where a user stopped in a song, so long recordings pick up where they left off
+type Position struct {
+	UserId    uuid.UUID `json:"user_id" db:"user_id" gorm:"primaryKey"`
+	SongId    uuid.UUID `json:"song_id" db:"song_id" gorm:"primaryKey;index"`
+	Position  int       `json:"position" db:"position" gorm:"not null"` // seconds
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at" gorm:"index"`
+
+	// Relationships
+	User User `json:"-" gorm:"foreignKey:UserId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Song Song `json:"-" gorm:"foreignKey:SongId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+func (Position) TableName() string {
+	return "song_positions"
+}
+
+// A song counts as finished this close to its end, and its position is cleared
+const FinishedMargin = 10 // seconds
+
+// Song statuses
+const (
+	StatusActive      = "active"
+	StatusQuarantined = "quarantined"
+	StatusRemoved     = "removed"
+)
+
+// Lyrics sources
+const (
+	LyricsSourceUpload = "upload"
+	LyricsSourceUslt   = "uslt"
+	LyricsSourceSylt   = "sylt"
+)
+
 type User struct {
 	Id       uuid.UUID
 	FullName string
@@ -32,9 +120,73 @@ func NewSong(title string, artistId string, filename string) *Song {
 		Title:    title,
 		ArtistId: artistUUID,
 		Filename: filename,
+		Status:   StatusActive,
 	}
 }
 
+func NewPosition(userId, songId uuid.UUID, position int) *Position {
+	return &Position{UserId: userId, SongId: songId, Position: position}
+}
+
+// IsFinished tells whether a position is at the end of the song, songs of unknown duration
+// are never finished
+func (s *Song) IsFinished(position int) bool {
+	return s.Duration > 0 && position >= s.Duration-FinishedMargin
+}
+
+// IsCut tells whether the song is a track of a single-file album rather than its whole file
+func (s *Song) IsCut() bool {
+	return s.StartMs > 0 || s.EndMs > 0
+}
+
+// IsAvailable tells whether the song can be listed and played, moderation hasn't pulled it
+func (s *Song) IsAvailable() bool {
+	return s.Status == StatusActive
+}
+
+// VisibleTo tells whether the user can see the song, uploaders still see their pulled songs
+func (s *Song) VisibleTo(userId string) bool {
+	return s.IsAvailable() || s.ArtistId.String() == userId
+}
+
 func (s *Song) ChangeSongTitle(newTitle string) {
 	s.Title = newTitle
 }
+
+func NewLyrics(songId uuid.UUID, language, text, source string) (*Lyrics, error) {
+	text = strings.TrimSpace(strings.ReplaceAll(text, "\r\n", "\n"))
+	if text == "" {
+		return nil, ErrEmptyLyrics
+	}
+
+	lyrics := &Lyrics{
+		SongId:   songId,
+		Language: language,
+		Plain:    text,
+		Source:   source,
+	}
+
+	if IsSyncedLyrics(text) {
+		lines, err := ParseLrc(text)
+		if err != nil {
+			return nil, err
+		}
+		lyrics.Synced = FormatLrc(lines)
+		lyrics.Plain = PlainFromLines(lines)
+	}
+
+	return lyrics, nil
+}
+
+// Lines returns the timed lines of synced lyrics, or nil for plain-only lyrics
+func (l *Lyrics) Lines() []LyricLine {
+	if l.Synced == "" {
+		return nil
+	}
+
+	lines, err := ParseLrc(l.Synced)
+	if err != nil {
+		return nil
+	}
+	return lines
+}
@@ -1,6 +1,10 @@
 package song
 
-import "gorm.io/gorm"
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
 
 type SqlSongRepository struct {
 	db *gorm.DB
@@ -17,6 +21,11 @@ func (r *SqlSongRepository) Create(song *Song) (string, error) {
 	return song.Id.String(), nil
 }
 
+// CreateMany adds songs in one statement, so either all of them are created or none
+func (r *SqlSongRepository) CreateMany(songs []*Song) error {
+	return r.db.Create(&songs).Error
+}
+
 func (r *SqlSongRepository) GetById(id string) (Song, error) {
 	var song Song
 	if err := r.db.Preload("Artist").First(&song, "id = ?", id).Error; err != nil {
@@ -27,24 +36,195 @@ func (r *SqlSongRepository) GetById(id string) (Song, error) {
 
 func (r *SqlSongRepository) GetByTitle(title string) ([]Song, error) {
 	var songs []Song
-	if err := r.db.Preload("Artist").Where("title LIKE ?", "%"+title+"%").Find(&songs).Error; err != nil {
+	if err := r.db.Scopes(available).Preload("Artist").Where("title LIKE ?", "%"+title+"%").Find(&songs).Error; err != nil {
 		return nil, err
 	}
 	return songs, nil
 }
 
-func (r *SqlSongRepository) GetByArtistId(id string) ([]Song, error) {
+// GetByArtistId returns an artist's songs, with all also the ones moderation pulled
+func (r *SqlSongRepository) GetByArtistId(id string, all bool) ([]Song, error) {
+	query := r.db.Preload("Artist").Where("artist_id = ?", id)
+	if !all {
+		query = query.Scopes(available)
+	}
+
 	var songs []Song
-	if err := r.db.Preload("Artist").Where("artist_id = ?", id).Find(&songs).Error; err != nil {
+	if err := query.Find(&songs).Error; err != nil {
 		return nil, err
 	}
 	return songs, nil
 }
 
+func (r *SqlSongRepository) SetPublic(id string, public bool) error {
+	return r.db.Model(&Song{}).Where("id = ?", id).Update("public", public).Error
+}
+
 func (r *SqlSongRepository) GetAll() ([]Song, error) {
 	var songs []Song
-	if err := r.db.Preload("Artist").Find(&songs).Error; err != nil {
+	if err := r.db.Scopes(available).Preload("Artist").Find(&songs).Error; err != nil {
 		return nil, err
 	}
 	return songs, nil
 }
+
+// GetByTags returns the songs someone put every one of the tags on
+func (r *SqlSongRepository) GetByTags(tags []string) ([]Song, error) {
+	query := r.db.Scopes(available).Preload("Artist")
+	for _, tag := range tags {
+		query = query.Where("id IN (?)", r.db.Table("song_tags").Select("song_id").Where("name = ?", tag))
+	}
+
+	var songs []Song
+	if err := query.Find(&songs).Error; err != nil {
+		return nil, err
+	}
+	return songs, nil
+}
+
+func (r *SqlSongRepository) SaveLyrics(lyrics *Lyrics) error {
+	return r.db.Save(lyrics).Error
+}
+
+func (r *SqlSongRepository) GetLyrics(songId string) (Lyrics, error) {
+	var lyrics Lyrics
+	if err := r.db.First(&lyrics, "song_id = ?", songId).Error; err != nil {
+		return Lyrics{}, err
+	}
+	return lyrics, nil
+}
+
+func (r *SqlSongRepository) Like(userId, songId string) error {
+	userUUID, err := uuid.Parse(userId)
+	if err != nil {
+		return err
+	}
+	songUUID, err := uuid.Parse(songId)
+	if err != nil {
+		return err
+	}
+
+	// Liking twice is a no-op
+	like := Like{UserId: userUUID, SongId: songUUID}
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&like).Error
+}
+
+func (r *SqlSongRepository) Unlike(userId, songId string) error {
+	return r.db.Where("user_id = ? AND song_id = ?", userId, songId).Delete(&Like{}).Error
+}
+
+func (r *SqlSongRepository) GetLiked(userId string, page, limit int) ([]Song, int64, error) {
+	query := r.db.Model(&Song{}).
+		Scopes(available).
+		Joins("JOIN likes ON likes.song_id = songs.id").
+		Where("likes.user_id = ?", userId)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var songs []Song
+	err := query.Preload("Artist").
+		Order("likes.created_at DESC").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&songs).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return songs, total, nil
+}
+
+func (r *SqlSongRepository) LikeCounts(songIds []uuid.UUID) (map[uuid.UUID]int64, error) {
+	var rows []struct {
+		SongId uuid.UUID
+		Count  int64
+	}
+	err := r.db.Model(&Like{}).
+		Select("song_id, COUNT(*) AS count").
+		Where("song_id IN ?", songIds).
+		Group("song_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[uuid.UUID]int64, len(rows))
+	for _, row := range rows {
+		counts[row.SongId] = row.Count
+	}
+	return counts, nil
+}
+
+func (r *SqlSongRepository) LikedSongIds(userId string, songIds []uuid.UUID) (map[uuid.UUID]bool, error) {
+	var liked []uuid.UUID
+	err := r.db.Model(&Like{}).
+		Where("user_id = ? AND song_id IN ?", userId, songIds).
+		Pluck("song_id", &liked).Error
+	if err != nil {
+		return nil, err
+	}
+
+	set := make(map[uuid.UUID]bool, len(liked))
+	for _, id := range liked {
+		set[id] = true
+	}
+	return set, nil
+}
+
+func (r *SqlSongRepository) SavePosition(position *Position) error {
+	return r.db.Omit("User", "Song").Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "song_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"position", "updated_at"}),
+	}).Create(position).Error
+}
+
+func (r *SqlSongRepository) DeletePosition(userId, songId string) error {
+	return r.db.Where("user_id = ? AND song_id = ?", userId, songId).Delete(&Position{}).Error
+}
+
+// GetInProgress returns the songs the user stopped partway through, most recent first
+func (r *SqlSongRepository) GetInProgress(userId string, page, limit int) ([]Song, int64, error) {
+	query := r.db.Model(&Song{}).
+		Scopes(available).
+		Joins("JOIN song_positions ON song_positions.song_id = songs.id").
+		Where("song_positions.user_id = ?", userId)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var songs []Song
+	err := query.Preload("Artist").
+		Order("song_positions.updated_at DESC").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&songs).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return songs, total, nil
+}
+
+func (r *SqlSongRepository) Positions(userId string, songIds []uuid.UUID) (map[uuid.UUID]int, error) {
+	var rows []Position
+	err := r.db.Select("song_id", "position").
+		Where("user_id = ? AND song_id IN ?", userId, songIds).
+		Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	positions := make(map[uuid.UUID]int, len(rows))
+	for _, row := range rows {
+		positions[row.SongId] = row.Position
+	}
+	return positions, nil
+}
+
+// available leaves out the songs moderation pulled
+func available(db *gorm.DB) *gorm.DB {
+	return db.Where("songs.status = ?", StatusActive)
+}
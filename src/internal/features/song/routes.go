@@ -6,9 +6,40 @@ func SetupRoutes(c *gin.RouterGroup, h *SongHandler, authMiddleware gin.HandlerF
 	c.Use(authMiddleware)
 
 	c.POST("", h.Create)
+	c.POST("/album", h.CreateAlbum)
 	c.GET("", h.GetAll)
 	c.GET("/:id", h.GetById)
 	c.GET("/title", h.GetByTitle)
 	c.GET("/artists/:artistId/songs", h.GetByArtistId)
 	c.GET("/:id/stream", h.StreamSong)
+	c.PUT("/:id/public", h.SetPublic)
+	c.GET("/:id/lyrics", h.GetLyrics)
+	c.PUT("/:id/lyrics", h.SetLyrics)
+	c.POST("/:id/lyrics/extract", h.ExtractLyrics)
+}
+
+func SetupLibraryRoutes(c *gin.RouterGroup, h *SongHandler, authMiddleware gin.HandlerFunc) {
+	c.Use(authMiddleware)
+
+	c.GET("", h.GetLiked)
+	c.PUT("/:songId", h.Like)
+	c.DELETE("/:songId", h.Unlike)
+}
+
+// SetupPositionRoutes lets listeners resume songs where they stopped
+func SetupPositionRoutes(c *gin.RouterGroup, h *SongHandler, authMiddleware gin.HandlerFunc) {
+	c.Use(authMiddleware)
+
+	c.GET("", h.GetInProgress)
+	c.PUT("/:songId", h.SavePosition)
+	c.DELETE("/:songId", h.ClearPosition)
+}
+
+// SetupSharedRoutes streams songs to whoever has a link to them, linkMiddleware checks the
+// link in place of a login
+func SetupSharedRoutes(c *gin.RouterGroup, h *SongHandler, linkMiddleware gin.HandlerFunc) {
+	c.Use(linkMiddleware)
+
+	c.GET("/:token/stream", h.StreamShared)
+	c.GET("/:token/songs/:id/stream", h.StreamShared)
 }
@@ -1,5 +1,16 @@
 package song
 
+import (
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+	audiotags "github.com/yosp313/gotify/src/internal/pkg/audio_tags"
+	"gorm.io/gorm"
+)
+
+var ErrNoTaggedLyrics = errors.New("no lyrics found in audio tags")
+
 type SongService struct {
 	repo SongRepository
 }
@@ -16,6 +27,10 @@ func (s *SongService) Create(song *Song) (string, error) {
 	return id, nil
 }
 
+func (s *SongService) CreateMany(songs []*Song) error {
+	return s.repo.CreateMany(songs)
+}
+
 func (s *SongService) GetById(id string) (Song, error) {
 	song, err := s.repo.GetById(id)
 	if err != nil {
@@ -24,6 +39,23 @@ func (s *SongService) GetById(id string) (Song, error) {
 	return song, nil
 }
 
+// SetPublic makes the song embeddable in other sites, or stops it from being
+func (s *SongService) SetPublic(id string, public bool) error {
+	return s.repo.SetPublic(id, public)
+}
+
+// GetVisible returns a song if the user can see it, as gorm.ErrRecordNotFound otherwise
+func (s *SongService) GetVisible(userId, id string) (Song, error) {
+	song, err := s.repo.GetById(id)
+	if err != nil {
+		return Song{}, err
+	}
+	if !song.VisibleTo(userId) {
+		return Song{}, gorm.ErrRecordNotFound
+	}
+	return song, nil
+}
+
 func (s *SongService) GetByTitle(title string) ([]Song, error) {
 	songs, err := s.repo.GetByTitle(title)
 	if err != nil {
@@ -32,8 +64,10 @@ func (s *SongService) GetByTitle(title string) ([]Song, error) {
 	return songs, nil
 }
 
-func (s *SongService) GetByArtistId(id string) ([]Song, error) {
-	songs, err := s.repo.GetByArtistId(id)
+// GetByArtistId returns an artist's songs, which include the ones moderation pulled when the
+// artist is the one asking
+func (s *SongService) GetByArtistId(userId, id string) ([]Song, error) {
+	songs, err := s.repo.GetByArtistId(id, userId == id)
 	if err != nil {
 		return nil, err
 	}
@@ -47,3 +81,208 @@ func (s *SongService) GetAll() ([]Song, error) {
 	}
 	return songs, nil
 }
+
+// GetByTags returns the songs tagged with all of the tags, which are matched the way the genre
+// package normalizes them: lower case with single spaces
+func (s *SongService) GetByTags(tags []string) ([]Song, error) {
+	normalized := make([]string, len(tags))
+	for i, tag := range tags {
+		normalized[i] = strings.Join(strings.Fields(strings.ToLower(tag)), " ")
+	}
+	return s.repo.GetByTags(normalized)
+}
+
+func (s *SongService) SetLyrics(songId, language, text string) (Lyrics, error) {
+	song, err := s.repo.GetById(songId)
+	if err != nil {
+		return Lyrics{}, err
+	}
+
+	lyrics, err := NewLyrics(song.Id, language, text, LyricsSourceUpload)
+	if err != nil {
+		return Lyrics{}, err
+	}
+
+	if err := s.repo.SaveLyrics(lyrics); err != nil {
+		return Lyrics{}, err
+	}
+	return *lyrics, nil
+}
+
+func (s *SongService) GetLyrics(songId string) (Lyrics, error) {
+	lyrics, err := s.repo.GetLyrics(songId)
+	if err != nil {
+		return Lyrics{}, err
+	}
+	return lyrics, nil
+}
+
+// ImportTaggedLyrics reads SYLT or USLT frames from the song's audio file.
+// Synced lyrics win over plain ones when both are present
+func (s *SongService) ImportTaggedLyrics(song Song, filePath string) (Lyrics, error) {
+	tags, err := audiotags.ReadFile(filePath)
+	if err != nil {
+		return Lyrics{}, err
+	}
+
+	lyrics, err := lyricsFromTags(song, tags)
+	if err != nil {
+		return Lyrics{}, err
+	}
+
+	if err := s.repo.SaveLyrics(lyrics); err != nil {
+		return Lyrics{}, err
+	}
+	return *lyrics, nil
+}
+
+func lyricsFromTags(song Song, tags *audiotags.Tags) (*Lyrics, error) {
+	for _, synced := range tags.SyncedLyrics {
+		// MPEG frame timestamps can't be converted without decoding the stream
+		if synced.TimestampFormat != audiotags.TimestampMilliseconds || len(synced.Lines) == 0 {
+			continue
+		}
+
+		lines := make([]LyricLine, 0, len(synced.Lines))
+		for _, line := range synced.Lines {
+			lines = append(lines, LyricLine{TimeMs: line.Timestamp, Text: strings.Join(strings.Fields(line.Text), " ")})
+		}
+
+		if lyrics, err := NewLyrics(song.Id, synced.Language, FormatLrc(lines), LyricsSourceSylt); err == nil {
+			return lyrics, nil
+		}
+	}
+
+	for _, plain := range tags.Lyrics {
+		if lyrics, err := NewLyrics(song.Id, plain.Language, plain.Text, LyricsSourceUslt); err == nil {
+			return lyrics, nil
+		}
+	}
+
+	return nil, ErrNoTaggedLyrics
+}
+
+func (s *SongService) Like(userId, songId string) (int64, error) {
+	song, err := s.GetVisible(userId, songId)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := s.repo.Like(userId, songId); err != nil {
+		return 0, err
+	}
+	return s.likeCount(song.Id)
+}
+
+func (s *SongService) Unlike(userId, songId string) (int64, error) {
+	song, err := s.repo.GetById(songId)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := s.repo.Unlike(userId, songId); err != nil {
+		return 0, err
+	}
+	return s.likeCount(song.Id)
+}
+
+func (s *SongService) GetLiked(userId string, page, limit int) ([]Song, int64, error) {
+	songs, total, err := s.repo.GetLiked(userId, page, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if err := s.AnnotateForUser(userId, songs); err != nil {
+		return nil, 0, err
+	}
+	return songs, total, nil
+}
+
+// AnnotateForUser fills in the listener-specific fields of each song, such as Liked and LikeCount
+func (s *SongService) AnnotateForUser(userId string, songs []Song) error {
+	if len(songs) == 0 {
+		return nil
+	}
+
+	ids := make([]uuid.UUID, len(songs))
+	for i, song := range songs {
+		ids[i] = song.Id
+	}
+
+	counts, err := s.repo.LikeCounts(ids)
+	if err != nil {
+		return err
+	}
+
+	liked, err := s.repo.LikedSongIds(userId, ids)
+	if err != nil {
+		return err
+	}
+
+	positions, err := s.repo.Positions(userId, ids)
+	if err != nil {
+		return err
+	}
+
+	for i := range songs {
+		songs[i].LikeCount = counts[songs[i].Id]
+		songs[i].Liked = liked[songs[i].Id]
+		if position, ok := positions[songs[i].Id]; ok {
+			songs[i].ResumeAt = &position
+		}
+	}
+	return nil
+}
+
+// SavePosition remembers where the user stopped in a song. Reaching the end or going back
+// to the start clears it instead, so the song plays from the top next time. It returns where
+// the song resumes, nil for the top
+func (s *SongService) SavePosition(userId, songId string, position int) (*int, error) {
+	userUUID, err := uuid.Parse(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	song, err := s.GetVisible(userId, songId)
+	if err != nil {
+		return nil, err
+	}
+
+	if position == 0 || song.IsFinished(position) {
+		return nil, s.repo.DeletePosition(userId, song.Id.String())
+	}
+
+	if err := s.repo.SavePosition(NewPosition(userUUID, song.Id, position)); err != nil {
+		return nil, err
+	}
+	return &position, nil
+}
+
+func (s *SongService) ClearPosition(userId, songId string) error {
+	song, err := s.repo.GetById(songId)
+	if err != nil {
+		return err
+	}
+	return s.repo.DeletePosition(userId, song.Id.String())
+}
+
+// GetInProgress returns the songs the user can resume, most recently listened first
+func (s *SongService) GetInProgress(userId string, page, limit int) ([]Song, int64, error) {
+	songs, total, err := s.repo.GetInProgress(userId, page, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if err := s.AnnotateForUser(userId, songs); err != nil {
+		return nil, 0, err
+	}
+	return songs, total, nil
+}
+
+func (s *SongService) likeCount(songId uuid.UUID) (int64, error) {
+	counts, err := s.repo.LikeCounts([]uuid.UUID{songId})
+	if err != nil {
+		return 0, err
+	}
+	return counts[songId], nil
+}
@@ -0,0 +1,202 @@
+package station
+
+import (
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yosp313/gotify/src/internal/pkg/auth"
+	"github.com/yosp313/gotify/src/internal/pkg/icy"
+	"github.com/yosp313/gotify/src/internal/utils"
+	"gorm.io/gorm"
+)
+
+type StationHandler struct {
+	service *StationService
+}
+
+func NewStationHandler(service *StationService) *StationHandler {
+	return &StationHandler{service: service}
+}
+
+func (h *StationHandler) Create(c *gin.Context) {
+	var settings Settings
+	if err := c.ShouldBindJSON(&settings); err != nil {
+		utils.HandleErrorWithMessage(c, err, "Invalid request body", 400)
+		return
+	}
+
+	station, err := h.service.Create(c.GetString("user_id"), settings)
+	if err != nil {
+		handleStationError(c, err, "Failed to create station")
+		return
+	}
+
+	c.JSON(201, station)
+}
+
+func (h *StationHandler) GetAll(c *gin.Context) {
+	stations, err := h.service.GetVisible(c.GetString("user_id"))
+	if err != nil {
+		utils.HandleErrorWithMessage(c, err, "Failed to retrieve stations", 500)
+		return
+	}
+
+	c.JSON(200, gin.H{"stations": stations})
+}
+
+func (h *StationHandler) GetById(c *gin.Context) {
+	station, err := h.service.Get(c.GetString("user_id"), c.Param("id"))
+	if err != nil {
+		handleStationError(c, err, "Failed to retrieve station")
+		return
+	}
+
+	c.JSON(200, station)
+}
+
+func (h *StationHandler) Update(c *gin.Context) {
+	var settings Settings
+	if err := c.ShouldBindJSON(&settings); err != nil {
+		utils.HandleErrorWithMessage(c, err, "Invalid request body", 400)
+		return
+	}
+
+	station, err := h.service.Update(c.GetString("user_id"), c.Param("id"), settings)
+	if err != nil {
+		handleStationError(c, err, "Failed to update station")
+		return
+	}
+
+	c.JSON(200, station)
+}
+
+func (h *StationHandler) Delete(c *gin.Context) {
+	if err := h.service.Delete(c.GetString("user_id"), c.Param("id")); err != nil {
+		handleStationError(c, err, "Failed to delete station")
+		return
+	}
+
+	c.Status(204)
+}
+
+func (h *StationHandler) CreateStreamToken(c *gin.Context) {
+	station, token, expiresAt, err := h.service.StreamToken(c.GetString("user_id"), c.Param("id"))
+	if err != nil {
+		handleStationError(c, err, "Failed to create stream token")
+		return
+	}
+
+	url := MountUrl(utils.BaseUrl(c), station) + "?stream_token=" + token
+	c.JSON(200, StreamToken{Token: token, Url: url, ExpiresAt: expiresAt})
+}
+
+// Mount finds the station a radio player asked for. Public stations need no login, which
+// most internet radio players can't do anyway. Private ones take the owner's token like the
+// rest of the API as a header, or a stream token in ?stream_token=
+func (h *StationHandler) Mount(authMiddleware gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Players often want a file extension in the URL
+		slug := strings.TrimSuffix(strings.TrimSuffix(c.Param("station"), ".mp3"), ".ogg")
+
+		station, err := h.service.GetBySlug(slug)
+		if err != nil {
+			handleStationError(c, err, "Failed to find station")
+			c.Abort()
+			return
+		}
+		c.Set("station", station)
+
+		if station.Public {
+			return
+		}
+		if token := c.Query("stream_token"); token != "" {
+			userId, err := h.service.AuthorizeStream(token, station)
+			if err != nil {
+				handleStationError(c, err, "Failed to tune in")
+				c.Abort()
+				return
+			}
+			c.Set("user_id", userId)
+			return
+		}
+		// The middleware runs the rest of the chain itself once the token checks out
+		authMiddleware(c)
+	}
+}
+
+// Listen streams the station the way Icecast does, with StreamTitle metadata for players
+// that ask for it on MP3 streams
+func (h *StationHandler) Listen(c *gin.Context) {
+	station := c.MustGet("station").(Station)
+	if !station.CanView(c.GetString("user_id")) {
+		c.JSON(404, gin.H{"error": "Station not found"})
+		return
+	}
+
+	l, err := h.service.Tune(station)
+	if err != nil {
+		handleStationError(c, err, "Failed to tune in")
+		return
+	}
+	defer h.service.Leave(l)
+
+	c.Header("Content-Type", station.ContentType())
+	c.Header("Cache-Control", "no-cache, no-store")
+	c.Header("icy-name", station.Name)
+	c.Header("icy-description", station.Description)
+	if station.Public {
+		c.Header("icy-pub", "1")
+	} else {
+		c.Header("icy-pub", "0")
+	}
+
+	var out io.Writer = c.Writer
+	var meta *icy.Writer
+	if station.Format == FormatMp3 && c.GetHeader("Icy-MetaData") == "1" {
+		c.Header("icy-metaint", strconv.Itoa(icy.MetaInterval))
+		meta = icy.NewWriter(c.Writer, icy.MetaInterval)
+		out = meta
+	}
+	c.Status(200)
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case chunk, ok := <-l.chunks:
+			if !ok {
+				return
+			}
+			if meta != nil {
+				meta.SetTitle(chunk.title)
+			}
+			if _, err := out.Write(chunk.data); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
+
+func handleStationError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound), errors.Is(err, ErrStationHidden):
+		c.JSON(404, gin.H{"error": "Station not found"})
+	case errors.Is(err, ErrNotStationOwner):
+		utils.HandleErrorWithMessage(c, err, "You can only change your own stations", 403)
+	case errors.Is(err, ErrSlugTaken), errors.Is(err, ErrEmptyRotation):
+		utils.HandleErrorWithMessage(c, err, message, 409)
+	case errors.Is(err, auth.ErrInvalidLink):
+		utils.HandleErrorWithMessage(c, err, message, 401)
+	case errors.Is(err, auth.ErrLinkExpired):
+		utils.HandleErrorWithMessage(c, err, message, 410)
+	case errors.Is(err, ErrInvalidSlug), errors.Is(err, ErrInvalidFormat), errors.Is(err, ErrInvalidSource),
+		errors.Is(err, ErrUnplayable):
+		utils.HandleErrorWithMessage(c, err, message, 400)
+	default:
+		utils.HandleErrorWithMessage(c, err, message, 500)
+	}
+}
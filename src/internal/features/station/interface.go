@@ -0,0 +1,20 @@
+package station
+
+import "github.com/google/uuid"
+
+type StationRepository interface {
+	Create(station *Station) error
+	Update(station *Station) error
+	Delete(id string) error
+	GetById(id string) (Station, error)
+	GetBySlug(slug string) (Station, error)
+	GetVisible(userId string) ([]Station, error)
+	SlugTaken(slug, exceptId string) (bool, error)
+	GetSongs(ids []uuid.UUID) ([]Song, error)
+}
+
+// SongSource resolves what a station plays, the playlist feature provides it
+type SongSource interface {
+	SongIds(userId, playlistId string) ([]uuid.UUID, error)
+	RuleSongIds(userId, rules string) ([]uuid.UUID, error)
+}
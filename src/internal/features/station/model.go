@@ -0,0 +1,172 @@
+package station
+
+import (
+	"errors"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Stream formats. Songs are passed through as they are, so a station only plays files in
+// its format
+const (
+	FormatMp3 = "mp3"
+	FormatOgg = "ogg"
+)
+
+// What stream tokens stream
+const TypeStation = "station"
+
+var (
+	ErrNotStationOwner = errors.New("only the owner can change a station")
+	ErrStationHidden   = errors.New("station is private")
+	ErrInvalidSlug     = errors.New("slug must be 2 to 50 lowercase letters, digits or dashes")
+	ErrSlugTaken       = errors.New("slug is already taken")
+	ErrInvalidFormat   = errors.New("format must be mp3 or ogg")
+	ErrInvalidSource   = errors.New("station needs either a playlist or rules")
+	ErrUnplayable      = errors.New("station source can't be played")
+	ErrEmptyRotation   = errors.New("station has no songs to play in its format")
+)
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,49}$`)
+
+// Station is a continuous stream everyone tuned in hears the same way, fed from a playlist
+// or smart playlist rules evaluated for the owner
+type Station struct {
+	Id          uuid.UUID  `json:"id" db:"id" gorm:"primaryKey"`
+	Slug        string     `json:"slug" db:"slug" gorm:"not null;uniqueIndex"`
+	Name        string     `json:"name" db:"name" gorm:"not null"`
+	Description string     `json:"description" db:"description"`
+	OwnerId     uuid.UUID  `json:"owner_id" db:"owner_id" gorm:"not null;index"`
+	PlaylistId  *uuid.UUID `json:"playlist_id" db:"playlist_id"`
+	Rules       string     `json:"rules,omitempty" db:"rules"`
+	Format      string     `json:"format" db:"format" gorm:"not null;default:mp3"`
+	Shuffle     bool       `json:"shuffle" db:"shuffle" gorm:"not null;default:false"`
+	Public      bool       `json:"public" db:"public" gorm:"not null;default:false"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
+
+	// Relationships
+	Owner User `json:"owner" gorm:"foreignKey:OwnerId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+
+	// Live state of the mount, not stored
+	Listeners  int   `json:"listeners" gorm:"-"`
+	NowPlaying *Song `json:"now_playing" gorm:"-"`
+}
+
+// Settings are what the owner chooses for a station
+type Settings struct {
+	Slug        string     `json:"slug" binding:"required"`
+	Name        string     `json:"name" binding:"required,max=100"`
+	Description string     `json:"description" binding:"max=500"`
+	PlaylistId  *uuid.UUID `json:"playlist_id"`
+	Rules       string     `json:"rules"`
+	Format      string     `json:"format"`
+	Shuffle     bool       `json:"shuffle"`
+	Public      bool       `json:"public"`
+}
+
+type Song struct {
+	Id       uuid.UUID `json:"id"`
+	Title    string    `json:"title"`
+	ArtistId uuid.UUID `json:"artist_id"`
+	Duration int       `json:"duration"`
+	Filename string    `json:"-"`
+
+	// Relationships
+	Artist User `json:"artist" gorm:"foreignKey:ArtistId;references:Id"`
+}
+
+// StreamToken lets the owner's player tune into a private station without the session in
+// its URL
+type StreamToken struct {
+	Token     string    `json:"token"`
+	Url       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type User struct {
+	Id       uuid.UUID `json:"id"`
+	FullName string    `json:"full_name"`
+}
+
+func NewStation(ownerId string, settings Settings) (*Station, error) {
+	ownerUUID, err := uuid.Parse(ownerId)
+	if err != nil {
+		return nil, err
+	}
+
+	station := &Station{Id: uuid.New(), OwnerId: ownerUUID}
+	station.Apply(settings)
+	return station, nil
+}
+
+func (s *Station) Apply(settings Settings) {
+	s.Slug = settings.Slug
+	s.Name = settings.Name
+	s.Description = settings.Description
+	s.PlaylistId = settings.PlaylistId
+	s.Rules = strings.TrimSpace(settings.Rules)
+	s.Format = settings.Format
+	s.Shuffle = settings.Shuffle
+	s.Public = settings.Public
+	if s.Format == "" {
+		s.Format = FormatMp3
+	}
+}
+
+func (s *Station) Validate() error {
+	if !slugPattern.MatchString(s.Slug) {
+		return ErrInvalidSlug
+	}
+	if s.Format != FormatMp3 && s.Format != FormatOgg {
+		return ErrInvalidFormat
+	}
+	if (s.PlaylistId == nil) == (s.Rules == "") {
+		return ErrInvalidSource
+	}
+	return nil
+}
+
+func (s *Station) IsOwner(userId string) bool {
+	return s.OwnerId.String() == userId
+}
+
+func (s *Station) CanView(userId string) bool {
+	return s.Public || s.IsOwner(userId)
+}
+
+// MountUrl is where players tune into the station
+func MountUrl(baseUrl string, station Station) string {
+	return baseUrl + "/radio/" + station.Slug + "." + station.Format
+}
+
+func (s *Station) ContentType() string {
+	if s.Format == FormatOgg {
+		return "audio/ogg"
+	}
+	return "audio/mpeg"
+}
+
+// Plays tells whether a song file can go out on the station's stream
+func (s *Station) Plays(song Song) bool {
+	switch strings.ToLower(filepath.Ext(song.Filename)) {
+	case ".mp3":
+		return s.Format == FormatMp3
+	case ".ogg", ".oga", ".opus":
+		return s.Format == FormatOgg
+	default:
+		return false
+	}
+}
+
+// StreamTitle is what internet radio players show for a song
+func (s Song) StreamTitle() string {
+	if s.Artist.FullName == "" {
+		return s.Title
+	}
+	return s.Artist.FullName + " - " + s.Title
+}
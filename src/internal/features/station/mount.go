@@ -0,0 +1,230 @@
+package station
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	audiotags "github.com/yosp313/gotify/src/internal/pkg/audio_tags"
+)
+
+const (
+	chunkSize = 4 << 10
+	// Audio handed to new listeners at once so their players start without waiting
+	burstSize = 64 << 10
+	// Chunks waiting for a slow listener before it gets dropped
+	listenerBuffer = 256
+	// How long a mount keeps going without listeners, so reconnecting players pick up where
+	// they left off
+	idleGrace = 30 * time.Second
+	// Bytes per second assumed for songs without a duration, 128 kbps
+	defaultByteRate = 16000
+	// The stream runs this far ahead of real time so players can fill their buffers
+	lead = 2 * time.Second
+)
+
+var errIdle = errors.New("nobody is listening")
+
+// chunk is a piece of the stream with the title of the song it belongs to, so metadata
+// changes line up with the audio
+type chunk struct {
+	data  []byte
+	title string
+}
+
+type listener struct {
+	chunks chan chunk
+	mount  *mount
+}
+
+// mount reads the rotation once, in real time, and fans the bytes out to every listener
+type mount struct {
+	load func() ([]Song, error)
+
+	mu         sync.Mutex
+	listeners  map[*listener]struct{}
+	burst      []chunk
+	burstBytes int
+	nowPlaying *Song
+	idleSince  time.Time
+	stopped    bool
+	stop       chan struct{}
+}
+
+func newMount(load func() ([]Song, error)) *mount {
+	return &mount{
+		load:      load,
+		listeners: map[*listener]struct{}{},
+		idleSince: time.Now(),
+		stop:      make(chan struct{}),
+	}
+}
+
+// join adds a listener, false when the mount already stopped
+func (m *mount) join() (*listener, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.stopped {
+		return nil, false
+	}
+
+	l := &listener{chunks: make(chan chunk, listenerBuffer), mount: m}
+	for _, c := range m.burst {
+		l.chunks <- c
+	}
+	m.listeners[l] = struct{}{}
+	return l, true
+}
+
+func (m *mount) leave(l *listener) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.listeners[l]; ok {
+		delete(m.listeners, l)
+		close(l.chunks)
+	}
+	if len(m.listeners) == 0 {
+		m.idleSince = time.Now()
+	}
+}
+
+func (m *mount) status() (int, *Song) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.listeners), m.nowPlaying
+}
+
+// shutdown stops the stream and disconnects everyone
+func (m *mount) shutdown() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.stopped {
+		return
+	}
+	m.stopped = true
+	close(m.stop)
+	for l := range m.listeners {
+		delete(m.listeners, l)
+		close(l.chunks)
+	}
+}
+
+// run plays the rotation until nobody listens anymore, the rotation is empty or the mount
+// is shut down
+func (m *mount) run() error {
+	defer m.shutdown()
+
+	// The stream clock, audio is sent as fast as it would play from here
+	started := time.Now()
+	var played time.Duration
+
+	for {
+		rotation, err := m.load()
+		if err != nil {
+			return err
+		}
+		if len(rotation) == 0 {
+			return ErrEmptyRotation
+		}
+
+		audible := false
+		for _, song := range rotation {
+			sent, err := m.play(song, started, &played)
+			if err != nil {
+				return err
+			}
+			audible = audible || sent
+		}
+		// Every file is missing or unreadable
+		if !audible {
+			return ErrEmptyRotation
+		}
+	}
+}
+
+// play streams one song and reports whether any of it went out. A missing or unreadable
+// file is skipped rather than taking the station down
+func (m *mount) play(song Song, started time.Time, played *time.Duration) (bool, error) {
+	file, err := os.Open(filepath.Join("songs", song.Filename))
+	if err != nil {
+		return false, nil
+	}
+	defer file.Close()
+
+	audio, err := audiotags.AudioSection(file)
+	if err != nil {
+		return false, nil
+	}
+
+	byteRate := int64(defaultByteRate)
+	if song.Duration > 0 && audio.Size() > 0 {
+		byteRate = max(audio.Size()/int64(song.Duration), 1)
+	}
+
+	m.mu.Lock()
+	m.nowPlaying = &song
+	m.mu.Unlock()
+
+	title := song.StreamTitle()
+	sent := false
+	for {
+		data := make([]byte, chunkSize)
+		n, err := io.ReadFull(audio, data)
+		if n > 0 {
+			if err := m.broadcast(chunk{data: data[:n], title: title}); err != nil {
+				return sent, err
+			}
+			sent = true
+
+			*played += time.Duration(int64(n) * int64(time.Second) / byteRate)
+			select {
+			case <-m.stop:
+				return sent, nil
+			case <-time.After(time.Until(started.Add(*played - lead))):
+			}
+		}
+		if err != nil {
+			// End of the song, or a read error which skips the rest of it
+			return sent, nil
+		}
+	}
+}
+
+func (m *mount) broadcast(c chunk) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.stopped {
+		return errIdle
+	}
+	if len(m.listeners) == 0 && time.Since(m.idleSince) > idleGrace {
+		return errIdle
+	}
+
+	for l := range m.listeners {
+		select {
+		case l.chunks <- c:
+		default:
+			// Too far behind to catch up, the player will reconnect
+			delete(m.listeners, l)
+			close(l.chunks)
+			if len(m.listeners) == 0 {
+				m.idleSince = time.Now()
+			}
+		}
+	}
+
+	m.burst = append(m.burst, c)
+	m.burstBytes += len(c.data)
+	for m.burstBytes-len(m.burst[0].data) >= burstSize {
+		m.burstBytes -= len(m.burst[0].data)
+		m.burst = m.burst[1:]
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package station
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type SqlStationRepository struct {
+	db *gorm.DB
+}
+
+func NewSqlStationRepository(db *gorm.DB) *SqlStationRepository {
+	return &SqlStationRepository{db: db}
+}
+
+func (r *SqlStationRepository) Create(station *Station) error {
+	return r.db.Omit("Owner").Create(station).Error
+}
+
+func (r *SqlStationRepository) Update(station *Station) error {
+	return r.db.Model(station).
+		Select("slug", "name", "description", "playlist_id", "rules", "format", "shuffle", "public", "updated_at").
+		Updates(station).Error
+}
+
+func (r *SqlStationRepository) Delete(id string) error {
+	return r.db.Delete(&Station{}, "id = ?", id).Error
+}
+
+func (r *SqlStationRepository) GetById(id string) (Station, error) {
+	var station Station
+	if err := r.db.Preload("Owner").First(&station, "id = ?", id).Error; err != nil {
+		return Station{}, err
+	}
+	return station, nil
+}
+
+func (r *SqlStationRepository) GetBySlug(slug string) (Station, error) {
+	var station Station
+	if err := r.db.Preload("Owner").First(&station, "slug = ?", slug).Error; err != nil {
+		return Station{}, err
+	}
+	return station, nil
+}
+
+// GetVisible returns public stations and the user's own
+func (r *SqlStationRepository) GetVisible(userId string) ([]Station, error) {
+	var stations []Station
+	err := r.db.Preload("Owner").
+		Where("public = ? OR owner_id = ?", true, userId).
+		Order("name").
+		Find(&stations).Error
+	if err != nil {
+		return nil, err
+	}
+	return stations, nil
+}
+
+func (r *SqlStationRepository) SlugTaken(slug, exceptId string) (bool, error) {
+	var count int64
+	err := r.db.Model(&Station{}).Where("slug = ? AND id <> ?", slug, exceptId).Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// GetSongs loads songs in the order of ids, ids of songs that are gone are skipped
+func (r *SqlStationRepository) GetSongs(ids []uuid.UUID) ([]Song, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	var found []Song
+	if err := r.db.Scopes(available).Preload("Artist").Where("id IN ?", ids).Find(&found).Error; err != nil {
+		return nil, err
+	}
+
+	byId := make(map[uuid.UUID]Song, len(found))
+	for _, song := range found {
+		byId[song.Id] = song
+	}
+
+	songs := make([]Song, 0, len(ids))
+	for _, id := range ids {
+		if song, ok := byId[id]; ok {
+			songs = append(songs, song)
+		}
+	}
+	return songs, nil
+}
+
+// available leaves out songs moderators quarantined or took down
+func available(db *gorm.DB) *gorm.DB {
+	return db.Where("songs.status = ?", "active")
+}
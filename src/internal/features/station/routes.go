@@ -0,0 +1,20 @@
+package station
+
+import "github.com/gin-gonic/gin"
+
+func SetupRoutes(c *gin.RouterGroup, h *StationHandler, authMiddleware gin.HandlerFunc) {
+	c.Use(authMiddleware)
+
+	c.POST("", h.Create)
+	c.GET("", h.GetAll)
+	c.GET("/:id", h.GetById)
+	c.PUT("/:id", h.Update)
+	c.DELETE("/:id", h.Delete)
+	c.POST("/:id/stream-token", h.CreateStreamToken)
+}
+
+// SetupMountRoutes serves the streams themselves, outside the API so radio players get
+// short URLs
+func SetupMountRoutes(c *gin.RouterGroup, h *StationHandler, authMiddleware gin.HandlerFunc) {
+	c.GET("/:station", h.Mount(authMiddleware), h.Listen)
+}
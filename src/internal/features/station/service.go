@@ -0,0 +1,259 @@
+package station
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yosp313/gotify/src/internal/pkg/auth"
+)
+
+type StationService struct {
+	repo  StationRepository
+	songs SongSource
+	links *auth.LinkSigner
+	// Guards mounts, the running streams by station id
+	mu     sync.Mutex
+	mounts map[uuid.UUID]*mount
+}
+
+func NewStationService(repo StationRepository, songs SongSource, links *auth.LinkSigner) *StationService {
+	return &StationService{repo: repo, songs: songs, links: links, mounts: map[uuid.UUID]*mount{}}
+}
+
+func (s *StationService) Create(userId string, settings Settings) (Station, error) {
+	station, err := NewStation(userId, settings)
+	if err != nil {
+		return Station{}, err
+	}
+	if err := s.check(station); err != nil {
+		return Station{}, err
+	}
+
+	if err := s.repo.Create(station); err != nil {
+		return Station{}, err
+	}
+	return s.Get(userId, station.Id.String())
+}
+
+func (s *StationService) GetVisible(userId string) ([]Station, error) {
+	stations, err := s.repo.GetVisible(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range stations {
+		s.withStatus(&stations[i])
+	}
+	return stations, nil
+}
+
+// StreamToken signs a short-lived link for the user's own player, so it can tune into a
+// private station without the user's session in its URL
+func (s *StationService) StreamToken(userId, id string) (Station, string, time.Time, error) {
+	station, err := s.Get(userId, id)
+	if err != nil {
+		return Station{}, "", time.Time{}, err
+	}
+
+	token, expiresAt := s.links.SignStream(TypeStation, station.Id.String(), userId)
+	return station, token, expiresAt, nil
+}
+
+// AuthorizeStream checks a stream token for the station and returns the user it streams as
+func (s *StationService) AuthorizeStream(token string, station Station) (string, error) {
+	return s.links.ParseStream(token, TypeStation, station.Id.String())
+}
+
+func (s *StationService) Get(userId, id string) (Station, error) {
+	station, err := s.repo.GetById(id)
+	if err != nil {
+		return Station{}, err
+	}
+	if !station.CanView(userId) {
+		return Station{}, ErrStationHidden
+	}
+
+	s.withStatus(&station)
+	return station, nil
+}
+
+// Update changes a station. Listeners stay tuned in and hear the new rotation once the
+// current one ends, unless the format changed which needs a new stream
+func (s *StationService) Update(userId, id string, settings Settings) (Station, error) {
+	station, err := s.owned(userId, id)
+	if err != nil {
+		return Station{}, err
+	}
+
+	format := station.Format
+	station.Apply(settings)
+	if err := s.check(&station); err != nil {
+		return Station{}, err
+	}
+
+	if err := s.repo.Update(&station); err != nil {
+		return Station{}, err
+	}
+	if station.Format != format {
+		s.stopMount(station.Id)
+	}
+	return s.Get(userId, id)
+}
+
+func (s *StationService) Delete(userId, id string) error {
+	station, err := s.owned(userId, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.Delete(id); err != nil {
+		return err
+	}
+	s.stopMount(station.Id)
+	return nil
+}
+
+func (s *StationService) GetBySlug(slug string) (Station, error) {
+	return s.repo.GetBySlug(slug)
+}
+
+// Tune connects a listener to the station's stream, starting the stream if nobody was
+// listening. Everyone tuned in shares the same stream
+func (s *StationService) Tune(station Station) (*listener, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if m, ok := s.mounts[station.Id]; ok {
+		if l, ok := m.join(); ok {
+			return l, nil
+		}
+	}
+
+	// Fail now rather than answering with a stream that ends at once
+	rotation, err := s.rotation(station.Id)
+	if err != nil {
+		return nil, err
+	}
+	if len(rotation) == 0 {
+		return nil, ErrEmptyRotation
+	}
+
+	m := newMount(func() ([]Song, error) {
+		return s.rotation(station.Id)
+	})
+	s.mounts[station.Id] = m
+	go func() {
+		if err := m.run(); err != nil && !errors.Is(err, errIdle) {
+			log.Printf("station: stream of %s stopped: %v", station.Slug, err)
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.mounts[station.Id] == m {
+			delete(s.mounts, station.Id)
+		}
+	}()
+
+	l, _ := m.join()
+	return l, nil
+}
+
+func (s *StationService) Leave(l *listener) {
+	l.mount.leave(l)
+}
+
+// rotation is the station's songs in its format, evaluated for the owner each time the
+// previous rotation ran out so playlist changes are picked up
+func (s *StationService) rotation(id uuid.UUID) ([]Song, error) {
+	station, err := s.repo.GetById(id.String())
+	if err != nil {
+		return nil, err
+	}
+
+	ids, err := s.sourceSongIds(station)
+	if err != nil {
+		return nil, err
+	}
+	songs, err := s.repo.GetSongs(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	rotation := make([]Song, 0, len(songs))
+	for _, song := range songs {
+		if station.Plays(song) {
+			rotation = append(rotation, song)
+		}
+	}
+	if station.Shuffle {
+		rand.Shuffle(len(rotation), func(i, j int) {
+			rotation[i], rotation[j] = rotation[j], rotation[i]
+		})
+	}
+	return rotation, nil
+}
+
+func (s *StationService) sourceSongIds(station Station) ([]uuid.UUID, error) {
+	owner := station.OwnerId.String()
+	if station.PlaylistId != nil {
+		return s.songs.SongIds(owner, station.PlaylistId.String())
+	}
+	return s.songs.RuleSongIds(owner, station.Rules)
+}
+
+// check validates a station before it's saved, including that its source can be played
+func (s *StationService) check(station *Station) error {
+	if err := station.Validate(); err != nil {
+		return err
+	}
+
+	taken, err := s.repo.SlugTaken(station.Slug, station.Id.String())
+	if err != nil {
+		return err
+	}
+	if taken {
+		return ErrSlugTaken
+	}
+
+	if _, err := s.sourceSongIds(*station); err != nil {
+		return fmt.Errorf("%w: %v", ErrUnplayable, err)
+	}
+	return nil
+}
+
+func (s *StationService) withStatus(station *Station) {
+	s.mu.Lock()
+	m, ok := s.mounts[station.Id]
+	s.mu.Unlock()
+
+	if ok {
+		station.Listeners, station.NowPlaying = m.status()
+	}
+}
+
+func (s *StationService) stopMount(id uuid.UUID) {
+	s.mu.Lock()
+	m, ok := s.mounts[id]
+	delete(s.mounts, id)
+	s.mu.Unlock()
+
+	if ok {
+		m.shutdown()
+	}
+}
+
+func (s *StationService) owned(userId, id string) (Station, error) {
+	station, err := s.repo.GetById(id)
+	if err != nil {
+		return Station{}, err
+	}
+	if !station.IsOwner(userId) {
+		return Station{}, ErrNotStationOwner
+	}
+	return station, nil
+}
@@ -0,0 +1,134 @@
+package stats
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yosp313/gotify/src/internal/utils"
+	"gorm.io/gorm"
+)
+
+type StatsHandler struct {
+	service *StatsService
+}
+
+type SummaryQuery struct {
+	From string `form:"from" json:"from" binding:"omitempty,datetime=2006-01-02"`
+	To   string `form:"to" json:"to" binding:"omitempty,datetime=2006-01-02"`
+	Year int    `form:"year" json:"year" binding:"omitempty,min=2000,max=9999"`
+	Top  int    `form:"top" json:"top" binding:"omitempty,min=1,max=50"`
+}
+
+func NewStatsHandler(service *StatsService) *StatsHandler {
+	return &StatsHandler{service: service}
+}
+
+// Range works out the requested days. A year wins over from/to, and
+// without either the range is the current year to date
+func (q SummaryQuery) Range() (time.Time, time.Time) {
+	now := time.Now().UTC()
+
+	if q.Year != 0 {
+		from := time.Date(q.Year, time.January, 1, 0, 0, 0, 0, time.UTC)
+		return from, from.AddDate(1, 0, -1)
+	}
+
+	from := time.Date(now.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)
+	to := now
+	if q.From != "" {
+		from, _ = time.Parse(DayFormat, q.From)
+	}
+	if q.To != "" {
+		to, _ = time.Parse(DayFormat, q.To)
+	}
+	return from, to
+}
+
+func (q SummaryQuery) TopN() int {
+	if q.Top == 0 {
+		return 10
+	}
+	return q.Top
+}
+
+func (h *StatsHandler) GetSummary(c *gin.Context) {
+	var query SummaryQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		utils.HandleErrorWithMessage(c, err, "Invalid query parameters", 400)
+		return
+	}
+
+	from, to := query.Range()
+	summary, err := h.service.GetSummary(c.GetString("user_id"), from, to, query.TopN())
+	if errors.Is(err, ErrInvalidRange) {
+		utils.HandleErrorWithMessage(c, err, "Invalid date range", 400)
+		return
+	}
+	if err != nil {
+		utils.HandleErrorWithMessage(c, err, "Failed to compute listening stats", 500)
+		return
+	}
+
+	c.JSON(200, summary)
+}
+
+func (h *StatsHandler) CreateSnapshot(c *gin.Context) {
+	var query SummaryQuery
+	if err := c.ShouldBindJSON(&query); err != nil {
+		utils.HandleErrorWithMessage(c, err, "Invalid request body", 400)
+		return
+	}
+
+	from, to := query.Range()
+	snapshot, err := h.service.CreateSnapshot(c.GetString("user_id"), from, to, query.TopN())
+	if errors.Is(err, ErrInvalidRange) {
+		utils.HandleErrorWithMessage(c, err, "Invalid date range", 400)
+		return
+	}
+	if err != nil {
+		utils.HandleErrorWithMessage(c, err, "Failed to create stats snapshot", 500)
+		return
+	}
+
+	c.JSON(201, snapshot)
+}
+
+func (h *StatsHandler) GetSnapshots(c *gin.Context) {
+	snapshots, err := h.service.GetSnapshots(c.GetString("user_id"))
+	if err != nil {
+		utils.HandleErrorWithMessage(c, err, "Failed to retrieve stats snapshots", 500)
+		return
+	}
+
+	c.JSON(200, gin.H{"snapshots": snapshots})
+}
+
+func (h *StatsHandler) DeleteSnapshot(c *gin.Context) {
+	deleted, err := h.service.DeleteSnapshot(c.GetString("user_id"), c.Param("id"))
+	if err != nil {
+		utils.HandleErrorWithMessage(c, err, "Failed to delete stats snapshot", 500)
+		return
+	}
+	if !deleted {
+		c.JSON(404, gin.H{"error": "Snapshot not found"})
+		return
+	}
+
+	c.Status(204)
+}
+
+// GetSharedSnapshot is public, snapshot ids are random and only shared on purpose
+func (h *StatsHandler) GetSharedSnapshot(c *gin.Context) {
+	snapshot, err := h.service.GetSnapshot(c.Param("id"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(404, gin.H{"error": "Snapshot not found"})
+		return
+	}
+	if err != nil {
+		utils.HandleErrorWithMessage(c, err, "Failed to retrieve stats snapshot", 500)
+		return
+	}
+
+	c.JSON(200, snapshot)
+}
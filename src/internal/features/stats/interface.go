@@ -0,0 +1,11 @@
+package stats
+
+import "time"
+
+type StatsRepository interface {
+	GetPlays(userId string, from, to time.Time) ([]PlayRow, error)
+	CreateSnapshot(snapshot *Snapshot) error
+	GetSnapshot(id string) (Snapshot, error)
+	GetSnapshots(userId string) ([]Snapshot, error)
+	DeleteSnapshot(userId, id string) (bool, error)
+}
@@ -0,0 +1,222 @@
+package stats
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const DayFormat = "2006-01-02"
+
+// Snapshot freezes a summary so it can be shared, the numbers don't change as more plays come in
+type Snapshot struct {
+	Id        uuid.UUID `json:"id" db:"id" gorm:"primaryKey"`
+	UserId    uuid.UUID `json:"user_id" db:"user_id" gorm:"not null;index"`
+	From      string    `json:"from" db:"from_day" gorm:"column:from_day;size:10;not null"`
+	To        string    `json:"to" db:"to_day" gorm:"column:to_day;size:10;not null"`
+	Summary   string    `json:"-" db:"summary" gorm:"type:text;not null"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+
+	// Relationships
+	User User `json:"-" gorm:"foreignKey:UserId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+type User struct {
+	Id       uuid.UUID
+	FullName string
+}
+
+// PlayRow is a counted play joined with its song and artist
+type PlayRow struct {
+	SongId         uuid.UUID
+	Title          string
+	ArtistId       uuid.UUID
+	ArtistName     string
+	StartedAt      time.Time
+	DurationPlayed int
+}
+
+type TopSong struct {
+	SongId     string  `json:"song_id"`
+	Title      string  `json:"title"`
+	ArtistId   string  `json:"artist_id"`
+	ArtistName string  `json:"artist_name"`
+	Plays      int     `json:"plays"`
+	Minutes    float64 `json:"minutes"`
+}
+
+type TopArtist struct {
+	ArtistId   string  `json:"artist_id"`
+	ArtistName string  `json:"artist_name"`
+	Plays      int     `json:"plays"`
+	Minutes    float64 `json:"minutes"`
+}
+
+type Streak struct {
+	Days int    `json:"days"`
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+}
+
+type Summary struct {
+	From            string      `json:"from"`
+	To              string      `json:"to"`
+	TotalPlays      int         `json:"total_plays"`
+	TotalMinutes    float64     `json:"total_minutes"`
+	DistinctSongs   int         `json:"distinct_songs"`
+	DistinctArtists int         `json:"distinct_artists"`
+	DaysListened    int         `json:"days_listened"`
+	LongestStreak   Streak      `json:"longest_streak"`
+	CurrentStreak   Streak      `json:"current_streak"`
+	TopSongs        []TopSong   `json:"top_songs"`
+	TopArtists      []TopArtist `json:"top_artists"`
+}
+
+type SnapshotResponse struct {
+	Id        string    `json:"id"`
+	UserName  string    `json:"user_name"`
+	CreatedAt time.Time `json:"created_at"`
+	Summary   Summary   `json:"summary"`
+}
+
+func NewSnapshot(userId uuid.UUID, summary Summary) (*Snapshot, error) {
+	data, err := json.Marshal(summary)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Snapshot{
+		Id:      uuid.New(),
+		UserId:  userId,
+		From:    summary.From,
+		To:      summary.To,
+		Summary: string(data),
+	}, nil
+}
+
+// Summarize aggregates plays between the from and to days, keeping the top n songs and artists.
+// Songs and artists are ranked by play count, then by minutes listened
+func Summarize(plays []PlayRow, from, to string, n int) Summary {
+	summary := Summary{From: from, To: to, TopSongs: []TopSong{}, TopArtists: []TopArtist{}}
+
+	songs := map[uuid.UUID]*TopSong{}
+	artists := map[uuid.UUID]*TopArtist{}
+	days := map[string]bool{}
+
+	for _, play := range plays {
+		day := play.StartedAt.UTC().Format(DayFormat)
+		if day < from || day > to {
+			continue
+		}
+		minutes := float64(play.DurationPlayed) / 60
+
+		summary.TotalPlays++
+		summary.TotalMinutes += minutes
+		days[day] = true
+
+		song := songs[play.SongId]
+		if song == nil {
+			song = &TopSong{
+				SongId:     play.SongId.String(),
+				Title:      play.Title,
+				ArtistId:   play.ArtistId.String(),
+				ArtistName: play.ArtistName,
+			}
+			songs[play.SongId] = song
+		}
+		song.Plays++
+		song.Minutes += minutes
+
+		artist := artists[play.ArtistId]
+		if artist == nil {
+			artist = &TopArtist{ArtistId: play.ArtistId.String(), ArtistName: play.ArtistName}
+			artists[play.ArtistId] = artist
+		}
+		artist.Plays++
+		artist.Minutes += minutes
+	}
+
+	summary.TotalMinutes = roundMinutes(summary.TotalMinutes)
+	summary.DistinctSongs = len(songs)
+	summary.DistinctArtists = len(artists)
+	summary.DaysListened = len(days)
+	// For ranges that end in the future, the current streak is counted up to today
+	summary.LongestStreak, summary.CurrentStreak = streaks(days, min(to, time.Now().UTC().Format(DayFormat)))
+
+	for _, song := range songs {
+		song.Minutes = roundMinutes(song.Minutes)
+		summary.TopSongs = append(summary.TopSongs, *song)
+	}
+	sort.Slice(summary.TopSongs, func(i, j int) bool {
+		a, b := summary.TopSongs[i], summary.TopSongs[j]
+		if a.Plays != b.Plays {
+			return a.Plays > b.Plays
+		}
+		if a.Minutes != b.Minutes {
+			return a.Minutes > b.Minutes
+		}
+		return a.Title < b.Title
+	})
+	summary.TopSongs = summary.TopSongs[:min(n, len(summary.TopSongs))]
+
+	for _, artist := range artists {
+		artist.Minutes = roundMinutes(artist.Minutes)
+		summary.TopArtists = append(summary.TopArtists, *artist)
+	}
+	sort.Slice(summary.TopArtists, func(i, j int) bool {
+		a, b := summary.TopArtists[i], summary.TopArtists[j]
+		if a.Plays != b.Plays {
+			return a.Plays > b.Plays
+		}
+		if a.Minutes != b.Minutes {
+			return a.Minutes > b.Minutes
+		}
+		return a.ArtistName < b.ArtistName
+	})
+	summary.TopArtists = summary.TopArtists[:min(n, len(summary.TopArtists))]
+
+	return summary
+}
+
+// streaks finds the longest run of consecutive listening days, and the run ending on the last day
+func streaks(days map[string]bool, lastDay string) (Streak, Streak) {
+	sorted := make([]string, 0, len(days))
+	for day := range days {
+		sorted = append(sorted, day)
+	}
+	sort.Strings(sorted)
+
+	var longest, current Streak
+	for i, day := range sorted {
+		if i > 0 && nextDay(sorted[i-1]) == day {
+			current.Days++
+			current.To = day
+		} else {
+			current = Streak{Days: 1, From: day, To: day}
+		}
+
+		if current.Days > longest.Days {
+			longest = current
+		}
+	}
+
+	// A streak is still going if it includes the last day or the day before it
+	if current.To != lastDay && nextDay(current.To) != lastDay {
+		current = Streak{}
+	}
+	return longest, current
+}
+
+func nextDay(day string) string {
+	t, err := time.Parse(DayFormat, day)
+	if err != nil {
+		return ""
+	}
+	return t.AddDate(0, 0, 1).Format(DayFormat)
+}
+
+func roundMinutes(minutes float64) float64 {
+	return float64(int(minutes*10+0.5)) / 10
+}
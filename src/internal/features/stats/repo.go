@@ -0,0 +1,57 @@
+package stats
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type SqlStatsRepository struct {
+	db *gorm.DB
+}
+
+func NewSqlStatsRepository(db *gorm.DB) *SqlStatsRepository {
+	return &SqlStatsRepository{db: db}
+}
+
+func (r *SqlStatsRepository) GetPlays(userId string, from, to time.Time) ([]PlayRow, error) {
+	var rows []PlayRow
+	err := r.db.Table("plays").
+		Select("plays.song_id, songs.title, songs.artist_id, users.full_name AS artist_name, plays.started_at, plays.duration_played").
+		Joins("JOIN songs ON songs.id = plays.song_id").
+		Joins("JOIN users ON users.id = songs.artist_id").
+		Where("plays.user_id = ? AND plays.counted = ? AND plays.started_at >= ? AND plays.started_at < ?", userId, true, from, to).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func (r *SqlStatsRepository) CreateSnapshot(snapshot *Snapshot) error {
+	return r.db.Create(snapshot).Error
+}
+
+func (r *SqlStatsRepository) GetSnapshot(id string) (Snapshot, error) {
+	var snapshot Snapshot
+	if err := r.db.Preload("User").First(&snapshot, "id = ?", id).Error; err != nil {
+		return Snapshot{}, err
+	}
+	return snapshot, nil
+}
+
+func (r *SqlStatsRepository) GetSnapshots(userId string) ([]Snapshot, error) {
+	var snapshots []Snapshot
+	if err := r.db.Where("user_id = ?", userId).Order("created_at DESC").Find(&snapshots).Error; err != nil {
+		return nil, err
+	}
+	return snapshots, nil
+}
+
+func (r *SqlStatsRepository) DeleteSnapshot(userId, id string) (bool, error) {
+	result := r.db.Where("id = ? AND user_id = ?", id, userId).Delete(&Snapshot{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
@@ -0,0 +1,16 @@
+package stats
+
+import "github.com/gin-gonic/gin"
+
+func SetupRoutes(c *gin.RouterGroup, h *StatsHandler, authMiddleware gin.HandlerFunc) {
+	c.Use(authMiddleware)
+
+	c.GET("", h.GetSummary)
+	c.GET("/snapshots", h.GetSnapshots)
+	c.POST("/snapshots", h.CreateSnapshot)
+	c.DELETE("/snapshots/:id", h.DeleteSnapshot)
+}
+
+func SetupSharedRoutes(c *gin.RouterGroup, h *StatsHandler) {
+	c.GET("/:id", h.GetSharedSnapshot)
+}
@@ -0,0 +1,93 @@
+package stats
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalidRange = errors.New("invalid date range")
+
+type StatsService struct {
+	repo StatsRepository
+}
+
+func NewStatsService(repo StatsRepository) *StatsService {
+	return &StatsService{repo: repo}
+}
+
+// GetSummary summarizes the user's plays between from and to, both inclusive UTC days
+func (s *StatsService) GetSummary(userId string, from, to time.Time, top int) (Summary, error) {
+	from, to = truncateDay(from.UTC()), truncateDay(to.UTC())
+	if to.Before(from) {
+		return Summary{}, ErrInvalidRange
+	}
+
+	// Plays are read a day wider on both sides and trimmed to UTC days when summarizing
+	plays, err := s.repo.GetPlays(userId, from.AddDate(0, 0, -1), to.AddDate(0, 0, 2))
+	if err != nil {
+		return Summary{}, err
+	}
+
+	return Summarize(plays, from.Format(DayFormat), to.Format(DayFormat), top), nil
+}
+
+func (s *StatsService) CreateSnapshot(userId string, from, to time.Time, top int) (SnapshotResponse, error) {
+	userUUID, err := uuid.Parse(userId)
+	if err != nil {
+		return SnapshotResponse{}, err
+	}
+
+	summary, err := s.GetSummary(userId, from, to, top)
+	if err != nil {
+		return SnapshotResponse{}, err
+	}
+
+	snapshot, err := NewSnapshot(userUUID, summary)
+	if err != nil {
+		return SnapshotResponse{}, err
+	}
+
+	if err := s.repo.CreateSnapshot(snapshot); err != nil {
+		return SnapshotResponse{}, err
+	}
+
+	return s.GetSnapshot(snapshot.Id.String())
+}
+
+func (s *StatsService) GetSnapshot(id string) (SnapshotResponse, error) {
+	snapshot, err := s.repo.GetSnapshot(id)
+	if err != nil {
+		return SnapshotResponse{}, err
+	}
+
+	var summary Summary
+	if err := json.Unmarshal([]byte(snapshot.Summary), &summary); err != nil {
+		return SnapshotResponse{}, err
+	}
+
+	return SnapshotResponse{
+		Id:        snapshot.Id.String(),
+		UserName:  snapshot.User.FullName,
+		CreatedAt: snapshot.CreatedAt,
+		Summary:   summary,
+	}, nil
+}
+
+func (s *StatsService) GetSnapshots(userId string) ([]Snapshot, error) {
+	snapshots, err := s.repo.GetSnapshots(userId)
+	if err != nil {
+		return nil, err
+	}
+	return snapshots, nil
+}
+
+func (s *StatsService) DeleteSnapshot(userId, id string) (bool, error) {
+	return s.repo.DeleteSnapshot(userId, id)
+}
+
+func truncateDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
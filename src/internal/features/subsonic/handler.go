@@ -0,0 +1,994 @@
+package subsonic
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	audiotags "github.com/yosp313/gotify/src/internal/pkg/audio_tags"
+	"github.com/yosp313/gotify/src/internal/utils"
+	"gorm.io/gorm"
+)
+
+type SubsonicHandler struct {
+	service *SubsonicService
+}
+
+func NewSubsonicHandler(service *SubsonicService) *SubsonicHandler {
+	return &SubsonicHandler{service: service}
+}
+
+// App passwords
+
+func (h *SubsonicHandler) CreateAppPassword(c *gin.Context) {
+	user, password, err := h.service.CreateAppPassword(c.GetString("user_id"))
+	if err != nil {
+		utils.HandleErrorWithMessage(c, err, "Failed to create app password", 500)
+		return
+	}
+
+	c.JSON(201, gin.H{"username": user.Email, "password": password})
+}
+
+func (h *SubsonicHandler) GetAppPassword(c *gin.Context) {
+	password, err := h.service.GetAppPassword(c.GetString("user_id"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(200, gin.H{"enabled": false})
+		return
+	}
+	if err != nil {
+		utils.HandleErrorWithMessage(c, err, "Failed to retrieve app password", 500)
+		return
+	}
+
+	c.JSON(200, gin.H{"enabled": true, "username": c.GetString("user_email"), "created_at": password.CreatedAt})
+}
+
+func (h *SubsonicHandler) RevokeAppPassword(c *gin.Context) {
+	err := h.service.RevokeAppPassword(c.GetString("user_id"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(404, gin.H{"error": "App password not found"})
+		return
+	}
+	if err != nil {
+		utils.HandleErrorWithMessage(c, err, "Failed to revoke app password", 500)
+		return
+	}
+
+	c.Status(204)
+}
+
+// Authenticate checks the credentials every Subsonic request carries, the username is the
+// account's email and the password its app password
+func (h *SubsonicHandler) Authenticate(c *gin.Context) {
+	username := param(c, "u")
+	password, token, salt := param(c, "p"), param(c, "t"), param(c, "s")
+	if username == "" || (password == "" && (token == "" || salt == "")) {
+		fail(c, CodeMissingParameter, "Required parameter is missing: u and either p or t and s")
+		c.Abort()
+		return
+	}
+
+	user, err := h.service.Authenticate(username, password, token, salt)
+	if err != nil {
+		handleSubsonicError(c, err, "Failed to authenticate")
+		c.Abort()
+		return
+	}
+
+	c.Set("user_id", user.Id.String())
+	c.Set("user_email", user.Email)
+	c.Set("user_full_name", user.FullName)
+}
+
+// System
+
+func (h *SubsonicHandler) Ping(c *gin.Context) {
+	respond(c, NewResponse())
+}
+
+func (h *SubsonicHandler) GetLicense(c *gin.Context) {
+	response := NewResponse()
+	response.License = &License{Valid: true}
+	respond(c, response)
+}
+
+func (h *SubsonicHandler) GetOpenSubsonicExtensions(c *gin.Context) {
+	response := NewResponse()
+	response.OpenSubsonicExtensions = []Extension{{Name: "formPost", Versions: []int{1}}}
+	respond(c, response)
+}
+
+func (h *SubsonicHandler) GetScanStatus(c *gin.Context) {
+	count, err := h.service.CountSongs()
+	if err != nil {
+		handleSubsonicError(c, err, "Failed to count songs")
+		return
+	}
+
+	response := NewResponse()
+	response.ScanStatus = &ScanStatus{Count: count}
+	respond(c, response)
+}
+
+// Browsing
+
+func (h *SubsonicHandler) GetMusicFolders(c *gin.Context) {
+	response := NewResponse()
+	response.MusicFolders = &MusicFolders{MusicFolder: []MusicFolder{{Id: MusicFolderId, Name: MusicFolderName}}}
+	respond(c, response)
+}
+
+func (h *SubsonicHandler) GetIndexes(c *gin.Context) {
+	indexes, err := h.indexes()
+	if err != nil {
+		handleSubsonicError(c, err, "Failed to retrieve artists")
+		return
+	}
+
+	response := NewResponse()
+	response.Indexes = indexes
+	respond(c, response)
+}
+
+func (h *SubsonicHandler) GetArtists(c *gin.Context) {
+	indexes, err := h.indexes()
+	if err != nil {
+		handleSubsonicError(c, err, "Failed to retrieve artists")
+		return
+	}
+
+	response := NewResponse()
+	response.Artists = indexes
+	respond(c, response)
+}
+
+// GetMusicDirectory browses by folders: an artist holds their album, the album their songs
+func (h *SubsonicHandler) GetMusicDirectory(c *gin.Context) {
+	id := param(c, "id")
+	artistId, ok := parseId(id, ArtistPrefix)
+	if !ok {
+		artistId, ok = parseId(id, AlbumPrefix)
+	}
+	if !ok {
+		fail(c, CodeNotFound, "Directory not found")
+		return
+	}
+
+	artist, songs, err := h.service.GetArtist(artistId)
+	if err != nil {
+		handleSubsonicError(c, err, "Failed to retrieve directory")
+		return
+	}
+
+	response := NewResponse()
+	if strings.HasPrefix(id, ArtistPrefix) {
+		album := newAlbumRef(artist)
+		response.Directory = &Directory{
+			Id:   ArtistId(artist.Id),
+			Name: artist.Name,
+			Child: []Child{{
+				Id:       album.Id,
+				Parent:   album.Parent,
+				IsDir:    true,
+				Title:    album.Title,
+				Artist:   album.Artist,
+				CoverArt: album.CoverArt,
+			}},
+		}
+	} else {
+		children, err := h.children(c, songs)
+		if err != nil {
+			handleSubsonicError(c, err, "Failed to retrieve directory")
+			return
+		}
+		response.Directory = &Directory{Id: AlbumId(artist.Id), Parent: ArtistId(artist.Id), Name: AlbumName, Child: children}
+	}
+	respond(c, response)
+}
+
+func (h *SubsonicHandler) GetArtist(c *gin.Context) {
+	artistId, ok := parseId(param(c, "id"), ArtistPrefix)
+	if !ok {
+		fail(c, CodeNotFound, "Artist not found")
+		return
+	}
+
+	artist, _, err := h.service.GetArtist(artistId)
+	if err != nil {
+		handleSubsonicError(c, err, "Failed to retrieve artist")
+		return
+	}
+
+	response := NewResponse()
+	response.Artist = &ArtistWithAlbums{ArtistRef: newArtistRef(artist), Album: []AlbumRef{newAlbumRef(artist)}}
+	respond(c, response)
+}
+
+// GetArtistInfo answers with nothing to tell, clients ask for it on every artist page
+func (h *SubsonicHandler) GetArtistInfo(c *gin.Context) {
+	response := NewResponse()
+	response.ArtistInfo = &ArtistInfo{}
+	respond(c, response)
+}
+
+func (h *SubsonicHandler) GetArtistInfo2(c *gin.Context) {
+	response := NewResponse()
+	response.ArtistInfo2 = &ArtistInfo{}
+	respond(c, response)
+}
+
+func (h *SubsonicHandler) GetAlbum(c *gin.Context) {
+	artistId, ok := parseId(param(c, "id"), AlbumPrefix)
+	if !ok {
+		fail(c, CodeNotFound, "Album not found")
+		return
+	}
+
+	artist, songs, err := h.service.GetArtist(artistId)
+	if err != nil {
+		handleSubsonicError(c, err, "Failed to retrieve album")
+		return
+	}
+
+	children, err := h.children(c, songs)
+	if err != nil {
+		handleSubsonicError(c, err, "Failed to retrieve album")
+		return
+	}
+	for i := range children {
+		children[i].Track = i + 1
+	}
+
+	response := NewResponse()
+	response.Album = &AlbumWithSongs{AlbumRef: newAlbumRef(artist), Song: children}
+	respond(c, response)
+}
+
+func (h *SubsonicHandler) GetSong(c *gin.Context) {
+	song, ok := h.song(c)
+	if !ok {
+		return
+	}
+
+	children, err := h.children(c, []Song{song})
+	if err != nil {
+		handleSubsonicError(c, err, "Failed to retrieve song")
+		return
+	}
+
+	response := NewResponse()
+	response.Song = &children[0]
+	respond(c, response)
+}
+
+func (h *SubsonicHandler) GetGenres(c *gin.Context) {
+	genres, err := h.service.GetGenres()
+	if err != nil {
+		handleSubsonicError(c, err, "Failed to retrieve genres")
+		return
+	}
+
+	response := NewResponse()
+	response.Genres = &Genres{Genre: make([]Genre, len(genres))}
+	for i, genre := range genres {
+		response.Genres.Genre[i] = Genre{Name: genre.Genre, SongCount: genre.SongCount, AlbumCount: genre.ArtistCount}
+	}
+	respond(c, response)
+}
+
+// Lists
+
+func (h *SubsonicHandler) GetAlbumList(c *gin.Context) {
+	albums, ok := h.albumList(c)
+	if !ok {
+		return
+	}
+
+	response := NewResponse()
+	response.AlbumList = albums
+	respond(c, response)
+}
+
+func (h *SubsonicHandler) GetAlbumList2(c *gin.Context) {
+	albums, ok := h.albumList(c)
+	if !ok {
+		return
+	}
+
+	response := NewResponse()
+	response.AlbumList2 = albums
+	respond(c, response)
+}
+
+func (h *SubsonicHandler) GetRandomSongs(c *gin.Context) {
+	songs, err := h.service.GetRandomSongs(intParam(c, "size", 10), param(c, "genre"))
+	if err != nil {
+		handleSubsonicError(c, err, "Failed to retrieve songs")
+		return
+	}
+
+	children, err := h.children(c, songs)
+	if err != nil {
+		handleSubsonicError(c, err, "Failed to retrieve songs")
+		return
+	}
+
+	response := NewResponse()
+	response.RandomSongs = &Songs{Song: children}
+	respond(c, response)
+}
+
+func (h *SubsonicHandler) GetSongsByGenre(c *gin.Context) {
+	genre := param(c, "genre")
+	if genre == "" {
+		fail(c, CodeMissingParameter, "Required parameter is missing: genre")
+		return
+	}
+
+	songs, err := h.service.GetSongsByGenre(genre, intParam(c, "offset", 0), intParam(c, "count", 10))
+	if err != nil {
+		handleSubsonicError(c, err, "Failed to retrieve songs")
+		return
+	}
+
+	children, err := h.children(c, songs)
+	if err != nil {
+		handleSubsonicError(c, err, "Failed to retrieve songs")
+		return
+	}
+
+	response := NewResponse()
+	response.SongsByGenre = &Songs{Song: children}
+	respond(c, response)
+}
+
+func (h *SubsonicHandler) GetNowPlaying(c *gin.Context) {
+	response := NewResponse()
+	response.NowPlaying = &NowPlaying{Entry: []Child{}}
+	respond(c, response)
+}
+
+func (h *SubsonicHandler) GetStarred(c *gin.Context) {
+	starred, ok := h.starred(c)
+	if !ok {
+		return
+	}
+
+	response := NewResponse()
+	response.Starred = starred
+	respond(c, response)
+}
+
+func (h *SubsonicHandler) GetStarred2(c *gin.Context) {
+	starred, ok := h.starred(c)
+	if !ok {
+		return
+	}
+
+	response := NewResponse()
+	response.Starred2 = starred
+	respond(c, response)
+}
+
+// Searching
+
+func (h *SubsonicHandler) Search2(c *gin.Context) {
+	result, ok := h.search(c)
+	if !ok {
+		return
+	}
+
+	response := NewResponse()
+	response.SearchResult2 = result
+	respond(c, response)
+}
+
+func (h *SubsonicHandler) Search3(c *gin.Context) {
+	result, ok := h.search(c)
+	if !ok {
+		return
+	}
+
+	response := NewResponse()
+	response.SearchResult3 = result
+	respond(c, response)
+}
+
+// Playlists
+
+func (h *SubsonicHandler) GetPlaylists(c *gin.Context) {
+	playlists, err := h.service.GetPlaylists(c.GetString("user_id"))
+	if err != nil {
+		handleSubsonicError(c, err, "Failed to retrieve playlists")
+		return
+	}
+
+	response := NewResponse()
+	response.Playlists = &Playlists{Playlist: make([]PlaylistRef, len(playlists))}
+	for i, playlist := range playlists {
+		response.Playlists.Playlist[i] = newPlaylistRef(playlist, playlist.Songs)
+	}
+	respond(c, response)
+}
+
+func (h *SubsonicHandler) GetPlaylist(c *gin.Context) {
+	id, ok := parseId(param(c, "id"), "")
+	if !ok {
+		fail(c, CodeNotFound, "Playlist not found")
+		return
+	}
+
+	playlist, err := h.service.GetPlaylist(c.GetString("user_id"), id)
+	if err != nil {
+		handlePlaylistError(c, err, "Failed to retrieve playlist")
+		return
+	}
+	h.respondPlaylist(c, playlist)
+}
+
+func (h *SubsonicHandler) CreatePlaylist(c *gin.Context) {
+	id := param(c, "playlistId")
+	name := param(c, "name")
+	if id == "" && name == "" {
+		fail(c, CodeMissingParameter, "Required parameter is missing: name or playlistId")
+		return
+	}
+	if _, ok := parseId(id, ""); id != "" && !ok {
+		fail(c, CodeNotFound, "Playlist not found")
+		return
+	}
+	songIds, ok := songIdParams(c, "songId")
+	if !ok {
+		return
+	}
+
+	playlist, err := h.service.CreatePlaylist(c.GetString("user_id"), id, name, songIds)
+	if err != nil {
+		handlePlaylistError(c, err, "Failed to save playlist")
+		return
+	}
+	h.respondPlaylist(c, playlist)
+}
+
+func (h *SubsonicHandler) UpdatePlaylist(c *gin.Context) {
+	id, ok := parseId(param(c, "playlistId"), "")
+	if !ok {
+		fail(c, CodeNotFound, "Playlist not found")
+		return
+	}
+	add, ok := songIdParams(c, "songIdToAdd")
+	if !ok {
+		return
+	}
+
+	var remove []int
+	for _, value := range params(c, "songIndexToRemove") {
+		index, err := strconv.Atoi(value)
+		if err != nil {
+			fail(c, CodeGeneric, "Invalid songIndexToRemove: "+value)
+			return
+		}
+		remove = append(remove, index)
+	}
+
+	var public *bool
+	if value, ok := optionalParam(c, "public"); ok {
+		parsed := value == "true"
+		public = &parsed
+	}
+	name, _ := optionalParam(c, "name")
+	comment, _ := optionalParam(c, "comment")
+
+	err := h.service.UpdatePlaylist(c.GetString("user_id"), id, pointerTo(name), pointerTo(comment), public, add, remove)
+	if err != nil {
+		handlePlaylistError(c, err, "Failed to update playlist")
+		return
+	}
+	respond(c, NewResponse())
+}
+
+func (h *SubsonicHandler) DeletePlaylist(c *gin.Context) {
+	id, ok := parseId(param(c, "id"), "")
+	if !ok {
+		fail(c, CodeNotFound, "Playlist not found")
+		return
+	}
+
+	if err := h.service.DeletePlaylist(c.GetString("user_id"), id); err != nil {
+		handlePlaylistError(c, err, "Failed to delete playlist")
+		return
+	}
+	respond(c, NewResponse())
+}
+
+// Media
+
+// Stream sends the file as uploaded, there's no transcoding so maxBitRate and format are
+// ignored. Plays are estimated from the bytes served like the regular stream endpoint
+func (h *SubsonicHandler) Stream(c *gin.Context) {
+	h.sendFile(c, true)
+}
+
+func (h *SubsonicHandler) Download(c *gin.Context) {
+	h.sendFile(c, false)
+}
+
+// GetCoverArt sends the picture embedded in a song's tags. Albums and artists use the first
+// of their songs that has one
+func (h *SubsonicHandler) GetCoverArt(c *gin.Context) {
+	id := param(c, "id")
+	var picture audiotags.Picture
+	var found bool
+	if artistId, ok := parseId(id, AlbumPrefix); ok {
+		picture, found = h.service.ArtistCover(artistId)
+	} else if artistId, ok := parseId(id, ArtistPrefix); ok {
+		picture, found = h.service.ArtistCover(artistId)
+	} else if songId, ok := parseId(id, ""); ok {
+		if song, err := h.service.GetSong(songId); err == nil {
+			picture, found = h.service.Cover(song)
+		}
+	}
+
+	if !found {
+		fail(c, CodeNotFound, "Cover art not found")
+		return
+	}
+	c.Header("Cache-Control", "public, max-age=3600")
+	c.Data(200, picture.MimeType, picture.Data)
+}
+
+// Annotation
+
+func (h *SubsonicHandler) Star(c *gin.Context) {
+	songIds, ok := songIdParams(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.service.Star(c.GetString("user_id"), uuidStrings(songIds)); err != nil {
+		handleSubsonicError(c, err, "Failed to star")
+		return
+	}
+	respond(c, NewResponse())
+}
+
+func (h *SubsonicHandler) Unstar(c *gin.Context) {
+	songIds, ok := songIdParams(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.service.Unstar(c.GetString("user_id"), uuidStrings(songIds)); err != nil {
+		handleSubsonicError(c, err, "Failed to unstar")
+		return
+	}
+	respond(c, NewResponse())
+}
+
+func (h *SubsonicHandler) Scrobble(c *gin.Context) {
+	songIds, ok := songIdParams(c, "id")
+	if !ok {
+		return
+	}
+	if len(songIds) == 0 {
+		fail(c, CodeMissingParameter, "Required parameter is missing: id")
+		return
+	}
+	if param(c, "submission") == "false" {
+		respond(c, NewResponse())
+		return
+	}
+
+	var times []time.Time
+	for _, value := range params(c, "time") {
+		ms, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			fail(c, CodeGeneric, "Invalid time: "+value)
+			return
+		}
+		times = append(times, time.UnixMilli(ms))
+	}
+
+	if err := h.service.Scrobble(c.GetString("user_id"), uuidStrings(songIds), times, clientName(c)); err != nil {
+		handleSubsonicError(c, err, "Failed to scrobble")
+		return
+	}
+	respond(c, NewResponse())
+}
+
+// User management
+
+func (h *SubsonicHandler) GetUser(c *gin.Context) {
+	user, err := h.service.GetUser(c.GetString("user_id"))
+	if err != nil {
+		handleSubsonicError(c, err, "Failed to retrieve user")
+		return
+	}
+	if username := param(c, "username"); username != "" && !strings.EqualFold(username, user.Email) {
+		fail(c, CodeNotAuthorized, "You can only look up your own user")
+		return
+	}
+
+	response := NewResponse()
+	response.User = &UserInfo{
+		Username:          user.Email,
+		Email:             user.Email,
+		ScrobblingEnabled: true,
+		DownloadRole:      true,
+		PlaylistRole:      true,
+		CoverArtRole:      true,
+		StreamRole:        true,
+		Folder:            []int{MusicFolderId},
+	}
+	respond(c, response)
+}
+
+func (h *SubsonicHandler) indexes() (*Indexes, error) {
+	artists, err := h.service.GetArtists()
+	if err != nil {
+		return nil, err
+	}
+
+	indexes := &Indexes{LastModified: time.Now().UnixMilli(), Index: []Index{}}
+	for _, artist := range artists {
+		name := indexName(artist.Name)
+		if n := len(indexes.Index); n == 0 || indexes.Index[n-1].Name != name {
+			indexes.Index = append(indexes.Index, Index{Name: name})
+		}
+		last := &indexes.Index[len(indexes.Index)-1]
+		last.Artist = append(last.Artist, newArtistRef(artist))
+	}
+	return indexes, nil
+}
+
+func (h *SubsonicHandler) albumList(c *gin.Context) (*AlbumList, bool) {
+	listType := param(c, "type")
+	switch listType {
+	case "":
+		fail(c, CodeMissingParameter, "Required parameter is missing: type")
+		return nil, false
+	case ListByGenre:
+		if param(c, "genre") == "" {
+			fail(c, CodeMissingParameter, "Required parameter is missing: genre")
+			return nil, false
+		}
+	case ListRandom, ListNewest, ListByName, ListByArtist, ListAlphabetical, ListFrequent, ListRecent,
+		ListStarred, ListByYear, ListHighest:
+	default:
+		fail(c, CodeGeneric, "Unknown list type: "+listType)
+		return nil, false
+	}
+
+	artists, err := h.service.GetAlbumList(c.GetString("user_id"), listType, param(c, "genre"),
+		intParam(c, "offset", 0), intParam(c, "size", 10))
+	if err != nil {
+		handleSubsonicError(c, err, "Failed to retrieve albums")
+		return nil, false
+	}
+
+	albums := &AlbumList{Album: make([]AlbumRef, len(artists))}
+	for i, artist := range artists {
+		albums.Album[i] = newAlbumRef(artist)
+	}
+	return albums, true
+}
+
+func (h *SubsonicHandler) starred(c *gin.Context) (*SearchResult, bool) {
+	songs, liked, err := h.service.GetStarred(c.GetString("user_id"))
+	if err != nil {
+		handleSubsonicError(c, err, "Failed to retrieve starred songs")
+		return nil, false
+	}
+
+	result := &SearchResult{Artist: []ArtistRef{}, Album: []AlbumRef{}, Song: make([]Child, len(songs))}
+	for i, song := range songs {
+		starred := liked[song.Id]
+		result.Song[i] = newChild(song, fileSize(song), &starred)
+	}
+	return result, true
+}
+
+// search matches artists and their albums by name and songs by title. Clients sync the whole
+// library by searching for nothing, or for "" in quotes
+func (h *SubsonicHandler) search(c *gin.Context) (*SearchResult, bool) {
+	query := strings.Trim(strings.TrimSpace(param(c, "query")), `"*`)
+
+	artists, err := h.service.SearchArtists(query, intParam(c, "artistOffset", 0), intParam(c, "artistCount", 20))
+	if err != nil {
+		handleSubsonicError(c, err, "Failed to search")
+		return nil, false
+	}
+	albums, err := h.service.SearchArtists(query, intParam(c, "albumOffset", 0), intParam(c, "albumCount", 20))
+	if err != nil {
+		handleSubsonicError(c, err, "Failed to search")
+		return nil, false
+	}
+	songs, err := h.service.SearchSongs(query, intParam(c, "songOffset", 0), intParam(c, "songCount", 20))
+	if err != nil {
+		handleSubsonicError(c, err, "Failed to search")
+		return nil, false
+	}
+
+	children, err := h.children(c, songs)
+	if err != nil {
+		handleSubsonicError(c, err, "Failed to search")
+		return nil, false
+	}
+
+	result := &SearchResult{Artist: make([]ArtistRef, len(artists)), Album: make([]AlbumRef, len(albums)), Song: children}
+	for i, artist := range artists {
+		result.Artist[i] = newArtistRef(artist)
+	}
+	for i, artist := range albums {
+		result.Album[i] = newAlbumRef(artist)
+	}
+	return result, true
+}
+
+// children describes songs, starred when the user liked them
+func (h *SubsonicHandler) children(c *gin.Context, songs []Song) ([]Child, error) {
+	liked, err := h.service.LikeTimes(c.GetString("user_id"), songs)
+	if err != nil {
+		return nil, err
+	}
+
+	children := make([]Child, len(songs))
+	for i, song := range songs {
+		var starred *time.Time
+		if likedAt, ok := liked[song.Id]; ok {
+			starred = &likedAt
+		}
+		children[i] = newChild(song, fileSize(song), starred)
+	}
+	return children, nil
+}
+
+func (h *SubsonicHandler) respondPlaylist(c *gin.Context, playlist Playlist) {
+	children, err := h.children(c, playlist.Songs)
+	if err != nil {
+		handleSubsonicError(c, err, "Failed to retrieve playlist")
+		return
+	}
+
+	response := NewResponse()
+	response.Playlist = &PlaylistWithSongs{PlaylistRef: newPlaylistRef(playlist, playlist.Songs), Entry: children}
+	respond(c, response)
+}
+
+func (h *SubsonicHandler) song(c *gin.Context) (Song, bool) {
+	id, ok := parseId(param(c, "id"), "")
+	if !ok {
+		fail(c, CodeNotFound, "Song not found")
+		return Song{}, false
+	}
+
+	song, err := h.service.GetSong(id)
+	if err != nil {
+		handleSubsonicError(c, err, "Failed to retrieve song")
+		return Song{}, false
+	}
+	return song, true
+}
+
+func (h *SubsonicHandler) sendFile(c *gin.Context, record bool) {
+	song, ok := h.song(c)
+	if !ok {
+		return
+	}
+
+	filePath := filepath.Join("songs", song.Filename)
+	file, err := os.Open(filePath)
+	if err != nil {
+		fail(c, CodeNotFound, "Audio file not found on disk")
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		fail(c, CodeNotFound, "Audio file not found on disk")
+		return
+	}
+
+	// Tracks of a single-file album are cut out of it as they're sent
+	var content io.ReadSeeker = file
+	size := info.Size()
+	if song.IsCut() {
+		clip, err := audiotags.Cut(file, time.Duration(song.StartMs)*time.Millisecond, time.Duration(song.EndMs)*time.Millisecond)
+		if err != nil {
+			fail(c, CodeGeneric, "Failed to cut track from album")
+			return
+		}
+		content, size = clip, clip.Size()
+	}
+
+	if record {
+		from, to := parseByteRange(c.GetHeader("Range"), size)
+		h.service.RecordStream(c.GetString("user_id"), song, clientName(c), from, to, size)
+	} else {
+		c.Header("Content-Disposition", `attachment; filename="`+strings.ReplaceAll(song.Title, `"`, "")+filepath.Ext(song.Filename)+`"`)
+	}
+
+	c.Header("Content-Type", contentType(song.Filename))
+	c.Header("Accept-Ranges", "bytes")
+	http.ServeContent(c.Writer, c.Request, song.Filename, info.ModTime(), content)
+}
+
+// respond answers in the format the client asked for with f, XML by default
+func respond(c *gin.Context, response *Response) {
+	switch param(c, "f") {
+	case "json":
+		c.JSON(200, gin.H{"subsonic-response": response})
+	case "jsonp":
+		c.JSONP(200, gin.H{"subsonic-response": response})
+	default:
+		c.XML(200, response)
+	}
+}
+
+// fail answers with a Subsonic error. Clients read the code from the body, so the status
+// stays 200 the way the Subsonic server does it
+func fail(c *gin.Context, code int, message string) {
+	respond(c, NewErrorResponse(code, message))
+}
+
+// param reads a parameter from the query or, for clients using formPost, the form
+func param(c *gin.Context, name string) string {
+	value, _ := optionalParam(c, name)
+	return value
+}
+
+func optionalParam(c *gin.Context, name string) (string, bool) {
+	if value, ok := c.GetQuery(name); ok {
+		return value, true
+	}
+	return c.GetPostForm(name)
+}
+
+func params(c *gin.Context, name string) []string {
+	return append(c.QueryArray(name), c.PostFormArray(name)...)
+}
+
+// intParam reads a count, offset or size, capped at MaxListSize
+func intParam(c *gin.Context, name string, fallback int) int {
+	value, err := strconv.Atoi(param(c, name))
+	if err != nil || value < 0 {
+		return fallback
+	}
+	return min(value, MaxListSize)
+}
+
+// songIdParams parses song ids, answering with not found when one isn't a song id
+func songIdParams(c *gin.Context, name string) ([]uuid.UUID, bool) {
+	var ids []uuid.UUID
+	for _, value := range params(c, name) {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			fail(c, CodeNotFound, "Song not found: "+value)
+			return nil, false
+		}
+		ids = append(ids, id)
+	}
+	return ids, true
+}
+
+// parseId strips the prefix of an artist or album id and checks what's left is a uuid
+func parseId(id, prefix string) (string, bool) {
+	rest, ok := strings.CutPrefix(id, prefix)
+	if !ok {
+		return "", false
+	}
+	if _, err := uuid.Parse(rest); err != nil {
+		return "", false
+	}
+	return rest, true
+}
+
+func uuidStrings(ids []uuid.UUID) []string {
+	values := make([]string, len(ids))
+	for i, id := range ids {
+		values[i] = id.String()
+	}
+	return values
+}
+
+func pointerTo(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+// indexName is the letter an artist is listed under, anything but a letter goes under #
+func indexName(name string) string {
+	for _, r := range name {
+		if unicode.IsLetter(r) {
+			return strings.ToUpper(string(r))
+		}
+		break
+	}
+	return "#"
+}
+
+// clientName names the client for play history from the c parameter every request carries
+func clientName(c *gin.Context) string {
+	client := param(c, "c")
+	if client == "" {
+		return "subsonic"
+	}
+	return client[:min(len(client), 64)]
+}
+
+func fileSize(song Song) int64 {
+	filePath := filepath.Join("songs", song.Filename)
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return 0
+	}
+	if !song.IsCut() {
+		return info.Size()
+	}
+
+	// Cutting every track of a listing to measure it is too slow, so a track of a single-file
+	// album is sized by its share of the album's length
+	duration, err := audiotags.Duration(filePath)
+	if err != nil || duration <= 0 {
+		return 0
+	}
+	return info.Size() * int64(song.Duration) * 1000 / duration.Milliseconds()
+}
+
+// Helper function to read the first range of a Range header, defaulting to the whole file
+func parseByteRange(header string, size int64) (int64, int64) {
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok {
+		return 0, size - 1
+	}
+	spec, _, _ = strings.Cut(spec, ",")
+
+	startStr, endStr, _ := strings.Cut(strings.TrimSpace(spec), "-")
+	start, startErr := strconv.ParseInt(startStr, 10, 64)
+	end, endErr := strconv.ParseInt(endStr, 10, 64)
+
+	switch {
+	case startErr != nil && endErr == nil:
+		// Suffix range, e.g. bytes=-500 is the last 500 bytes
+		return max(size-end, 0), size - 1
+	case startErr != nil:
+		return 0, size - 1
+	case endErr != nil || end >= size:
+		return start, size - 1
+	default:
+		return start, end
+	}
+}
+
+func handleSubsonicError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound), errors.Is(err, ErrNotFound):
+		fail(c, CodeNotFound, "Requested data was not found")
+	case errors.Is(err, ErrWrongCredentials):
+		fail(c, CodeWrongCredentials, "Wrong username or password")
+	default:
+		fail(c, CodeGeneric, message+": "+err.Error())
+	}
+}
+
+// handlePlaylistError reports what the playlist feature refused, like changing someone else's
+// playlist, as not authorized
+func handlePlaylistError(c *gin.Context, err error, message string) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		fail(c, CodeNotFound, "Playlist not found")
+		return
+	}
+	fail(c, CodeNotAuthorized, message+": "+err.Error())
+}
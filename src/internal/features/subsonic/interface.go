@@ -0,0 +1,56 @@
+package subsonic
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type SubsonicRepository interface {
+	GetAppPassword(userId string) (AppPassword, error)
+	SaveAppPassword(password *AppPassword) error
+	DeleteAppPassword(userId string) (bool, error)
+	GetUserByEmail(email string) (User, error)
+	GetUser(id string) (User, error)
+
+	GetArtists() ([]Artist, error)
+	GetArtist(id string) (Artist, error)
+	GetAlbumList(userId, listType, genre string, offset, size int) ([]Artist, error)
+	SearchArtists(query string, offset, count int) ([]Artist, error)
+
+	GetSong(id string) (Song, error)
+	GetSongs(ids []uuid.UUID) ([]Song, error)
+	GetArtistSongs(artistId string) ([]Song, error)
+	SearchSongs(query string, offset, count int) ([]Song, error)
+	GetRandomSongs(size int, genre string) ([]Song, error)
+	GetSongsByGenre(genre string, offset, count int) ([]Song, error)
+	CountSongs() (int64, error)
+	GetGenres() ([]GenreCount, error)
+	GetLikedSongs(userId string) ([]Song, map[uuid.UUID]time.Time, error)
+	GetLikeTimes(userId string, songIds []uuid.UUID) (map[uuid.UUID]time.Time, error)
+
+	GetPlaylist(id string) (Playlist, error)
+	GetVisiblePlaylists(userId string) ([]Playlist, error)
+}
+
+// PlaylistManager is the playlist feature's service, it checks who may see and change playlists
+type PlaylistManager interface {
+	SongIds(userId, id string) ([]uuid.UUID, error)
+	CreateWithSongs(userId, name string, songIds []uuid.UUID) (uuid.UUID, error)
+	AddSongs(userId, id string, songIds []uuid.UUID) error
+	RemovePositions(userId, id string, positions []int) error
+	SetDetails(userId, id string, name, description *string, public *bool) error
+	Delete(userId, id string) error
+}
+
+// Likes is the song feature's service, starring a song in a client likes it
+type Likes interface {
+	Like(userId, songId string) (int64, error)
+	Unlike(userId, songId string) (int64, error)
+}
+
+// PlayRecorder is the play history feature's service
+type PlayRecorder interface {
+	RecordStream(userId string, songId uuid.UUID, duration int, client string, from, to, size int64)
+	Scrobble(userId, songId string, startedAt time.Time, client string) error
+}
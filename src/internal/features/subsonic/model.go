@@ -0,0 +1,134 @@
+package subsonic
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ApiVersion = "1.16.1"
+	ServerType = "gotify"
+	// Songs carry no album, so each artist's songs are shown as one album under this name
+	AlbumName = "[Unknown Album]"
+	// Everything lives in the one music folder
+	MusicFolderId   = 1
+	MusicFolderName = "Music"
+	// Prefixes telling artist and album ids apart from song ids
+	ArtistPrefix = "ar-"
+	AlbumPrefix  = "al-"
+	// Most items a list endpoint returns at once
+	MaxListSize = 500
+)
+
+// Error codes from the Subsonic API
+const (
+	CodeGeneric          = 0
+	CodeMissingParameter = 10
+	CodeWrongCredentials = 40
+	CodeNotAuthorized    = 50
+	CodeNotFound         = 70
+)
+
+var (
+	ErrWrongCredentials = errors.New("wrong username or password")
+	ErrNotFound         = errors.New("requested data was not found")
+)
+
+// AppPassword lets Subsonic clients sign in. Their token authentication hashes the password
+// with a salt on the client, so the server has to keep it readable and it can't be the
+// account password
+type AppPassword struct {
+	UserId    uuid.UUID `json:"user_id" db:"user_id" gorm:"primaryKey"`
+	Password  string    `json:"-" db:"password" gorm:"not null"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+
+	// Relationships
+	User User `json:"-" gorm:"foreignKey:UserId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+type Song struct {
+	Id        uuid.UUID `json:"id"`
+	Title     string    `json:"title"`
+	ArtistId  uuid.UUID `json:"artist_id"`
+	Filename  string    `json:"-"`
+	Duration  int       `json:"duration"`
+	Genre     string    `json:"genre"`
+	Bpm       int       `json:"bpm"`
+	Track     int       `json:"track"`
+	StartMs   int64     `json:"start_ms"`
+	EndMs     int64     `json:"end_ms"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// Relationships
+	Artist User `json:"artist" gorm:"foreignKey:ArtistId;references:Id"`
+}
+
+type User struct {
+	Id       uuid.UUID `json:"id"`
+	FullName string    `json:"full_name"`
+	Email    string    `json:"-"`
+}
+
+type Playlist struct {
+	Id          uuid.UUID `json:"id"`
+	OwnerId     uuid.UUID `json:"owner_id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Public      bool      `json:"public"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	// Relationships
+	Owner User `json:"owner" gorm:"foreignKey:OwnerId;references:Id"`
+
+	// Songs in playing order, smart playlists evaluated for the user
+	Songs []Song `json:"songs" gorm:"-"`
+}
+
+// Artist is a user with uploaded songs, Subsonic clients browse the library by them
+type Artist struct {
+	Id        uuid.UUID
+	Name      string
+	SongCount int
+	Duration  int
+}
+
+type GenreCount struct {
+	Genre       string
+	SongCount   int
+	ArtistCount int
+}
+
+// Album list types of getAlbumList2, albums being artists here
+const (
+	ListRandom       = "random"
+	ListNewest       = "newest"
+	ListByName       = "alphabeticalByName"
+	ListByArtist     = "alphabeticalByArtist"
+	ListFrequent     = "frequent"
+	ListRecent       = "recent"
+	ListStarred      = "starred"
+	ListByGenre      = "byGenre"
+	ListByYear       = "byYear"
+	ListHighest      = "highest"
+	ListAlphabetical = "alphabetical"
+)
+
+func NewAppPassword(userId uuid.UUID, password string) *AppPassword {
+	return &AppPassword{UserId: userId, Password: password}
+}
+
+func ArtistId(id uuid.UUID) string {
+	return ArtistPrefix + id.String()
+}
+
+func AlbumId(artistId uuid.UUID) string {
+	return AlbumPrefix + artistId.String()
+}
+
+// IsCut tells whether the song is a track of a single-file album rather than its whole file
+func (s *Song) IsCut() bool {
+	return s.StartMs > 0 || s.EndMs > 0
+}
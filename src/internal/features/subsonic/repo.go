@@ -0,0 +1,324 @@
+package subsonic
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// artistColumns sums up each artist's songs, artists without songs are left out by the join
+const artistColumns = "users.id, users.full_name AS name, COUNT(songs.id) AS song_count, COALESCE(SUM(songs.duration), 0) AS duration"
+
+type SqlSubsonicRepository struct {
+	db *gorm.DB
+}
+
+func NewSqlSubsonicRepository(db *gorm.DB) *SqlSubsonicRepository {
+	return &SqlSubsonicRepository{db: db}
+}
+
+func (r *SqlSubsonicRepository) GetAppPassword(userId string) (AppPassword, error) {
+	var password AppPassword
+	if err := r.db.First(&password, "user_id = ?", userId).Error; err != nil {
+		return AppPassword{}, err
+	}
+	return password, nil
+}
+
+// SaveAppPassword replaces the user's app password
+func (r *SqlSubsonicRepository) SaveAppPassword(password *AppPassword) error {
+	return r.db.Omit("User").Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"password", "created_at"}),
+	}).Create(password).Error
+}
+
+func (r *SqlSubsonicRepository) DeleteAppPassword(userId string) (bool, error) {
+	result := r.db.Delete(&AppPassword{}, "user_id = ?", userId)
+	return result.RowsAffected > 0, result.Error
+}
+
+// GetUserByEmail matches case-insensitively, clients tend to capitalize the username field
+func (r *SqlSubsonicRepository) GetUserByEmail(email string) (User, error) {
+	var user User
+	if err := r.db.First(&user, "LOWER(email) = LOWER(?)", email).Error; err != nil {
+		return User{}, err
+	}
+	return user, nil
+}
+
+func (r *SqlSubsonicRepository) GetUser(id string) (User, error) {
+	var user User
+	if err := r.db.First(&user, "id = ?", id).Error; err != nil {
+		return User{}, err
+	}
+	return user, nil
+}
+
+func (r *SqlSubsonicRepository) artists() *gorm.DB {
+	return r.db.Table("users").
+		Select(artistColumns).
+		Joins("JOIN songs ON songs.artist_id = users.id").
+		Scopes(available).
+		Group("users.id, users.full_name")
+}
+
+func (r *SqlSubsonicRepository) GetArtists() ([]Artist, error) {
+	var artists []Artist
+	if err := r.artists().Order("users.full_name").Scan(&artists).Error; err != nil {
+		return nil, err
+	}
+	return artists, nil
+}
+
+func (r *SqlSubsonicRepository) GetArtist(id string) (Artist, error) {
+	var artists []Artist
+	if err := r.artists().Where("users.id = ?", id).Scan(&artists).Error; err != nil {
+		return Artist{}, err
+	}
+	if len(artists) == 0 {
+		return Artist{}, gorm.ErrRecordNotFound
+	}
+	return artists[0], nil
+}
+
+// GetAlbumList pages through artists in the order of a getAlbumList2 type. Frequent and
+// recent only count the user's own plays, starred means the user liked one of their songs
+func (r *SqlSubsonicRepository) GetAlbumList(userId, listType, genre string, offset, size int) ([]Artist, error) {
+	query := r.artists()
+	switch listType {
+	case ListRandom:
+		query = query.Order("RANDOM()")
+	case ListNewest:
+		query = query.Order("MAX(songs.created_at) DESC")
+	case ListFrequent:
+		query = query.Select(artistColumns+", (SELECT COUNT(*) FROM plays JOIN songs played ON played.id = plays.song_id"+
+			" WHERE played.artist_id = users.id AND plays.user_id = ? AND plays.counted = ?) AS play_count", userId, true).
+			Having("play_count > 0").
+			Order("play_count DESC")
+	case ListRecent:
+		query = query.Select(artistColumns+", (SELECT MAX(plays.started_at) FROM plays JOIN songs played ON played.id = plays.song_id"+
+			" WHERE played.artist_id = users.id AND plays.user_id = ?) AS last_played", userId).
+			Having("last_played IS NOT NULL").
+			Order("last_played DESC")
+	case ListStarred:
+		query = query.Where("EXISTS (SELECT 1 FROM likes JOIN songs liked ON liked.id = likes.song_id"+
+			" WHERE liked.artist_id = users.id AND likes.user_id = ?)", userId).
+			Order("users.full_name")
+	case ListByGenre:
+		query = query.Where("EXISTS (SELECT 1 FROM songs tagged WHERE tagged.artist_id = users.id AND tagged.genre = ?)", genre).
+			Order("users.full_name")
+	default:
+		query = query.Order("users.full_name")
+	}
+
+	var artists []Artist
+	if err := query.Offset(offset).Limit(size).Scan(&artists).Error; err != nil {
+		return nil, err
+	}
+	return artists, nil
+}
+
+func (r *SqlSubsonicRepository) SearchArtists(query string, offset, count int) ([]Artist, error) {
+	var artists []Artist
+	err := r.artists().
+		Where("users.full_name LIKE ?", "%"+query+"%").
+		Order("users.full_name").
+		Offset(offset).
+		Limit(count).
+		Scan(&artists).Error
+	if err != nil {
+		return nil, err
+	}
+	return artists, nil
+}
+
+func (r *SqlSubsonicRepository) GetSong(id string) (Song, error) {
+	var song Song
+	if err := r.db.Scopes(available).Preload("Artist").First(&song, "id = ?", id).Error; err != nil {
+		return Song{}, err
+	}
+	return song, nil
+}
+
+// GetSongs loads songs in the order of ids, ids of songs that are gone are skipped
+func (r *SqlSubsonicRepository) GetSongs(ids []uuid.UUID) ([]Song, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	var found []Song
+	if err := r.db.Scopes(available).Preload("Artist").Where("id IN ?", ids).Find(&found).Error; err != nil {
+		return nil, err
+	}
+
+	byId := make(map[uuid.UUID]Song, len(found))
+	for _, song := range found {
+		byId[song.Id] = song
+	}
+
+	songs := make([]Song, 0, len(ids))
+	for _, id := range ids {
+		if song, ok := byId[id]; ok {
+			songs = append(songs, song)
+		}
+	}
+	return songs, nil
+}
+
+// GetArtistSongs returns an artist's songs in upload order, which is their album's track order
+func (r *SqlSubsonicRepository) GetArtistSongs(artistId string) ([]Song, error) {
+	var songs []Song
+	err := r.db.Scopes(available).
+		Preload("Artist").
+		Where("artist_id = ?", artistId).
+		Order("created_at, title").
+		Find(&songs).Error
+	if err != nil {
+		return nil, err
+	}
+	return songs, nil
+}
+
+func (r *SqlSubsonicRepository) SearchSongs(query string, offset, count int) ([]Song, error) {
+	var songs []Song
+	err := r.db.Scopes(available).
+		Preload("Artist").
+		Where("title LIKE ?", "%"+query+"%").
+		Order("title").
+		Offset(offset).
+		Limit(count).
+		Find(&songs).Error
+	if err != nil {
+		return nil, err
+	}
+	return songs, nil
+}
+
+func (r *SqlSubsonicRepository) GetRandomSongs(size int, genre string) ([]Song, error) {
+	query := r.db.Scopes(available).Preload("Artist")
+	if genre != "" {
+		query = query.Where("genre = ?", genre)
+	}
+
+	var songs []Song
+	if err := query.Order("RANDOM()").Limit(size).Find(&songs).Error; err != nil {
+		return nil, err
+	}
+	return songs, nil
+}
+
+func (r *SqlSubsonicRepository) GetSongsByGenre(genre string, offset, count int) ([]Song, error) {
+	var songs []Song
+	err := r.db.Scopes(available).
+		Preload("Artist").
+		Where("genre = ?", genre).
+		Order("title").
+		Offset(offset).
+		Limit(count).
+		Find(&songs).Error
+	if err != nil {
+		return nil, err
+	}
+	return songs, nil
+}
+
+func (r *SqlSubsonicRepository) CountSongs() (int64, error) {
+	var count int64
+	err := r.db.Model(&Song{}).Scopes(available).Count(&count).Error
+	return count, err
+}
+
+func (r *SqlSubsonicRepository) GetGenres() ([]GenreCount, error) {
+	var genres []GenreCount
+	err := r.db.Model(&Song{}).
+		Scopes(available).
+		Select("genre, COUNT(*) AS song_count, COUNT(DISTINCT artist_id) AS artist_count").
+		Where("genre <> ''").
+		Group("genre").
+		Order("genre").
+		Scan(&genres).Error
+	if err != nil {
+		return nil, err
+	}
+	return genres, nil
+}
+
+// GetLikedSongs returns the user's liked songs, latest first, with when each was liked
+func (r *SqlSubsonicRepository) GetLikedSongs(userId string) ([]Song, map[uuid.UUID]time.Time, error) {
+	var songs []Song
+	err := r.db.Scopes(available).
+		Preload("Artist").
+		Joins("JOIN likes ON likes.song_id = songs.id").
+		Where("likes.user_id = ?", userId).
+		Order("likes.created_at DESC").
+		Find(&songs).Error
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ids := make([]uuid.UUID, len(songs))
+	for i, song := range songs {
+		ids[i] = song.Id
+	}
+	liked, err := r.GetLikeTimes(userId, ids)
+	if err != nil {
+		return nil, nil, err
+	}
+	return songs, liked, nil
+}
+
+// GetLikeTimes returns when the user liked each of the songs they liked
+func (r *SqlSubsonicRepository) GetLikeTimes(userId string, songIds []uuid.UUID) (map[uuid.UUID]time.Time, error) {
+	if len(songIds) == 0 {
+		return map[uuid.UUID]time.Time{}, nil
+	}
+
+	var rows []struct {
+		SongId    uuid.UUID
+		CreatedAt time.Time
+	}
+	err := r.db.Table("likes").
+		Select("song_id, created_at").
+		Where("user_id = ? AND song_id IN ?", userId, songIds).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	liked := make(map[uuid.UUID]time.Time, len(rows))
+	for _, row := range rows {
+		liked[row.SongId] = row.CreatedAt
+	}
+	return liked, nil
+}
+
+func (r *SqlSubsonicRepository) GetPlaylist(id string) (Playlist, error) {
+	var playlist Playlist
+	if err := r.db.Preload("Owner").First(&playlist, "id = ?", id).Error; err != nil {
+		return Playlist{}, err
+	}
+	return playlist, nil
+}
+
+// GetVisiblePlaylists returns the user's own playlists, the ones they collaborate on and
+// public ones
+func (r *SqlSubsonicRepository) GetVisiblePlaylists(userId string) ([]Playlist, error) {
+	var playlists []Playlist
+	err := r.db.Preload("Owner").
+		Where("owner_id = ? OR public = ? OR id IN (SELECT playlist_id FROM playlist_collaborators WHERE user_id = ?)",
+			userId, true, userId).
+		Order("name").
+		Find(&playlists).Error
+	if err != nil {
+		return nil, err
+	}
+	return playlists, nil
+}
+
+// available leaves out the songs moderation pulled
+func available(db *gorm.DB) *gorm.DB {
+	return db.Where("songs.status = ?", "active")
+}
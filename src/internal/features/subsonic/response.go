@@ -0,0 +1,329 @@
+package subsonic
+
+import (
+	"encoding/xml"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Response is the subsonic-response envelope every endpoint answers with, as XML by default
+// or JSON when the client asks with f=json
+type Response struct {
+	XMLName       xml.Name `xml:"subsonic-response" json:"-"`
+	Xmlns         string   `xml:"xmlns,attr" json:"-"`
+	Status        string   `xml:"status,attr" json:"status"`
+	Version       string   `xml:"version,attr" json:"version"`
+	Type          string   `xml:"type,attr" json:"type"`
+	ServerVersion string   `xml:"serverVersion,attr" json:"serverVersion"`
+	OpenSubsonic  bool     `xml:"openSubsonic,attr" json:"openSubsonic"`
+
+	Error                  *Error             `xml:"error,omitempty" json:"error,omitempty"`
+	License                *License           `xml:"license,omitempty" json:"license,omitempty"`
+	OpenSubsonicExtensions []Extension        `xml:"openSubsonicExtensions,omitempty" json:"openSubsonicExtensions,omitempty"`
+	MusicFolders           *MusicFolders      `xml:"musicFolders,omitempty" json:"musicFolders,omitempty"`
+	Indexes                *Indexes           `xml:"indexes,omitempty" json:"indexes,omitempty"`
+	Artists                *Indexes           `xml:"artists,omitempty" json:"artists,omitempty"`
+	Directory              *Directory         `xml:"directory,omitempty" json:"directory,omitempty"`
+	Artist                 *ArtistWithAlbums  `xml:"artist,omitempty" json:"artist,omitempty"`
+	ArtistInfo             *ArtistInfo        `xml:"artistInfo,omitempty" json:"artistInfo,omitempty"`
+	ArtistInfo2            *ArtistInfo        `xml:"artistInfo2,omitempty" json:"artistInfo2,omitempty"`
+	Album                  *AlbumWithSongs    `xml:"album,omitempty" json:"album,omitempty"`
+	Song                   *Child             `xml:"song,omitempty" json:"song,omitempty"`
+	AlbumList              *AlbumList         `xml:"albumList,omitempty" json:"albumList,omitempty"`
+	AlbumList2             *AlbumList         `xml:"albumList2,omitempty" json:"albumList2,omitempty"`
+	RandomSongs            *Songs             `xml:"randomSongs,omitempty" json:"randomSongs,omitempty"`
+	SongsByGenre           *Songs             `xml:"songsByGenre,omitempty" json:"songsByGenre,omitempty"`
+	Genres                 *Genres            `xml:"genres,omitempty" json:"genres,omitempty"`
+	SearchResult2          *SearchResult      `xml:"searchResult2,omitempty" json:"searchResult2,omitempty"`
+	SearchResult3          *SearchResult      `xml:"searchResult3,omitempty" json:"searchResult3,omitempty"`
+	Playlists              *Playlists         `xml:"playlists,omitempty" json:"playlists,omitempty"`
+	Playlist               *PlaylistWithSongs `xml:"playlist,omitempty" json:"playlist,omitempty"`
+	Starred                *SearchResult      `xml:"starred,omitempty" json:"starred,omitempty"`
+	Starred2               *SearchResult      `xml:"starred2,omitempty" json:"starred2,omitempty"`
+	NowPlaying             *NowPlaying        `xml:"nowPlaying,omitempty" json:"nowPlaying,omitempty"`
+	ScanStatus             *ScanStatus        `xml:"scanStatus,omitempty" json:"scanStatus,omitempty"`
+	User                   *UserInfo          `xml:"user,omitempty" json:"user,omitempty"`
+}
+
+type Error struct {
+	Code    int    `xml:"code,attr" json:"code"`
+	Message string `xml:"message,attr" json:"message"`
+}
+
+type License struct {
+	Valid bool `xml:"valid,attr" json:"valid"`
+}
+
+type Extension struct {
+	Name     string `xml:"name,attr" json:"name"`
+	Versions []int  `xml:"versions" json:"versions"`
+}
+
+type MusicFolders struct {
+	MusicFolder []MusicFolder `xml:"musicFolder" json:"musicFolder"`
+}
+
+type MusicFolder struct {
+	Id   int    `xml:"id,attr" json:"id"`
+	Name string `xml:"name,attr" json:"name"`
+}
+
+type Indexes struct {
+	IgnoredArticles string  `xml:"ignoredArticles,attr" json:"ignoredArticles"`
+	LastModified    int64   `xml:"lastModified,attr,omitempty" json:"lastModified,omitempty"`
+	Index           []Index `xml:"index" json:"index"`
+}
+
+type Index struct {
+	Name   string      `xml:"name,attr" json:"name"`
+	Artist []ArtistRef `xml:"artist" json:"artist"`
+}
+
+type ArtistRef struct {
+	Id         string `xml:"id,attr" json:"id"`
+	Name       string `xml:"name,attr" json:"name"`
+	CoverArt   string `xml:"coverArt,attr,omitempty" json:"coverArt,omitempty"`
+	AlbumCount int    `xml:"albumCount,attr" json:"albumCount"`
+}
+
+type ArtistWithAlbums struct {
+	ArtistRef
+	Album []AlbumRef `xml:"album" json:"album"`
+}
+
+// ArtistInfo is left empty, there are no biographies or similar artists to tell about
+type ArtistInfo struct{}
+
+type AlbumRef struct {
+	Id        string `xml:"id,attr" json:"id"`
+	Parent    string `xml:"parent,attr,omitempty" json:"parent,omitempty"`
+	IsDir     bool   `xml:"isDir,attr" json:"isDir"`
+	Name      string `xml:"name,attr" json:"name"`
+	Title     string `xml:"title,attr" json:"title"`
+	Artist    string `xml:"artist,attr" json:"artist"`
+	ArtistId  string `xml:"artistId,attr" json:"artistId"`
+	CoverArt  string `xml:"coverArt,attr" json:"coverArt"`
+	SongCount int    `xml:"songCount,attr" json:"songCount"`
+	Duration  int    `xml:"duration,attr" json:"duration"`
+}
+
+type AlbumWithSongs struct {
+	AlbumRef
+	Song []Child `xml:"song" json:"song"`
+}
+
+// Child is a song, or a directory when browsing by folders
+type Child struct {
+	Id          string `xml:"id,attr" json:"id"`
+	Parent      string `xml:"parent,attr,omitempty" json:"parent,omitempty"`
+	IsDir       bool   `xml:"isDir,attr" json:"isDir"`
+	Title       string `xml:"title,attr" json:"title"`
+	Album       string `xml:"album,attr,omitempty" json:"album,omitempty"`
+	Artist      string `xml:"artist,attr,omitempty" json:"artist,omitempty"`
+	Track       int    `xml:"track,attr,omitempty" json:"track,omitempty"`
+	Genre       string `xml:"genre,attr,omitempty" json:"genre,omitempty"`
+	CoverArt    string `xml:"coverArt,attr,omitempty" json:"coverArt,omitempty"`
+	Size        int64  `xml:"size,attr,omitempty" json:"size,omitempty"`
+	ContentType string `xml:"contentType,attr,omitempty" json:"contentType,omitempty"`
+	Suffix      string `xml:"suffix,attr,omitempty" json:"suffix,omitempty"`
+	Duration    int    `xml:"duration,attr,omitempty" json:"duration,omitempty"`
+	Path        string `xml:"path,attr,omitempty" json:"path,omitempty"`
+	Created     string `xml:"created,attr,omitempty" json:"created,omitempty"`
+	Starred     string `xml:"starred,attr,omitempty" json:"starred,omitempty"`
+	AlbumId     string `xml:"albumId,attr,omitempty" json:"albumId,omitempty"`
+	ArtistId    string `xml:"artistId,attr,omitempty" json:"artistId,omitempty"`
+	Type        string `xml:"type,attr,omitempty" json:"type,omitempty"`
+	MediaType   string `xml:"mediaType,attr,omitempty" json:"mediaType,omitempty"`
+	Bpm         int    `xml:"bpm,attr,omitempty" json:"bpm,omitempty"`
+}
+
+type Directory struct {
+	Id     string  `xml:"id,attr" json:"id"`
+	Parent string  `xml:"parent,attr,omitempty" json:"parent,omitempty"`
+	Name   string  `xml:"name,attr" json:"name"`
+	Child  []Child `xml:"child" json:"child"`
+}
+
+type AlbumList struct {
+	Album []AlbumRef `xml:"album" json:"album"`
+}
+
+type Songs struct {
+	Song []Child `xml:"song" json:"song"`
+}
+
+type Genres struct {
+	Genre []Genre `xml:"genre" json:"genre"`
+}
+
+type Genre struct {
+	Name       string `xml:",chardata" json:"value"`
+	SongCount  int    `xml:"songCount,attr" json:"songCount"`
+	AlbumCount int    `xml:"albumCount,attr" json:"albumCount"`
+}
+
+// SearchResult holds search results and starred items, which share a shape
+type SearchResult struct {
+	Artist []ArtistRef `xml:"artist" json:"artist"`
+	Album  []AlbumRef  `xml:"album" json:"album"`
+	Song   []Child     `xml:"song" json:"song"`
+}
+
+type Playlists struct {
+	Playlist []PlaylistRef `xml:"playlist" json:"playlist"`
+}
+
+type PlaylistRef struct {
+	Id        string `xml:"id,attr" json:"id"`
+	Name      string `xml:"name,attr" json:"name"`
+	Comment   string `xml:"comment,attr,omitempty" json:"comment,omitempty"`
+	Owner     string `xml:"owner,attr" json:"owner"`
+	Public    bool   `xml:"public,attr" json:"public"`
+	SongCount int    `xml:"songCount,attr" json:"songCount"`
+	Duration  int    `xml:"duration,attr" json:"duration"`
+	Created   string `xml:"created,attr" json:"created"`
+	Changed   string `xml:"changed,attr" json:"changed"`
+}
+
+type PlaylistWithSongs struct {
+	PlaylistRef
+	Entry []Child `xml:"entry" json:"entry"`
+}
+
+type NowPlaying struct {
+	Entry []Child `xml:"entry" json:"entry"`
+}
+
+type ScanStatus struct {
+	Scanning bool  `xml:"scanning,attr" json:"scanning"`
+	Count    int64 `xml:"count,attr" json:"count"`
+}
+
+type UserInfo struct {
+	Username          string `xml:"username,attr" json:"username"`
+	Email             string `xml:"email,attr" json:"email"`
+	ScrobblingEnabled bool   `xml:"scrobblingEnabled,attr" json:"scrobblingEnabled"`
+	AdminRole         bool   `xml:"adminRole,attr" json:"adminRole"`
+	SettingsRole      bool   `xml:"settingsRole,attr" json:"settingsRole"`
+	DownloadRole      bool   `xml:"downloadRole,attr" json:"downloadRole"`
+	UploadRole        bool   `xml:"uploadRole,attr" json:"uploadRole"`
+	PlaylistRole      bool   `xml:"playlistRole,attr" json:"playlistRole"`
+	CoverArtRole      bool   `xml:"coverArtRole,attr" json:"coverArtRole"`
+	CommentRole       bool   `xml:"commentRole,attr" json:"commentRole"`
+	PodcastRole       bool   `xml:"podcastRole,attr" json:"podcastRole"`
+	StreamRole        bool   `xml:"streamRole,attr" json:"streamRole"`
+	JukeboxRole       bool   `xml:"jukeboxRole,attr" json:"jukeboxRole"`
+	ShareRole         bool   `xml:"shareRole,attr" json:"shareRole"`
+	Folder            []int  `xml:"folder" json:"folder"`
+}
+
+func NewResponse() *Response {
+	return &Response{
+		Xmlns:         "http://subsonic.org/restapi",
+		Status:        "ok",
+		Version:       ApiVersion,
+		Type:          ServerType,
+		ServerVersion: ApiVersion,
+		OpenSubsonic:  true,
+	}
+}
+
+func NewErrorResponse(code int, message string) *Response {
+	response := NewResponse()
+	response.Status = "failed"
+	response.Error = &Error{Code: code, Message: message}
+	return response
+}
+
+func newArtistRef(artist Artist) ArtistRef {
+	return ArtistRef{
+		Id:         ArtistId(artist.Id),
+		Name:       artist.Name,
+		CoverArt:   AlbumId(artist.Id),
+		AlbumCount: 1,
+	}
+}
+
+func newAlbumRef(artist Artist) AlbumRef {
+	return AlbumRef{
+		Id:        AlbumId(artist.Id),
+		Parent:    ArtistId(artist.Id),
+		IsDir:     true,
+		Name:      AlbumName,
+		Title:     AlbumName,
+		Artist:    artist.Name,
+		ArtistId:  ArtistId(artist.Id),
+		CoverArt:  AlbumId(artist.Id),
+		SongCount: artist.SongCount,
+		Duration:  artist.Duration,
+	}
+}
+
+// newChild describes a song, size is the file's size on disk when it's known
+func newChild(song Song, size int64, starred *time.Time) Child {
+	suffix := strings.TrimPrefix(strings.ToLower(filepath.Ext(song.Filename)), ".")
+	child := Child{
+		Id:          song.Id.String(),
+		Parent:      AlbumId(song.ArtistId),
+		Title:       song.Title,
+		Album:       AlbumName,
+		Artist:      song.Artist.FullName,
+		Genre:       song.Genre,
+		CoverArt:    song.Id.String(),
+		Size:        size,
+		ContentType: contentType(song.Filename),
+		Suffix:      suffix,
+		Duration:    song.Duration,
+		Path:        fmt.Sprintf("%s/%s/%s.%s", song.Artist.FullName, AlbumName, song.Title, suffix),
+		Created:     song.CreatedAt.UTC().Format(time.RFC3339),
+		AlbumId:     AlbumId(song.ArtistId),
+		ArtistId:    ArtistId(song.ArtistId),
+		Type:        "music",
+		MediaType:   "song",
+		Bpm:         song.Bpm,
+		Track:       song.Track,
+	}
+	if starred != nil {
+		child.Starred = starred.UTC().Format(time.RFC3339)
+	}
+	return child
+}
+
+func newPlaylistRef(playlist Playlist, songs []Song) PlaylistRef {
+	duration := 0
+	for _, song := range songs {
+		duration += song.Duration
+	}
+
+	return PlaylistRef{
+		Id:        playlist.Id.String(),
+		Name:      playlist.Name,
+		Comment:   playlist.Description,
+		Owner:     playlist.Owner.Email,
+		Public:    playlist.Public,
+		SongCount: len(songs),
+		Duration:  duration,
+		Created:   playlist.CreatedAt.UTC().Format(time.RFC3339),
+		Changed:   playlist.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+// Helper function to get content type based on file extension
+func contentType(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".wav":
+		return "audio/wav"
+	case ".ogg":
+		return "audio/ogg"
+	case ".m4a":
+		return "audio/mp4"
+	case ".aac":
+		return "audio/aac"
+	case ".flac":
+		return "audio/flac"
+	default:
+		return "audio/mpeg"
+	}
+}
@@ -0,0 +1,60 @@
+package subsonic
+
+import "github.com/gin-gonic/gin"
+
+// SetupRoutes serves the Subsonic API under /rest. Clients call each endpoint with or
+// without a .view suffix, by GET or by POST with form parameters
+func SetupRoutes(c *gin.RouterGroup, h *SubsonicHandler) {
+	c.Use(h.Authenticate)
+
+	endpoints := map[string]gin.HandlerFunc{
+		"ping":                      h.Ping,
+		"getLicense":                h.GetLicense,
+		"getOpenSubsonicExtensions": h.GetOpenSubsonicExtensions,
+		"getScanStatus":             h.GetScanStatus,
+		"getMusicFolders":           h.GetMusicFolders,
+		"getIndexes":                h.GetIndexes,
+		"getMusicDirectory":         h.GetMusicDirectory,
+		"getArtists":                h.GetArtists,
+		"getArtist":                 h.GetArtist,
+		"getArtistInfo":             h.GetArtistInfo,
+		"getArtistInfo2":            h.GetArtistInfo2,
+		"getAlbum":                  h.GetAlbum,
+		"getSong":                   h.GetSong,
+		"getGenres":                 h.GetGenres,
+		"getAlbumList":              h.GetAlbumList,
+		"getAlbumList2":             h.GetAlbumList2,
+		"getRandomSongs":            h.GetRandomSongs,
+		"getSongsByGenre":           h.GetSongsByGenre,
+		"getNowPlaying":             h.GetNowPlaying,
+		"getStarred":                h.GetStarred,
+		"getStarred2":               h.GetStarred2,
+		"search2":                   h.Search2,
+		"search3":                   h.Search3,
+		"getPlaylists":              h.GetPlaylists,
+		"getPlaylist":               h.GetPlaylist,
+		"createPlaylist":            h.CreatePlaylist,
+		"updatePlaylist":            h.UpdatePlaylist,
+		"deletePlaylist":            h.DeletePlaylist,
+		"stream":                    h.Stream,
+		"download":                  h.Download,
+		"getCoverArt":               h.GetCoverArt,
+		"star":                      h.Star,
+		"unstar":                    h.Unstar,
+		"scrobble":                  h.Scrobble,
+		"getUser":                   h.GetUser,
+	}
+	for name, handler := range endpoints {
+		c.Match([]string{"GET", "POST"}, "/"+name, handler)
+		c.Match([]string{"GET", "POST"}, "/"+name+".view", handler)
+	}
+}
+
+// SetupAccountRoutes lets users create the app password Subsonic clients sign in with
+func SetupAccountRoutes(c *gin.RouterGroup, h *SubsonicHandler, authMiddleware gin.HandlerFunc) {
+	c.Use(authMiddleware)
+
+	c.GET("", h.GetAppPassword)
+	c.POST("", h.CreateAppPassword)
+	c.DELETE("", h.RevokeAppPassword)
+}
@@ -0,0 +1,335 @@
+package subsonic
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	audiotags "github.com/yosp313/gotify/src/internal/pkg/audio_tags"
+	"gorm.io/gorm"
+)
+
+type SubsonicService struct {
+	repo      SubsonicRepository
+	playlists PlaylistManager
+	likes     Likes
+	plays     PlayRecorder
+}
+
+func NewSubsonicService(repo SubsonicRepository, playlists PlaylistManager, likes Likes, plays PlayRecorder) *SubsonicService {
+	return &SubsonicService{repo: repo, playlists: playlists, likes: likes, plays: plays}
+}
+
+// CreateAppPassword generates a new app password for the user, replacing the old one
+func (s *SubsonicService) CreateAppPassword(userId string) (User, string, error) {
+	user, err := s.repo.GetUser(userId)
+	if err != nil {
+		return User{}, "", err
+	}
+
+	secret := make([]byte, 16)
+	if _, err := rand.Read(secret); err != nil {
+		return User{}, "", err
+	}
+	password := hex.EncodeToString(secret)
+
+	if err := s.repo.SaveAppPassword(NewAppPassword(user.Id, password)); err != nil {
+		return User{}, "", err
+	}
+	return user, password, nil
+}
+
+func (s *SubsonicService) GetAppPassword(userId string) (AppPassword, error) {
+	return s.repo.GetAppPassword(userId)
+}
+
+func (s *SubsonicService) RevokeAppPassword(userId string) error {
+	deleted, err := s.repo.DeleteAppPassword(userId)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Authenticate checks Subsonic credentials against the user's app password. Clients send
+// either the password, plain or hex encoded after "enc:", or md5(password + salt) as a
+// token along with the salt
+func (s *SubsonicService) Authenticate(username, password, token, salt string) (User, error) {
+	user, err := s.repo.GetUserByEmail(username)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return User{}, ErrWrongCredentials
+	}
+	if err != nil {
+		return User{}, err
+	}
+
+	appPassword, err := s.repo.GetAppPassword(user.Id.String())
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return User{}, ErrWrongCredentials
+	}
+	if err != nil {
+		return User{}, err
+	}
+
+	var expected, given string
+	if token != "" {
+		sum := md5.Sum([]byte(appPassword.Password + salt))
+		expected, given = hex.EncodeToString(sum[:]), strings.ToLower(token)
+	} else {
+		expected, given = appPassword.Password, password
+		if encoded, ok := strings.CutPrefix(password, "enc:"); ok {
+			decoded, err := hex.DecodeString(encoded)
+			if err != nil {
+				return User{}, ErrWrongCredentials
+			}
+			given = string(decoded)
+		}
+	}
+
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(given)) != 1 {
+		return User{}, ErrWrongCredentials
+	}
+	return user, nil
+}
+
+func (s *SubsonicService) GetUser(userId string) (User, error) {
+	return s.repo.GetUser(userId)
+}
+
+func (s *SubsonicService) GetArtists() ([]Artist, error) {
+	return s.repo.GetArtists()
+}
+
+// GetArtist returns an artist with their songs, which make up their one album
+func (s *SubsonicService) GetArtist(artistId string) (Artist, []Song, error) {
+	artist, err := s.repo.GetArtist(artistId)
+	if err != nil {
+		return Artist{}, nil, err
+	}
+
+	songs, err := s.repo.GetArtistSongs(artistId)
+	if err != nil {
+		return Artist{}, nil, err
+	}
+	return artist, songs, nil
+}
+
+func (s *SubsonicService) GetAlbumList(userId, listType, genre string, offset, size int) ([]Artist, error) {
+	return s.repo.GetAlbumList(userId, listType, genre, offset, size)
+}
+
+func (s *SubsonicService) SearchArtists(query string, offset, count int) ([]Artist, error) {
+	if count == 0 {
+		return nil, nil
+	}
+	return s.repo.SearchArtists(query, offset, count)
+}
+
+func (s *SubsonicService) SearchSongs(query string, offset, count int) ([]Song, error) {
+	if count == 0 {
+		return nil, nil
+	}
+	return s.repo.SearchSongs(query, offset, count)
+}
+
+func (s *SubsonicService) GetSong(id string) (Song, error) {
+	return s.repo.GetSong(id)
+}
+
+func (s *SubsonicService) GetRandomSongs(size int, genre string) ([]Song, error) {
+	return s.repo.GetRandomSongs(size, genre)
+}
+
+func (s *SubsonicService) GetSongsByGenre(genre string, offset, count int) ([]Song, error) {
+	return s.repo.GetSongsByGenre(genre, offset, count)
+}
+
+func (s *SubsonicService) GetGenres() ([]GenreCount, error) {
+	return s.repo.GetGenres()
+}
+
+func (s *SubsonicService) CountSongs() (int64, error) {
+	return s.repo.CountSongs()
+}
+
+func (s *SubsonicService) GetStarred(userId string) ([]Song, map[uuid.UUID]time.Time, error) {
+	return s.repo.GetLikedSongs(userId)
+}
+
+// LikeTimes tells which of the songs the user liked and when, clients show them as starred
+func (s *SubsonicService) LikeTimes(userId string, songs []Song) (map[uuid.UUID]time.Time, error) {
+	ids := make([]uuid.UUID, len(songs))
+	for i, song := range songs {
+		ids[i] = song.Id
+	}
+	return s.repo.GetLikeTimes(userId, ids)
+}
+
+// Cover reads the cover art embedded in a song's file
+func (s *SubsonicService) Cover(song Song) (audiotags.Picture, bool) {
+	tags, err := audiotags.ReadFile(filepath.Join("songs", song.Filename))
+	if err != nil {
+		return audiotags.Picture{}, false
+	}
+	return tags.Cover()
+}
+
+// ArtistCover is the cover of the first of the artist's songs that has one
+func (s *SubsonicService) ArtistCover(artistId string) (audiotags.Picture, bool) {
+	songs, err := s.repo.GetArtistSongs(artistId)
+	if err != nil {
+		return audiotags.Picture{}, false
+	}
+
+	for _, song := range songs {
+		if picture, ok := s.Cover(song); ok {
+			return picture, true
+		}
+	}
+	return audiotags.Picture{}, false
+}
+
+func (s *SubsonicService) Star(userId string, songIds []string) error {
+	for _, id := range songIds {
+		if _, err := s.likes.Like(userId, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SubsonicService) Unstar(userId string, songIds []string) error {
+	for _, id := range songIds {
+		if _, err := s.likes.Unlike(userId, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Scrobble records plays clients report as listened through. Now playing notifications,
+// sent with submission=false, aren't kept
+func (s *SubsonicService) Scrobble(userId string, songIds []string, times []time.Time, client string) error {
+	for i, id := range songIds {
+		startedAt := time.Now()
+		if i < len(times) {
+			startedAt = times[i]
+		}
+		if err := s.plays.Scrobble(userId, id, startedAt, client); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SubsonicService) RecordStream(userId string, song Song, client string, from, to, size int64) {
+	s.plays.RecordStream(userId, song.Id, song.Duration, client, from, to, size)
+}
+
+// GetPlaylists returns the playlists the user can see with their songs, smart playlists
+// evaluated for the user
+func (s *SubsonicService) GetPlaylists(userId string) ([]Playlist, error) {
+	playlists, err := s.repo.GetVisiblePlaylists(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	visible := make([]Playlist, 0, len(playlists))
+	for _, playlist := range playlists {
+		songs, err := s.playlistSongs(userId, playlist.Id.String())
+		if err != nil {
+			// Smart playlists with rules that no longer parse are skipped
+			continue
+		}
+		playlist.Songs = songs
+		visible = append(visible, playlist)
+	}
+	return visible, nil
+}
+
+func (s *SubsonicService) GetPlaylist(userId, id string) (Playlist, error) {
+	songs, err := s.playlistSongs(userId, id)
+	if err != nil {
+		return Playlist{}, err
+	}
+
+	playlist, err := s.repo.GetPlaylist(id)
+	if err != nil {
+		return Playlist{}, err
+	}
+	playlist.Songs = songs
+	return playlist, nil
+}
+
+// CreatePlaylist creates a playlist, or replaces the songs of an existing one when id isn't
+// empty, the way createPlaylist does both
+func (s *SubsonicService) CreatePlaylist(userId, id, name string, songIds []uuid.UUID) (Playlist, error) {
+	if id == "" {
+		created, err := s.playlists.CreateWithSongs(userId, name, songIds)
+		if err != nil {
+			return Playlist{}, err
+		}
+		return s.GetPlaylist(userId, created.String())
+	}
+
+	current, err := s.playlists.SongIds(userId, id)
+	if err != nil {
+		return Playlist{}, err
+	}
+	positions := make([]int, len(current))
+	for i := range positions {
+		positions[i] = i
+	}
+	if err := s.playlists.RemovePositions(userId, id, positions); err != nil {
+		return Playlist{}, err
+	}
+	if err := s.playlists.AddSongs(userId, id, songIds); err != nil {
+		return Playlist{}, err
+	}
+	if name != "" {
+		if err := s.playlists.SetDetails(userId, id, &name, nil, nil); err != nil {
+			return Playlist{}, err
+		}
+	}
+	return s.GetPlaylist(userId, id)
+}
+
+// UpdatePlaylist changes the details that are given, then removes songs by their position
+// before the change and appends the new ones
+func (s *SubsonicService) UpdatePlaylist(userId, id string, name, comment *string, public *bool, add []uuid.UUID, remove []int) error {
+	if name != nil || comment != nil || public != nil {
+		if err := s.playlists.SetDetails(userId, id, name, comment, public); err != nil {
+			return err
+		}
+	}
+	if len(remove) > 0 {
+		if err := s.playlists.RemovePositions(userId, id, remove); err != nil {
+			return err
+		}
+	}
+	if len(add) > 0 {
+		return s.playlists.AddSongs(userId, id, add)
+	}
+	return nil
+}
+
+func (s *SubsonicService) DeletePlaylist(userId, id string) error {
+	return s.playlists.Delete(userId, id)
+}
+
+func (s *SubsonicService) playlistSongs(userId, id string) ([]Song, error) {
+	ids, err := s.playlists.SongIds(userId, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetSongs(ids)
+}
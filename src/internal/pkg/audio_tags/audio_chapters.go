@@ -0,0 +1,430 @@
+package audiotags
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+var ErrNoChapters = errors.New("no chapters found")
+
+// maxSamples caps how many samples of a chapter track are read, chapter tracks hold a few
+// dozen and anything near this is a broken or hostile file
+const maxSamples = 1 << 16
+
+// Chapter is a marked section of an audio file, as audiobooks and long recordings carry
+type Chapter struct {
+	Title   string
+	StartMs int64
+	// EndMs is 0 when the file doesn't say, the chapter then runs until the next one
+	EndMs int64
+
+	// ID3 element id, to order chapters by the table of contents
+	element string
+}
+
+// ReadChapters reads the chapter markers of an audio file: ID3 CHAP frames for MP3, and for
+// MP4/M4B the QuickTime chapter track or else Nero chpl atom
+func ReadChapters(filePath string) ([]Chapter, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	head := make([]byte, 8)
+	if _, err := io.ReadFull(file, head); err != nil {
+		return nil, ErrNoChapters
+	}
+
+	var chapters []Chapter
+	switch {
+	case string(head[:3]) == "ID3":
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		tags, err := Read(file)
+		if err != nil {
+			return nil, err
+		}
+		chapters = tags.Chapters
+	case string(head[4:8]) == "ftyp":
+		chapters = mp4Chapters(file, info.Size())
+	}
+
+	if len(chapters) == 0 {
+		return nil, ErrNoChapters
+	}
+	return chapters, nil
+}
+
+// parseChap reads a CHAP frame: element id, start and end times in milliseconds, byte
+// offsets we don't need, then embedded frames holding the title
+func (t *Tags) parseChap(data []byte) (Chapter, bool) {
+	element, rest := splitTerminated(0, data)
+	if len(rest) < 16 {
+		return Chapter{}, false
+	}
+
+	chapter := Chapter{
+		element: string(element),
+		StartMs: int64(binary.BigEndian.Uint32(rest[0:4])),
+		EndMs:   int64(binary.BigEndian.Uint32(rest[4:8])),
+	}
+
+	embedded := &Tags{Version: t.Version, Frames: map[string]string{}, embedded: true}
+	embedded.parseFrames(rest[16:])
+	chapter.Title = embedded.Title()
+	return chapter, true
+}
+
+// parseCtoc reads a CTOC frame, keeping the entries of the top-level table of contents
+func (t *Tags) parseCtoc(data []byte) {
+	_, rest := splitTerminated(0, data)
+	if len(rest) < 2 {
+		return
+	}
+
+	topLevel := rest[0]&0x02 != 0
+	count := int(rest[1])
+	rest = rest[2:]
+	if !topLevel {
+		return
+	}
+
+	toc := make([]string, 0, count)
+	for i := 0; i < count && len(rest) > 0; i++ {
+		var entry []byte
+		entry, rest = splitTerminated(0, rest)
+		toc = append(toc, string(entry))
+	}
+	t.toc = toc
+}
+
+// orderChapters puts chapters in table of contents order when the tag has one, otherwise
+// by start time
+func (t *Tags) orderChapters() {
+	if len(t.toc) == 0 {
+		sort.SliceStable(t.Chapters, func(i, j int) bool {
+			return t.Chapters[i].StartMs < t.Chapters[j].StartMs
+		})
+		return
+	}
+
+	byElement := make(map[string]Chapter, len(t.Chapters))
+	for _, chapter := range t.Chapters {
+		byElement[chapter.element] = chapter
+	}
+
+	ordered := make([]Chapter, 0, len(t.toc))
+	for _, element := range t.toc {
+		if chapter, ok := byElement[element]; ok {
+			ordered = append(ordered, chapter)
+		}
+	}
+	t.Chapters = ordered
+}
+
+// mp4Chapters prefers the QuickTime chapter track iTunes and most audiobook tools write,
+// falling back to the Nero chpl atom
+func mp4Chapters(r io.ReaderAt, size int64) []Chapter {
+	moov, err := FindMp4Box(r, 0, size, "moov")
+	if err != nil {
+		return nil
+	}
+
+	if chapters := quickTimeChapters(r, moov); len(chapters) > 0 {
+		return chapters
+	}
+	return neroChapters(r, moov)
+}
+
+// neroChapters reads moov/udta/chpl, chapter starts in 100 nanosecond units with
+// length-prefixed titles
+func neroChapters(r io.ReaderAt, moov Mp4Box) []Chapter {
+	data, err := readMp4Path(r, moov, "udta", "chpl")
+	if err != nil || len(data) < 5 {
+		return nil
+	}
+
+	version := data[0]
+	data = data[4:]
+	if version > 0 {
+		if len(data) < 4 {
+			return nil
+		}
+		data = data[4:]
+	}
+	if len(data) < 1 {
+		return nil
+	}
+	count := int(data[0])
+	data = data[1:]
+
+	chapters := make([]Chapter, 0, count)
+	for i := 0; i < count && len(data) >= 9; i++ {
+		start := binary.BigEndian.Uint64(data[:8])
+		length := int(data[8])
+		data = data[9:]
+		if len(data) < length {
+			break
+		}
+		chapters = append(chapters, Chapter{Title: string(data[:length]), StartMs: int64(start / 10000)})
+		data = data[length:]
+	}
+	return chapters
+}
+
+// quickTimeChapters finds the text track a track's tref/chap points at and reads its
+// samples, each of which is a chapter title lasting the sample's duration
+func quickTimeChapters(r io.ReaderAt, moov Mp4Box) []Chapter {
+	traks := mp4Children(r, moov, "trak")
+
+	var chapterTrackIds []uint32
+	for _, trak := range traks {
+		if chap, err := readMp4Path(r, trak, "tref", "chap"); err == nil {
+			for i := 0; i+4 <= len(chap); i += 4 {
+				chapterTrackIds = append(chapterTrackIds, binary.BigEndian.Uint32(chap[i:i+4]))
+			}
+		}
+	}
+	if len(chapterTrackIds) == 0 {
+		return nil
+	}
+
+	for _, trak := range traks {
+		id, ok := mp4TrackId(r, trak)
+		if !ok || id != chapterTrackIds[0] {
+			continue
+		}
+		return textTrackChapters(r, trak)
+	}
+	return nil
+}
+
+func mp4TrackId(r io.ReaderAt, trak Mp4Box) (uint32, bool) {
+	tkhd, err := readMp4Path(r, trak, "tkhd")
+	if err != nil || len(tkhd) < 24 {
+		return 0, false
+	}
+	if tkhd[0] == 1 {
+		return binary.BigEndian.Uint32(tkhd[20:24]), true
+	}
+	return binary.BigEndian.Uint32(tkhd[12:16]), true
+}
+
+func textTrackChapters(r io.ReaderAt, trak Mp4Box) []Chapter {
+	mdhd, err := readMp4Path(r, trak, "mdia", "mdhd")
+	if err != nil || len(mdhd) < 20 {
+		return nil
+	}
+	var timescale uint32
+	if mdhd[0] == 1 {
+		if len(mdhd) < 24 {
+			return nil
+		}
+		timescale = binary.BigEndian.Uint32(mdhd[20:24])
+	} else {
+		timescale = binary.BigEndian.Uint32(mdhd[12:16])
+	}
+	if timescale == 0 {
+		return nil
+	}
+
+	stts, _ := readMp4Path(r, trak, "mdia", "minf", "stbl", "stts")
+	stsz, _ := readMp4Path(r, trak, "mdia", "minf", "stbl", "stsz")
+	stsc, _ := readMp4Path(r, trak, "mdia", "minf", "stbl", "stsc")
+	offsets := chunkOffsets(r, trak)
+
+	durations := sampleTable(stts, 8, func(entry []byte, add func(uint64) bool) {
+		// Each entry is a run of samples sharing a duration
+		delta := uint64(binary.BigEndian.Uint32(entry[4:8]))
+		for n := min(binary.BigEndian.Uint32(entry[:4]), maxSamples); n > 0; n-- {
+			if !add(delta) {
+				return
+			}
+		}
+	})
+	sizes := sampleSizes(stsz)
+	if len(durations) == 0 || len(sizes) == 0 || len(offsets) == 0 || len(stsc) < 8 {
+		return nil
+	}
+
+	// Lay samples out in their chunks following stsc: first chunk, samples per chunk. Entries
+	// run in chunk order, so no chunk is walked twice however the table is made
+	var sampleOffsets []int64
+	next := 1
+	entries := int(binary.BigEndian.Uint32(stsc[4:8]))
+	for i := 0; i < entries && 8+i*12+12 <= len(stsc) && len(sampleOffsets) < len(sizes); i++ {
+		entry := stsc[8+i*12:]
+		first := int(binary.BigEndian.Uint32(entry[0:4]))
+		perChunk := int(binary.BigEndian.Uint32(entry[4:8]))
+		last := len(offsets)
+		if i+1 < entries && 8+(i+1)*12+4 <= len(stsc) {
+			last = int(binary.BigEndian.Uint32(stsc[8+(i+1)*12:])) - 1
+		}
+		for chunk := max(first, next); chunk <= last && chunk <= len(offsets) && len(sampleOffsets) < len(sizes); chunk++ {
+			next = chunk + 1
+			offset := offsets[chunk-1]
+			for s := 0; s < perChunk && len(sampleOffsets) < len(sizes); s++ {
+				sampleOffsets = append(sampleOffsets, offset)
+				offset += int64(sizes[len(sampleOffsets)-1])
+			}
+		}
+	}
+
+	var chapters []Chapter
+	var at uint64
+	for i := 0; i < len(sampleOffsets) && i < len(durations); i++ {
+		start := int64(at * 1000 / uint64(timescale))
+		at += durations[i]
+		end := int64(at * 1000 / uint64(timescale))
+
+		title, ok := readTextSample(r, sampleOffsets[i], sizes[i])
+		if !ok {
+			continue
+		}
+		chapters = append(chapters, Chapter{Title: title, StartMs: start, EndMs: end})
+	}
+	return chapters
+}
+
+// readTextSample reads a QuickTime text sample: a 16-bit length then the text, UTF-8 or
+// UTF-16 with a byte order mark
+func readTextSample(r io.ReaderAt, offset int64, size uint32) (string, bool) {
+	if size < 2 || size > 1<<16 {
+		return "", false
+	}
+	data := make([]byte, size)
+	if _, err := r.ReadAt(data, offset); err != nil {
+		return "", false
+	}
+
+	length := int(binary.BigEndian.Uint16(data[:2]))
+	if 2+length > len(data) {
+		return "", false
+	}
+	text := data[2 : 2+length]
+
+	if bytes.HasPrefix(text, []byte{0xfe, 0xff}) && len(text)%2 == 0 {
+		units := make([]uint16, 0, len(text)/2-1)
+		for i := 2; i+1 < len(text); i += 2 {
+			units = append(units, binary.BigEndian.Uint16(text[i:i+2]))
+		}
+		return strings.TrimSpace(string(utf16.Decode(units))), true
+	}
+	return strings.TrimSpace(string(text)), true
+}
+
+func chunkOffsets(r io.ReaderAt, trak Mp4Box) []int64 {
+	if stco, err := readMp4Path(r, trak, "mdia", "minf", "stbl", "stco"); err == nil {
+		return toOffsets(sampleTable(stco, 4, func(entry []byte, add func(uint64) bool) {
+			add(uint64(binary.BigEndian.Uint32(entry)))
+		}))
+	}
+	if co64, err := readMp4Path(r, trak, "mdia", "minf", "stbl", "co64"); err == nil {
+		return toOffsets(sampleTable(co64, 8, func(entry []byte, add func(uint64) bool) {
+			add(binary.BigEndian.Uint64(entry))
+		}))
+	}
+	return nil
+}
+
+func toOffsets(values []uint64) []int64 {
+	offsets := make([]int64, len(values))
+	for i, v := range values {
+		offsets[i] = int64(v)
+	}
+	return offsets
+}
+
+func sampleSizes(stsz []byte) []uint32 {
+	if len(stsz) < 12 {
+		return nil
+	}
+	uniform := binary.BigEndian.Uint32(stsz[4:8])
+	count := int(binary.BigEndian.Uint32(stsz[8:12]))
+	if count > maxSamples {
+		return nil
+	}
+
+	sizes := make([]uint32, 0, count)
+	for i := 0; i < count; i++ {
+		if uniform != 0 {
+			sizes = append(sizes, uniform)
+			continue
+		}
+		if 12+i*4+4 > len(stsz) {
+			break
+		}
+		sizes = append(sizes, binary.BigEndian.Uint32(stsz[12+i*4:]))
+	}
+	return sizes
+}
+
+// sampleTable walks the entries of a full box holding a count then fixed-size entries. It
+// stops once maxSamples values were added, add reports whether there's room for more
+func sampleTable(data []byte, entrySize int, each func(entry []byte, add func(uint64) bool)) []uint64 {
+	if len(data) < 8 {
+		return nil
+	}
+
+	var values []uint64
+	add := func(v uint64) bool {
+		if len(values) >= maxSamples {
+			return false
+		}
+		values = append(values, v)
+		return true
+	}
+	count := int(binary.BigEndian.Uint32(data[4:8]))
+	for i := 0; i < count && len(values) < maxSamples && 8+(i+1)*entrySize <= len(data); i++ {
+		each(data[8+i*entrySize:8+(i+1)*entrySize], add)
+	}
+	return values
+}
+
+// readMp4Path descends from box through the given child types and reads the last one
+func readMp4Path(r io.ReaderAt, box Mp4Box, path ...string) ([]byte, error) {
+	for _, kind := range path {
+		child, err := FindMp4Box(r, box.DataOffset, box.DataOffset+box.DataSize, kind)
+		if err != nil {
+			return nil, err
+		}
+		box = child
+	}
+	if box.DataSize > 1<<24 {
+		return nil, ErrNoChapters
+	}
+
+	data := make([]byte, box.DataSize)
+	if _, err := r.ReadAt(data, box.DataOffset); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// mp4Children lists the children of box with the given type
+func mp4Children(r io.ReaderAt, box Mp4Box, kind string) []Mp4Box {
+	var children []Mp4Box
+	start, end := box.DataOffset, box.DataOffset+box.DataSize
+	for start < end {
+		child, err := FindMp4Box(r, start, end, kind)
+		if err != nil {
+			break
+		}
+		children = append(children, child)
+		start = child.DataOffset + child.DataSize
+	}
+	return children
+}
@@ -0,0 +1,86 @@
+package audiotags
+
+import (
+	"bytes"
+	"encoding/binary"
+	"runtime/debug"
+	"testing"
+)
+
+// id3Frame builds an ID3v2.3 frame, whose size isn't syncsafe
+func id3Frame(id string, data []byte) []byte {
+	frame := make([]byte, 10, 10+len(data))
+	copy(frame, id)
+	binary.BigEndian.PutUint32(frame[4:8], uint32(len(data)))
+	return append(frame, data...)
+}
+
+// id3Tag wraps frames in an ID3v2.3 tag header
+func id3Tag(frames []byte) []byte {
+	size := len(frames)
+	header := []byte{'I', 'D', '3', 3, 0, 0, byte(size >> 21 & 0x7f), byte(size >> 14 & 0x7f), byte(size >> 7 & 0x7f), byte(size & 0x7f)}
+	return append(header, frames...)
+}
+
+// chapData is the body of a CHAP frame up to its embedded frames
+func chapData(element string, startMs, endMs uint32) []byte {
+	data := append([]byte(element), 0)
+	times := make([]byte, 16)
+	binary.BigEndian.PutUint32(times[0:4], startMs)
+	binary.BigEndian.PutUint32(times[4:8], endMs)
+	return append(data, times...)
+}
+
+func TestReadChapters(t *testing.T) {
+	title := func(text string) []byte { return id3Frame("TIT2", append([]byte{3}, text...)) }
+
+	var frames []byte
+	frames = append(frames, id3Frame("CTOC", []byte("toc\x00\x03\x02ch2\x00ch1\x00"))...)
+	frames = append(frames, id3Frame("CHAP", append(chapData("ch1", 60000, 120000), title("Second")...))...)
+	frames = append(frames, id3Frame("CHAP", append(chapData("ch2", 0, 60000), title("First")...))...)
+
+	tags, err := Read(bytes.NewReader(id3Tag(frames)))
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if len(tags.Chapters) != 2 {
+		t.Fatalf("got %d chapters, want 2", len(tags.Chapters))
+	}
+	if tags.Chapters[0].Title != "First" || tags.Chapters[0].StartMs != 0 || tags.Chapters[0].EndMs != 60000 {
+		t.Errorf("first chapter = %+v, want the table of contents order", tags.Chapters[0])
+	}
+	if tags.Chapters[1].Title != "Second" || tags.Chapters[1].StartMs != 60000 {
+		t.Errorf("second chapter = %+v", tags.Chapters[1])
+	}
+}
+
+func TestReadNestedChapters(t *testing.T) {
+	// Chapters nested this deep used to overflow the stack, which no recover catches. A small
+	// stack makes that happen with a small tag
+	defer debug.SetMaxStack(debug.SetMaxStack(4 << 20))
+
+	const depth = 100000
+	prefix := chapData("c", 0, 1000)
+	inner := id3Frame("TIT2", append([]byte{3}, "Innermost"...))
+
+	frames := make([]byte, 0, depth*(10+len(prefix))+len(inner))
+	for level := 0; level < depth; level++ {
+		size := (depth-level)*(10+len(prefix)) + len(inner) - 10
+		header := make([]byte, 10)
+		copy(header, "CHAP")
+		binary.BigEndian.PutUint32(header[4:8], uint32(size))
+		frames = append(append(frames, header...), prefix...)
+	}
+	frames = append(frames, inner...)
+
+	tags, err := Read(bytes.NewReader(id3Tag(frames)))
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if len(tags.Chapters) != 1 {
+		t.Fatalf("got %d chapters, want only the outer one", len(tags.Chapters))
+	}
+	if tags.Chapters[0].Title != "" {
+		t.Errorf("Title = %q, titles of nested chapters must not be read", tags.Chapters[0].Title)
+	}
+}
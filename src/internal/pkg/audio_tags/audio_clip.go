@@ -0,0 +1,387 @@
+package audiotags
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"time"
+)
+
+var ErrCannotCut = errors.New("only WAV and FLAC files can be cut")
+
+// Clip is a stretch of an audio file that plays on its own: a fresh header followed by the
+// file's audio between two points in time. It seeks, so it can answer range requests
+type Clip struct {
+	header []byte
+	audio  *io.SectionReader
+	offset int64
+}
+
+// Cut makes a clip of a WAV or FLAC file from start to end, an end of 0 running to the end of
+// the file. WAV cuts are sample exact. FLAC is cut on frame boundaries without decoding, so a
+// clip can start up to one frame (usually under a tenth of a second) early. Clips cut at the
+// same point meet exactly, with nothing lost or repeated between them
+func Cut(file *os.File, start, end time.Duration) (*Clip, error) {
+	head := make([]byte, 12)
+	if _, err := file.ReadAt(head, 0); err != nil {
+		return nil, ErrCannotCut
+	}
+
+	switch {
+	case string(head[:4]) == "RIFF" && string(head[8:12]) == "WAVE":
+		return cutWav(file, start, end)
+	case string(head[:4]) == "fLaC":
+		return cutFlac(file, start, end)
+	default:
+		return nil, ErrCannotCut
+	}
+}
+
+func (c *Clip) Size() int64 {
+	return int64(len(c.header)) + c.audio.Size()
+}
+
+func (c *Clip) Read(p []byte) (int, error) {
+	headerSize := int64(len(c.header))
+	if c.offset < headerSize {
+		n := copy(p, c.header[c.offset:])
+		c.offset += int64(n)
+		return n, nil
+	}
+
+	n, err := c.audio.ReadAt(p, c.offset-headerSize)
+	c.offset += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+func (c *Clip) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += c.offset
+	case io.SeekEnd:
+		offset += c.Size()
+	}
+	if offset < 0 {
+		return 0, errors.New("seek before start of clip")
+	}
+	c.offset = offset
+	return offset, nil
+}
+
+// samplesAt converts a time to a sample count, rounding down
+func samplesAt(at time.Duration, sampleRate int64) int64 {
+	return int64(at/time.Millisecond) * sampleRate / 1000
+}
+
+func cutWav(file *os.File, start, end time.Duration) (*Clip, error) {
+	var format []byte
+	offset := int64(12)
+	chunk := make([]byte, 8)
+	for {
+		if _, err := file.ReadAt(chunk, offset); err != nil {
+			return nil, ErrCannotCut
+		}
+		size := int64(binary.LittleEndian.Uint32(chunk[4:]))
+		offset += 8
+
+		switch string(chunk[:4]) {
+		case "fmt ":
+			// The format is 16 bytes, 40 with WAVE_FORMAT_EXTENSIBLE, anything much bigger
+			// is a broken file
+			if size < 16 || size > 64 {
+				return nil, ErrCannotCut
+			}
+			format = make([]byte, size)
+			if _, err := file.ReadAt(format, offset); err != nil {
+				return nil, ErrCannotCut
+			}
+		case "data":
+			if format == nil {
+				return nil, ErrCannotCut
+			}
+			return wavClip(file, format, offset, size, start, end)
+		}
+
+		// Chunks are padded to an even size
+		offset += size + size%2
+	}
+}
+
+func wavClip(file *os.File, format []byte, dataOffset, dataSize int64, start, end time.Duration) (*Clip, error) {
+	sampleRate := int64(binary.LittleEndian.Uint32(format[4:8]))
+	blockAlign := int64(binary.LittleEndian.Uint16(format[12:14]))
+	if sampleRate == 0 || blockAlign == 0 {
+		return nil, ErrCannotCut
+	}
+
+	frames := dataSize / blockAlign
+	from := min(samplesAt(start, sampleRate), frames)
+	to := frames
+	if end > 0 {
+		to = min(max(samplesAt(end, sampleRate), from), frames)
+	}
+	size := (to - from) * blockAlign
+
+	var header bytes.Buffer
+	header.WriteString("RIFF")
+	binary.Write(&header, binary.LittleEndian, uint32(4+8+len(format)+len(format)%2+8+int(size)))
+	header.WriteString("WAVEfmt ")
+	binary.Write(&header, binary.LittleEndian, uint32(len(format)))
+	header.Write(format)
+	if len(format)%2 == 1 {
+		header.WriteByte(0)
+	}
+	header.WriteString("data")
+	binary.Write(&header, binary.LittleEndian, uint32(size))
+
+	return &Clip{header: header.Bytes(), audio: io.NewSectionReader(file, dataOffset+from*blockAlign, size)}, nil
+}
+
+// flacStream is what cutting needs to know about a FLAC file
+type flacStream struct {
+	file       *os.File
+	size       int64
+	info       []byte // STREAMINFO
+	audioStart int64
+	samples    int64 // in total, 0 when unknown
+	blockSize  int64 // of fixed-blocksize streams, whose frames are numbered instead
+	variable   bool
+}
+
+// flacFrame is where a frame starts in the file and the first sample it holds
+type flacFrame struct {
+	offset int64
+	sample int64
+}
+
+func cutFlac(file *os.File, start, end time.Duration) (*Clip, error) {
+	stream, err := readFlacStream(file)
+	if err != nil {
+		return nil, err
+	}
+
+	packed := binary.BigEndian.Uint64(stream.info[10:18])
+	sampleRate := int64(packed >> 44)
+	totalSamples := int64(packed & 0xFFFFFFFFF)
+	if sampleRate == 0 {
+		return nil, ErrCannotCut
+	}
+
+	first, ok := stream.frameAt(samplesAt(start, sampleRate))
+	if !ok {
+		return nil, ErrCannotCut
+	}
+	last := flacFrame{offset: stream.size, sample: totalSamples}
+	if end > 0 {
+		if frame, ok := stream.frameAt(samplesAt(end, sampleRate)); ok && frame.sample < totalSamples {
+			last = frame
+		}
+	}
+	if last.offset < first.offset {
+		last = first
+	}
+
+	// The clip's STREAMINFO gets its own length and no MD5, which was of the whole file
+	info := bytes.Clone(stream.info)
+	if totalSamples > 0 {
+		samples := max(last.sample-first.sample, 0)
+		binary.BigEndian.PutUint64(info[10:18], packed&^0xFFFFFFFFF|uint64(samples)&0xFFFFFFFFF)
+	}
+	clear(info[18:34])
+
+	header := append([]byte("fLaC"), 0x80, 0, 0, byte(len(info)))
+	header = append(header, info...)
+
+	return &Clip{header: header, audio: io.NewSectionReader(file, first.offset, last.offset-first.offset)}, nil
+}
+
+func readFlacStream(file *os.File) (*flacStream, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	stream := &flacStream{file: file, size: info.Size()}
+
+	offset := int64(4)
+	block := make([]byte, 4)
+	for {
+		if _, err := file.ReadAt(block, offset); err != nil {
+			return nil, ErrCannotCut
+		}
+		length := int64(block[1])<<16 | int64(block[2])<<8 | int64(block[3])
+		if block[0]&0x7F == 0 {
+			if length < 34 {
+				return nil, ErrCannotCut
+			}
+			stream.info = make([]byte, 34)
+			if _, err := file.ReadAt(stream.info, offset+4); err != nil {
+				return nil, ErrCannotCut
+			}
+		}
+		offset += 4 + length
+		if block[0]&0x80 != 0 {
+			break
+		}
+	}
+	if stream.info == nil {
+		return nil, ErrCannotCut
+	}
+	stream.audioStart = offset
+	stream.samples = int64(binary.BigEndian.Uint64(stream.info[10:18]) & 0xFFFFFFFFF)
+	stream.blockSize = int64(binary.BigEndian.Uint16(stream.info[2:4]))
+
+	sync := make([]byte, 2)
+	if _, err := file.ReadAt(sync, offset); err != nil || sync[0] != 0xFF || sync[1]&0xFE != 0xF8 {
+		return nil, ErrCannotCut
+	}
+	stream.variable = sync[1] == 0xF9
+	return stream, nil
+}
+
+// frameAt finds the frame holding a sample, by bisecting the file on frame headers
+func (s *flacStream) frameAt(sample int64) (flacFrame, bool) {
+	best, ok := s.nextFrame(s.audioStart)
+	if !ok {
+		return flacFrame{}, false
+	}
+
+	lo, hi := best.offset, s.size
+	for hi-lo > 1<<16 {
+		mid := lo + (hi-lo)/2
+		frame, ok := s.nextFrame(mid)
+		if !ok || frame.offset >= hi || frame.sample > sample {
+			hi = mid
+			continue
+		}
+		best, lo = frame, frame.offset
+	}
+
+	for {
+		frame, ok := s.nextFrame(best.offset + 1)
+		if !ok || frame.sample > sample {
+			return best, true
+		}
+		best = frame
+	}
+}
+
+// nextFrame finds the first frame header at or after offset. The sync code can turn up inside
+// audio data, so a header is only taken when its CRC checks out
+func (s *flacStream) nextFrame(offset int64) (flacFrame, bool) {
+	buf := make([]byte, 1<<16)
+	for offset < s.size {
+		n, _ := s.file.ReadAt(buf, offset)
+		if n < 2 {
+			return flacFrame{}, false
+		}
+
+		for i := 0; i+1 < n; i++ {
+			if buf[i] != 0xFF || buf[i+1]&0xFE != 0xF8 || (buf[i+1] == 0xF9) != s.variable {
+				continue
+			}
+			// Headers are at most 16 bytes, read them whole when they cross the buffer's end
+			header := buf[i:n]
+			if len(header) < 16 {
+				header = make([]byte, 16)
+				m, _ := s.file.ReadAt(header, offset+int64(i))
+				header = header[:m]
+			}
+			number, ok := parseFlacFrameHeader(header)
+			if !s.variable {
+				number *= s.blockSize
+			}
+			// A header past the end of the stream is a chance match in audio data too
+			if ok && (s.samples == 0 || number < s.samples) {
+				return flacFrame{offset: offset + int64(i), sample: number}, true
+			}
+		}
+		offset += int64(n - 1)
+	}
+	return flacFrame{}, false
+}
+
+// parseFlacFrameHeader checks a frame header and returns its frame or sample number
+func parseFlacFrameHeader(header []byte) (int64, bool) {
+	if len(header) < 6 {
+		return 0, false
+	}
+	blockSizeCode, rateCode := header[2]>>4, header[2]&0x0F
+	channels, sampleSize := header[3]>>4, header[3]>>1&0x07
+	if blockSizeCode == 0 || rateCode == 0x0F || channels > 10 || sampleSize == 3 || header[3]&0x01 != 0 {
+		return 0, false
+	}
+
+	// The number is coded like UTF-8, up to 36 bits in 7 bytes
+	number, extra, ok := flacNumberLead(header[4])
+	at := 5 + extra
+	if !ok || at >= len(header) {
+		return 0, false
+	}
+	for _, b := range header[5:at] {
+		if b&0xC0 != 0x80 {
+			return 0, false
+		}
+		number = number<<6 | int64(b&0x3F)
+	}
+
+	switch blockSizeCode {
+	case 6:
+		at++
+	case 7:
+		at += 2
+	}
+	switch rateCode {
+	case 12:
+		at++
+	case 13, 14:
+		at += 2
+	}
+	if at >= len(header) || crc8(header[:at]) != header[at] {
+		return 0, false
+	}
+	return number, true
+}
+
+// flacNumberLead reads the first byte of a frame number, returning its bits and how many
+// continuation bytes follow
+func flacNumberLead(lead byte) (int64, int, bool) {
+	switch {
+	case lead&0x80 == 0:
+		return int64(lead), 0, true
+	case lead&0xE0 == 0xC0:
+		return int64(lead & 0x1F), 1, true
+	case lead&0xF0 == 0xE0:
+		return int64(lead & 0x0F), 2, true
+	case lead&0xF8 == 0xF0:
+		return int64(lead & 0x07), 3, true
+	case lead&0xFC == 0xF8:
+		return int64(lead & 0x03), 4, true
+	case lead&0xFE == 0xFC:
+		return int64(lead & 0x01), 5, true
+	case lead == 0xFE:
+		return 0, 6, true
+	default:
+		return 0, 0, false
+	}
+}
+
+// crc8 is the CRC FLAC frame headers end with, polynomial x^8 + x^2 + x + 1
+func crc8(data []byte) byte {
+	var crc byte
+	for _, b := range data {
+		crc ^= b
+		for range 8 {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ 0x07
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,350 @@
+package audiotags
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"time"
+)
+
+var ErrUnknownDuration = errors.New("could not determine audio duration")
+
+// Duration works out the playing time of an audio file from its headers without decoding it.
+// MP3 (TLEN, Xing/Info or CBR estimate), WAV, FLAC, Ogg and MP4/M4A are supported
+func Duration(filePath string) (time.Duration, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	head := make([]byte, 12)
+	if _, err := io.ReadFull(file, head); err != nil {
+		return 0, ErrUnknownDuration
+	}
+
+	switch {
+	case string(head[:3]) == "ID3":
+		return id3Duration(file, info.Size())
+	case string(head[:4]) == "RIFF" && string(head[8:12]) == "WAVE":
+		return wavDuration(file)
+	case string(head[:4]) == "fLaC":
+		return flacDuration(file)
+	case string(head[:4]) == "OggS":
+		return oggDuration(file, info.Size())
+	case string(head[4:8]) == "ftyp":
+		return mp4Duration(file, info.Size())
+	default:
+		return mpegDuration(file, 0, info.Size())
+	}
+}
+
+func id3Duration(file *os.File, size int64) (time.Duration, error) {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	header := make([]byte, 10)
+	if _, err := io.ReadFull(file, header); err != nil {
+		return 0, ErrUnknownDuration
+	}
+	tagSize := int64(10 + syncsafe(header[6:10]))
+	if header[5]&0x10 != 0 {
+		tagSize += 10 // footer
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err == nil {
+		if tags, err := Read(file); err == nil && tags.LengthMs() > 0 {
+			return time.Duration(tags.LengthMs()) * time.Millisecond, nil
+		}
+	}
+
+	return mpegDuration(file, tagSize, size)
+}
+
+var (
+	mpegBitrates = [2][3][16]int{
+		// MPEG-1 layers I, II, III
+		{
+			{0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448},
+			{0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384},
+			{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+		},
+		// MPEG-2 and 2.5 layers I, II, III
+		{
+			{0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256},
+			{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+			{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+		},
+	}
+	mpegSampleRates = map[int][3]int{
+		3: {44100, 48000, 32000}, // MPEG-1
+		2: {22050, 24000, 16000}, // MPEG-2
+		0: {11025, 12000, 8000},  // MPEG-2.5
+	}
+)
+
+func mpegDuration(file *os.File, offset, size int64) (time.Duration, error) {
+	buf := make([]byte, 8192)
+	n, err := file.ReadAt(buf, offset)
+	if err != nil && err != io.EOF {
+		return 0, err
+	}
+	buf = buf[:n]
+
+	for i := 0; i+4 <= len(buf); i++ {
+		if buf[i] != 0xFF || buf[i+1]&0xE0 != 0xE0 {
+			continue
+		}
+
+		version := int(buf[i+1]>>3) & 0x03
+		layer := 4 - int(buf[i+1]>>1)&0x03
+		bitrateIndex := int(buf[i+2] >> 4)
+		rateIndex := int(buf[i+2]>>2) & 0x03
+		if version == 1 || layer == 4 || bitrateIndex == 0 || bitrateIndex == 15 || rateIndex == 3 {
+			continue
+		}
+
+		table := 0
+		if version != 3 {
+			table = 1
+		}
+		bitrate := mpegBitrates[table][layer-1][bitrateIndex] * 1000
+		sampleRate := mpegSampleRates[version][rateIndex]
+
+		samplesPerFrame := 1152
+		switch {
+		case layer == 1:
+			samplesPerFrame = 384
+		case layer == 3 && version != 3:
+			samplesPerFrame = 576
+		}
+
+		// VBR files carry the frame count in a Xing/Info header
+		if frames := xingFrames(buf[i:]); frames > 0 {
+			seconds := float64(frames) * float64(samplesPerFrame) / float64(sampleRate)
+			return time.Duration(seconds * float64(time.Second)), nil
+		}
+
+		audioBytes := size - offset - int64(i)
+		seconds := float64(audioBytes*8) / float64(bitrate)
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+
+	return 0, ErrUnknownDuration
+}
+
+func xingFrames(frame []byte) int {
+	for _, marker := range []string{"Xing", "Info"} {
+		at := bytes.Index(frame[:min(len(frame), 64)], []byte(marker))
+		if at < 0 || at+12 > len(frame) {
+			continue
+		}
+
+		flags := binary.BigEndian.Uint32(frame[at+4:])
+		if flags&0x01 == 0 {
+			return 0
+		}
+		return int(binary.BigEndian.Uint32(frame[at+8:]))
+	}
+	return 0
+}
+
+func wavDuration(file *os.File) (time.Duration, error) {
+	if _, err := file.Seek(12, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	byteRate := 0
+	chunk := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(file, chunk); err != nil {
+			return 0, ErrUnknownDuration
+		}
+		id := string(chunk[:4])
+		size := int64(binary.LittleEndian.Uint32(chunk[4:]))
+
+		switch id {
+		case "fmt ":
+			format := make([]byte, 16)
+			if _, err := io.ReadFull(file, format); err != nil {
+				return 0, ErrUnknownDuration
+			}
+			byteRate = int(binary.LittleEndian.Uint32(format[8:12]))
+			size -= 16
+		case "data":
+			if byteRate == 0 {
+				return 0, ErrUnknownDuration
+			}
+			seconds := float64(size) / float64(byteRate)
+			return time.Duration(seconds * float64(time.Second)), nil
+		}
+
+		// Chunks are padded to an even size
+		if _, err := file.Seek(size+size%2, io.SeekCurrent); err != nil {
+			return 0, ErrUnknownDuration
+		}
+	}
+}
+
+func flacDuration(file *os.File) (time.Duration, error) {
+	info, err := ReadFlacStreamInfo(file)
+	if err != nil {
+		return 0, err
+	}
+	if info.SampleRate == 0 || info.TotalSamples == 0 {
+		return 0, ErrUnknownDuration
+	}
+
+	seconds := float64(info.TotalSamples) / float64(info.SampleRate)
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+type FlacStreamInfo struct {
+	SampleRate    int
+	Channels      int
+	BitsPerSample int
+	TotalSamples  int64
+}
+
+// ReadFlacStreamInfo reads the mandatory STREAMINFO metadata block of a FLAC file
+func ReadFlacStreamInfo(r io.ReadSeeker) (FlacStreamInfo, error) {
+	if _, err := r.Seek(4, io.SeekStart); err != nil {
+		return FlacStreamInfo{}, err
+	}
+
+	block := make([]byte, 4+34)
+	if _, err := io.ReadFull(r, block); err != nil || block[0]&0x7F != 0 {
+		return FlacStreamInfo{}, ErrUnknownDuration
+	}
+
+	data := block[4:]
+	packed := binary.BigEndian.Uint64(data[10:18])
+
+	return FlacStreamInfo{
+		SampleRate:    int(packed >> 44),
+		Channels:      int(packed>>41&0x07) + 1,
+		BitsPerSample: int(packed>>36&0x1F) + 1,
+		TotalSamples:  int64(packed & 0xFFFFFFFFF),
+	}, nil
+}
+
+func oggDuration(file *os.File, size int64) (time.Duration, error) {
+	head := make([]byte, 64)
+	if _, err := file.ReadAt(head, 0); err != nil && err != io.EOF {
+		return 0, err
+	}
+
+	// The first packet of the first page identifies the codec
+	sampleRate := 0
+	switch {
+	case bytes.Contains(head, []byte("\x01vorbis")):
+		at := bytes.Index(head, []byte("\x01vorbis"))
+		if at+16 <= len(head) {
+			sampleRate = int(binary.LittleEndian.Uint32(head[at+12:]))
+		}
+	case bytes.Contains(head, []byte("OpusHead")):
+		sampleRate = 48000 // Opus granule positions are always at 48kHz
+	}
+	if sampleRate == 0 {
+		return 0, ErrUnknownDuration
+	}
+
+	// The granule position of the last page is the total sample count
+	tailSize := min(size, 64*1024)
+	tail := make([]byte, tailSize)
+	if _, err := file.ReadAt(tail, size-tailSize); err != nil && err != io.EOF {
+		return 0, err
+	}
+
+	last := bytes.LastIndex(tail, []byte("OggS"))
+	if last < 0 || last+14 > len(tail) {
+		return 0, ErrUnknownDuration
+	}
+	granule := int64(binary.LittleEndian.Uint64(tail[last+6:]))
+	if granule <= 0 {
+		return 0, ErrUnknownDuration
+	}
+
+	seconds := float64(granule) / float64(sampleRate)
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+func mp4Duration(file *os.File, size int64) (time.Duration, error) {
+	moov, err := FindMp4Box(file, 0, size, "moov")
+	if err != nil {
+		return 0, err
+	}
+	mvhd, err := FindMp4Box(file, moov.DataOffset, moov.DataOffset+moov.DataSize, "mvhd")
+	if err != nil {
+		return 0, err
+	}
+
+	data := make([]byte, min(mvhd.DataSize, 32))
+	if _, err := file.ReadAt(data, mvhd.DataOffset); err != nil {
+		return 0, ErrUnknownDuration
+	}
+
+	var timescale, duration uint64
+	if data[0] == 1 && len(data) >= 32 {
+		timescale = uint64(binary.BigEndian.Uint32(data[20:24]))
+		duration = binary.BigEndian.Uint64(data[24:32])
+	} else if len(data) >= 20 {
+		timescale = uint64(binary.BigEndian.Uint32(data[12:16]))
+		duration = uint64(binary.BigEndian.Uint32(data[16:20]))
+	}
+	if timescale == 0 {
+		return 0, ErrUnknownDuration
+	}
+
+	seconds := float64(duration) / float64(timescale)
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+type Mp4Box struct {
+	Type       string
+	DataOffset int64
+	DataSize   int64
+}
+
+// FindMp4Box looks for a box of the given type between start and end, without descending into children
+func FindMp4Box(r io.ReaderAt, start, end int64, boxType string) (Mp4Box, error) {
+	header := make([]byte, 16)
+	for offset := start; offset+8 <= end; {
+		if _, err := r.ReadAt(header[:8], offset); err != nil {
+			return Mp4Box{}, ErrUnknownDuration
+		}
+
+		size := int64(binary.BigEndian.Uint32(header[:4]))
+		kind := string(header[4:8])
+		headerSize := int64(8)
+
+		switch size {
+		case 0:
+			size = end - offset // box extends to the end
+		case 1:
+			if _, err := r.ReadAt(header[8:16], offset+8); err != nil {
+				return Mp4Box{}, ErrUnknownDuration
+			}
+			size = int64(binary.BigEndian.Uint64(header[8:16]))
+			headerSize = 16
+		}
+		if size < headerSize {
+			return Mp4Box{}, ErrUnknownDuration
+		}
+
+		if kind == boxType {
+			return Mp4Box{Type: kind, DataOffset: offset + headerSize, DataSize: size - headerSize}, nil
+		}
+		offset += size
+	}
+
+	return Mp4Box{}, ErrUnknownDuration
+}
@@ -0,0 +1,57 @@
+package audiotags
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+)
+
+// Fingerprint hashes the audio data of a file, leaving out ID3v2 and ID3v1 tags so that
+// retagging a file doesn't change it. Two copies of the same encode get the same fingerprint
+func Fingerprint(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	audio, err := AudioSection(file)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, audio); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// AudioSection is the part of a file between its ID3v2 and ID3v1 tags, the whole file when
+// it has neither
+func AudioSection(file *os.File) (*io.SectionReader, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	start, end := int64(0), info.Size()
+
+	header := make([]byte, 10)
+	if _, err := file.ReadAt(header, 0); err == nil && string(header[:3]) == "ID3" {
+		start = int64(10 + syncsafe(header[6:10]))
+		if header[5]&0x10 != 0 {
+			start += 10 // footer
+		}
+	}
+
+	trailer := make([]byte, 3)
+	if end-start >= 128 {
+		if _, err := file.ReadAt(trailer, end-128); err == nil && string(trailer) == "TAG" {
+			end -= 128
+		}
+	}
+
+	return io.NewSectionReader(file, min(start, end), max(end-start, 0)), nil
+}
@@ -0,0 +1,54 @@
+package audiotags
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// id3v1Genres are the standard ID3v1 genres, which TCON frames may refer to by number
+var id3v1Genres = []string{
+	"Blues", "Classic Rock", "Country", "Dance", "Disco", "Funk", "Grunge", "Hip-Hop",
+	"Jazz", "Metal", "New Age", "Oldies", "Other", "Pop", "R&B", "Rap",
+	"Reggae", "Rock", "Techno", "Industrial", "Alternative", "Ska", "Death Metal", "Pranks",
+	"Soundtrack", "Euro-Techno", "Ambient", "Trip-Hop", "Vocal", "Jazz+Funk", "Fusion", "Trance",
+	"Classical", "Instrumental", "Acid", "House", "Game", "Sound Clip", "Gospel", "Noise",
+	"AlternRock", "Bass", "Soul", "Punk", "Space", "Meditative", "Instrumental Pop", "Instrumental Rock",
+	"Ethnic", "Gothic", "Darkwave", "Techno-Industrial", "Electronic", "Pop-Folk", "Eurodance", "Dream",
+	"Southern Rock", "Comedy", "Cult", "Gangsta", "Top 40", "Christian Rap", "Pop/Funk", "Jungle",
+	"Native American", "Cabaret", "New Wave", "Psychedelic", "Rave", "Showtunes", "Trailer", "Lo-Fi",
+	"Tribal", "Acid Punk", "Acid Jazz", "Polka", "Retro", "Musical", "Rock & Roll", "Hard Rock",
+}
+
+var genreReference = regexp.MustCompile(`^\((\d+)\)`)
+
+// Genre resolves the TCON frame, which may hold a name, an ID3v1 genre number like "17",
+// or a reference like "(17)" optionally followed by a refinement like "(17)Indie Rock"
+func (t *Tags) Genre() string {
+	genre := strings.TrimSpace(t.Frames["TCON"])
+
+	if match := genreReference.FindStringSubmatch(genre); match != nil {
+		if refinement := strings.TrimSpace(genre[len(match[0]):]); refinement != "" {
+			return refinement
+		}
+		genre = match[1]
+	}
+
+	if number, err := strconv.Atoi(genre); err == nil {
+		if number >= 0 && number < len(id3v1Genres) {
+			return id3v1Genres[number]
+		}
+		return ""
+	}
+
+	return genre
+}
+
+// Bpm returns the TBPM frame value, or 0 if it's missing or invalid
+func (t *Tags) Bpm() int {
+	bpm, err := strconv.ParseFloat(strings.TrimSpace(t.Frames["TBPM"]), 64)
+	if err != nil || bpm < 0 {
+		return 0
+	}
+	return int(bpm + 0.5)
+}
@@ -0,0 +1,410 @@
+package audiotags
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+var (
+	ErrNoTag              = errors.New("no ID3v2 tag found")
+	ErrUnsupportedVersion = errors.New("unsupported ID3v2 version")
+	ErrMalformedTag       = errors.New("malformed ID3v2 tag")
+)
+
+// SYLT timestamp formats as defined by the ID3v2 spec
+const (
+	TimestampMpegFrames   = 1
+	TimestampMilliseconds = 2
+)
+
+// APIC picture type of the front cover
+const PictureFrontCover = 3
+
+type Tags struct {
+	Version      int
+	Frames       map[string]string
+	Lyrics       []Lyrics
+	SyncedLyrics []SyncedLyrics
+	Pictures     []Picture
+	Chapters     []Chapter
+
+	// Top-level table of contents, the order of CHAP element ids
+	toc []string
+	// Set while reading the frames embedded in a CHAP frame, which only hold text. Chapters
+	// nested in chapters would let a tag recurse as deep as it's long
+	embedded bool
+}
+
+// Lyrics holds an unsynchronised lyrics (USLT) frame
+type Lyrics struct {
+	Language    string
+	Description string
+	Text        string
+}
+
+// SyncedLyrics holds a synchronised lyrics (SYLT) frame
+type SyncedLyrics struct {
+	Language        string
+	Description     string
+	TimestampFormat int
+	ContentType     int
+	Lines           []SyncedLine
+}
+
+type SyncedLine struct {
+	Timestamp int
+	Text      string
+}
+
+// Picture holds an attached picture (APIC) frame, usually cover art
+type Picture struct {
+	MimeType    string
+	Type        int
+	Description string
+	Data        []byte
+}
+
+func (t *Tags) Text(id string) string {
+	return t.Frames[id]
+}
+
+func (t *Tags) Title() string {
+	return t.Frames["TIT2"]
+}
+
+func (t *Tags) Artist() string {
+	return t.Frames["TPE1"]
+}
+
+func (t *Tags) Album() string {
+	return t.Frames["TALB"]
+}
+
+// Cover returns the front cover, or the first picture when none is marked as such
+func (t *Tags) Cover() (Picture, bool) {
+	for _, picture := range t.Pictures {
+		if picture.Type == PictureFrontCover {
+			return picture, true
+		}
+	}
+	if len(t.Pictures) > 0 {
+		return t.Pictures[0], true
+	}
+	return Picture{}, false
+}
+
+// LengthMs returns the TLEN frame value, or 0 if it's missing or invalid
+func (t *Tags) LengthMs() int {
+	length, err := strconv.Atoi(strings.TrimSpace(t.Frames["TLEN"]))
+	if err != nil || length < 0 {
+		return 0
+	}
+	return length
+}
+
+func ReadFile(filePath string) (*Tags, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return Read(file)
+}
+
+// Read parses an ID3v2.3 or ID3v2.4 tag from the start of r
+func Read(r io.Reader) (*Tags, error) {
+	header := make([]byte, 10)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, ErrNoTag
+	}
+
+	if string(header[:3]) != "ID3" {
+		return nil, ErrNoTag
+	}
+
+	version := int(header[3])
+	if version != 3 && version != 4 {
+		return nil, ErrUnsupportedVersion
+	}
+
+	flags := header[5]
+	size := syncsafe(header[6:10])
+
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, ErrMalformedTag
+	}
+
+	// v2.3 applies unsynchronisation to the whole tag, v2.4 does it per frame
+	if version == 3 && flags&0x80 != 0 {
+		body = removeUnsync(body)
+	}
+
+	if flags&0x40 != 0 {
+		body = skipExtendedHeader(body, version)
+	}
+
+	tags := &Tags{Version: version, Frames: map[string]string{}}
+	tags.parseFrames(body)
+	tags.orderChapters()
+
+	return tags, nil
+}
+
+func (t *Tags) parseFrames(body []byte) {
+	for len(body) >= 10 {
+		id := string(body[:4])
+		if body[0] == 0 || !isFrameId(id) {
+			return // reached padding
+		}
+
+		var size int
+		if t.Version == 4 {
+			size = syncsafe(body[4:8])
+		} else {
+			size = int(binary.BigEndian.Uint32(body[4:8]))
+		}
+		formatFlags := body[9]
+
+		if size < 0 || 10+size > len(body) {
+			return
+		}
+
+		data := body[10 : 10+size]
+		body = body[10+size:]
+
+		// Compressed and encrypted frames aren't supported
+		if t.Version == 3 && formatFlags&0xC0 != 0 {
+			continue
+		}
+		if t.Version == 4 {
+			if formatFlags&0x0C != 0 {
+				continue
+			}
+			if formatFlags&0x01 != 0 && len(data) >= 4 {
+				data = data[4:] // data length indicator
+			}
+			if formatFlags&0x02 != 0 {
+				data = removeUnsync(data)
+			}
+		}
+
+		t.parseFrame(id, data)
+	}
+}
+
+func (t *Tags) parseFrame(id string, data []byte) {
+	if len(data) == 0 || t.embedded && id[0] != 'T' {
+		return
+	}
+
+	switch {
+	case id == "USLT":
+		if lyrics, ok := parseUslt(data); ok {
+			t.Lyrics = append(t.Lyrics, lyrics)
+		}
+	case id == "SYLT":
+		if lyrics, ok := parseSylt(data); ok {
+			t.SyncedLyrics = append(t.SyncedLyrics, lyrics)
+		}
+	case id == "APIC":
+		if picture, ok := parseApic(data); ok {
+			t.Pictures = append(t.Pictures, picture)
+		}
+	case id == "CHAP":
+		if chapter, ok := t.parseChap(data); ok {
+			t.Chapters = append(t.Chapters, chapter)
+		}
+	case id == "CTOC":
+		t.parseCtoc(data)
+	case id == "TXXX":
+		return
+	case id[0] == 'T':
+		text := decodeText(data[0], data[1:])
+		// v2.4 separates multiple values with NUL, keep the first one
+		text, _, _ = strings.Cut(text, "\x00")
+		t.Frames[id] = strings.TrimSpace(text)
+	}
+}
+
+func parseUslt(data []byte) (Lyrics, bool) {
+	if len(data) < 4 {
+		return Lyrics{}, false
+	}
+
+	encoding := data[0]
+	language := string(data[1:4])
+	description, rest := splitTerminated(encoding, data[4:])
+
+	return Lyrics{
+		Language:    language,
+		Description: decodeText(encoding, description),
+		Text:        decodeText(encoding, rest),
+	}, true
+}
+
+func parseApic(data []byte) (Picture, bool) {
+	encoding := data[0]
+	mimeType, rest := splitTerminated(0, data[1:])
+	if len(rest) < 1 {
+		return Picture{}, false
+	}
+
+	picture := Picture{MimeType: strings.ToLower(string(mimeType)), Type: int(rest[0])}
+	description, image := splitTerminated(encoding, rest[1:])
+	if len(image) == 0 {
+		return Picture{}, false
+	}
+	picture.Description = decodeText(encoding, description)
+	picture.Data = image
+
+	// Some taggers write the format alone, "-->" means the picture is only a link
+	switch picture.MimeType {
+	case "-->":
+		return Picture{}, false
+	case "", "jpg", "jpeg", "image/jpg":
+		picture.MimeType = "image/jpeg"
+	case "png":
+		picture.MimeType = "image/png"
+	}
+	return picture, true
+}
+
+func parseSylt(data []byte) (SyncedLyrics, bool) {
+	if len(data) < 6 {
+		return SyncedLyrics{}, false
+	}
+
+	encoding := data[0]
+	lyrics := SyncedLyrics{
+		Language:        string(data[1:4]),
+		TimestampFormat: int(data[4]),
+		ContentType:     int(data[5]),
+	}
+
+	description, rest := splitTerminated(encoding, data[6:])
+	lyrics.Description = decodeText(encoding, description)
+
+	for len(rest) > 0 {
+		var text []byte
+		text, rest = splitTerminated(encoding, rest)
+		if len(rest) < 4 {
+			break
+		}
+
+		lyrics.Lines = append(lyrics.Lines, SyncedLine{
+			Timestamp: int(binary.BigEndian.Uint32(rest[:4])),
+			Text:      decodeText(encoding, text),
+		})
+		rest = rest[4:]
+	}
+
+	return lyrics, true
+}
+
+// splitTerminated splits data at the first string terminator for the given encoding
+func splitTerminated(encoding byte, data []byte) ([]byte, []byte) {
+	if encoding == 1 || encoding == 2 {
+		for i := 0; i+1 < len(data); i += 2 {
+			if data[i] == 0 && data[i+1] == 0 {
+				return data[:i], data[i+2:]
+			}
+		}
+		return data, nil
+	}
+
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		return data[:i], data[i+1:]
+	}
+	return data, nil
+}
+
+func decodeText(encoding byte, data []byte) string {
+	switch encoding {
+	case 0:
+		runes := make([]rune, 0, len(data))
+		for _, b := range data {
+			runes = append(runes, rune(b))
+		}
+		return strings.TrimRight(string(runes), "\x00")
+	case 1:
+		return decodeUtf16(data, true)
+	case 2:
+		return decodeUtf16(data, false)
+	default:
+		return strings.TrimRight(string(data), "\x00")
+	}
+}
+
+func decodeUtf16(data []byte, withBom bool) string {
+	bigEndian := true
+	if withBom && len(data) >= 2 {
+		switch {
+		case data[0] == 0xFF && data[1] == 0xFE:
+			bigEndian = false
+			data = data[2:]
+		case data[0] == 0xFE && data[1] == 0xFF:
+			data = data[2:]
+		}
+	}
+
+	units := make([]uint16, 0, len(data)/2)
+	for i := 0; i+1 < len(data); i += 2 {
+		if bigEndian {
+			units = append(units, binary.BigEndian.Uint16(data[i:]))
+		} else {
+			units = append(units, binary.LittleEndian.Uint16(data[i:]))
+		}
+	}
+
+	return strings.TrimRight(string(utf16.Decode(units)), "\x00")
+}
+
+func skipExtendedHeader(body []byte, version int) []byte {
+	if len(body) < 4 {
+		return nil
+	}
+
+	var size int
+	if version == 4 {
+		size = syncsafe(body[:4]) // includes the size bytes themselves
+	} else {
+		size = int(binary.BigEndian.Uint32(body[:4])) + 4
+	}
+
+	if size > len(body) {
+		return nil
+	}
+	return body[size:]
+}
+
+func removeUnsync(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		out = append(out, data[i])
+		if data[i] == 0xFF && i+1 < len(data) && data[i+1] == 0x00 {
+			i++
+		}
+	}
+	return out
+}
+
+func syncsafe(b []byte) int {
+	return int(b[0]&0x7F)<<21 | int(b[1]&0x7F)<<14 | int(b[2]&0x7F)<<7 | int(b[3]&0x7F)
+}
+
+func isFrameId(id string) bool {
+	for _, c := range id {
+		if (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
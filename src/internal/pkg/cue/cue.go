@@ -0,0 +1,207 @@
+package cue
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Cue sheet times are minutes:seconds:frames, with 75 frames a second
+const FramesPerSecond = 75
+
+var (
+	ErrNoTracks  = errors.New("cue sheet has no tracks")
+	ErrMalformed = errors.New("malformed cue sheet")
+)
+
+// Sheet is a parsed cue sheet, describing where tracks start in one or more audio files
+type Sheet struct {
+	Title     string
+	Performer string
+	// REM comments such as GENRE and DATE, keyed by their upper-cased name
+	Comments map[string]string
+	Files    []File
+}
+
+type File struct {
+	Name   string
+	Type   string
+	Tracks []Track
+}
+
+type Track struct {
+	Number    int
+	Title     string
+	Performer string
+	// Start is INDEX 01, where the track proper begins in its file
+	Start time.Duration
+	// Pregap is INDEX 00 when the sheet has one, the silence before Start
+	Pregap *time.Duration
+}
+
+// Tracks returns every track of the sheet in order, with the file each is in
+func (s *Sheet) Tracks() []FileTrack {
+	var tracks []FileTrack
+	for _, file := range s.Files {
+		for _, track := range file.Tracks {
+			tracks = append(tracks, FileTrack{File: file.Name, Track: track})
+		}
+	}
+	return tracks
+}
+
+type FileTrack struct {
+	File string
+	Track
+}
+
+// Parse reads a cue sheet. Sheets are usually UTF-8 but older rippers wrote Latin-1, which
+// is decoded as such when the sheet isn't valid UTF-8
+func Parse(r io.Reader) (*Sheet, error) {
+	data, err := io.ReadAll(io.LimitReader(r, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if !utf8.Valid(data) {
+		data = latin1ToUtf8(data)
+	}
+
+	sheet := &Sheet{Comments: map[string]string{}}
+	var file *File
+	var track *Track
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		command, args := splitCommand(scanner.Text())
+		if command == "" {
+			continue
+		}
+
+		switch command {
+		case "REM":
+			name, value := splitCommand(args)
+			if track == nil && name != "" {
+				sheet.Comments[name] = unquote(value)
+			}
+		case "TITLE":
+			if track != nil {
+				track.Title = unquote(args)
+			} else {
+				sheet.Title = unquote(args)
+			}
+		case "PERFORMER":
+			if track != nil {
+				track.Performer = unquote(args)
+			} else {
+				sheet.Performer = unquote(args)
+			}
+		case "FILE":
+			name, fileType := splitFile(args)
+			sheet.Files = append(sheet.Files, File{Name: name, Type: fileType})
+			file = &sheet.Files[len(sheet.Files)-1]
+			track = nil
+		case "TRACK":
+			if file == nil {
+				return nil, fmt.Errorf("%w: line %d: TRACK before FILE", ErrMalformed, line)
+			}
+			numberArg, _ := splitCommand(args)
+			number, err := strconv.Atoi(numberArg)
+			if err != nil {
+				return nil, fmt.Errorf("%w: line %d: invalid track number", ErrMalformed, line)
+			}
+			file.Tracks = append(file.Tracks, Track{Number: number, Performer: sheet.Performer})
+			track = &file.Tracks[len(file.Tracks)-1]
+		case "INDEX":
+			if track == nil {
+				return nil, fmt.Errorf("%w: line %d: INDEX before TRACK", ErrMalformed, line)
+			}
+			indexArg, timeArg := splitCommand(args)
+			at, err := ParseTime(timeArg)
+			if err != nil {
+				return nil, fmt.Errorf("%w: line %d: %v", ErrMalformed, line, err)
+			}
+			switch indexArg {
+			case "00":
+				track.Pregap = &at
+			case "01":
+				track.Start = at
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(sheet.Tracks()) == 0 {
+		return nil, ErrNoTracks
+	}
+	return sheet, nil
+}
+
+// ParseTime reads a mm:ss:ff cue time. Minutes go past 59 on long files
+func ParseTime(value string) (time.Duration, error) {
+	parts := strings.Split(strings.TrimSpace(value), ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("invalid time %q", value)
+	}
+
+	var numbers [3]int
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid time %q", value)
+		}
+		numbers[i] = n
+	}
+	if numbers[1] >= 60 || numbers[2] >= FramesPerSecond {
+		return 0, fmt.Errorf("invalid time %q", value)
+	}
+
+	frames := (numbers[0]*60+numbers[1])*FramesPerSecond + numbers[2]
+	return time.Duration(frames) * time.Second / FramesPerSecond, nil
+}
+
+// splitCommand cuts a line into its upper-cased first word and the rest
+func splitCommand(line string) (string, string) {
+	line = strings.TrimSpace(line)
+	command, args, _ := strings.Cut(line, " ")
+	return strings.ToUpper(command), strings.TrimSpace(args)
+}
+
+// splitFile reads FILE arguments, a possibly quoted name followed by the file type
+func splitFile(args string) (string, string) {
+	if strings.HasPrefix(args, `"`) {
+		if end := strings.Index(args[1:], `"`); end >= 0 {
+			return args[1 : end+1], strings.TrimSpace(args[end+2:])
+		}
+	}
+
+	i := strings.LastIndex(args, " ")
+	if i < 0 {
+		return args, ""
+	}
+	return strings.TrimSpace(args[:i]), strings.TrimSpace(args[i+1:])
+}
+
+func unquote(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) >= 2 && strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`) {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+func latin1ToUtf8(data []byte) []byte {
+	runes := make([]rune, len(data))
+	for i, b := range data {
+		runes[i] = rune(b)
+	}
+	return []byte(string(runes))
+}
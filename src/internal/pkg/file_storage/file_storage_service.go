@@ -1,16 +1,74 @@
 package filestorage
 
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
 type FileStorageService interface {
 	UploadFile(filePath string, fileName string) (string, error)
-	// DownloadFile(fileId string) (string, error)
+	SaveFile(r io.Reader, fileName string) (string, error)
+	FilePath(fileName string) string
+	DeleteFile(fileName string) error
 }
 
-type LocalFileStorageService struct{}
+// LocalFileStorageService keeps files in a directory on disk
+type LocalFileStorageService struct {
+	dir string
+}
 
-func NewLocalFileStorageService() *LocalFileStorageService {
-	return &LocalFileStorageService{}
+func NewLocalFileStorageService(dir string) *LocalFileStorageService {
+	return &LocalFileStorageService{dir: dir}
 }
 
+// UploadFile copies the file at filePath into storage as fileName
 func (l *LocalFileStorageService) UploadFile(filePath string, fileName string) (string, error) {
-	return "", nil
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	return l.SaveFile(file, fileName)
+}
+
+// SaveFile writes r into storage as fileName. The file only shows up once it's complete,
+// so a failed write never leaves half a file behind
+func (l *LocalFileStorageService) SaveFile(r io.Reader, fileName string) (string, error) {
+	if err := os.MkdirAll(l.dir, 0755); err != nil {
+		return "", err
+	}
+
+	tmp, err := os.CreateTemp(l.dir, ".upload-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+
+	fileName = filepath.Base(fileName)
+	if err := os.Rename(tmp.Name(), l.FilePath(fileName)); err != nil {
+		return "", err
+	}
+	return fileName, nil
+}
+
+func (l *LocalFileStorageService) FilePath(fileName string) string {
+	return filepath.Join(l.dir, filepath.Base(fileName))
+}
+
+func (l *LocalFileStorageService) DeleteFile(fileName string) error {
+	err := os.Remove(l.FilePath(fileName))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
 }
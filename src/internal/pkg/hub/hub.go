@@ -0,0 +1,151 @@
+package hub
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+const (
+	// Messages waiting for a slow client before it gets disconnected
+	sendBuffer   = 64
+	writeTimeout = 10 * time.Second
+	// Largest message a client may send
+	MaxMessageBytes = 64 << 10
+)
+
+// Message is the envelope of everything pushed to clients. ServerTime is in Unix
+// milliseconds so clients can work out their clock offset
+type Message struct {
+	Type       string `json:"type"`
+	Data       any    `json:"data,omitempty"`
+	ServerTime int64  `json:"server_time"`
+}
+
+func NewMessage(messageType string, data any) Message {
+	return Message{Type: messageType, Data: data, ServerTime: time.Now().UnixMilli()}
+}
+
+// Client is one WebSocket connection, writes go through a buffered queue so a slow
+// client never blocks the others
+type Client struct {
+	Id     string
+	Name   string
+	UserId string
+
+	conn      *websocket.Conn
+	send      chan Message
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func NewClient(conn *websocket.Conn, id, name, userId string) *Client {
+	conn.MaxPayloadBytes = MaxMessageBytes
+
+	client := &Client{
+		Id:     id,
+		Name:   name,
+		UserId: userId,
+		conn:   conn,
+		send:   make(chan Message, sendBuffer),
+		done:   make(chan struct{}),
+	}
+	go client.writeLoop()
+	return client
+}
+
+// Send queues a message, a client that can't keep up is disconnected
+func (c *Client) Send(message Message) {
+	select {
+	case <-c.done:
+	case c.send <- message:
+	default:
+		c.Close()
+	}
+}
+
+// Receive reads the next raw message from the client
+func (c *Client) Receive() ([]byte, error) {
+	var data []byte
+	err := websocket.Message.Receive(c.conn, &data)
+	return data, err
+}
+
+func (c *Client) Close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.conn.Close()
+	})
+}
+
+func (c *Client) writeLoop() {
+	for {
+		select {
+		case <-c.done:
+			return
+		case message := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if err := websocket.JSON.Send(c.conn, message); err != nil {
+				c.Close()
+				return
+			}
+		}
+	}
+}
+
+// Hub groups clients by topic, e.g. all devices of a user or all listeners in a room
+type Hub struct {
+	mu     sync.RWMutex
+	topics map[string]map[*Client]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{topics: map[string]map[*Client]struct{}{}}
+}
+
+func (h *Hub) Join(topic string, client *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.topics[topic] == nil {
+		h.topics[topic] = map[*Client]struct{}{}
+	}
+	h.topics[topic][client] = struct{}{}
+}
+
+func (h *Hub) Leave(topic string, client *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.topics[topic], client)
+	if len(h.topics[topic]) == 0 {
+		delete(h.topics, topic)
+	}
+}
+
+// Publish sends a message to every client in the topic
+func (h *Hub) Publish(topic string, message Message) {
+	for _, client := range h.Clients(topic) {
+		client.Send(message)
+	}
+}
+
+func (h *Hub) Clients(topic string) []*Client {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	clients := make([]*Client, 0, len(h.topics[topic]))
+	for client := range h.topics[topic] {
+		clients = append(clients, client)
+	}
+	return clients
+}
+
+// Serve upgrades the request to a WebSocket and runs handle until the connection ends.
+// Any origin is accepted, like the CORS middleware does for plain requests
+func Serve(w http.ResponseWriter, r *http.Request, handle func(conn *websocket.Conn)) {
+	server := websocket.Server{Handler: handle}
+	server.ServeHTTP(w, r)
+}
@@ -0,0 +1,76 @@
+package icy
+
+import (
+	"io"
+	"strings"
+)
+
+// MetaInterval is how many audio bytes go between two metadata blocks, what Icecast uses
+const MetaInterval = 16000
+
+// Longest metadata block the one byte length prefix can announce
+const maxMetadata = 255 * 16
+
+// Writer interleaves SHOUTcast/Icecast metadata into an audio stream for clients that sent
+// "Icy-MetaData: 1" and were answered with an icy-metaint header
+type Writer struct {
+	w         io.Writer
+	interval  int
+	remaining int
+	title     string
+	changed   bool
+}
+
+func NewWriter(w io.Writer, interval int) *Writer {
+	return &Writer{w: w, interval: interval, remaining: interval}
+}
+
+// SetTitle changes the StreamTitle sent with the next metadata block
+func (w *Writer) SetTitle(title string) {
+	if title != w.title {
+		w.title = title
+		w.changed = true
+	}
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := min(len(p), w.remaining)
+		m, err := w.w.Write(p[:n])
+		written += m
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+		w.remaining -= n
+
+		if w.remaining == 0 {
+			if _, err := w.w.Write(w.metadata()); err != nil {
+				return written, err
+			}
+			w.remaining = w.interval
+		}
+	}
+	return written, nil
+}
+
+// metadata builds the next block: a length byte counting 16 byte units followed by the
+// padded text. Unchanged titles are only sent once, after that the block is empty
+func (w *Writer) metadata() []byte {
+	if !w.changed {
+		return []byte{0}
+	}
+	w.changed = false
+
+	text := "StreamTitle='" + strings.ReplaceAll(w.title, "';", "'") + "';"
+	if len(text) > maxMetadata {
+		text = text[:maxMetadata-2] + "';"
+	}
+
+	units := (len(text) + 15) / 16
+	block := make([]byte, 1+units*16)
+	block[0] = byte(units)
+	copy(block[1:], text)
+	return block
+}
@@ -0,0 +1,55 @@
+package scheduler
+
+import (
+	"log"
+	"runtime/debug"
+	"time"
+)
+
+type job struct {
+	name     string
+	interval time.Duration
+	run      func() error
+}
+
+// Scheduler runs background jobs at a fixed interval, each in its own goroutine
+type Scheduler struct {
+	jobs []job
+}
+
+func NewScheduler() *Scheduler {
+	return &Scheduler{}
+}
+
+func (s *Scheduler) Every(interval time.Duration, name string, run func() error) {
+	s.jobs = append(s.jobs, job{name: name, interval: interval, run: run})
+}
+
+// Start runs every job once right away and then on its interval
+func (s *Scheduler) Start() {
+	for _, j := range s.jobs {
+		go func() {
+			ticker := time.NewTicker(j.interval)
+			defer ticker.Stop()
+
+			for {
+				j.runOnce()
+				<-ticker.C
+			}
+		}()
+	}
+}
+
+// runOnce runs the job and logs how it failed, a panic included, so one bad run doesn't take
+// the server down or stop the job
+func (j job) runOnce() {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("scheduler: job %s panicked: %v\n%s", j.name, r, debug.Stack())
+		}
+	}()
+
+	if err := j.run(); err != nil {
+		log.Printf("scheduler: job %s failed: %v", j.name, err)
+	}
+}
@@ -3,6 +3,7 @@ package utils
 import (
 	"fmt"
 	"os"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
@@ -26,3 +27,37 @@ func GetEnv(key string, fallback string) string {
 	}
 	return value
 }
+
+// BaseUrl is the absolute URL of the server root the request came in on, e.g. https://host
+func BaseUrl(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host
+}
+
+// PublicBaseUrl is the configured base URL, or the one the request came in on when none is,
+// with the Cache-Control scope responses built with it can have. The request's Host header
+// can be forged, so those must not be kept by shared caches
+func PublicBaseUrl(c *gin.Context, configured string) (string, string) {
+	if configured != "" {
+		return configured, "public"
+	}
+	return BaseUrl(c), "private"
+}
+
+// GetPagination reads the page and limit query parameters, falling back to page 1 of 20 items
+func GetPagination(c *gin.Context) (page int, limit int) {
+	page, err := strconv.Atoi(c.Query("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	limit, err = strconv.Atoi(c.Query("limit"))
+	if err != nil || limit < 1 {
+		limit = 20
+	}
+
+	return page, min(limit, 100)
+}